@@ -10,11 +10,11 @@ import (
 	"maunium.net/go/mautrix/id"
 )
 
-func (bot *Bot) SendNotice(ctx context.Context, roomID id.RoomID, message string, args ...any) {
+func (bot *Bot) SendNotice(ctx context.Context, roomID id.RoomID, message string, args ...any) id.EventID {
 	if len(args) > 0 {
 		message = fmt.Sprintf(message, args...)
 	}
-	bot.SendNoticeOpts(ctx, roomID, message, nil)
+	return bot.SendNoticeOpts(ctx, roomID, message, nil)
 }
 
 type SendNoticeOpts struct {
@@ -24,7 +24,7 @@ type SendNoticeOpts struct {
 	SendAsText       bool
 }
 
-func (bot *Bot) SendNoticeOpts(ctx context.Context, roomID id.RoomID, message string, opts *SendNoticeOpts) {
+func (bot *Bot) SendNoticeOpts(ctx context.Context, roomID id.RoomID, message string, opts *SendNoticeOpts) id.EventID {
 	if opts == nil {
 		opts = &SendNoticeOpts{}
 	}
@@ -35,9 +35,27 @@ func (bot *Bot) SendNoticeOpts(ctx context.Context, roomID id.RoomID, message st
 	if opts.Mentions != nil {
 		content.Mentions = opts.Mentions
 	}
-	_, err := bot.Client.SendMessageEvent(ctx, roomID, event.EventMessage, &content)
+	resp, err := bot.Client.SendMessageEvent(ctx, roomID, event.EventMessage, &content)
 	if err != nil {
 		zerolog.Ctx(ctx).Err(err).
 			Msg("Failed to send management room message")
+		return ""
+	}
+	return resp.EventID
+}
+
+// EditNotice replaces the content of a notice previously sent by the bot.
+func (bot *Bot) EditNotice(ctx context.Context, roomID id.RoomID, eventID id.EventID, message string, args ...any) {
+	if len(args) > 0 {
+		message = fmt.Sprintf(message, args...)
+	}
+	content := format.RenderMarkdown(message, true, false)
+	content.MsgType = event.MsgNotice
+	content.SetEdit(eventID)
+	_, err := bot.Client.SendMessageEvent(ctx, roomID, event.EventMessage, &content)
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).
+			Stringer("edit_target", eventID).
+			Msg("Failed to edit management room message")
 	}
 }
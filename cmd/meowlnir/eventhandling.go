@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"time"
 
 	"github.com/rs/zerolog"
 	"maunium.net/go/mautrix"
@@ -9,6 +10,7 @@ import (
 	"maunium.net/go/mautrix/id"
 
 	"go.mau.fi/meowlnir/config"
+	"go.mau.fi/meowlnir/policylist"
 )
 
 func (m *Meowlnir) AddEventHandlers() {
@@ -38,6 +40,8 @@ func (m *Meowlnir) AddEventHandlers() {
 	m.EventProcessor.On(event.StateUnstablePolicyUser, m.UpdatePolicyList)
 	m.EventProcessor.On(event.StateUnstablePolicyRoom, m.UpdatePolicyList)
 	m.EventProcessor.On(event.StateUnstablePolicyServer, m.UpdatePolicyList)
+	m.EventProcessor.On(policylist.StatePolicyContent, m.UpdatePolicyList)
+	m.EventProcessor.On(policylist.StatePolicyMedia, m.UpdatePolicyList)
 	m.EventProcessor.On(event.EventRedaction, m.UpdatePolicyList)
 	// Management room config
 	m.EventProcessor.On(config.StateWatchedLists, m.HandleConfigChange)
@@ -49,7 +53,27 @@ func (m *Meowlnir) AddEventHandlers() {
 	m.EventProcessor.On(event.StateMember, m.HandleMember)
 	m.EventProcessor.On(event.EventMessage, m.HandleMessage)
 	m.EventProcessor.On(event.EventSticker, m.HandleMessage)
+	m.EventProcessor.On(event.EventReaction, m.HandleReaction)
 	m.EventProcessor.On(event.EventEncrypted, m.HandleEncrypted)
+	// Track when events were last received from the homeserver for the !status command
+	for _, evtType := range []event.Type{
+		event.StateMember, event.EventMessage, event.EventReaction, event.EventEncrypted,
+		event.StatePolicyUser, event.StatePolicyRoom, event.StatePolicyServer,
+	} {
+		m.EventProcessor.PrependHandler(evtType, m.trackLastEvent)
+	}
+}
+
+func (m *Meowlnir) trackLastEvent(_ context.Context, _ *event.Event) {
+	m.LastEventReceived.Store(time.Now().UnixMilli())
+}
+
+func (m *Meowlnir) getLastEventReceived() time.Time {
+	ts := m.LastEventReceived.Load()
+	if ts == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ts)
 }
 
 func (m *Meowlnir) HandleToDeviceEvent(ctx context.Context, evt *event.Event) {
@@ -149,7 +173,22 @@ func (m *Meowlnir) HandleEncrypted(ctx context.Context, evt *event.Event) {
 	//}
 }
 
+func (m *Meowlnir) HandleReaction(ctx context.Context, evt *event.Event) {
+	m.MapLock.RLock()
+	_, isBot := m.Bots[evt.Sender]
+	managementRoom, isManagement := m.EvaluatorByManagementRoom[evt.RoomID]
+	m.MapLock.RUnlock()
+	if !isBot && isManagement {
+		managementRoom.HandleReaction(ctx, evt)
+	}
+}
+
 func (m *Meowlnir) HandleMessage(ctx context.Context, evt *event.Event) {
+	if evt.Type == event.EventReaction {
+		// Decrypted reactions are passed here by the crypto helper
+		m.HandleReaction(ctx, evt)
+		return
+	}
 	content, ok := evt.Content.Parsed.(*event.MessageEventContent)
 	if !ok {
 		return
@@ -162,6 +201,14 @@ func (m *Meowlnir) HandleMessage(ctx context.Context, evt *event.Event) {
 	if isBot {
 		return
 	}
+	if evt.RoomID == m.Config.Meowlnir.AppealRoom && content.MsgType == event.MsgText {
+		m.MapLock.RLock()
+		appealHandler, ok := m.EvaluatorByManagementRoom[m.Config.Meowlnir.ReportRoom]
+		m.MapLock.RUnlock()
+		if ok {
+			appealHandler.HandleAppeal(ctx, evt)
+		}
+	}
 	if isManagement {
 		if content.MsgType == event.MsgText && managementRoom.Admins.Has(evt.Sender) {
 			managementRoom.HandleCommand(ctx, evt)
@@ -9,7 +9,9 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	_ "github.com/lib/pq"
 	"github.com/rs/zerolog"
@@ -28,6 +30,7 @@ import (
 	cryptoupgrade "maunium.net/go/mautrix/crypto/sql_store_upgrade"
 	"maunium.net/go/mautrix/id"
 	"maunium.net/go/mautrix/sqlstatestore"
+	"maunium.net/go/mautrix/synapseadmin"
 
 	"go.mau.fi/meowlnir/bot"
 	"go.mau.fi/meowlnir/config"
@@ -62,6 +65,8 @@ type Meowlnir struct {
 	EvaluatorByProtectedRoom  map[id.RoomID]*policyeval.PolicyEvaluator
 	EvaluatorByManagementRoom map[id.RoomID]*policyeval.PolicyEvaluator
 	HackyAutoRedactPatterns   []glob.Glob
+	AdminAPI                  *synapseadmin.Client
+	LastEventReceived         atomic.Int64
 }
 
 func (m *Meowlnir) loadSecret(secret string) [32]byte {
@@ -89,6 +94,7 @@ func (m *Meowlnir) Init(configPath string, noSaveConfig bool) {
 	policylist.HackyRuleFilterHashes = exslices.CastFunc(policylist.HackyRuleFilter, func(s string) [32]byte {
 		return util.SHA256String(s)
 	})
+	policylist.FoldUserIDCase = m.Config.Meowlnir.FoldUserIDCase
 
 	m.Log, err = m.Config.Logging.Compile()
 	if err != nil {
@@ -171,6 +177,17 @@ func (m *Meowlnir) Init(configPath string, noSaveConfig bool) {
 	}
 	m.HackyAutoRedactPatterns = compiledGlobs
 
+	if m.Config.Meowlnir.AdminAPIToken != "" {
+		var adminClient *mautrix.Client
+		adminClient, err = mautrix.NewClient(m.Config.Homeserver.Address, "", m.Config.Meowlnir.AdminAPIToken)
+		if err != nil {
+			m.Log.WithLevel(zerolog.FatalLevel).Err(err).Msg("Failed to create admin API client")
+			os.Exit(13)
+		}
+		adminClient.Log = m.Log.With().Str("component", "admin api").Logger()
+		m.AdminAPI = &synapseadmin.Client{Client: adminClient}
+	}
+
 	m.Log.Info().Msg("Initialization complete")
 }
 
@@ -226,7 +243,7 @@ func (m *Meowlnir) initBot(ctx context.Context, db *database.Bot) *bot.Bot {
 }
 
 func (m *Meowlnir) newPolicyEvaluator(bot *bot.Bot, roomID id.RoomID) *policyeval.PolicyEvaluator {
-	return policyeval.NewPolicyEvaluator(
+	eval := policyeval.NewPolicyEvaluator(
 		bot, m.PolicyStore,
 		roomID,
 		m.DB,
@@ -236,8 +253,33 @@ func (m *Meowlnir) newPolicyEvaluator(bot *bot.Bot, roomID id.RoomID) *policyeva
 		m.Config.Antispam.AutoRejectInvitesToken != "",
 		m.Config.Antispam.FilterLocalInvites,
 		m.Config.Meowlnir.DryRun,
+		m.Config.Meowlnir.TakedownRedactAllRooms,
 		m.HackyAutoRedactPatterns,
 	)
+	eval.ReportBanList = m.Config.Meowlnir.ReportBanList
+	eval.NeverBan = m.Config.Meowlnir.NeverBan
+	eval.LeaveBannedRooms = m.Config.Meowlnir.LeaveBannedRooms
+	eval.AllowCustomRecommendations = m.Config.Meowlnir.AllowCustomRecommendations
+	eval.UndoAnyAdmin = m.Config.Meowlnir.UndoAnyAdmin
+	eval.FlapDetection = m.Config.Meowlnir.FlapDetection
+	eval.AdminAPI = m.AdminAPI
+	eval.RedactEdits = m.Config.Meowlnir.RedactEdits
+	eval.UpstreamReporting = &m.Config.UpstreamReporting
+	eval.Webhook = &m.Config.Webhook
+	eval.ConfirmationTimeout = time.Duration(m.Config.Meowlnir.ConfirmationTimeoutSeconds) * time.Second
+	eval.WildcardBanConfirmThreshold = m.Config.Meowlnir.WildcardBanConfirmThreshold
+	eval.ExemptPowerLevel = m.Config.Meowlnir.ExemptPowerLevel
+	eval.CommandPrefix = m.Config.Meowlnir.CommandPrefix
+	eval.CommandAliases = m.Config.Meowlnir.CommandAliases
+	eval.BanRedactWindow = time.Duration(m.Config.Meowlnir.BanRedactWindowMinutes) * time.Minute
+	eval.NoticeCoalesceWindow = time.Duration(m.Config.Meowlnir.NoticeCoalesceWindowMS) * time.Millisecond
+	eval.ReevaluationDebounce = time.Duration(m.Config.Meowlnir.ReevaluationDebounceMS) * time.Millisecond
+	eval.Deactivation = m.Config.Meowlnir.Deactivation
+	eval.ServerACL = m.Config.Meowlnir.ServerACL
+	eval.BulkKick = m.Config.Meowlnir.BulkKick
+	eval.AuditRoom = m.Config.Meowlnir.AuditRoom
+	eval.GetLastEventReceived = m.getLastEventReceived
+	return eval
 }
 
 func (m *Meowlnir) loadManagementRoom(ctx context.Context, roomID id.RoomID, bot *bot.Bot) bool {
@@ -298,6 +340,9 @@ func (m *Meowlnir) Run(ctx context.Context) {
 
 	m.EventProcessor.Start(ctx)
 	go m.AS.Start()
+	if m.Config.Metrics.Enabled {
+		go m.startMetricsListener()
+	}
 
 	var wg sync.WaitGroup
 	m.MapLock.Lock()
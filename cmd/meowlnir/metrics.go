@@ -0,0 +1,18 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func (m *Meowlnir) startMetricsListener() {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+	m.Log.Info().Str("listen", m.Config.Metrics.Listen).Msg("Starting metrics listener")
+	err := http.ListenAndServe(m.Config.Metrics.Listen, mux)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		m.Log.Err(err).Msg("Metrics listener failed")
+	}
+}
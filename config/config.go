@@ -27,10 +27,59 @@ type MeowlnirConfig struct {
 
 	ManagementSecret string `yaml:"management_secret"`
 	DryRun           bool   `yaml:"dry_run"`
+	AdminAPIToken    string `yaml:"admin_api_token"`
 
 	ReportRoom          id.RoomID `yaml:"report_room"`
+	AppealRoom          id.RoomID `yaml:"appeal_room"`
+	AuditRoom           id.RoomID `yaml:"audit_room"`
+	ReportBanList       string    `yaml:"report_ban_list"`
 	HackyRuleFilter     []string  `yaml:"hacky_rule_filter"`
 	HackyRedactPatterns []string  `yaml:"hacky_redact_patterns"`
+	NeverBan            []string  `yaml:"never_ban"`
+
+	TakedownRedactAllRooms bool `yaml:"takedown_redact_all_rooms"`
+	LeaveBannedRooms       bool `yaml:"leave_banned_rooms"`
+	FoldUserIDCase         bool `yaml:"fold_user_id_case"`
+	RedactEdits            bool `yaml:"redact_edits"`
+
+	AllowCustomRecommendations  bool `yaml:"allow_custom_recommendations"`
+	UndoAnyAdmin                bool `yaml:"undo_any_admin"`
+	ConfirmationTimeoutSeconds  int  `yaml:"confirmation_timeout_seconds"`
+	WildcardBanConfirmThreshold int  `yaml:"wildcard_ban_confirm_threshold"`
+	ExemptPowerLevel            int  `yaml:"exempt_power_level"`
+	BanRedactWindowMinutes      int  `yaml:"ban_redact_window_minutes"`
+	NoticeCoalesceWindowMS      int  `yaml:"notice_coalesce_window_ms"`
+	ReevaluationDebounceMS      int  `yaml:"reevaluation_debounce_ms"`
+
+	CommandPrefix  string            `yaml:"command_prefix"`
+	CommandAliases map[string]string `yaml:"command_aliases"`
+
+	FlapDetection FlapDetectionConfig `yaml:"flap_detection"`
+	Deactivation  DeactivationConfig  `yaml:"deactivation"`
+	ServerACL     ServerACLConfig     `yaml:"server_acl"`
+	BulkKick      BulkKickConfig      `yaml:"bulk_kick"`
+}
+
+type BulkKickConfig struct {
+	Concurrency int `yaml:"concurrency"`
+	DelayMS     int `yaml:"delay_ms"`
+}
+
+type ServerACLConfig struct {
+	Enabled               bool `yaml:"enabled"`
+	Prune                 bool `yaml:"prune"`
+	ResyncIntervalMinutes int  `yaml:"resync_interval_minutes"`
+}
+
+type DeactivationConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	BanList string `yaml:"ban_list"`
+}
+
+type FlapDetectionConfig struct {
+	Threshold        int  `yaml:"threshold"`
+	WindowMinutes    int  `yaml:"window_minutes"`
+	PauseEnforcement bool `yaml:"pause_enforcement"`
 }
 
 type AntispamConfig struct {
@@ -39,17 +88,39 @@ type AntispamConfig struct {
 	AutoRejectInvitesToken string `yaml:"auto_reject_invites_token"`
 }
 
+type UpstreamReportingConfig struct {
+	URL        string   `yaml:"url"`
+	Token      string   `yaml:"token"`
+	Categories []string `yaml:"categories"`
+	MaxRetries int      `yaml:"max_retries"`
+}
+
+type WebhookConfig struct {
+	URL        string `yaml:"url"`
+	Secret     string `yaml:"secret"`
+	MaxRetries int    `yaml:"max_retries"`
+}
+
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Listen  string `yaml:"listen"`
+}
+
 type EncryptionConfig struct {
 	Enable    bool   `yaml:"enable"`
 	PickleKey string `yaml:"pickle_key"`
 }
 
 type Config struct {
-	Homeserver HomeserverConfig  `yaml:"homeserver"`
-	Meowlnir   MeowlnirConfig    `yaml:"meowlnir"`
-	Antispam   AntispamConfig    `yaml:"antispam"`
-	Encryption EncryptionConfig  `yaml:"encryption"`
-	Database   dbutil.Config     `yaml:"database"`
-	SynapseDB  dbutil.Config     `yaml:"synapse_db"`
-	Logging    zeroconfig.Config `yaml:"logging"`
+	Homeserver HomeserverConfig `yaml:"homeserver"`
+	Meowlnir   MeowlnirConfig   `yaml:"meowlnir"`
+	Antispam   AntispamConfig   `yaml:"antispam"`
+
+	UpstreamReporting UpstreamReportingConfig `yaml:"upstream_reporting"`
+	Webhook           WebhookConfig           `yaml:"webhook"`
+	Metrics           MetricsConfig           `yaml:"metrics"`
+	Encryption        EncryptionConfig        `yaml:"encryption"`
+	Database          dbutil.Config           `yaml:"database"`
+	SynapseDB         dbutil.Config           `yaml:"synapse_db"`
+	Logging           zeroconfig.Config       `yaml:"logging"`
 }
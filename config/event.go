@@ -20,8 +20,12 @@ type WatchedPolicyList struct {
 	DontApplyACL bool      `json:"dont_apply_acl"`
 	AutoUnban    bool      `json:"auto_unban"`
 	AutoSuspend  bool      `json:"auto_suspend"`
+	AllowPublic  bool      `json:"allow_public"`
 
 	DontNotifyOnChange bool `json:"dont_notify_on_change"`
+
+	// ReasonTemplates maps lowercase template names to full reasons, which can be used as `:name` in ban commands.
+	ReasonTemplates map[string]string `json:"reason_templates,omitempty"`
 }
 
 type WatchedListsEventContent struct {
@@ -33,6 +37,9 @@ type ProtectedRoomsEventContent struct {
 
 	// TODO make this less hacky
 	SkipACL []id.RoomID `json:"skip_acl"`
+	// ObserveOnly contains protected rooms where policies are only reported to the management room
+	// instead of being enforced automatically.
+	ObserveOnly []id.RoomID `json:"observe_only,omitempty"`
 }
 
 func init() {
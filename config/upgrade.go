@@ -32,9 +32,38 @@ func upgradeConfig(helper up.Helper) {
 
 	generateOrCopy(helper, "meowlnir", "management_secret")
 	helper.Copy(up.Bool, "meowlnir", "dry_run")
+	helper.Copy(up.Str|up.Null, "meowlnir", "admin_api_token")
 	helper.Copy(up.Str|up.Null, "meowlnir", "report_room")
+	helper.Copy(up.Str|up.Null, "meowlnir", "appeal_room")
+	helper.Copy(up.Str|up.Null, "meowlnir", "audit_room")
+	helper.Copy(up.Str|up.Null, "meowlnir", "report_ban_list")
 	helper.Copy(up.List, "meowlnir", "hacky_rule_filter")
 	helper.Copy(up.List, "meowlnir", "hacky_redact_patterns")
+	helper.Copy(up.List, "meowlnir", "never_ban")
+	helper.Copy(up.Bool, "meowlnir", "takedown_redact_all_rooms")
+	helper.Copy(up.Bool, "meowlnir", "leave_banned_rooms")
+	helper.Copy(up.Bool, "meowlnir", "fold_user_id_case")
+	helper.Copy(up.Bool, "meowlnir", "redact_edits")
+	helper.Copy(up.Bool, "meowlnir", "allow_custom_recommendations")
+	helper.Copy(up.Bool, "meowlnir", "undo_any_admin")
+	helper.Copy(up.Int, "meowlnir", "confirmation_timeout_seconds")
+	helper.Copy(up.Int, "meowlnir", "wildcard_ban_confirm_threshold")
+	helper.Copy(up.Int, "meowlnir", "exempt_power_level")
+	helper.Copy(up.Int, "meowlnir", "ban_redact_window_minutes")
+	helper.Copy(up.Int, "meowlnir", "notice_coalesce_window_ms")
+	helper.Copy(up.Int, "meowlnir", "reevaluation_debounce_ms")
+	helper.Copy(up.Str, "meowlnir", "command_prefix")
+	helper.Copy(up.Map, "meowlnir", "command_aliases")
+	helper.Copy(up.Int, "meowlnir", "flap_detection", "threshold")
+	helper.Copy(up.Int, "meowlnir", "flap_detection", "window_minutes")
+	helper.Copy(up.Bool, "meowlnir", "flap_detection", "pause_enforcement")
+	helper.Copy(up.Bool, "meowlnir", "deactivation", "enabled")
+	helper.Copy(up.Str|up.Null, "meowlnir", "deactivation", "ban_list")
+	helper.Copy(up.Bool, "meowlnir", "server_acl", "enabled")
+	helper.Copy(up.Bool, "meowlnir", "server_acl", "prune")
+	helper.Copy(up.Int, "meowlnir", "server_acl", "resync_interval_minutes")
+	helper.Copy(up.Int, "meowlnir", "bulk_kick", "concurrency")
+	helper.Copy(up.Int, "meowlnir", "bulk_kick", "delay_ms")
 
 	if secret, ok := helper.Get(up.Str, "meowlnir", "antispam_secret"); ok && secret != "generate" {
 		helper.Set(up.Str, secret, "antispam", "secret")
@@ -44,6 +73,18 @@ func upgradeConfig(helper up.Helper) {
 	helper.Copy(up.Str|up.Null, "antispam", "auto_reject_invites_token")
 	helper.Copy(up.Bool, "antispam", "filter_local_invites")
 
+	helper.Copy(up.Str|up.Null, "upstream_reporting", "url")
+	helper.Copy(up.Str|up.Null, "upstream_reporting", "token")
+	helper.Copy(up.List, "upstream_reporting", "categories")
+	helper.Copy(up.Int, "upstream_reporting", "max_retries")
+
+	helper.Copy(up.Str|up.Null, "webhook", "url")
+	helper.Copy(up.Str|up.Null, "webhook", "secret")
+	helper.Copy(up.Int, "webhook", "max_retries")
+
+	helper.Copy(up.Bool, "metrics", "enabled")
+	helper.Copy(up.Str, "metrics", "listen")
+
 	if secret, ok := helper.Get(up.Str, "meowlnir", "pickle_key"); ok && secret != "generate" {
 		helper.Set(up.Str, secret, "encryption", "pickle_key")
 	} else {
@@ -74,6 +115,9 @@ var SpacedBlocks = [][]string{
 	{"meowlnir", "management_secret"},
 	{"meowlnir", "report_room"},
 	{"antispam"},
+	{"upstream_reporting"},
+	{"webhook"},
+	{"metrics"},
 	{"encryption"},
 	{"database"},
 	{"synapse_db"},
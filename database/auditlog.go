@@ -0,0 +1,100 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"go.mau.fi/util/dbutil"
+	"maunium.net/go/mautrix/id"
+)
+
+const (
+	getAuditLogBaseQuery = `
+		SELECT id, management_room, action, target_user, target_event, in_room_id, actor, reason, created_at, policy_list, entity, recommendation
+		FROM audit_log
+	`
+	getAuditLogByRoomQuery = getAuditLogBaseQuery + `WHERE management_room=$1 AND in_room_id=$2 ORDER BY id DESC LIMIT $3`
+	getAllAuditLogQuery    = getAuditLogBaseQuery + `WHERE management_room=$1 ORDER BY id ASC`
+	getRecentAuditLogQuery = getAuditLogBaseQuery + `WHERE management_room=$1 AND action NOT IN ('send_policy', 'remove_policy') ORDER BY id DESC LIMIT $2`
+	getLatestAuditLogQuery = getAuditLogBaseQuery + `WHERE management_room=$1 ORDER BY id DESC LIMIT $2`
+	insertAuditLogQuery    = `
+		INSERT INTO audit_log (management_room, action, target_user, target_event, in_room_id, actor, reason, created_at, policy_list, entity, recommendation)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+)
+
+type AuditLogQuery struct {
+	*dbutil.QueryHelper[*AuditLogEntry]
+}
+
+func (alq *AuditLogQuery) Put(ctx context.Context, entry *AuditLogEntry) error {
+	return alq.Exec(ctx, insertAuditLogQuery, entry.sqlVariables()...)
+}
+
+func (alq *AuditLogQuery) GetByRoom(ctx context.Context, managementRoom, roomID id.RoomID, limit int) ([]*AuditLogEntry, error) {
+	return alq.QueryMany(ctx, getAuditLogByRoomQuery, managementRoom, roomID, limit)
+}
+
+// GetRecent returns the most recent moderation actions (i.e. excluding policy changes), newest first.
+func (alq *AuditLogQuery) GetRecent(ctx context.Context, managementRoom id.RoomID, limit int) ([]*AuditLogEntry, error) {
+	return alq.QueryMany(ctx, getRecentAuditLogQuery, managementRoom, limit)
+}
+
+// GetLatest returns the most recent audit log entries including policy changes, newest first.
+func (alq *AuditLogQuery) GetLatest(ctx context.Context, managementRoom id.RoomID, limit int) ([]*AuditLogEntry, error) {
+	return alq.QueryMany(ctx, getLatestAuditLogQuery, managementRoom, limit)
+}
+
+func (alq *AuditLogQuery) GetAll(ctx context.Context, managementRoom id.RoomID) ([]*AuditLogEntry, error) {
+	return alq.QueryMany(ctx, getAllAuditLogQuery, managementRoom)
+}
+
+type AuditLogAction string
+
+const (
+	AuditLogActionBan        AuditLogAction = "ban"
+	AuditLogActionUnban      AuditLogAction = "unban"
+	AuditLogActionKick       AuditLogAction = "kick"
+	AuditLogActionRedact     AuditLogAction = "redact"
+	AuditLogActionMute       AuditLogAction = "mute"
+	AuditLogActionUnmute     AuditLogAction = "unmute"
+	AuditLogActionDeactivate AuditLogAction = "deactivate"
+	AuditLogActionTakedown   AuditLogAction = "takedown"
+
+	AuditLogActionSendPolicy   AuditLogAction = "send_policy"
+	AuditLogActionRemovePolicy AuditLogAction = "remove_policy"
+)
+
+type AuditLogEntry struct {
+	ID             int64          `json:"id"`
+	ManagementRoom id.RoomID      `json:"management_room"`
+	Action         AuditLogAction `json:"action"`
+	TargetUser     id.UserID      `json:"target_user,omitempty"`
+	TargetEvent    id.EventID     `json:"target_event,omitempty"`
+	InRoomID       id.RoomID      `json:"in_room_id,omitempty"`
+	Actor          id.UserID      `json:"actor,omitempty"`
+	Reason         string         `json:"reason,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	PolicyList     id.RoomID      `json:"policy_list,omitempty"`
+	Entity         string         `json:"entity,omitempty"`
+	Recommendation string         `json:"recommendation,omitempty"`
+	// DryRun is only set for entries that are sent to the audit room or webhook, entries are never stored in dry run mode.
+	DryRun bool `json:"dry_run,omitempty"`
+	// Undone is only tracked in memory. It's set for entries that were reversed with `!undo`
+	// and entries created by `!undo` itself, so that they're skipped by later undos.
+	Undone bool `json:"-"`
+}
+
+func (e *AuditLogEntry) sqlVariables() []any {
+	return []any{e.ManagementRoom, e.Action, e.TargetUser, e.TargetEvent, e.InRoomID, e.Actor, e.Reason, e.CreatedAt.UnixMilli(), e.PolicyList, e.Entity, e.Recommendation}
+}
+
+func (e *AuditLogEntry) Scan(row dbutil.Scannable) (*AuditLogEntry, error) {
+	var createdAt int64
+	err := row.Scan(&e.ID, &e.ManagementRoom, &e.Action, &e.TargetUser, &e.TargetEvent, &e.InRoomID, &e.Actor, &e.Reason, &createdAt, &e.PolicyList, &e.Entity, &e.Recommendation)
+	if err != nil {
+		return nil, err
+	}
+	e.CreatedAt = time.UnixMilli(createdAt)
+	return e, nil
+}
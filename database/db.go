@@ -11,6 +11,11 @@ type Database struct {
 	TakenAction    *TakenActionQuery
 	Bot            *BotQuery
 	ManagementRoom *ManagementRoomQuery
+	AuditLog       *AuditLogQuery
+	EntityNote     *EntityNoteQuery
+	Report         *ReportQuery
+	UserHash       *UserHashQuery
+	ActionRetry    *ActionRetryQuery
 }
 
 func New(db *dbutil.Database) *Database {
@@ -30,5 +35,28 @@ func New(db *dbutil.Database) *Database {
 		ManagementRoom: &ManagementRoomQuery{
 			Database: db,
 		},
+		AuditLog: &AuditLogQuery{
+			QueryHelper: dbutil.MakeQueryHelper(db, func(qh *dbutil.QueryHelper[*AuditLogEntry]) *AuditLogEntry {
+				return &AuditLogEntry{}
+			}),
+		},
+		EntityNote: &EntityNoteQuery{
+			QueryHelper: dbutil.MakeQueryHelper(db, func(qh *dbutil.QueryHelper[*EntityNote]) *EntityNote {
+				return &EntityNote{}
+			}),
+		},
+		Report: &ReportQuery{
+			QueryHelper: dbutil.MakeQueryHelper(db, func(qh *dbutil.QueryHelper[*Report]) *Report {
+				return &Report{}
+			}),
+		},
+		UserHash: &UserHashQuery{
+			Database: db,
+		},
+		ActionRetry: &ActionRetryQuery{
+			QueryHelper: dbutil.MakeQueryHelper(db, func(qh *dbutil.QueryHelper[*ActionRetry]) *ActionRetry {
+				return &ActionRetry{}
+			}),
+		},
 	}
 }
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"go.mau.fi/util/dbutil"
+	"maunium.net/go/mautrix/id"
+)
+
+const (
+	getEntityNotesQuery = `
+		SELECT management_room, entity, note, author, created_at
+		FROM entity_note
+		WHERE management_room=$1 AND entity=$2
+		ORDER BY created_at ASC
+	`
+	insertEntityNoteQuery = `
+		INSERT INTO entity_note (management_room, entity, note, author, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	deleteEntityNotesQuery = `
+		DELETE FROM entity_note WHERE management_room=$1 AND entity=$2
+	`
+)
+
+type EntityNoteQuery struct {
+	*dbutil.QueryHelper[*EntityNote]
+}
+
+func (enq *EntityNoteQuery) Put(ctx context.Context, note *EntityNote) error {
+	return enq.Exec(ctx, insertEntityNoteQuery, note.sqlVariables()...)
+}
+
+func (enq *EntityNoteQuery) GetAllByEntity(ctx context.Context, managementRoom id.RoomID, entity string) ([]*EntityNote, error) {
+	return enq.QueryMany(ctx, getEntityNotesQuery, managementRoom, entity)
+}
+
+func (enq *EntityNoteQuery) DeleteAllByEntity(ctx context.Context, managementRoom id.RoomID, entity string) error {
+	return enq.Exec(ctx, deleteEntityNotesQuery, managementRoom, entity)
+}
+
+// EntityNote is an internal moderator note about a policy entity.
+// Notes are only stored in Meowlnir's database and are never sent to policy lists.
+type EntityNote struct {
+	ManagementRoom id.RoomID
+	Entity         string
+	Note           string
+	Author         id.UserID
+	CreatedAt      time.Time
+}
+
+func (n *EntityNote) sqlVariables() []any {
+	return []any{n.ManagementRoom, n.Entity, n.Note, n.Author, n.CreatedAt.UnixMilli()}
+}
+
+func (n *EntityNote) Scan(row dbutil.Scannable) (*EntityNote, error) {
+	var createdAt int64
+	err := row.Scan(&n.ManagementRoom, &n.Entity, &n.Note, &n.Author, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	n.CreatedAt = time.UnixMilli(createdAt)
+	return n, nil
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"go.mau.fi/util/dbutil"
+	"maunium.net/go/mautrix/id"
+)
+
+const (
+	getReportBaseQuery = `
+		SELECT id, management_room, reporter, target_user, room_id, event_id, reason, created_at, handled_by, handled_at
+		FROM report
+	`
+	getReportByIDQuery          = getReportBaseQuery + `WHERE management_room=$1 AND id=$2`
+	getNextUnhandledReportQuery = getReportBaseQuery + `WHERE management_room=$1 AND handled_at=0 AND id>$2 ORDER BY id ASC LIMIT 1`
+	getReportsByTargetQuery     = getReportBaseQuery + `WHERE management_room=$1 AND target_user=$2 ORDER BY id ASC`
+	getRecentUnhandledQuery     = getReportBaseQuery + `WHERE management_room=$1 AND handled_at=0 ORDER BY id DESC LIMIT $2`
+	countUnhandledReportsQuery  = `SELECT COUNT(*) FROM report WHERE management_room=$1 AND handled_at=0`
+	insertReportQuery           = `
+		INSERT INTO report (management_room, reporter, target_user, room_id, event_id, reason, created_at, handled_by, handled_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`
+	markReportHandledQuery = `
+		UPDATE report SET handled_by=$3, handled_at=$4 WHERE management_room=$1 AND id=$2 AND handled_at=0
+	`
+)
+
+type ReportQuery struct {
+	*dbutil.QueryHelper[*Report]
+}
+
+func (rq *ReportQuery) Put(ctx context.Context, report *Report) error {
+	return rq.GetDB().QueryRow(ctx, insertReportQuery, report.sqlVariables()...).Scan(&report.ID)
+}
+
+func (rq *ReportQuery) GetByID(ctx context.Context, managementRoom id.RoomID, reportID int64) (*Report, error) {
+	return rq.QueryOne(ctx, getReportByIDQuery, managementRoom, reportID)
+}
+
+// GetNextUnhandled returns the oldest unhandled report with an ID higher than the given one.
+func (rq *ReportQuery) GetNextUnhandled(ctx context.Context, managementRoom id.RoomID, afterID int64) (*Report, error) {
+	return rq.QueryOne(ctx, getNextUnhandledReportQuery, managementRoom, afterID)
+}
+
+// GetRecentUnhandled returns the newest unhandled reports, newest first.
+func (rq *ReportQuery) GetRecentUnhandled(ctx context.Context, managementRoom id.RoomID, limit int) ([]*Report, error) {
+	return rq.QueryMany(ctx, getRecentUnhandledQuery, managementRoom, limit)
+}
+
+func (rq *ReportQuery) GetAllByTargetUser(ctx context.Context, managementRoom id.RoomID, targetUser id.UserID) ([]*Report, error) {
+	return rq.QueryMany(ctx, getReportsByTargetQuery, managementRoom, targetUser)
+}
+
+func (rq *ReportQuery) CountUnhandled(ctx context.Context, managementRoom id.RoomID) (count int, err error) {
+	err = rq.GetDB().QueryRow(ctx, countUnhandledReportsQuery, managementRoom).Scan(&count)
+	return
+}
+
+// MarkHandled marks the given report as handled. Reports that are already handled are not modified.
+func (rq *ReportQuery) MarkHandled(ctx context.Context, managementRoom id.RoomID, reportID int64, handledBy id.UserID) error {
+	return rq.Exec(ctx, markReportHandledQuery, managementRoom, reportID, handledBy, time.Now().UnixMilli())
+}
+
+// Report is a user report received through the client-server API.
+type Report struct {
+	ID             int64
+	ManagementRoom id.RoomID
+	Reporter       id.UserID
+	TargetUser     id.UserID
+	RoomID         id.RoomID
+	EventID        id.EventID
+	Reason         string
+	CreatedAt      time.Time
+	HandledBy      id.UserID
+	HandledAt      time.Time
+}
+
+func (r *Report) IsHandled() bool {
+	return !r.HandledAt.IsZero()
+}
+
+func (r *Report) sqlVariables() []any {
+	var handledAt int64
+	if !r.HandledAt.IsZero() {
+		handledAt = r.HandledAt.UnixMilli()
+	}
+	return []any{
+		r.ManagementRoom, r.Reporter, r.TargetUser, r.RoomID, r.EventID, r.Reason,
+		r.CreatedAt.UnixMilli(), r.HandledBy, handledAt,
+	}
+}
+
+func (r *Report) Scan(row dbutil.Scannable) (*Report, error) {
+	var createdAt, handledAt int64
+	err := row.Scan(
+		&r.ID, &r.ManagementRoom, &r.Reporter, &r.TargetUser, &r.RoomID, &r.EventID, &r.Reason,
+		&createdAt, &r.HandledBy, &handledAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	r.CreatedAt = time.UnixMilli(createdAt)
+	if handledAt != 0 {
+		r.HandledAt = time.UnixMilli(handledAt)
+	}
+	return r, nil
+}
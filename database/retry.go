@@ -0,0 +1,117 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"go.mau.fi/util/dbutil"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+)
+
+const (
+	getActionRetryBaseQuery = `
+		SELECT id, management_room, action, target_user, room_id, event_id, reason,
+		       policy_list, entity, recommendation, attempts, last_error, next_attempt_at, created_at
+		FROM action_retry
+	`
+	getDueActionRetriesQuery = getActionRetryBaseQuery + `WHERE management_room=$1 AND next_attempt_at<=$2 ORDER BY id ASC`
+	getAllActionRetriesQuery = getActionRetryBaseQuery + `WHERE management_room=$1 ORDER BY id ASC`
+	insertActionRetryQuery   = `
+		INSERT INTO action_retry (
+			management_room, action, target_user, room_id, event_id, reason,
+			policy_list, entity, recommendation, attempts, last_error, next_attempt_at, created_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id
+	`
+	updateActionRetryQuery = `
+		UPDATE action_retry SET attempts=$3, last_error=$4, next_attempt_at=$5 WHERE management_room=$1 AND id=$2
+	`
+	rescheduleAllActionRetriesQuery = `UPDATE action_retry SET next_attempt_at=$2 WHERE management_room=$1`
+	deleteActionRetryQuery          = `DELETE FROM action_retry WHERE management_room=$1 AND id=$2`
+	deleteAllActionRetriesQuery     = `DELETE FROM action_retry WHERE management_room=$1`
+)
+
+type ActionRetryQuery struct {
+	*dbutil.QueryHelper[*ActionRetry]
+}
+
+func (arq *ActionRetryQuery) Put(ctx context.Context, retry *ActionRetry) error {
+	return arq.GetDB().QueryRow(ctx, insertActionRetryQuery, retry.sqlVariables()...).Scan(&retry.ID)
+}
+
+// GetDue returns all queued actions whose next attempt is at or before the given time.
+func (arq *ActionRetryQuery) GetDue(ctx context.Context, managementRoom id.RoomID, now time.Time) ([]*ActionRetry, error) {
+	return arq.QueryMany(ctx, getDueActionRetriesQuery, managementRoom, now.UnixMilli())
+}
+
+func (arq *ActionRetryQuery) GetAll(ctx context.Context, managementRoom id.RoomID) ([]*ActionRetry, error) {
+	return arq.QueryMany(ctx, getAllActionRetriesQuery, managementRoom)
+}
+
+// Update stores the attempt count, last error and next attempt time of the given retry.
+func (arq *ActionRetryQuery) Update(ctx context.Context, retry *ActionRetry) error {
+	return arq.Exec(ctx, updateActionRetryQuery, retry.ManagementRoom, retry.ID, retry.Attempts, retry.LastError, retry.NextAttemptAt.UnixMilli())
+}
+
+// RescheduleAll sets the next attempt time of all queued actions in the given management room.
+func (arq *ActionRetryQuery) RescheduleAll(ctx context.Context, managementRoom id.RoomID, nextAttempt time.Time) error {
+	return arq.Exec(ctx, rescheduleAllActionRetriesQuery, managementRoom, nextAttempt.UnixMilli())
+}
+
+func (arq *ActionRetryQuery) Delete(ctx context.Context, managementRoom id.RoomID, retryID int64) error {
+	return arq.Exec(ctx, deleteActionRetryQuery, managementRoom, retryID)
+}
+
+func (arq *ActionRetryQuery) DeleteAll(ctx context.Context, managementRoom id.RoomID) error {
+	return arq.Exec(ctx, deleteAllActionRetriesQuery, managementRoom)
+}
+
+type ActionRetryType string
+
+const (
+	ActionRetryTypeBan    ActionRetryType = "ban"
+	ActionRetryTypeKick   ActionRetryType = "kick"
+	ActionRetryTypeRedact ActionRetryType = "redact"
+)
+
+// ActionRetry is a moderation action that failed due to a transient error and is queued to be retried.
+type ActionRetry struct {
+	ID             int64
+	ManagementRoom id.RoomID
+	Action         ActionRetryType
+	TargetUser     id.UserID
+	RoomID         id.RoomID
+	EventID        id.EventID
+	Reason         string
+	PolicyList     id.RoomID
+	Entity         string
+	Recommendation event.PolicyRecommendation
+	Attempts       int
+	LastError      string
+	NextAttemptAt  time.Time
+	CreatedAt      time.Time
+}
+
+func (ar *ActionRetry) sqlVariables() []any {
+	return []any{
+		ar.ManagementRoom, ar.Action, ar.TargetUser, ar.RoomID, ar.EventID, ar.Reason,
+		ar.PolicyList, ar.Entity, ar.Recommendation, ar.Attempts, ar.LastError,
+		ar.NextAttemptAt.UnixMilli(), ar.CreatedAt.UnixMilli(),
+	}
+}
+
+func (ar *ActionRetry) Scan(row dbutil.Scannable) (*ActionRetry, error) {
+	var nextAttemptAt, createdAt int64
+	err := row.Scan(
+		&ar.ID, &ar.ManagementRoom, &ar.Action, &ar.TargetUser, &ar.RoomID, &ar.EventID, &ar.Reason,
+		&ar.PolicyList, &ar.Entity, &ar.Recommendation, &ar.Attempts, &ar.LastError, &nextAttemptAt, &createdAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	ar.NextAttemptAt = time.UnixMilli(nextAttemptAt)
+	ar.CreatedAt = time.UnixMilli(createdAt)
+	return ar, nil
+}
@@ -1,4 +1,4 @@
--- v0 -> v1 (compatible with v1+): Latest schema
+-- v0 -> v7 (compatible with v1+): Latest schema
 CREATE TABLE bot (
     username     TEXT PRIMARY KEY NOT NULL,
     displayname  TEXT NOT NULL,
@@ -27,3 +27,81 @@ CREATE TABLE taken_action (
 
 CREATE INDEX taken_action_list_idx ON taken_action (policy_list);
 CREATE INDEX taken_action_entity_idx ON taken_action (policy_list, rule_entity);
+
+CREATE TABLE audit_log (
+    -- only: postgres
+    id              BIGINT PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+    -- only: sqlite (line commented)
+--  id              INTEGER PRIMARY KEY,
+    management_room TEXT   NOT NULL,
+    action          TEXT   NOT NULL,
+    target_user     TEXT   NOT NULL,
+    target_event    TEXT   NOT NULL,
+    in_room_id      TEXT   NOT NULL,
+    actor           TEXT   NOT NULL,
+    reason          TEXT   NOT NULL,
+    created_at      BIGINT NOT NULL,
+    policy_list     TEXT   NOT NULL DEFAULT '',
+    entity          TEXT   NOT NULL DEFAULT '',
+    recommendation  TEXT   NOT NULL DEFAULT ''
+);
+
+CREATE INDEX audit_log_room_idx ON audit_log (management_room, in_room_id);
+
+CREATE TABLE entity_note (
+    management_room TEXT   NOT NULL,
+    entity          TEXT   NOT NULL,
+    note            TEXT   NOT NULL,
+    author          TEXT   NOT NULL,
+    created_at      BIGINT NOT NULL
+);
+
+CREATE INDEX entity_note_entity_idx ON entity_note (management_room, entity);
+
+CREATE TABLE report (
+    -- only: postgres
+    id              BIGINT PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+    -- only: sqlite (line commented)
+--  id              INTEGER PRIMARY KEY,
+    management_room TEXT   NOT NULL,
+    reporter        TEXT   NOT NULL,
+    target_user     TEXT   NOT NULL,
+    room_id         TEXT   NOT NULL,
+    event_id        TEXT   NOT NULL,
+    reason          TEXT   NOT NULL,
+    created_at      BIGINT NOT NULL,
+    handled_by      TEXT   NOT NULL,
+    handled_at      BIGINT NOT NULL
+);
+
+CREATE INDEX report_management_room_idx ON report (management_room, handled_at);
+
+CREATE TABLE user_hash (
+    hash      TEXT   PRIMARY KEY NOT NULL,
+    user_id   TEXT   NOT NULL,
+    last_seen BIGINT NOT NULL
+);
+
+CREATE INDEX user_hash_last_seen_idx ON user_hash (last_seen);
+
+CREATE TABLE action_retry (
+    -- only: postgres
+    id              BIGINT PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+    -- only: sqlite (line commented)
+--  id              INTEGER PRIMARY KEY,
+    management_room TEXT   NOT NULL,
+    action          TEXT   NOT NULL,
+    target_user     TEXT   NOT NULL,
+    room_id         TEXT   NOT NULL,
+    event_id        TEXT   NOT NULL,
+    reason          TEXT   NOT NULL,
+    policy_list     TEXT   NOT NULL,
+    entity          TEXT   NOT NULL,
+    recommendation  TEXT   NOT NULL,
+    attempts        BIGINT NOT NULL,
+    last_error      TEXT   NOT NULL,
+    next_attempt_at BIGINT NOT NULL,
+    created_at      BIGINT NOT NULL
+);
+
+CREATE INDEX action_retry_management_room_idx ON action_retry (management_room, next_attempt_at);
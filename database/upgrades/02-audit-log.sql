@@ -0,0 +1,17 @@
+-- v1 -> v2: Add audit log of moderation actions
+CREATE TABLE audit_log (
+    -- only: postgres
+    id              BIGINT PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+    -- only: sqlite (line commented)
+--  id              INTEGER PRIMARY KEY,
+    management_room TEXT   NOT NULL,
+    action          TEXT   NOT NULL,
+    target_user     TEXT   NOT NULL,
+    target_event    TEXT   NOT NULL,
+    in_room_id      TEXT   NOT NULL,
+    actor           TEXT   NOT NULL,
+    reason          TEXT   NOT NULL,
+    created_at      BIGINT NOT NULL
+);
+
+CREATE INDEX audit_log_room_idx ON audit_log (management_room, in_room_id);
@@ -0,0 +1,10 @@
+-- v2 -> v3: Add internal moderator notes for entities
+CREATE TABLE entity_note (
+    management_room TEXT   NOT NULL,
+    entity          TEXT   NOT NULL,
+    note            TEXT   NOT NULL,
+    author          TEXT   NOT NULL,
+    created_at      BIGINT NOT NULL
+);
+
+CREATE INDEX entity_note_entity_idx ON entity_note (management_room, entity);
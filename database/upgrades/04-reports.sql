@@ -0,0 +1,18 @@
+-- v3 -> v4: Track user reports for review
+CREATE TABLE report (
+    -- only: postgres
+    id              BIGINT PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+    -- only: sqlite (line commented)
+--  id              INTEGER PRIMARY KEY,
+    management_room TEXT   NOT NULL,
+    reporter        TEXT   NOT NULL,
+    target_user     TEXT   NOT NULL,
+    room_id         TEXT   NOT NULL,
+    event_id        TEXT   NOT NULL,
+    reason          TEXT   NOT NULL,
+    created_at      BIGINT NOT NULL,
+    handled_by      TEXT   NOT NULL,
+    handled_at      BIGINT NOT NULL
+);
+
+CREATE INDEX report_management_room_idx ON report (management_room, handled_at);
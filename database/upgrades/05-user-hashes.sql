@@ -0,0 +1,8 @@
+-- v4 -> v5: Persistent reverse index of user ID hashes
+CREATE TABLE user_hash (
+    hash      TEXT   PRIMARY KEY NOT NULL,
+    user_id   TEXT   NOT NULL,
+    last_seen BIGINT NOT NULL
+);
+
+CREATE INDEX user_hash_last_seen_idx ON user_hash (last_seen);
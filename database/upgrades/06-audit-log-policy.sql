@@ -0,0 +1,4 @@
+-- v5 -> v6: Store policy details in audit log
+ALTER TABLE audit_log ADD COLUMN policy_list TEXT NOT NULL DEFAULT '';
+ALTER TABLE audit_log ADD COLUMN entity TEXT NOT NULL DEFAULT '';
+ALTER TABLE audit_log ADD COLUMN recommendation TEXT NOT NULL DEFAULT '';
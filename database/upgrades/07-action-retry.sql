@@ -0,0 +1,22 @@
+-- v6 -> v7: Queue failed moderation actions for retrying
+CREATE TABLE action_retry (
+    -- only: postgres
+    id              BIGINT PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+    -- only: sqlite (line commented)
+--  id              INTEGER PRIMARY KEY,
+    management_room TEXT   NOT NULL,
+    action          TEXT   NOT NULL,
+    target_user     TEXT   NOT NULL,
+    room_id         TEXT   NOT NULL,
+    event_id        TEXT   NOT NULL,
+    reason          TEXT   NOT NULL,
+    policy_list     TEXT   NOT NULL,
+    entity          TEXT   NOT NULL,
+    recommendation  TEXT   NOT NULL,
+    attempts        BIGINT NOT NULL,
+    last_error      TEXT   NOT NULL,
+    next_attempt_at BIGINT NOT NULL,
+    created_at      BIGINT NOT NULL
+);
+
+CREATE INDEX action_retry_management_room_idx ON action_retry (management_room, next_attempt_at);
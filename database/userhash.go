@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"go.mau.fi/util/dbutil"
+	"maunium.net/go/mautrix/id"
+)
+
+const (
+	getUserByHashQuery = `
+		SELECT user_id FROM user_hash WHERE hash=$1
+	`
+	putUserHashQuery = `
+		INSERT INTO user_hash (hash, user_id, last_seen)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (hash) DO UPDATE
+			SET last_seen=excluded.last_seen
+	`
+	deleteOldUserHashesQuery = `
+		DELETE FROM user_hash WHERE last_seen<$1
+	`
+)
+
+// UserHashQuery is a persistent reverse index from SHA-256 hashes of user IDs to the user IDs themselves.
+// It's used to resolve hashed policies for users who aren't currently in any protected room.
+type UserHashQuery struct {
+	*dbutil.Database
+}
+
+func hashUserID(userID id.UserID) string {
+	hash := sha256.Sum256([]byte(userID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// Put stores the hashes of the given users, or updates their last seen timestamp if they're already stored.
+func (uhq *UserHashQuery) Put(ctx context.Context, lastSeen time.Time, userIDs ...id.UserID) error {
+	return uhq.DoTxn(ctx, nil, func(ctx context.Context) error {
+		for _, userID := range userIDs {
+			_, err := uhq.Exec(ctx, putUserHashQuery, hashUserID(userID), userID, lastSeen.UnixMilli())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Get finds the user ID with the given hash. If the hash isn't known, an empty string is returned.
+func (uhq *UserHashQuery) Get(ctx context.Context, hash [32]byte) (userID id.UserID, err error) {
+	err = uhq.QueryRow(ctx, getUserByHashQuery, base64.StdEncoding.EncodeToString(hash[:])).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+	}
+	return
+}
+
+// DeleteOlderThan removes all hashes of users who haven't been seen since the given time.
+func (uhq *UserHashQuery) DeleteOlderThan(ctx context.Context, lastSeen time.Time) (int64, error) {
+	res, err := uhq.Exec(ctx, deleteOldUserHashesQuery, lastSeen.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
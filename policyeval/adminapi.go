@@ -0,0 +1,110 @@
+package policyeval
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"maunium.net/go/mautrix/id"
+	"maunium.net/go/mautrix/synapseadmin"
+)
+
+type reqAdminRedactUser struct {
+	Rooms  []id.RoomID `json:"rooms"`
+	Reason string      `json:"reason,omitempty"`
+}
+
+type respAdminRedactUser struct {
+	RedactID string `json:"redact_id"`
+}
+
+type respAdminRedactStatus struct {
+	Status           string                `json:"status"`
+	FailedRedactions map[id.EventID]string `json:"failed_redactions"`
+}
+
+const (
+	adminRedactPollInterval = 5 * time.Second
+	adminRedactTimeout      = 30 * time.Minute
+)
+
+// synapseAdmin returns the client that should be used for Synapse admin API calls:
+// the dedicated admin client if an admin token is configured, or the bot's own client otherwise.
+func (pe *PolicyEvaluator) synapseAdmin() *synapseadmin.Client {
+	if pe.AdminAPI != nil {
+		return pe.AdminAPI
+	}
+	return pe.Bot.SynapseAdmin
+}
+
+// redactUserAdminAPI redacts events from the given user using the Synapse admin API.
+// If rooms is empty, events are redacted in all rooms the user is in, not just ones the bot has joined.
+//
+// https://element-hq.github.io/synapse/latest/admin_api/user_admin_api.html#redact-all-the-events-of-a-user
+func (pe *PolicyEvaluator) redactUserAdminAPI(ctx context.Context, userID id.UserID, rooms []id.RoomID, reason string) error {
+	if rooms == nil {
+		rooms = []id.RoomID{}
+	}
+	var resp respAdminRedactUser
+	_, err := pe.AdminAPI.MakeRequest(
+		ctx, http.MethodPost, pe.AdminAPI.BuildAdminURL("v1", "user", userID, "redact"),
+		&reqAdminRedactUser{Rooms: rooms, Reason: filterReason(reason)}, &resp,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to start redaction: %w", err)
+	}
+	log := zerolog.Ctx(ctx).With().
+		Stringer("user_id", userID).
+		Str("redact_id", resp.RedactID).
+		Logger()
+	log.Debug().Msg("Started admin API redaction")
+	deadline := time.Now().Add(adminRedactTimeout)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(adminRedactPollInterval):
+		}
+		var status respAdminRedactStatus
+		_, err = pe.AdminAPI.MakeRequest(
+			ctx, http.MethodGet, pe.AdminAPI.BuildAdminURL("v1", "user", "redact_status", resp.RedactID), nil, &status,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to get redaction status: %w", err)
+		}
+		switch status.Status {
+		case "complete":
+			log.Debug().Int("failed_count", len(status.FailedRedactions)).Msg("Admin API redaction completed")
+			output := fmt.Sprintf("Redacted events from [%s](%s) %s using the homeserver admin API",
+				userID, userID.URI().MatrixToURL(), describeAdminRedactScope(rooms))
+			if len(status.FailedRedactions) > 0 {
+				failures := make([]string, 0, len(status.FailedRedactions))
+				for evtID, reason := range status.FailedRedactions {
+					failures = append(failures, fmt.Sprintf("* `%s`: %s", evtID, reason))
+				}
+				output += fmt.Sprintf("\n\nFailed to redact %s:\n\n%s", pluralize(len(failures), "event"), strings.Join(failures, "\n"))
+			}
+			pe.sendNotice(ctx, output)
+			for _, roomID := range rooms {
+				pe.logRedaction(ctx, userID, roomID, reason)
+			}
+			if len(rooms) == 0 {
+				pe.logRedaction(ctx, userID, "", reason)
+			}
+			return nil
+		case "failed":
+			return fmt.Errorf("redaction task failed")
+		}
+	}
+	return fmt.Errorf("redaction task didn't complete in %s", adminRedactTimeout)
+}
+
+func describeAdminRedactScope(rooms []id.RoomID) string {
+	if len(rooms) == 0 {
+		return "in all rooms on the server"
+	}
+	return "in " + pluralize(len(rooms), "room")
+}
@@ -106,6 +106,7 @@ func (pe *PolicyEvaluator) HandleUserMayInvite(ctx context.Context, inviter, inv
 			// will catch them and call RejectPendingInvites.
 			pe.protectedRoomMembers[inviter] = []id.RoomID{}
 			pe.memberHashes[util.SHA256String(string(inviter))] = inviter
+			pe.dirtyUserHashes[inviter] = struct{}{}
 		}
 		pe.protectedRoomsLock.Unlock()
 	}
@@ -0,0 +1,191 @@
+package policyeval
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/meowlnir/bot"
+	"go.mau.fi/meowlnir/database"
+	"go.mau.fi/meowlnir/policylist"
+	"go.mau.fi/meowlnir/util"
+)
+
+type pendingAppeal struct {
+	UserID   id.UserID
+	RoomID   id.RoomID
+	EventID  id.EventID
+	Policies policylist.Match
+}
+
+// getAppealedPolicies returns all ban and takedown policies that match the given user.
+func (pe *PolicyEvaluator) getAppealedPolicies(userID id.UserID) (policies policylist.Match) {
+	for _, policy := range pe.Store.MatchUser(pe.GetWatchedLists(), userID) {
+		if policy.Recommendation == event.PolicyRecommendationBan || policy.Recommendation == event.PolicyRecommendationUnstableTakedown {
+			policies = append(policies, policy)
+		}
+	}
+	return
+}
+
+// HandleAppeal posts a message sent in the appeal room to the management room along with the policies
+// that the sender matches, so that admins can approve or deny it by replying.
+func (pe *PolicyEvaluator) HandleAppeal(ctx context.Context, evt *event.Event) {
+	content := evt.Content.AsMessage()
+	pe.appealsLock.Lock()
+	defer pe.appealsLock.Unlock()
+	if _, alreadyPending := pe.appealsByUser[evt.Sender]; alreadyPending {
+		zerolog.Ctx(ctx).Debug().Stringer("user_id", evt.Sender).Msg("Ignoring appeal from user who already has a pending appeal")
+		return
+	}
+	policies := pe.getAppealedPolicies(evt.Sender)
+	if len(policies) == 0 {
+		pe.sendNotice(
+			ctx, "[%s](%s) sent [an appeal](%s), but doesn't match any ban policies",
+			evt.Sender, evt.Sender.URI().MatrixToURL(), evt.RoomID.EventURI(evt.ID).MatrixToURL(),
+		)
+		return
+	}
+	var buf strings.Builder
+	_, _ = fmt.Fprintf(
+		&buf, "📨 [%s](%s) sent [an appeal](%s):\n\n> %s\n\nMatching ban policies:\n\n",
+		evt.Sender, evt.Sender.URI().MatrixToURL(), evt.RoomID.EventURI(evt.ID).MatrixToURL(),
+		strings.ReplaceAll(format.EscapeMarkdown(content.Body), "\n", "\n> "),
+	)
+	for _, policy := range policies {
+		listName := policy.RoomID.String()
+		if meta := pe.GetWatchedListMeta(policy.RoomID); meta != nil {
+			listName = meta.Name
+		}
+		_, _ = fmt.Fprintf(
+			&buf, "* %s %s in **%s** by [%s](%s)%s\n",
+			formatMatchRecommendation(policy), formatMatchEntity(policy), format.EscapeMarkdown(listName),
+			policy.Sender, policy.Sender.URI().MatrixToURL(), formatMatchReason(policy),
+		)
+	}
+	buf.WriteString("\nReply to this message with `/approve [reason]` to lift the bans or `/deny [reason]` to keep them")
+	noticeID := pe.sendNoticeNow(ctx, buf.String())
+	if noticeID == "" {
+		return
+	}
+	pe.pendingAppeals[noticeID] = &pendingAppeal{
+		UserID:   evt.Sender,
+		RoomID:   evt.RoomID,
+		EventID:  evt.ID,
+		Policies: policies,
+	}
+	pe.appealsByUser[evt.Sender] = noticeID
+}
+
+// popAppeal removes and returns the pending appeal whose notice has the given event ID.
+func (pe *PolicyEvaluator) popAppeal(noticeID id.EventID) *pendingAppeal {
+	pe.appealsLock.Lock()
+	defer pe.appealsLock.Unlock()
+	appeal, ok := pe.pendingAppeals[noticeID]
+	if ok {
+		delete(pe.pendingAppeals, noticeID)
+		delete(pe.appealsByUser, appeal.UserID)
+	}
+	return appeal
+}
+
+// handleAppealResponse handles `/approve` and `/deny` replies to appeal notices.
+// It returns true if the message was an appeal response.
+func (pe *PolicyEvaluator) handleAppealResponse(ctx context.Context, evt *event.Event) bool {
+	content := evt.Content.AsMessage()
+	replyTo := content.RelatesTo.GetReplyTo()
+	if replyTo == "" {
+		return false
+	}
+	content.RemoveReplyFallback()
+	fields := strings.Fields(content.Body)
+	if len(fields) == 0 {
+		return false
+	}
+	action := strings.ToLower(fields[0])
+	if action != "/approve" && action != "/deny" {
+		return false
+	}
+	appeal := pe.popAppeal(replyTo)
+	if appeal == nil {
+		return false
+	}
+	reason := strings.Join(fields[1:], " ")
+	zerolog.Ctx(ctx).Info().
+		Stringer("appeal_user_id", appeal.UserID).
+		Str("appeal_action", action).
+		Msg("Handling appeal response")
+	if action == "/approve" {
+		pe.approveAppeal(ctx, appeal, reason)
+	} else {
+		pe.sendNotice(ctx, "Denied appeal from [%s](%s), the bans stay in place", appeal.UserID, appeal.UserID.URI().MatrixToURL())
+		pe.replyToAppeal(ctx, appeal, "denied", reason)
+	}
+	return true
+}
+
+// replyToAppeal tells the appealing user about the outcome of their appeal in the appeal room.
+func (pe *PolicyEvaluator) replyToAppeal(ctx context.Context, appeal *pendingAppeal, outcome, reason string) {
+	message := fmt.Sprintf("%s, your appeal was %s.", appeal.UserID, outcome)
+	if reason != "" {
+		message += " " + reason
+	}
+	pe.Bot.SendNoticeOpts(ctx, appeal.RoomID, message, &bot.SendNoticeOpts{DisallowMarkdown: true})
+}
+
+// approveAppeal lifts all bans of the appealing user. Policies for the exact user ID are removed,
+// while wildcard policies get an unban recommendation for the user in the same list.
+func (pe *PolicyEvaluator) approveAppeal(ctx context.Context, appeal *pendingAppeal, reason string) {
+	if pe.DryRun {
+		pe.sendNotice(ctx, "Would approve appeal from [%s](%s), but dry run is enabled", appeal.UserID, appeal.UserID.URI().MatrixToURL())
+		return
+	}
+	userHash := util.SHA256String(appeal.UserID.String())
+	var failed []string
+	for _, policy := range appeal.Policies {
+		var err error
+		if policy.Entity == appeal.UserID.String() || (policy.EntityHash != nil && *policy.EntityHash == userHash) {
+			_, err = pe.RemovePolicy(ctx, policy)
+		} else {
+			_, err = pe.SendPolicy(ctx, policy.RoomID, policylist.EntityTypeUser, "", appeal.UserID.String(), &event.ModPolicyContent{
+				Entity:         appeal.UserID.String(),
+				Reason:         reason,
+				Recommendation: event.PolicyRecommendationUnban,
+			})
+		}
+		if err != nil {
+			zerolog.Ctx(ctx).Err(err).Any("policy", policy).Msg("Failed to lift ban policy for appeal")
+			failed = append(failed, fmt.Sprintf("* %s: %v", formatMatchEntity(policy), err))
+		}
+	}
+	if len(failed) > 0 {
+		pe.sendNotice(
+			ctx, "Failed to lift some bans for [%s](%s), not unbanning them from rooms:\n\n%s",
+			appeal.UserID, appeal.UserID.URI().MatrixToURL(), strings.Join(failed, "\n"),
+		)
+		return
+	}
+	var unbannedFrom int
+	for _, roomID := range pe.GetProtectedRooms() {
+		if !pe.Bot.StateStore.IsMembership(ctx, roomID, appeal.UserID, event.MembershipBan) {
+			continue
+		}
+		if pe.UndoBan(ctx, appeal.UserID, roomID) {
+			unbannedFrom++
+			err := pe.DB.TakenAction.Delete(ctx, appeal.UserID, roomID, database.TakenActionTypeBanOrUnban)
+			if err != nil {
+				zerolog.Ctx(ctx).Err(err).Stringer("room_id", roomID).Msg("Failed to delete taken action after unbanning")
+			}
+		}
+	}
+	pe.sendNotice(
+		ctx, "Approved appeal from [%s](%s), lifted bans from %d matching policies and unbanned them from %s",
+		appeal.UserID, appeal.UserID.URI().MatrixToURL(), len(appeal.Policies), pluralize(unbannedFrom, "room"),
+	)
+	pe.replyToAppeal(ctx, appeal, "approved", reason)
+}
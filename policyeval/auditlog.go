@@ -0,0 +1,172 @@
+package policyeval
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog"
+	"maunium.net/go/mautrix/crypto/attachment"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/meowlnir/database"
+)
+
+type contextKey int
+
+const (
+	contextKeyActor contextKey = iota
+	contextKeyUndo
+)
+
+// withActor marks the given context as being caused by the given user, so that audit log entries can be attributed.
+func withActor(ctx context.Context, actor id.UserID) context.Context {
+	return context.WithValue(ctx, contextKeyActor, actor)
+}
+
+func actorFromContext(ctx context.Context) id.UserID {
+	actor, _ := ctx.Value(contextKeyActor).(id.UserID)
+	return actor
+}
+
+func (pe *PolicyEvaluator) logAction(ctx context.Context, entry *database.AuditLogEntry) {
+	entry.ManagementRoom = pe.ManagementRoom
+	entry.CreatedAt = time.Now()
+	entry.DryRun = pe.DryRun
+	actionsPerformed.WithLabelValues(string(entry.Action), strconv.FormatBool(entry.DryRun)).Inc()
+	if entry.Actor == "" {
+		entry.Actor = actorFromContext(ctx)
+	}
+	if isUndoContext(ctx) {
+		entry.Undone = true
+	}
+	if !pe.DryRun {
+		err := pe.DB.AuditLog.Put(ctx, entry)
+		if err != nil {
+			zerolog.Ctx(ctx).Err(err).Any("entry", entry).Msg("Failed to save audit log entry")
+		}
+	}
+	pe.recordRecentAction(entry)
+	pe.maybeSendWebhook(ctx, entry)
+	if pe.AuditRoom != "" {
+		pe.postAuditLogEntry(ctx, entry)
+	}
+}
+
+// AuditLogEntryKey is the key in audit room messages that contains the audit log entry as JSON.
+const AuditLogEntryKey = "fi.mau.meowlnir.audit_log_entry"
+
+// postAuditLogEntry sends the given audit log entry to the audit room. The entry is included both as
+// a JSON code block in the message body and as a raw field in the event content for machine parsing.
+func (pe *PolicyEvaluator) postAuditLogEntry(ctx context.Context, entry *database.AuditLogEntry) {
+	entryJSON, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Any("entry", entry).Msg("Failed to marshal audit log entry")
+		return
+	}
+	target := entry.Entity
+	if entry.TargetUser != "" {
+		target = entry.TargetUser.String()
+	}
+	summary := fmt.Sprintf("%s %s", format.SafeMarkdownCode(entry.Action), format.SafeMarkdownCode(target))
+	if entry.Actor != "" {
+		summary += " by " + format.SafeMarkdownCode(entry.Actor)
+	}
+	if entry.DryRun {
+		summary += " (dry run)"
+	}
+	content := format.RenderMarkdown(fmt.Sprintf("%s\n\n```json\n%s\n```", summary, entryJSON), true, false)
+	content.MsgType = event.MsgNotice
+	_, err = pe.Bot.SendMessageEvent(ctx, pe.AuditRoom, event.EventMessage, &event.Content{
+		Parsed: &content,
+		Raw:    map[string]any{AuditLogEntryKey: entry},
+	})
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Any("entry", entry).Msg("Failed to send audit log entry to audit room")
+	}
+}
+
+type chainedAuditLogLine struct {
+	Entry    string `json:"entry"`
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+}
+
+// exportAuditLog serializes all audit log entries of this management room as JSON lines.
+//
+// If chained is true, each line wraps the serialized entry together with the hash of the previous line,
+// where hash = hex(sha256(prev_hash + entry)). The returned head hash is the hash of the last line.
+func (pe *PolicyEvaluator) exportAuditLog(ctx context.Context, chained bool) (data []byte, count int, head string, err error) {
+	entries, err := pe.DB.AuditLog.GetAll(ctx, pe.ManagementRoom)
+	if err != nil {
+		return nil, 0, "", fmt.Errorf("failed to get audit log: %w", err)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		if !chained {
+			err = enc.Encode(entry)
+		} else {
+			var entryJSON []byte
+			entryJSON, err = json.Marshal(entry)
+			if err != nil {
+				return nil, 0, "", fmt.Errorf("failed to marshal entry %d: %w", entry.ID, err)
+			}
+			hash := sha256.Sum256([]byte(head + string(entryJSON)))
+			line := &chainedAuditLogLine{
+				Entry:    string(entryJSON),
+				PrevHash: head,
+				Hash:     hex.EncodeToString(hash[:]),
+			}
+			head = line.Hash
+			err = enc.Encode(line)
+		}
+		if err != nil {
+			return nil, 0, "", fmt.Errorf("failed to encode entry %d: %w", entry.ID, err)
+		}
+	}
+	return buf.Bytes(), len(entries), head, nil
+}
+
+func (pe *PolicyEvaluator) sendFile(ctx context.Context, fileName, mimeType string, data []byte) error {
+	content := &event.MessageEventContent{
+		MsgType:  event.MsgFile,
+		Body:     fileName,
+		FileName: fileName,
+		Info: &event.FileInfo{
+			MimeType: mimeType,
+			Size:     len(data),
+		},
+	}
+	uploadMime := mimeType
+	var file *attachment.EncryptedFile
+	if pe.Bot.CryptoHelper != nil {
+		file = attachment.NewEncryptedFile()
+		file.EncryptInPlace(data)
+		uploadMime = "application/octet-stream"
+	}
+	resp, err := pe.Bot.UploadBytes(ctx, data, uploadMime)
+	if err != nil {
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+	if file != nil {
+		content.File = &event.EncryptedFileInfo{
+			EncryptedFile: *file,
+			URL:           resp.ContentURI.CUString(),
+		}
+	} else {
+		content.URL = resp.ContentURI.CUString()
+	}
+	_, err = pe.Bot.SendMessageEvent(ctx, pe.ManagementRoom, event.EventMessage, content)
+	if err != nil {
+		return fmt.Errorf("failed to send file message: %w", err)
+	}
+	return nil
+}
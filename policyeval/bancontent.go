@@ -0,0 +1,77 @@
+package policyeval
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+
+	"go.mau.fi/meowlnir/policylist"
+)
+
+var cmdBanContent = &CommandHandler{
+	Name: "ban-content",
+	Func: func(ce *CommandEvent) {
+		isRegex := slices.Contains(ce.Args, "--regex")
+		banSender := slices.Contains(ce.Args, "--ban-sender")
+		if isRegex || banSender {
+			ce.Args = slices.DeleteFunc(ce.Args, func(arg string) bool { return arg == "--regex" || arg == "--ban-sender" })
+		}
+		if len(ce.Args) < 2 {
+			replyUsage(ce)
+			return
+		}
+		list := ce.Meta.FindListByShortcode(ce.Args[0])
+		if list == nil {
+			ce.Reply("List %s not found", format.SafeMarkdownCode(ce.Args[0]))
+			return
+		}
+		pattern := ce.Args[1]
+		if isRegex {
+			if _, err := policylist.CompileEntityRegex(pattern); err != nil {
+				ce.Reply("Invalid regex %s: %v", format.SafeMarkdownCode(pattern), err)
+				return
+			}
+		} else if pattern == "*" {
+			ce.Reply("Refusing to send a content policy that matches all messages")
+			return
+		}
+		content := &event.ModPolicyContent{
+			Entity:         pattern,
+			Reason:         expandReasonTemplate(list, strings.Join(ce.Args[2:], " ")),
+			Recommendation: event.PolicyRecommendationBan,
+		}
+		action := "redact"
+		if banSender {
+			action = "redact and ban the sender of"
+		}
+		if ce.Meta.DryRun {
+			ce.Reply(
+				"Would send a content policy for %s to **%s** to %s matching messages, but dry run is enabled",
+				format.SafeMarkdownCode(pattern), format.EscapeMarkdown(list.Name), action,
+			)
+			return
+		}
+		extra := make(map[string]any)
+		if isRegex {
+			extra[policylist.UnstableRegexKey] = true
+		}
+		if banSender {
+			extra[policylist.UnstableBanSenderKey] = true
+		}
+		resp, err := ce.Meta.sendPolicyWithExtra(ce.Ctx, list.RoomID, policylist.EntityTypeContent, "", pattern, content, extra)
+		if err != nil {
+			ce.Reply("Failed to send content policy: %v", err)
+			return
+		}
+		zerolog.Ctx(ce.Ctx).Info().
+			Stringer("policy_list", list.RoomID).
+			Any("policy", content).
+			Bool("ban_sender", banSender).
+			Stringer("policy_event_id", resp.EventID).
+			Msg("Sent content policy from command")
+		ce.React(SuccessReaction)
+	},
+}
@@ -0,0 +1,217 @@
+package policyeval
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/meowlnir/database"
+	"go.mau.fi/meowlnir/policylist"
+	"go.mau.fi/meowlnir/util"
+)
+
+// maxHashedMediaSize is the maximum size of media that is downloaded to check against media policies.
+// The limit is enforced while downloading, as the size in the event info is set by the sender.
+const maxHashedMediaSize = 100 * 1024 * 1024
+
+// maxConcurrentMediaChecks is the number of messages whose media can be downloaded for media policy checks at once.
+const maxConcurrentMediaChecks = 4
+
+var errMediaTooLarge = fmt.Errorf("media is larger than %d bytes", maxHashedMediaSize)
+
+// downloadMediaHash downloads the given media, decrypting it if file is non-nil, and returns its media policy hash.
+// Unencrypted media is hashed while streaming, while encrypted media has to be buffered to verify it before decrypting.
+func (pe *PolicyEvaluator) downloadMediaHash(ctx context.Context, uri id.ContentURI, file *event.EncryptedFileInfo) (string, error) {
+	resp, err := pe.Bot.Download(ctx, uri)
+	if err != nil {
+		return "", fmt.Errorf("failed to download: %w", err)
+	}
+	defer resp.Body.Close()
+	body := io.LimitReader(resp.Body, maxHashedMediaSize+1)
+	if file != nil {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return "", fmt.Errorf("failed to download: %w", err)
+		} else if len(data) > maxHashedMediaSize {
+			return "", errMediaTooLarge
+		} else if err = file.DecryptInPlace(data); err != nil {
+			return "", fmt.Errorf("failed to decrypt: %w", err)
+		}
+		return policylist.HashMedia(data), nil
+	}
+	hasher := sha256.New()
+	n, err := io.Copy(hasher, body)
+	if err != nil {
+		return "", fmt.Errorf("failed to download: %w", err)
+	} else if n > maxHashedMediaSize {
+		return "", errMediaTooLarge
+	}
+	return base64.StdEncoding.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// getMessageMedia returns the main media file of the given message, if any.
+func getMessageMedia(content *event.MessageEventContent) (id.ContentURI, *event.EncryptedFileInfo) {
+	if content.Info != nil && content.Info.Size > maxHashedMediaSize {
+		return id.ContentURI{}, nil
+	}
+	if content.File != nil {
+		return content.File.URL.ParseOrIgnore(), content.File
+	}
+	return content.URL.ParseOrIgnore(), nil
+}
+
+// checkMessageMedia hashes the media in a message in a protected room and matches it against media policies
+// in watched lists. Matching media is quarantined using the admin API and the message is redacted.
+func (pe *PolicyEvaluator) checkMessageMedia(ctx context.Context, evt *event.Event, content *event.MessageEventContent) {
+	uri, file := getMessageMedia(content)
+	if uri.IsEmpty() || pe.Admins.Has(evt.Sender) {
+		return
+	}
+	watchedLists := pe.GetWatchedLists()
+	if !pe.Store.HasMediaPolicies(watchedLists) {
+		return
+	}
+	log := zerolog.Ctx(ctx).With().
+		Stringer("room_id", evt.RoomID).
+		Stringer("event_id", evt.ID).
+		Stringer("sender", evt.Sender).
+		Stringer("mxc", uri).
+		Logger()
+	select {
+	case pe.mediaCheckSem <- struct{}{}:
+	case <-ctx.Done():
+		return
+	}
+	mediaHash, err := pe.downloadMediaHash(ctx, uri, file)
+	<-pe.mediaCheckSem
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to hash media to check against media policies")
+		return
+	}
+	policy := pe.Store.MatchMedia(watchedLists, mediaHash).Recommendations().BanOrUnban
+	if policy == nil || (policy.Recommendation != event.PolicyRecommendationBan && policy.Recommendation != event.PolicyRecommendationUnstableTakedown) {
+		return
+	}
+	listName := policy.RoomID.String()
+	if meta := pe.GetWatchedListMeta(policy.RoomID); meta != nil {
+		listName = meta.Name
+	}
+	eventLink := evt.RoomID.EventURI(evt.ID).MatrixToURL()
+	if pe.isObserveOnlyRoom(evt.RoomID) {
+		log.Info().Msg("Media matches media policy in observe-only room")
+		pe.sendNotice(
+			ctx, "👀 [A message](%s) from [%s](%s) in %s contains media matching a policy in %s, but the room is observe-only: %s",
+			eventLink, evt.Sender, evt.Sender.URI().MatrixToURL(), pe.formatRoomLink(evt.RoomID),
+			format.EscapeMarkdown(listName), format.SafeMarkdownCode(policy.Reason),
+		)
+		return
+	}
+	log.Info().Str("media_hash", mediaHash).Msg("Quarantining media matching media policy")
+	var results []string
+	if !pe.DryRun {
+		err = pe.quarantineMediaAdminAPI(ctx, uri)
+	}
+	if err != nil {
+		log.Err(err).Msg("Failed to quarantine media matching media policy")
+		results = append(results, fmt.Sprintf("failed to quarantine media: %v", err))
+	} else {
+		results = append(results, "quarantined media")
+	}
+	_, err = pe.redactEventAndEdits(ctx, evt.RoomID, evt.ID, filterReason(policy.Reason))
+	if err != nil {
+		log.Err(err).Msg("Failed to redact message with media matching media policy")
+		results = append(results, fmt.Sprintf("failed to redact message: %v", err))
+	} else {
+		results = append(results, "redacted message")
+		pe.logAction(ctx, &database.AuditLogEntry{
+			Action:         database.AuditLogActionRedact,
+			TargetUser:     evt.Sender,
+			TargetEvent:    evt.ID,
+			InRoomID:       evt.RoomID,
+			Actor:          policy.Sender,
+			Reason:         policy.Reason,
+			PolicyList:     policy.RoomID,
+			Entity:         policy.Entity,
+			Recommendation: string(policy.Recommendation),
+		})
+	}
+	pe.sendNotice(
+		ctx, "[A message](%s) from [%s](%s) in %s contains media matching a policy in %s: %s",
+		eventLink, evt.Sender, evt.Sender.URI().MatrixToURL(), pe.formatRoomLink(evt.RoomID),
+		format.EscapeMarkdown(listName), strings.Join(results, ", "),
+	)
+}
+
+var cmdBanMedia = &CommandHandler{
+	Name: "ban-media",
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) < 2 {
+			replyUsage(ce)
+			return
+		}
+		list := ce.Meta.FindListByShortcode(ce.Args[0])
+		if list == nil {
+			ce.Reply("List %s not found", format.SafeMarkdownCode(ce.Args[0]))
+			return
+		}
+		var mediaHash string
+		var uri id.ContentURI
+		if _, isHash := util.DecodeBase64Hash(ce.Args[1]); isHash {
+			mediaHash = ce.Args[1]
+		} else {
+			var err error
+			uri, err = id.ParseContentURI(ce.Args[1])
+			if err != nil {
+				ce.Reply("Invalid mxc URI %s: %v", format.SafeMarkdownCode(ce.Args[1]), err)
+				return
+			}
+			mediaHash, err = ce.Meta.downloadMediaHash(ce.Ctx, uri, nil)
+			if err != nil {
+				zerolog.Ctx(ce.Ctx).Err(err).Stringer("mxc", uri).Msg("Failed to hash media for ban-media command")
+				ce.Reply(
+					"Failed to hash %s: %v\n\nIf you have the file, you can ban its SHA-256 hash (in base64) directly instead of the mxc URI",
+					format.SafeMarkdownCode(uri.String()), err,
+				)
+				return
+			}
+		}
+		policy := &event.ModPolicyContent{
+			Entity:         mediaHash,
+			Reason:         expandReasonTemplate(list, strings.Join(ce.Args[2:], " ")),
+			Recommendation: event.PolicyRecommendationBan,
+		}
+		if ce.Meta.DryRun {
+			ce.Reply(
+				"Would send a media policy for %s to **%s**, but dry run is enabled",
+				format.SafeMarkdownCode(mediaHash), format.EscapeMarkdown(list.Name),
+			)
+			return
+		}
+		resp, err := ce.Meta.SendPolicy(ce.Ctx, list.RoomID, policylist.EntityTypeMedia, "", mediaHash, policy)
+		if err != nil {
+			ce.Reply("Failed to send media policy: %v", err)
+			return
+		}
+		zerolog.Ctx(ce.Ctx).Info().
+			Stringer("policy_list", list.RoomID).
+			Any("policy", policy).
+			Stringer("policy_event_id", resp.EventID).
+			Msg("Sent media policy from command")
+		if !uri.IsEmpty() {
+			if err = ce.Meta.quarantineMediaAdminAPI(ce.Ctx, uri); err != nil {
+				ce.Reply("Sent media policy for %s, but failed to quarantine %s: %v", format.SafeMarkdownCode(mediaHash), format.SafeMarkdownCode(uri.String()), err)
+			} else {
+				ce.Reply("Sent media policy for %s and quarantined %s", format.SafeMarkdownCode(mediaHash), format.SafeMarkdownCode(uri.String()))
+			}
+		}
+		ce.React(SuccessReaction)
+	},
+}
@@ -1,14 +1,19 @@
 package policyeval
 
 import (
+	"cmp"
 	"context"
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"maps"
 	"regexp"
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -21,6 +26,7 @@ import (
 	"maunium.net/go/mautrix/synapseadmin"
 
 	"go.mau.fi/meowlnir/config"
+	"go.mau.fi/meowlnir/database"
 	"go.mau.fi/meowlnir/policylist"
 	"go.mau.fi/meowlnir/util"
 )
@@ -29,30 +35,93 @@ type CommandEvent = commands.Event[*PolicyEvaluator]
 type CommandHandler = commands.Handler[*PolicyEvaluator]
 
 const SuccessReaction = "✅"
+const FailureReaction = "❌"
 
-func (pe *PolicyEvaluator) HandleCommand(ctx context.Context, evt *event.Event) {
+func (pe *PolicyEvaluator) isTrustedEvent(ctx context.Context, evt *event.Event) bool {
 	if !evt.Mautrix.WasEncrypted && pe.Bot.CryptoHelper != nil {
 		zerolog.Ctx(ctx).Warn().
-			Msg("Dropping unencrypted command event")
-		return
+			Stringer("event_type", &evt.Type).
+			Msg("Dropping unencrypted management room event")
+		return false
 	} else if evt.Mautrix.WasEncrypted && evt.Mautrix.TrustState < id.TrustStateCrossSignedTOFU {
 		zerolog.Ctx(ctx).Warn().
+			Stringer("event_type", &evt.Type).
 			Stringer("trust_state", evt.Mautrix.TrustState).
 			Msg("Dropping encrypted event with insufficient trust state")
+		return false
+	}
+	return true
+}
+
+func (pe *PolicyEvaluator) HandleCommand(ctx context.Context, evt *event.Event) {
+	if !pe.isTrustedEvent(ctx, evt) {
+		return
+	}
+	ctx = withActor(ctx, evt.Sender)
+	if pe.handleAppealResponse(ctx, evt) {
 		return
 	}
 	pe.commandProcessor.Process(ctx, evt)
 }
 
+const defaultCommandPrefix = "!"
+
+func (pe *PolicyEvaluator) commandPrefix() string {
+	if pe.CommandPrefix == "" {
+		return defaultCommandPrefix
+	}
+	return strings.ToLower(pe.CommandPrefix)
+}
+
+// validateCommandPrefix accepts commands in the form `<prefix>command` and `<prefix>meowlnir command`
+// and strips the prefix from the command name.
+func validateCommandPrefix(ce *CommandEvent) bool {
+	prefix := ce.Meta.commandPrefix()
+	if ce.Command == prefix+"meowlnir" && len(ce.Args) > 0 {
+		ce.Command = strings.ToLower(ce.ShiftArg())
+		return true
+	} else if strings.HasPrefix(ce.Command, prefix) {
+		ce.Command = ce.Command[len(prefix):]
+		return true
+	}
+	return false
+}
+
+// resolveCommandAlias replaces the command name with the target of a configured alias, if there is one.
+func resolveCommandAlias(ce *CommandEvent) bool {
+	if target, ok := ce.Meta.CommandAliases[ce.Command]; ok {
+		ce.Command = strings.ToLower(target)
+	}
+	return true
+}
+
 var cmdJoin = &CommandHandler{
 	Name: "join",
 	Func: func(ce *CommandEvent) {
 		if len(ce.Args) == 0 {
-			ce.Reply("Usage: `!join <room ID>...`")
+			replyUsage(ce)
 			return
 		}
 		for _, arg := range ce.Args {
-			_, err := ce.Meta.Bot.JoinRoom(ce.Ctx, arg, nil)
+			target, via, ok := parseJoinTarget(arg)
+			if !ok {
+				ce.Reply(
+					"Invalid room %s (must be a room ID, alias, matrix.to link or `matrix:` URI)",
+					format.SafeMarkdownCode(arg),
+				)
+				continue
+			}
+			roomID := id.RoomID(target)
+			if strings.HasPrefix(target, "#") {
+				if resp, err := ce.Meta.Bot.ResolveAlias(ce.Ctx, id.RoomAlias(target)); err == nil {
+					roomID = resp.RoomID
+				}
+			}
+			if policy := ce.Meta.getRoomBanPolicy(roomID); policy != nil {
+				ce.Reply("Not joining %s, as it's %s", format.SafeMarkdownCode(arg), ce.Meta.formatRoomBanPolicy(policy))
+				continue
+			}
+			_, err := ce.Meta.Bot.JoinRoom(ce.Ctx, target, &mautrix.ReqJoinRoom{Via: via})
 			if err != nil {
 				ce.Reply("Failed to join room %s: %v", format.SafeMarkdownCode(arg), err)
 			} else {
@@ -67,7 +136,7 @@ var cmdKnock = &CommandHandler{
 	Name: "knock",
 	Func: func(ce *CommandEvent) {
 		if len(ce.Args) == 0 {
-			ce.Reply("Usage: `!knock <rooms...>`")
+			replyUsage(ce)
 			return
 		}
 		for _, arg := range ce.Args {
@@ -86,13 +155,16 @@ var cmdLeave = &CommandHandler{
 	Name: "leave",
 	Func: func(ce *CommandEvent) {
 		if len(ce.Args) == 0 {
-			ce.Reply("Usage: `!leave <room ID>...`")
+			replyUsage(ce)
 			return
 		}
 		for _, arg := range ce.Args {
 			target := resolveRoom(ce, arg)
 			if target == "" {
 				continue
+			} else if ce.Meta.DryRun {
+				ce.Reply("Dry run: would have left room %s", format.SafeMarkdownCode(arg))
+				continue
 			}
 			_, err := ce.Meta.Bot.LeaveRoom(ce.Ctx, target)
 			if err != nil {
@@ -108,9 +180,8 @@ var cmdPowerLevel = &CommandHandler{
 	Name:    "powerlevel",
 	Aliases: []string{"pl"},
 	Func: func(ce *CommandEvent) {
-
-		if len(ce.Args) < 1 {
-			ce.Reply("Usage: `!powerlevel <room|all> <key> <level>`")
+		if len(ce.Args) < 3 {
+			replyUsage(ce)
 			return
 		}
 		var rooms []id.RoomID
@@ -190,6 +261,14 @@ var cmdPowerLevel = &CommandHandler{
 					format.SafeMarkdownCode(strconv.Itoa(level)),
 				)
 				continue
+			} else if ce.Meta.DryRun {
+				ce.Reply(
+					"Dry run: would have set power level for %s in %s to %s",
+					format.SafeMarkdownCode(key),
+					format.SafeMarkdownCode(room),
+					format.SafeMarkdownCode(strconv.Itoa(level)),
+				)
+				continue
 			}
 			_, err = ce.Meta.Bot.Client.SendStateEvent(ce.Ctx, room, event.StatePowerLevels, "", &pls)
 			if err != nil {
@@ -204,8 +283,63 @@ var cmdPowerLevel = &CommandHandler{
 var cmdRedact = &CommandHandler{
 	Name: "redact",
 	Func: func(ce *CommandEvent) {
+		confirmCount := len(ce.Args) > 0 && ce.Args[0] == "--confirm-count"
+		if confirmCount {
+			ce.Args = ce.Args[1:]
+		}
+		exact := slices.Contains(ce.Args, "--exact")
+		if exact {
+			ce.Args = slices.DeleteFunc(ce.Args, func(arg string) bool { return arg == "--exact" })
+		}
+		var maxAge time.Duration
+		if sinceIdx := slices.Index(ce.Args, "--since"); sinceIdx >= 0 && sinceIdx+1 < len(ce.Args) {
+			var err error
+			maxAge, err = util.ParseDuration(ce.Args[sinceIdx+1])
+			if err != nil || maxAge <= 0 {
+				ce.Reply("Invalid duration %s", format.SafeMarkdownCode(ce.Args[sinceIdx+1]))
+				return
+			}
+			ce.Args = slices.Delete(ce.Args, sinceIdx, sinceIdx+2)
+		}
+		var limit int
+		if limitIdx := slices.Index(ce.Args, "--limit"); limitIdx >= 0 && limitIdx+1 < len(ce.Args) {
+			var err error
+			limit, err = strconv.Atoi(ce.Args[limitIdx+1])
+			if err != nil || limit <= 0 {
+				ce.Reply("Invalid limit %s", format.SafeMarkdownCode(ce.Args[limitIdx+1]))
+				return
+			}
+			ce.Args = slices.Delete(ce.Args, limitIdx, limitIdx+2)
+		}
 		if len(ce.Args) < 1 {
-			ce.Reply("Usage: `!redact <event link or user ID> [reason]`")
+			replyUsage(ce)
+			return
+		}
+		if ce.Args[0][0] == '@' && !exact && containsGlobWildcard(ce.Args[0]) {
+			pattern := glob.Compile(ce.Args[0])
+			reason := strings.Join(ce.Args[1:], " ")
+			users := slices.Collect(ce.Meta.findMatchingUsers(pattern, nil, false))
+			if len(users) == 0 {
+				ce.Reply("No users matching %s found in any rooms", format.SafeMarkdownCode(ce.Args[0]))
+				return
+			} else if !confirmCount && maxAge <= 0 && limit <= 0 {
+				ce.Reply(
+					"Found %d users matching %s. Redactions can't be undone, use `%sredact --confirm-count %s` to redact all of their messages, or use `--since` or `--limit` to redact fewer.",
+					len(users), format.SafeMarkdownCode(ce.Args[0]), ce.Meta.commandPrefix(), ce.Args[0],
+				)
+				return
+			} else if len(users) > bulkUserConfirmThreshold {
+				ce.Meta.requestConfirmation(
+					ce.Ctx,
+					fmt.Sprintf("%d users matching %s found, are you sure you want to redact messages from all of them?", len(users), format.SafeMarkdownCode(ce.Args[0])),
+					func(ctx context.Context, _ id.UserID) {
+						ce.Ctx = ctx
+						redactUsers(ce, users, maxAge, limit, reason)
+					},
+				)
+				return
+			}
+			redactUsers(ce, users, maxAge, limit, reason)
 			return
 		}
 		var target *id.MatrixURI
@@ -223,14 +357,40 @@ var cmdRedact = &CommandHandler{
 			}
 		}
 		reason := strings.Join(ce.Args[1:], " ")
-		if target.Sigil1 == '@' {
+		if target.Sigil1 == '@' && (maxAge > 0 || limit > 0) {
+			ce.Meta.RedactUserFiltered(ce.Ctx, target.UserID(), maxAge, limit, reason)
+		} else if target.Sigil1 == '@' {
+			if !confirmCount {
+				count, ok := ce.Meta.countEventsToRedact(ce.Ctx, target.UserID())
+				if !ok {
+					ce.Reply(
+						"Couldn't count events from %s in protected rooms. Redactions can't be undone, use `%sredact --confirm-count %s` to redact all of them.",
+						format.SafeMarkdownCode(target.UserID()), ce.Meta.commandPrefix(), target.UserID(),
+					)
+					return
+				} else if count > redactConfirmThreshold {
+					ce.Reply(
+						"Found %d events from %s in protected rooms. Redactions can't be undone, use `%sredact --confirm-count %s` to redact all of them.",
+						count, format.SafeMarkdownCode(target.UserID()), ce.Meta.commandPrefix(), target.UserID(),
+					)
+					return
+				}
+			}
 			ce.Meta.RedactUser(ce.Ctx, target.UserID(), reason, false)
 		} else if target.Sigil1 == '!' && target.Sigil2 == '$' {
-			_, err = ce.Meta.Bot.RedactEvent(ce.Ctx, target.RoomID(), target.EventID(), mautrix.ReqRedact{Reason: reason})
+			relatedCount, err := ce.Meta.redactEventAndEdits(ce.Ctx, target.RoomID(), target.EventID(), reason)
 			if err != nil {
 				ce.Reply("Failed to redact event %s: %v", format.SafeMarkdownCode(target.EventID()), err)
 				return
+			} else if relatedCount > 0 {
+				ce.Reply("Redacted event%s", formatRelatedRedactions(relatedCount))
 			}
+			ce.Meta.logAction(ce.Ctx, &database.AuditLogEntry{
+				Action:      database.AuditLogActionRedact,
+				TargetEvent: target.EventID(),
+				InRoomID:    target.RoomID(),
+				Reason:      reason,
+			})
 		} else {
 			ce.Reply("Invalid target %s (must be a user ID or event link)", format.SafeMarkdownCode(ce.Args[0]))
 			return
@@ -239,11 +399,22 @@ var cmdRedact = &CommandHandler{
 	},
 }
 
+func redactUsers(ce *CommandEvent, users []id.UserID, maxAge time.Duration, limit int, reason string) {
+	for _, userID := range users {
+		if maxAge > 0 || limit > 0 {
+			ce.Meta.RedactUserFiltered(ce.Ctx, userID, maxAge, limit, reason)
+		} else {
+			ce.Meta.RedactUser(ce.Ctx, userID, reason, false)
+		}
+	}
+	ce.React(SuccessReaction)
+}
+
 var cmdRedactRecent = &CommandHandler{
 	Name: "redact-recent",
 	Func: func(ce *CommandEvent) {
 		if len(ce.Args) < 2 {
-			ce.Reply("Usage: `!redact-recent <room ID> <since duration> [reason]`")
+			replyUsage(ce)
 			return
 		}
 		room := resolveRoom(ce, ce.Args[0])
@@ -254,67 +425,317 @@ var cmdRedactRecent = &CommandHandler{
 		if err != nil {
 			ce.Reply("Invalid duration %s: %v", format.SafeMarkdownCode(ce.Args[1]), err)
 			return
+		} else if since <= 0 {
+			ce.Reply("Duration must be positive")
+			return
 		}
 		reason := strings.Join(ce.Args[2:], " ")
-		redactedCount, err := ce.Meta.redactRecentMessages(ce.Ctx, room, "", since, false, reason)
+		redactedCount, err := ce.Meta.redactRecentMessages(ce.Ctx, room, "", since, false, 0, reason)
 		if err != nil {
 			ce.Reply("Failed to redact recent messages: %v", err)
 			return
 		}
+		if redactedCount > 0 {
+			ce.Meta.logRedaction(ce.Ctx, "", room, reason)
+		}
 		ce.Reply("Redacted %d messages", redactedCount)
 		ce.React(SuccessReaction)
 	},
 }
 
+var cmdRedactEvent = &CommandHandler{
+	Name: "redact-event",
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) < 1 {
+			replyUsage(ce)
+			return
+		}
+		var target *id.MatrixURI
+		var reason string
+		if strings.HasPrefix(ce.Args[0], "!") || strings.HasPrefix(ce.Args[0], "#") {
+			if len(ce.Args) < 2 || !strings.HasPrefix(ce.Args[1], "$") {
+				replyUsage(ce)
+				return
+			}
+			room := resolveRoom(ce, ce.Args[0])
+			if room == "" {
+				return
+			}
+			target = room.EventURI(id.EventID(ce.Args[1]))
+			reason = strings.Join(ce.Args[2:], " ")
+		} else {
+			var err error
+			target, err = id.ParseMatrixURIOrMatrixToURL(ce.Args[0])
+			if err != nil || target.Sigil1 != '!' || target.Sigil2 != '$' {
+				ce.Reply("Invalid event link %s", format.SafeMarkdownCode(ce.Args[0]))
+				return
+			}
+			reason = strings.Join(ce.Args[1:], " ")
+		}
+		var pls event.PowerLevelsEventContent
+		err := ce.Meta.Bot.StateEvent(ce.Ctx, target.RoomID(), event.StatePowerLevels, "", &pls)
+		if err != nil {
+			ce.Reply("Failed to get power levels in %s: %v", format.SafeMarkdownCode(target.RoomID()), err)
+			ce.React(FailureReaction)
+			return
+		} else if pls.GetUserLevel(ce.Meta.Bot.UserID) < pls.Redact() {
+			ce.Reply("The bot doesn't have permission to redact events in %s", format.SafeMarkdownCode(target.RoomID()))
+			ce.React(FailureReaction)
+			return
+		}
+		if redactLinkedEvent(ce, target, reason) {
+			ce.React(SuccessReaction)
+		} else {
+			ce.React(FailureReaction)
+		}
+	},
+}
+
 var cmdKick = &CommandHandler{
 	Name: "kick",
 	Func: func(ce *CommandEvent) {
+		var onlyRooms []id.RoomID
+		for roomIdx := slices.Index(ce.Args, "--room"); roomIdx >= 0 && roomIdx+1 < len(ce.Args); roomIdx = slices.Index(ce.Args, "--room") {
+			roomID := resolveRoom(ce, ce.Args[roomIdx+1])
+			if roomID == "" {
+				return
+			} else if !ce.Meta.IsProtectedRoom(roomID) {
+				ce.Reply("%s is not a protected room", format.SafeMarkdownCode(ce.Args[roomIdx+1]))
+				return
+			}
+			onlyRooms = append(onlyRooms, roomID)
+			ce.Args = slices.Delete(ce.Args, roomIdx, roomIdx+2)
+		}
+		exact := slices.Contains(ce.Args, "--exact")
+		if exact {
+			ce.Args = slices.DeleteFunc(ce.Args, func(arg string) bool { return arg == "--exact" })
+		}
+		force := slices.Contains(ce.Args, "--force")
+		if force {
+			ce.Args = slices.DeleteFunc(ce.Args, func(arg string) bool { return arg == "--force" })
+		}
 		if len(ce.Args) < 1 {
-			ce.Reply("Usage: `!kick <user ID> [reason]`")
+			replyUsage(ce)
 			return
 		}
-		ignoreUserLimit := ce.Args[0] == "--force"
-		if ignoreUserLimit {
-			ce.Args = ce.Args[1:]
-		}
-		pattern := glob.Compile(ce.Args[0])
+		pattern := compileUserPattern(ce.Args[0], exact)
 		reason := strings.Join(ce.Args[1:], " ")
 		users := slices.Collect(ce.Meta.findMatchingUsers(pattern, nil, true))
-		if len(users) > 10 && !ignoreUserLimit {
-			// TODO replace the force flag with a reaction confirmation
-			ce.Reply("%d users matching %s found, use `--force` to kick all of them.", len(users), format.SafeMarkdownCode(ce.Args[0]))
-			return
+		if !force {
+			var exempt []id.UserID
+			users, exempt = ce.Meta.filterExemptUsers(ce.Ctx, users)
+			if len(exempt) > 0 {
+				ce.Reply("%s", ce.Meta.formatExemptUsers(exempt))
+			}
 		}
-		for _, userID := range users {
-			successCount := 0
-			rooms := ce.Meta.getRoomsUserIsIn(userID)
-			if len(rooms) == 0 {
-				continue
+		// Exact user IDs aren't filtered, as kickUser reports the specified rooms the user isn't in
+		if _, isExact := pattern.(glob.ExactGlob); onlyRooms != nil && !isExact {
+			users = slices.DeleteFunc(users, func(userID id.UserID) bool {
+				return len(ce.Meta.getKickTargetRooms(userID, onlyRooms)) == 0
+			})
+		}
+		if len(users) == 0 {
+			if onlyRooms != nil {
+				ce.Reply("No users matching %s found in the specified rooms", format.SafeMarkdownCode(ce.Args[0]))
+			} else {
+				ce.Reply("No users matching %s found in any rooms", format.SafeMarkdownCode(ce.Args[0]))
 			}
-			roomStrings := make([]string, len(rooms))
-			for i, room := range rooms {
-				roomStrings[i] = fmt.Sprintf("[%s](%s)", room, room.URI().MatrixToURL())
-				var err error
-				if !ce.Meta.DryRun {
-					_, err = ce.Meta.Bot.KickUser(ce.Ctx, room, &mautrix.ReqKickUser{
-						Reason: reason,
-						UserID: userID,
-					})
+			return
+		} else if len(users) > bulkUserConfirmThreshold {
+			ce.Meta.requestConfirmation(
+				ce.Ctx,
+				fmt.Sprintf("%d users matching %s found, are you sure you want to kick all of them?", len(users), format.SafeMarkdownCode(ce.Args[0])),
+				func(ctx context.Context, _ id.UserID) {
+					ce.Ctx = ctx
+					kickUsers(ce, users, onlyRooms, reason)
+				},
+			)
+			return
+		}
+		kickUsers(ce, users, onlyRooms, reason)
+	},
+}
+
+// getKickTargetRooms returns the protected rooms the given user should be kicked from.
+// If onlyRooms is nil, that's all rooms the user is in, otherwise it's the given rooms that the user is in.
+func (pe *PolicyEvaluator) getKickTargetRooms(userID id.UserID, onlyRooms []id.RoomID) []id.RoomID {
+	rooms := pe.getRoomsUserIsIn(userID)
+	if onlyRooms != nil {
+		rooms = slices.DeleteFunc(rooms, func(roomID id.RoomID) bool {
+			return !slices.Contains(onlyRooms, roomID)
+		})
+	}
+	return rooms
+}
+
+const bulkKickProgressInterval = 5 * time.Second
+
+// bulkUserConfirmThreshold is the number of users matching a glob above which commands require confirmation.
+const bulkUserConfirmThreshold = 10
+
+func kickUsers(ce *CommandEvent, users []id.UserID, onlyRooms []id.RoomID, reason string) {
+	if len(users) == 1 {
+		kickUser(ce, users[0], onlyRooms, reason)
+		ce.React(SuccessReaction)
+		return
+	}
+	concurrency := max(ce.Meta.BulkKick.Concurrency, 1)
+	delay := time.Duration(ce.Meta.BulkKick.DelayMS) * time.Millisecond
+	progressEventID := ce.Reply("Kicking %d users...", len(users))
+
+	var processed, kickedRooms, failedRooms atomic.Int64
+	var failuresLock sync.Mutex
+	var failures []string
+	queue := make(chan id.UserID)
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for range concurrency {
+		go func() {
+			defer wg.Done()
+			for userID := range queue {
+				rooms := ce.Meta.getKickTargetRooms(userID, onlyRooms)
+				if len(rooms) > 0 {
+					kicked, failed := ce.Meta.kickFromRooms(ce.Ctx, userID, rooms, reason)
+					kickedRooms.Add(int64(len(kicked)))
+					failedRooms.Add(int64(len(failed)))
+					if len(failed) > 0 {
+						failuresLock.Lock()
+						for room, err := range failed {
+							failures = append(failures, fmt.Sprintf("* %s in %s: %v", format.SafeMarkdownCode(userID), format.SafeMarkdownCode(room), err))
+						}
+						failuresLock.Unlock()
+					}
+					if delay > 0 {
+						time.Sleep(delay)
+					}
 				}
-				if err != nil {
-					ce.Reply("Failed to kick %s from %s: %v", format.SafeMarkdownCode(userID), format.SafeMarkdownCode(room), err)
-				} else {
-					successCount++
+				processed.Add(1)
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(bulkKickProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if progressEventID != "" {
+					ce.Meta.Bot.EditNotice(
+						ce.Ctx, ce.RoomID, progressEventID, "Kicking users: processed %d/%d, kicked from %d rooms, %d failures...",
+						processed.Load(), len(users), kickedRooms.Load(), failedRooms.Load(),
+					)
 				}
+			case <-done:
+				return
 			}
-			ce.Reply("Kicked %s from %d rooms: %s", format.SafeMarkdownCode(userID), successCount, strings.Join(roomStrings, ", "))
 		}
-		if len(users) == 0 {
-			ce.Reply("No users matching %s found in any rooms", format.SafeMarkdownCode(ce.Args[0]))
-			return
+	}()
+	for _, userID := range users {
+		queue <- userID
+	}
+	close(queue)
+	wg.Wait()
+	close(done)
+
+	summary := fmt.Sprintf(
+		"Finished kicking %d users: kicked from %d rooms, %d failures",
+		len(users), kickedRooms.Load(), failedRooms.Load(),
+	)
+	if progressEventID != "" {
+		ce.Meta.Bot.EditNotice(ce.Ctx, ce.RoomID, progressEventID, summary)
+	} else {
+		ce.Reply(summary)
+	}
+	if len(failures) > 0 {
+		slices.Sort(failures)
+		if len(failures) > maxPreviewedUsers {
+			failures = append(failures[:maxPreviewedUsers], fmt.Sprintf("* ...and %d more", len(failures)-maxPreviewedUsers))
 		}
-		ce.React(SuccessReaction)
-	},
+		ce.Reply("Failed kicks:\n\n%s", strings.Join(failures, "\n"))
+	}
+	ce.React(SuccessReaction)
+}
+
+func kickUser(ce *CommandEvent, userID id.UserID, onlyRooms []id.RoomID, reason string) {
+	rooms := ce.Meta.getKickTargetRooms(userID, onlyRooms)
+	if onlyRooms != nil {
+		kickUserFromRooms(ce, userID, rooms, onlyRooms, reason)
+		return
+	} else if len(rooms) == 0 {
+		return
+	}
+	kicked, failed := ce.Meta.kickFromRooms(ce.Ctx, userID, rooms, reason)
+	roomStrings := make([]string, len(rooms))
+	for i, room := range rooms {
+		roomStrings[i] = fmt.Sprintf("[%s](%s)", room, room.URI().MatrixToURL())
+		if err, ok := failed[room]; ok {
+			ce.Reply("Failed to kick %s from %s: %v", format.SafeMarkdownCode(userID), format.SafeMarkdownCode(room), err)
+		}
+	}
+	ce.Reply("Kicked %s from %d rooms: %s", format.SafeMarkdownCode(userID), len(kicked), strings.Join(roomStrings, ", "))
+}
+
+// kickUserFromRooms kicks a single user from the rooms specified with `--room` and reports the result for each room.
+func kickUserFromRooms(ce *CommandEvent, userID id.UserID, rooms, onlyRooms []id.RoomID, reason string) {
+	var kicked []id.RoomID
+	var failed map[id.RoomID]error
+	if len(rooms) > 0 {
+		kicked, failed = ce.Meta.kickFromRooms(ce.Ctx, userID, rooms, reason)
+	}
+	lines := make([]string, len(onlyRooms))
+	for i, roomID := range onlyRooms {
+		roomLink := fmt.Sprintf("[%s](%s)", format.EscapeMarkdown(ce.Meta.getProtectedRoomName(roomID)), roomID.URI().MatrixToURL())
+		if err, isFailed := failed[roomID]; isFailed {
+			lines[i] = fmt.Sprintf("* %s: failed to kick: %v", roomLink, err)
+		} else if slices.Contains(kicked, roomID) {
+			lines[i] = fmt.Sprintf("* %s: kicked", roomLink)
+		} else {
+			lines[i] = fmt.Sprintf("* %s: user is not in the room", roomLink)
+		}
+	}
+	ce.Reply("Kick results for %s:\n\n%s", format.SafeMarkdownCode(userID), strings.Join(lines, "\n"))
+}
+
+var knownRecommendations = map[string]event.PolicyRecommendation{
+	"ban":                                   event.PolicyRecommendationBan,
+	string(event.PolicyRecommendationBan):   event.PolicyRecommendationBan,
+	"unban":                                 event.PolicyRecommendationUnban,
+	string(event.PolicyRecommendationUnban): event.PolicyRecommendationUnban,
+	"takedown":                              event.PolicyRecommendationUnstableTakedown,
+	string(event.PolicyRecommendationUnstableTakedown): event.PolicyRecommendationUnstableTakedown,
+	"warn": policylist.UnstableRecommendationWarn,
+	string(policylist.UnstableRecommendationWarn): policylist.UnstableRecommendationWarn,
+}
+
+// parseRecommendation validates a recommendation given to a command. Known recommendations can be specified
+// using a short name, while custom recommendations are only allowed if enabled in the config and namespaced,
+// which prevents typos of the standard recommendations from being sent as custom ones.
+func (pe *PolicyEvaluator) parseRecommendation(ce *CommandEvent, input string) (event.PolicyRecommendation, bool) {
+	if rec, ok := knownRecommendations[strings.ToLower(input)]; ok {
+		return rec, true
+	} else if !pe.AllowCustomRecommendations {
+		ce.Reply(
+			"Unknown recommendation %s (custom recommendations are disabled). Known recommendations: %s",
+			format.SafeMarkdownCode(input), formatKnownRecommendations(),
+		)
+		return "", false
+	} else if !strings.Contains(input, ".") || strings.ContainsAny(input, " \t") {
+		ce.Reply(
+			"Custom recommendation %s must be namespaced (e.g. `com.example.something`). Known recommendations: %s",
+			format.SafeMarkdownCode(input), formatKnownRecommendations(),
+		)
+		return "", false
+	}
+	return event.PolicyRecommendation(input), true
+}
+
+func formatKnownRecommendations() string {
+	keys := slices.Sorted(maps.Keys(knownRecommendations))
+	for i, key := range keys {
+		keys[i] = format.SafeMarkdownCode(key)
+	}
+	return strings.Join(keys, ", ")
 }
 
 func (pe *PolicyEvaluator) deduplicatePolicy(
@@ -331,7 +752,7 @@ func (pe *PolicyEvaluator) deduplicatePolicy(
 	rec := match.Recommendations().BanOrUnban
 	if rec == nil {
 		return entityType, "", true
-	} else if rec.Recommendation == policy.Recommendation && rec.EntityOrHash() == policy.EntityOrHash() {
+	} else if rec.Recommendation == policy.Recommendation && isSamePolicyEntity(rec, policy) {
 		if rec.Reason == policy.Reason {
 			ce.Reply(
 				"%s already has a %s recommendation in [%s](%s) for %s (sent by [%s](%s) at %s)",
@@ -365,120 +786,918 @@ func (pe *PolicyEvaluator) deduplicatePolicy(
 	}
 }
 
-var cmdBan = &CommandHandler{
-	Name:    "ban",
-	Aliases: []string{"takedown"},
+// isSamePolicyEntity checks whether an existing policy targets the same entity as new policy content.
+// Plaintext entities and their hashes are considered the same, so that banning an entity
+// doesn't create a duplicate policy if it's already banned by hash or vice versa.
+func isSamePolicyEntity(existing *policylist.Policy, policy *event.ModPolicyContent) bool {
+	if existing.EntityOrHash() == policy.EntityOrHash() {
+		return true
+	} else if policy.Entity == "" {
+		return false
+	} else if existing.Entity != "" {
+		return existing.Entity == policy.Entity
+	}
+	return existing.EntityHash != nil && *existing.EntityHash == util.SHA256String(policy.Entity)
+}
+
+var cmdMute = &CommandHandler{
+	Name:    "mute",
+	Aliases: []string{"unmute"},
 	Func: func(ce *CommandEvent) {
-		if len(ce.Args) < 2 {
-			ce.Reply("Usage: `%s [--hash] <list shortcode> <entity> [reason]`", ce.Command)
+		if len(ce.Args) < 1 {
+			replyUsage(ce)
 			return
 		}
-		hash := ce.Args[0] == "--hash"
-		if hash {
-			ce.Args = ce.Args[1:]
+		mute := ce.Command == "mute"
+		force := slices.Contains(ce.Args, "--force")
+		if force {
+			ce.Args = slices.DeleteFunc(ce.Args, func(arg string) bool { return arg == "--force" })
 		}
-		list := ce.Meta.FindListByShortcode(ce.Args[0])
-		if list == nil {
-			ce.Reply("List %s not found", format.SafeMarkdownCode(ce.Args[0]))
+		if len(ce.Args) < 1 {
+			replyUsage(ce)
 			return
 		}
-		policy := &event.ModPolicyContent{
-			Entity:         ce.Args[1],
-			Reason:         strings.Join(ce.Args[2:], " "),
-			Recommendation: event.PolicyRecommendationBan,
-		}
-		if hash {
-			targetHash := util.SHA256String(policy.Entity)
-			policy.UnstableHashes = &event.PolicyHashes{
-				SHA256: base64.StdEncoding.EncodeToString(targetHash[:]),
+		pattern := glob.Compile(ce.Args[0])
+		reason := strings.Join(ce.Args[1:], " ")
+		users := slices.Collect(ce.Meta.findMatchingUsers(pattern, nil, true))
+		if mute && !force {
+			var exempt []id.UserID
+			users, exempt = ce.Meta.filterExemptUsers(ce.Ctx, users)
+			if len(exempt) > 0 {
+				ce.Reply("%s", ce.Meta.formatExemptUsers(exempt))
 			}
 		}
-		if ce.Command == "takedown" {
-			policy.Recommendation = event.PolicyRecommendationUnstableTakedown
-		}
-		entityType, existingStateKey, ok := ce.Meta.deduplicatePolicy(ce, list, policy)
-		if !ok {
+		if len(users) == 0 {
+			ce.Reply("No users matching %s found in any rooms", format.SafeMarkdownCode(ce.Args[0]))
 			return
-		}
-		target := policy.Entity
-		if hash {
-			policy.Entity = ""
-		}
-		resp, err := ce.Meta.SendPolicy(ce.Ctx, list.RoomID, entityType, existingStateKey, target, policy)
-		if err != nil {
-			ce.Reply("Failed to send ban policy: %v", err)
+		} else if len(users) > bulkUserConfirmThreshold {
+			ce.Meta.requestConfirmation(
+				ce.Ctx,
+				fmt.Sprintf("%d users matching %s found, are you sure you want to %s all of them?", len(users), format.SafeMarkdownCode(ce.Args[0]), ce.Command),
+				func(ctx context.Context, _ id.UserID) {
+					ce.Ctx = ctx
+					muteUsers(ce, users, mute, reason)
+				},
+			)
 			return
 		}
-		zerolog.Ctx(ce.Ctx).Info().
-			Stringer("policy_list", list.RoomID).
-			Any("policy", policy).
-			Stringer("policy_event_id", resp.EventID).
-			Msg("Sent ban policy from command")
-		ce.React(SuccessReaction)
+		muteUsers(ce, users, mute, reason)
 	},
 }
 
-var cmdRemovePolicy = &CommandHandler{
-	Name:    "remove-policy",
-	Aliases: []string{"remove-ban", "remove-unban"},
+func muteUsers(ce *CommandEvent, users []id.UserID, mute bool, reason string) {
+	action := database.AuditLogActionMute
+	verb := "Muted"
+	if !mute {
+		action = database.AuditLogActionUnmute
+		verb = "Unmuted"
+	}
+	if ce.Meta.DryRun {
+		verb = "Dry run: would " + strings.ToLower(verb[:len(verb)-1])
+	}
+	for _, userID := range users {
+		successCount := 0
+		rooms := ce.Meta.getRoomsUserIsIn(userID)
+		for _, room := range rooms {
+			err := ce.Meta.setUserMuted(ce.Ctx, room, userID, mute)
+			if errors.Is(err, errAlreadyMuted) || errors.Is(err, errNotMuted) {
+				continue
+			} else if err != nil {
+				ce.Reply("Failed to %s %s in %s: %v", ce.Command, format.SafeMarkdownCode(userID), format.SafeMarkdownCode(room), err)
+				continue
+			}
+			successCount++
+			ce.Meta.logAction(ce.Ctx, &database.AuditLogEntry{
+				Action:     action,
+				TargetUser: userID,
+				InRoomID:   room,
+				Reason:     reason,
+			})
+		}
+		ce.Reply("%s %s in %d/%d rooms", verb, format.SafeMarkdownCode(userID), successCount, len(rooms))
+	}
+	ce.React(SuccessReaction)
+}
+
+var cmdBan = &CommandHandler{
+	Name:    "ban",
+	Aliases: []string{"takedown"},
 	Func: func(ce *CommandEvent) {
-		if len(ce.Args) < 2 {
-			ce.Reply("Usage: `!remove-policy <list> <entity>`")
-			return
+		var internalNote string
+		if noteIdx := slices.Index(ce.Args, "--internal-note"); noteIdx >= 0 {
+			internalNote = strings.Join(ce.Args[noteIdx+1:], " ")
+			ce.Args = ce.Args[:noteIdx]
 		}
-		list := ce.Meta.FindListByShortcode(ce.Args[0])
-		if list == nil {
-			ce.Reply("List %s not found", format.SafeMarkdownCode(ce.Args[0]))
-			return
+		var recommendation event.PolicyRecommendation
+		if recIdx := slices.Index(ce.Args, "--rec"); recIdx >= 0 {
+			if ce.Command != "ban" || recIdx+1 >= len(ce.Args) {
+				replyUsage(ce)
+				return
+			}
+			var ok bool
+			recommendation, ok = ce.Meta.parseRecommendation(ce, ce.Args[recIdx+1])
+			if !ok {
+				return
+			}
+			ce.Args = slices.Delete(ce.Args, recIdx, recIdx+2)
 		}
-		target := ce.Args[1]
-		entityType, ok := validateEntity(target)
-		if !ok {
-			ce.Reply("Invalid entity %s", format.SafeMarkdownCode(target))
-			return
+		redactEvent := slices.Contains(ce.Args, "--redact-event")
+		if redactEvent {
+			ce.Args = slices.DeleteFunc(ce.Args, func(arg string) bool { return arg == "--redact-event" })
 		}
-		var existingStateKey string
-		var match policylist.Match
-		if hashEntity, ok := util.DecodeBase64Hash(target); ok {
-			match = ce.Meta.Store.MatchHash([]id.RoomID{list.RoomID}, entityType, *hashEntity)
-		} else {
-			match = ce.Meta.Store.MatchExact([]id.RoomID{list.RoomID}, entityType, target)
+		redactMessages := slices.Contains(ce.Args, "--redact")
+		if redactMessages {
+			ce.Args = slices.DeleteFunc(ce.Args, func(arg string) bool { return arg == "--redact" })
 		}
-		if len(match) == 0 {
-			ce.Reply("No rule banning %s found in [%s](%s)", format.SafeMarkdownCode(target), format.EscapeMarkdown(list.Name), list.RoomID.URI().MatrixToURL())
-			return
+		exact := slices.Contains(ce.Args, "--exact")
+		if exact {
+			ce.Args = slices.DeleteFunc(ce.Args, func(arg string) bool { return arg == "--exact" })
 		}
-		if rec := match.Recommendations().BanOrUnban; rec != nil {
-			existingStateKey = rec.StateKey
-			// TODO: handle wildcards and multiple matches, etc
-			if ce.Command == "remove-unban" && rec.Recommendation != event.PolicyRecommendationUnban {
-				ce.Reply("%s does not have an unban recommendation", format.SafeMarkdownCode(target))
-				return
-			} else if ce.Command == "remove-ban" && rec.Recommendation != event.PolicyRecommendationBan {
-				ce.Reply("%s does not have a ban recommendation", format.SafeMarkdownCode(target))
+		var expiry time.Time
+		if durIdx := slices.Index(ce.Args, "--duration"); durIdx >= 0 && durIdx+1 < len(ce.Args) {
+			duration, err := util.ParseDuration(ce.Args[durIdx+1])
+			if err != nil || duration <= 0 {
+				ce.Reply("Invalid duration %s", format.SafeMarkdownCode(ce.Args[durIdx+1]))
 				return
 			}
+			expiry = time.Now().Add(duration)
+			ce.Args = slices.Delete(ce.Args, durIdx, durIdx+2)
 		}
-		policy := &event.ModPolicyContent{}
-		resp, err := ce.Meta.SendPolicy(ce.Ctx, list.RoomID, entityType, existingStateKey, target, policy)
-		if err != nil {
-			ce.Reply("Failed to remove policy: %v", err)
+		if len(ce.Args) < 2 {
+			replyUsage(ce)
 			return
 		}
-		zerolog.Ctx(ce.Ctx).Info().
-			Stringer("policy_list", list.RoomID).
-			Any("policy", policy).
-			Stringer("policy_event_id", resp.EventID).
-			Msg("Removed policy from command")
-		ce.React(SuccessReaction)
-	},
-}
-
+		confirmHash := ce.Args[0] == "--confirm-hash"
+		hash := ce.Args[0] == "--hash" || confirmHash
+		if hash {
+			ce.Args = ce.Args[1:]
+		}
+		regex := ce.Args[0] == "--regex"
+		if regex {
+			ce.Args = ce.Args[1:]
+			if hash || exact || len(ce.Args) < 2 {
+				replyUsage(ce)
+				return
+			}
+		}
+		list := ce.Meta.FindListByShortcode(ce.Args[0])
+		if list == nil {
+			ce.Reply("List %s not found", format.SafeMarkdownCode(ce.Args[0]))
+			return
+		}
+		var linkedEvent *id.MatrixURI
+		if !regex && isMatrixLink(ce.Args[1]) {
+			if uri, err := id.ParseMatrixURIOrMatrixToURL(ce.Args[1]); err == nil && uri.Sigil2 != '$' {
+				ce.Args[1] = uri.PrimaryIdentifier()
+			} else {
+				var sender id.UserID
+				sender, linkedEvent = ce.Meta.getLinkedEventSender(ce, ce.Args[1])
+				if sender == "" {
+					return
+				}
+				ce.Args[1] = sender.String()
+			}
+		}
+		entities := ce.Args[1:2]
+		// Regexes can contain anything, so only a single one is accepted
+		for i, arg := range ce.Args[2:] {
+			arg = normalizeIDArg(arg)
+			if _, isEntity := validateEntity(arg); !isEntity || regex {
+				break
+			}
+			ce.Args[2+i] = arg
+			entities = append(entities, arg)
+		}
+		var hashInputs map[string]string
+		if hash {
+			hashInputs = make(map[string]string)
+			// Allow banning hashes of known entities without having to know the plaintext entity
+			for i, entity := range entities {
+				if entityHash, ok := util.DecodeBase64Hash(entity); ok {
+					resolved, _, found := ce.Meta.resolveEntityHash(ce.Ctx, *entityHash)
+					if !found {
+						ce.Reply("No user, protected room or server found for hash %s", format.SafeMarkdownCode(entity))
+						return
+					}
+					entities[i] = resolved
+					hashInputs[entities[i]] = entity
+				} else if !exact && containsGlobWildcard(entity) {
+					// Hashed policies are matched by comparing hashes, so wildcards would never match anything
+					ce.Reply("Can't hash %s, only exact user IDs, room IDs and server names can be hashed", format.SafeMarkdownCode(entity))
+					return
+				}
+			}
+		}
+		if !regex {
+			for i, entity := range entities {
+				if entityType, _ := validateEntity(entity); entityType != policylist.EntityTypeUser {
+					continue
+				}
+				normalized, err := normalizeUserEntity(entity)
+				if err != nil {
+					ce.Reply("Invalid user ID %s: %v", format.SafeMarkdownCode(entity), err)
+					return
+				}
+				entities[i] = normalized
+			}
+		}
+		if exact && !hash && slices.ContainsFunc(entities, containsGlobWildcard) {
+			// Globs can't escape wildcards, so entities with literal wildcards are sent as escaped regex policies.
+			// Regex applies to the whole command, so refuse instead of turning other entities into regexes too.
+			if len(entities) > 1 {
+				ce.Reply(
+					"Entities with literal wildcards are sent as escaped regex policies, which only Meowlnir understands. " +
+						"Ban them separately from other entities to avoid sending all of them as regexes.",
+				)
+				return
+			}
+			entities[0] = regexp.QuoteMeta(entities[0])
+			regex = true
+		}
+		params := &banParams{
+			List:           list,
+			Reason:         expandReasonTemplate(list, strings.Join(ce.Args[1+len(entities):], " ")),
+			Recommendation: event.PolicyRecommendationBan,
+			Hash:           hash,
+			Regex:          regex,
+			Expiry:         expiry,
+			InternalNote:   internalNote,
+			Redact:         redactMessages,
+		}
+		if ce.Command == "takedown" {
+			params.Recommendation = event.PolicyRecommendationUnstableTakedown
+		} else if recommendation != "" {
+			params.Recommendation = recommendation
+		}
+		if confirmHash {
+			prompt := fmt.Sprintf(
+				"The %s policies will contain these hashes:\n\n%s",
+				format.SafeMarkdownCode(params.Recommendation), ce.Meta.describeEntityHashes(ce.Ctx, entities, hashInputs),
+			)
+			if ce.Meta.DryRun {
+				ce.Reply("%s", prompt)
+			} else {
+				ce.Meta.requestConfirmation(ce.Ctx, prompt+"\n\nAre you sure you want to send them?", func(ctx context.Context, _ id.UserID) {
+					ce.Ctx = ctx
+					sendBanPolicies(ce, entities, params)
+					if redactEvent && linkedEvent != nil {
+						redactLinkedEvent(ce, linkedEvent, params.Reason)
+					}
+				})
+				return
+			}
+		}
+		if !ce.Meta.DryRun && !hash && ce.Meta.WildcardBanConfirmThreshold > 0 {
+			if summary, total := ce.Meta.summarizeWildcardMatches(entities, regex); total > ce.Meta.WildcardBanConfirmThreshold {
+				ce.Meta.requestConfirmation(
+					ce.Ctx,
+					fmt.Sprintf(
+						"The %s policies would match %d users currently in protected rooms:\n\n%s\n\nAre you sure you want to send them?",
+						format.SafeMarkdownCode(params.Recommendation), total, summary,
+					),
+					func(ctx context.Context, _ id.UserID) {
+						ce.Ctx = ctx
+						sendBanPolicies(ce, entities, params)
+						if redactEvent && linkedEvent != nil {
+							redactLinkedEvent(ce, linkedEvent, params.Reason)
+						}
+					},
+				)
+				return
+			}
+		}
+		sendBanPolicies(ce, entities, params)
+		if redactEvent && linkedEvent != nil {
+			redactLinkedEvent(ce, linkedEvent, params.Reason)
+		}
+	},
+}
+
+// describeEntityHashes lists the hash that will be sent for each entity along with the user, protected room
+// or server it resolves to. Entities that were given as hashes are marked as unverifiable,
+// since the intended plaintext entity is unknown.
+func (pe *PolicyEvaluator) describeEntityHashes(ctx context.Context, entities []string, hashInputs map[string]string) string {
+	lines := make([]string, len(entities))
+	for i, entity := range entities {
+		entityHash := util.SHA256String(entity)
+		lines[i] = fmt.Sprintf(
+			"* %s → %s", format.SafeMarkdownCode(entity),
+			format.SafeMarkdownCode(base64.StdEncoding.EncodeToString(entityHash[:])),
+		)
+		if input, ok := hashInputs[entity]; ok {
+			lines[i] += fmt.Sprintf(" (warning: given as hash %s, can't verify that this is the intended entity)", format.SafeMarkdownCode(input))
+		} else if resolved, entityType, found := pe.resolveEntityHash(ctx, entityHash); !found {
+			lines[i] += " (doesn't match any known user, protected room or server)"
+		} else if entityType == policylist.EntityTypeUser {
+			lines[i] += fmt.Sprintf(" (known user [%s](%s))", resolved, id.UserID(resolved).URI().MatrixToURL())
+		} else if entityType == policylist.EntityTypeRoom {
+			lines[i] += fmt.Sprintf(" (protected room %s)", pe.formatRoomLink(id.RoomID(resolved)))
+		} else {
+			lines[i] += " (server with members in protected rooms)"
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// getLinkedEventSender fetches the event in a matrix.to or matrix: event link and returns its sender.
+// If the link is invalid or the event can't be fetched, the error has already been replied and the user ID is empty.
+func (pe *PolicyEvaluator) getLinkedEventSender(ce *CommandEvent, link string) (id.UserID, *id.MatrixURI) {
+	uri, err := id.ParseMatrixURIOrMatrixToURL(link)
+	if err != nil || uri.Sigil1 != '!' || uri.Sigil2 != '$' {
+		ce.Reply("Invalid event link %s", format.SafeMarkdownCode(link))
+		return "", nil
+	}
+	evt, err := pe.Bot.GetEvent(ce.Ctx, uri.RoomID(), uri.EventID())
+	if err != nil {
+		zerolog.Ctx(ce.Ctx).Err(err).
+			Stringer("room_id", uri.RoomID()).
+			Stringer("event_id", uri.EventID()).
+			Msg("Failed to fetch linked event for ban command")
+		ce.Reply("Failed to fetch event %s: %v\n\nPlease specify the user ID explicitly instead", format.SafeMarkdownCode(uri.EventID()), err)
+		return "", nil
+	}
+	return evt.Sender, uri
+}
+
+func redactLinkedEvent(ce *CommandEvent, target *id.MatrixURI, reason string) bool {
+	relatedCount, err := ce.Meta.redactEventAndEdits(ce.Ctx, target.RoomID(), target.EventID(), reason)
+	if err != nil {
+		ce.Reply("Failed to redact event %s: %v", format.SafeMarkdownCode(target.EventID()), err)
+		return false
+	}
+	if ce.Meta.DryRun {
+		ce.Reply("Dry run: would have redacted event%s", formatRelatedRedactions(relatedCount))
+	} else {
+		ce.Reply("Redacted event%s", formatRelatedRedactions(relatedCount))
+	}
+	ce.Meta.logAction(ce.Ctx, &database.AuditLogEntry{
+		Action:      database.AuditLogActionRedact,
+		TargetEvent: target.EventID(),
+		InRoomID:    target.RoomID(),
+		Reason:      reason,
+	})
+	return true
+}
+
+// summarizeWildcardMatches counts the users currently in protected rooms that match each wildcard entity.
+// Entities without wildcards are not included in the summary or the total. Regex entities are always counted.
+func (pe *PolicyEvaluator) summarizeWildcardMatches(entities []string, regex bool) (summary string, total int) {
+	var lines []string
+	for _, entity := range entities {
+		var pattern glob.Glob
+		if regex {
+			var err error
+			pattern, err = policylist.CompileEntityRegex(entity)
+			if err != nil || !strings.HasPrefix(strings.TrimPrefix(entity, "^"), "@") {
+				continue
+			}
+		} else if !strings.ContainsAny(entity, "*?") {
+			continue
+		} else {
+			switch entityType, _ := validateEntity(entity); entityType {
+			case policylist.EntityTypeUser:
+				pattern = glob.Compile(entity)
+			case policylist.EntityTypeServer:
+				pattern = glob.Compile("@*:" + entity)
+			default:
+				continue
+			}
+		}
+		var count int
+		for range pe.findMatchingUsers(pattern, nil, true) {
+			count++
+		}
+		total += count
+		lines = append(lines, fmt.Sprintf("* %s - %s", format.SafeMarkdownCode(entity), pluralize(count, "user")))
+	}
+	return strings.Join(lines, "\n"), total
+}
+
+func sendBanPolicies(ce *CommandEvent, entities []string, params *banParams) {
+	if len(entities) == 1 {
+		sent, err := ce.Meta.sendBanPolicy(ce, entities[0], params)
+		if err != nil {
+			ce.Reply("Failed to send ban policy: %v", err)
+			return
+		} else if !sent {
+			return
+		}
+		if !params.Expiry.IsZero() {
+			ce.Reply("Policy will expire at %s", params.Expiry.Format(time.RFC3339))
+		}
+		ce.React(SuccessReaction)
+		return
+	}
+	results := make([]string, len(entities))
+	var sentCount int
+	sentResult, summaryVerb := "sent", "Sent"
+	if ce.Meta.DryRun {
+		sentResult, summaryVerb = "would be sent", "Dry run: would have sent"
+	}
+	for i, entity := range entities {
+		sent, err := ce.Meta.sendBanPolicy(ce, entity, params)
+		if err != nil {
+			results[i] = fmt.Sprintf("* %s - failed: %v", format.SafeMarkdownCode(entity), err)
+		} else if !sent {
+			results[i] = fmt.Sprintf("* %s - skipped", format.SafeMarkdownCode(entity))
+		} else {
+			results[i] = fmt.Sprintf("* %s - %s", format.SafeMarkdownCode(entity), sentResult)
+			sentCount++
+		}
+	}
+	var expirySuffix string
+	if !params.Expiry.IsZero() {
+		expirySuffix = fmt.Sprintf(", expiring at %s", params.Expiry.Format(time.RFC3339))
+	}
+	ce.Reply(
+		"%s %d/%d %s policies to %s%s:\n\n%s",
+		summaryVerb, sentCount, len(entities), format.SafeMarkdownCode(params.Recommendation),
+		format.EscapeMarkdown(params.List.Name), expirySuffix, strings.Join(results, "\n"),
+	)
+	if sentCount > 0 {
+		ce.React(SuccessReaction)
+	}
+}
+
+var cmdBanServer = &CommandHandler{
+	Name: "ban-server",
+	Func: func(ce *CommandEvent) {
+		updateACL := slices.Contains(ce.Args, "--acl")
+		if updateACL {
+			ce.Args = slices.DeleteFunc(ce.Args, func(arg string) bool { return arg == "--acl" })
+		}
+		if len(ce.Args) < 2 {
+			replyUsage(ce)
+			return
+		}
+		list := ce.Meta.FindListByShortcode(ce.Args[0])
+		if list == nil {
+			ce.Reply("List %s not found", format.SafeMarkdownCode(ce.Args[0]))
+			return
+		}
+		server := ce.Args[1]
+		if entityType, _ := validateEntity(server); entityType != policylist.EntityTypeServer {
+			ce.Reply("%s is not a valid server name or glob", format.SafeMarkdownCode(server))
+			return
+		} else if glob.Compile(server).Match(ce.Meta.Bot.ServerName) {
+			ce.Reply("Refusing to ban %s as it matches the bot's own server", format.SafeMarkdownCode(server))
+			return
+		}
+		sent, err := ce.Meta.sendBanPolicy(ce, server, &banParams{
+			List:           list,
+			Reason:         expandReasonTemplate(list, strings.Join(ce.Args[2:], " ")),
+			Recommendation: event.PolicyRecommendationBan,
+		})
+		if err != nil {
+			ce.Reply("Failed to send ban policy: %v", err)
+			return
+		} else if !sent {
+			return
+		}
+		if updateACL {
+			results := ce.Meta.DenyServerInProtectedRooms(ce.Ctx, server)
+			if len(results) == 0 {
+				ce.Reply("No protected rooms to update server ACL in")
+			} else {
+				ce.Reply("Server ACL update results:\n\n%s", strings.Join(results, "\n"))
+			}
+		}
+		ce.React(SuccessReaction)
+	},
+}
+
+var cmdBanRoom = &CommandHandler{
+	Name: "ban-room",
+	Func: func(ce *CommandEvent) {
+		leave := slices.Contains(ce.Args, "--leave")
+		if leave {
+			ce.Args = slices.DeleteFunc(ce.Args, func(arg string) bool { return arg == "--leave" })
+		}
+		if len(ce.Args) < 2 {
+			replyUsage(ce)
+			return
+		}
+		list := ce.Meta.FindListByShortcode(ce.Args[0])
+		if list == nil {
+			ce.Reply("List %s not found", format.SafeMarkdownCode(ce.Args[0]))
+			return
+		}
+		roomID := resolveRoom(ce, ce.Args[1])
+		if roomID == "" {
+			return
+		} else if roomID == ce.Meta.ManagementRoom || ce.Meta.GetWatchedListMeta(roomID) != nil {
+			ce.Reply("Refusing to ban the management room or a watched policy list")
+			return
+		}
+		sent, err := ce.Meta.sendBanPolicy(ce, roomID.String(), &banParams{
+			List:           list,
+			Reason:         expandReasonTemplate(list, strings.Join(ce.Args[2:], " ")),
+			Recommendation: event.PolicyRecommendationBan,
+		})
+		if err != nil {
+			ce.Reply("Failed to send ban policy: %v", err)
+			return
+		} else if !sent {
+			return
+		}
+		isJoined := ce.Meta.Bot.StateStore.IsMembership(ce.Ctx, roomID, ce.Meta.Bot.UserID, event.MembershipJoin)
+		isProtected := ce.Meta.IsProtectedRoom(roomID)
+		switch {
+		case !isJoined:
+			ce.Reply("The bot is not in %s", format.SafeMarkdownCode(roomID))
+		case !leave:
+			if isProtected && ce.Meta.LeaveBannedRooms {
+				ce.Reply("The bot is in %s, which is a protected room. It will be unprotected and left automatically once the policy takes effect", format.SafeMarkdownCode(roomID))
+			} else if isProtected {
+				ce.Reply("The bot is in %s, which is a protected room. Leaving it will require confirmation once the policy takes effect, or use `--leave` to leave it now", format.SafeMarkdownCode(roomID))
+			} else {
+				ce.Reply("The bot is in %s. Use `--leave` to leave it", format.SafeMarkdownCode(roomID))
+			}
+		case ce.Meta.DryRun:
+			ce.Reply("Dry run: would have left %s", format.SafeMarkdownCode(roomID))
+		default:
+			if isProtected {
+				if err = ce.Meta.unprotectRoom(ce.Ctx, roomID); err != nil {
+					ce.Reply("Failed to remove %s from protected rooms: %v", format.SafeMarkdownCode(roomID), err)
+					return
+				}
+			}
+			_, err = ce.Meta.Bot.LeaveRoom(ce.Ctx, roomID)
+			if err != nil {
+				ce.Reply("Failed to leave %s: %v", format.SafeMarkdownCode(roomID), err)
+				return
+			} else if isProtected {
+				ce.Reply("Unprotected and left %s", format.SafeMarkdownCode(roomID))
+			} else {
+				ce.Reply("Left %s", format.SafeMarkdownCode(roomID))
+			}
+		}
+		ce.React(SuccessReaction)
+	},
+}
+
+var cmdSyncACL = &CommandHandler{
+	Name: "sync-acl",
+	Func: func(ce *CommandEvent) {
+		if !ce.Meta.ServerACL.Enabled {
+			ce.Reply("Server ACL management is disabled in the config")
+			return
+		}
+		changed, _ := ce.Meta.SyncACL(ce.Ctx)
+		if changed == 0 {
+			ce.Reply("Server ACLs are already up to date in all protected rooms")
+		}
+		ce.React(SuccessReaction)
+	},
+}
+
+type banParams struct {
+	List           *config.WatchedPolicyList
+	Reason         string
+	Recommendation event.PolicyRecommendation
+	Hash           bool
+	Regex          bool
+	Expiry         time.Time
+	InternalNote   string
+	Redact         bool
+}
+
+// sendBanPolicy sends a single policy for the ban command. If the policy is skipped
+// (e.g. due to a duplicate), the reason has already been replied and sent is false.
+func (pe *PolicyEvaluator) sendBanPolicy(ce *CommandEvent, entity string, params *banParams) (sent bool, err error) {
+	policy := &event.ModPolicyContent{
+		Entity:         entity,
+		Reason:         params.Reason,
+		Recommendation: params.Recommendation,
+	}
+	if params.Hash {
+		targetHash := util.SHA256String(policy.Entity)
+		policy.UnstableHashes = &event.PolicyHashes{
+			SHA256: base64.StdEncoding.EncodeToString(targetHash[:]),
+		}
+	}
+	var entityType policylist.EntityType
+	var existingStateKey string
+	var ok bool
+	if params.Regex {
+		entityType, ok = validateRegexEntity(ce, policy.Entity)
+	} else {
+		entityType, existingStateKey, ok = pe.deduplicatePolicy(ce, params.List, policy)
+	}
+	if !ok {
+		return false, nil
+	} else if err = pe.checkBanSafeguards(entityType, policy.Entity, policy.Recommendation, params.Regex); err != nil {
+		ce.Reply("Not sending policy: %v", err)
+		return false, nil
+	} else if pe.DryRun {
+		pe.previewBanPolicy(ce, entityType, policy, params)
+		return true, nil
+	}
+	target := policy.Entity
+	if params.Hash {
+		policy.Entity = ""
+	}
+	extra := make(map[string]any)
+	if !params.Expiry.IsZero() {
+		extra[policylist.UnstableExpiryKey] = params.Expiry.UnixMilli()
+	}
+	if params.Regex {
+		extra[policylist.UnstableRegexKey] = true
+	}
+	resp, err := pe.sendPolicyWithExtra(ce.Ctx, params.List.RoomID, entityType, existingStateKey, target, policy, extra)
+	if err != nil {
+		return false, err
+	}
+	zerolog.Ctx(ce.Ctx).Info().
+		Stringer("policy_list", params.List.RoomID).
+		Any("policy", policy).
+		Stringer("policy_event_id", resp.EventID).
+		Time("expiry", params.Expiry).
+		Msg("Sent ban policy from command")
+	pe.maybeSubmitUpstreamReport(ce.Ctx, entityType, policy, params.List.RoomID, resp.EventID)
+	if params.InternalNote != "" {
+		pe.addEntityNote(ce, policy.EntityOrHash(), params.InternalNote)
+	}
+	if params.Redact {
+		if counts, counted := pe.redactBannedUsers(ce, entityType, target, params); len(counts) > 0 {
+			ce.Reply("%s", formatBanRedactions(counts, counted))
+		}
+	}
+	return true, nil
+}
+
+// redactBannedUsers redacts recent messages in protected rooms from the users matching a newly sent user policy
+// and returns the number of redacted events per user. The boolean is false if some of the counts aren't known.
+// Server and room policies are ignored, as they don't target individual senders.
+func (pe *PolicyEvaluator) redactBannedUsers(ce *CommandEvent, entityType policylist.EntityType, entity string, params *banParams) (map[id.UserID]int, bool) {
+	if entityType != policylist.EntityTypeUser {
+		return nil, true
+	}
+	var users []id.UserID
+	if params.Regex {
+		// The regex was already validated, so compiling can't fail here
+		pattern, _ := policylist.CompileEntityRegex(entity)
+		users = slices.Collect(pe.findMatchingUsers(pattern, nil, false))
+	} else if strings.ContainsAny(entity, "*?") {
+		users = slices.Collect(pe.findMatchingUsers(glob.Compile(entity), nil, false))
+	} else {
+		users = []id.UserID{id.UserID(entity)}
+	}
+	counts := make(map[id.UserID]int, len(users))
+	allCounted := true
+	for _, userID := range users {
+		if pe.BanRedactWindow > 0 {
+			counts[userID] = pe.RedactUserFiltered(ce.Ctx, userID, pe.BanRedactWindow, 0, params.Reason)
+		} else {
+			var counted bool
+			counts[userID], counted = pe.RedactUser(ce.Ctx, userID, params.Reason, false)
+			allCounted = allCounted && counted
+		}
+	}
+	return counts, allCounted
+}
+
+func formatBanRedactions(counts map[id.UserID]int, counted bool) string {
+	var total int
+	for _, count := range counts {
+		total += count
+	}
+	output := fmt.Sprintf("Redacted %s from %s", pluralize(total, "event"), pluralize(len(counts), "user"))
+	if !counted {
+		output += " (events redacted using the admin API or in failed lookups aren't included)"
+	}
+	return output
+}
+
+const maxPreviewedUsers = 10
+
+// previewBanPolicy replies with the policy that would be sent and the users it would affect.
+// It's used instead of sending policies when dry run is enabled.
+func (pe *PolicyEvaluator) previewBanPolicy(ce *CommandEvent, entityType policylist.EntityType, policy *event.ModPolicyContent, params *banParams) {
+	list := params.List
+	var users []id.UserID
+	switch {
+	case params.Regex:
+		// The regex was already validated, so compiling can't fail here
+		pattern, _ := policylist.CompileEntityRegex(policy.Entity)
+		if entityType == policylist.EntityTypeUser {
+			users = slices.Collect(pe.findMatchingUsers(pattern, nil, true))
+		} else if entityType == policylist.EntityTypeServer {
+			for _, userID := range pe.getAllUsers() {
+				if pattern.Match(userID.Homeserver()) && len(pe.getRoomsUserIsIn(userID)) > 0 {
+					users = append(users, userID)
+				}
+			}
+		}
+	case entityType == policylist.EntityTypeUser:
+		hypothetical := &policylist.Policy{ModPolicyContent: policy, Pattern: glob.Compile(policy.Entity)}
+		users = slices.Collect(pe.findMatchingUsers(hypothetical.UserMatchPattern(), nil, true))
+	case entityType == policylist.EntityTypeServer:
+		users = slices.Collect(pe.findMatchingUsers(glob.Compile("@*:"+policy.Entity), nil, true))
+	}
+	var buf strings.Builder
+	_, _ = fmt.Fprintf(
+		&buf, "Dry run: would send %s policy for %s to %s with reason %s",
+		format.SafeMarkdownCode(policy.Recommendation), format.SafeMarkdownCode(policy.EntityOrHash()),
+		format.EscapeMarkdown(list.Name), format.SafeMarkdownCode(policy.Reason),
+	)
+	if entityType != policylist.EntityTypeRoom {
+		_, _ = fmt.Fprintf(&buf, "\n\nThe policy matches %s currently in protected rooms", pluralize(len(users), "user"))
+		if len(users) > 0 {
+			buf.WriteString(":\n\n")
+			for _, userID := range users[:min(len(users), maxPreviewedUsers)] {
+				_, _ = fmt.Fprintf(&buf, "* [%s](%s)\n", userID, userID.URI().MatrixToURL())
+			}
+			if len(users) > maxPreviewedUsers {
+				_, _ = fmt.Fprintf(&buf, "* ...and %d more\n", len(users)-maxPreviewedUsers)
+			}
+		}
+	}
+	ce.Reply("%s", buf.String())
+}
+
+var cmdRemovePolicy = &CommandHandler{
+	Name:    "remove-policy",
+	Aliases: []string{"remove-ban", "remove-unban"},
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) < 2 {
+			replyUsage(ce)
+			return
+		}
+		list := ce.Meta.FindListByShortcode(ce.Args[0])
+		if list == nil {
+			ce.Reply("List %s not found", format.SafeMarkdownCode(ce.Args[0]))
+			return
+		}
+		target := ce.Args[1]
+		entityType, ok := validateEntity(target)
+		if !ok {
+			ce.Reply("Invalid entity %s", format.SafeMarkdownCode(target))
+			return
+		}
+		listIDs := []id.RoomID{list.RoomID}
+		var match policylist.Match
+		if hashEntity, ok := util.DecodeBase64Hash(target); ok {
+			match = ce.Meta.Store.MatchHash(listIDs, entityType, *hashEntity)
+		} else if strings.ContainsAny(target, "*?") {
+			// Wildcard targets remove the wildcard rule itself rather than every rule the wildcard matches
+			match = ce.Meta.Store.MatchExact(listIDs, entityType, target)
+		} else {
+			switch entityType {
+			case policylist.EntityTypeUser:
+				match = ce.Meta.Store.MatchUser(listIDs, id.UserID(target))
+			case policylist.EntityTypeRoom:
+				match = ce.Meta.Store.MatchRoom(listIDs, id.RoomID(target))
+			case policylist.EntityTypeServer:
+				match = ce.Meta.Store.MatchServer(listIDs, target)
+			}
+		}
+		match = slices.DeleteFunc(match, func(policy *policylist.Policy) bool {
+			switch ce.Command {
+			case "remove-ban":
+				return policy.Recommendation != event.PolicyRecommendationBan
+			case "remove-unban":
+				return policy.Recommendation != event.PolicyRecommendationUnban
+			default:
+				return false
+			}
+		})
+		if len(match) == 0 {
+			var recType string
+			switch ce.Command {
+			case "remove-ban":
+				recType = "ban "
+			case "remove-unban":
+				recType = "unban "
+			}
+			ce.Reply("No %srule matching %s found in [%s](%s)", recType, format.SafeMarkdownCode(target), format.EscapeMarkdown(list.Name), list.RoomID.URI().MatrixToURL())
+			return
+		}
+		results := make([]string, len(match))
+		var removedCount int
+		for i, policy := range match {
+			resp, err := ce.Meta.RemovePolicy(ce.Ctx, policy)
+			if err != nil {
+				results[i] = fmt.Sprintf(
+					"* Failed to remove %s rule for %s: %v",
+					format.SafeMarkdownCode(policy.Recommendation), format.SafeMarkdownCode(policy.EntityOrHash()), err,
+				)
+				continue
+			}
+			zerolog.Ctx(ce.Ctx).Info().
+				Stringer("policy_list", list.RoomID).
+				Any("removed_policy", policy).
+				Stringer("policy_event_id", resp.EventID).
+				Msg("Removed policy from command")
+			results[i] = fmt.Sprintf(
+				"* Removed %s rule for %s (reason was %s)",
+				format.SafeMarkdownCode(policy.Recommendation), format.SafeMarkdownCode(policy.EntityOrHash()),
+				format.SafeMarkdownCode(policy.Reason),
+			)
+			removedCount++
+		}
+		ce.Reply("%s", strings.Join(results, "\n"))
+		if removedCount > 0 {
+			ce.React(SuccessReaction)
+		}
+	},
+}
+
+var cmdMovePolicy = &CommandHandler{
+	Name: "move-policy",
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) < 3 {
+			replyUsage(ce)
+			return
+		}
+		from := ce.Meta.FindListByShortcode(ce.Args[0])
+		if from == nil {
+			ce.Reply("List %s not found", format.SafeMarkdownCode(ce.Args[0]))
+			return
+		}
+		to := ce.Meta.FindListByShortcode(ce.Args[1])
+		if to == nil {
+			ce.Reply("List %s not found", format.SafeMarkdownCode(ce.Args[1]))
+			return
+		} else if from.RoomID == to.RoomID {
+			ce.Reply("Source and destination lists are the same")
+			return
+		}
+		target := ce.Args[2]
+		match := ce.Meta.findExactPolicies([]id.RoomID{from.RoomID}, target)
+		if len(match) == 0 {
+			ce.Reply("No rule for %s found in [%s](%s)", format.SafeMarkdownCode(target), format.EscapeMarkdown(from.Name), from.RoomID.URI().MatrixToURL())
+			return
+		}
+		results := make([]string, len(match))
+		for i, policy := range match {
+			if ce.Meta.DryRun {
+				results[i] = fmt.Sprintf(
+					"* Dry run: would move %s rule for %s from %s to %s",
+					format.SafeMarkdownCode(policy.Recommendation), format.SafeMarkdownCode(policy.EntityOrHash()),
+					format.EscapeMarkdown(from.Name), format.EscapeMarkdown(to.Name),
+				)
+				continue
+			}
+			resp, err := ce.Meta.movePolicy(ce.Ctx, policy, to)
+			if err != nil {
+				results[i] = fmt.Sprintf(
+					"* Failed to move %s rule for %s: %v",
+					format.SafeMarkdownCode(policy.Recommendation), format.SafeMarkdownCode(policy.EntityOrHash()), err,
+				)
+				continue
+			}
+			results[i] = fmt.Sprintf(
+				"* Moved %s rule for %s to %s ([new event](%s))",
+				format.SafeMarkdownCode(policy.Recommendation), format.SafeMarkdownCode(policy.EntityOrHash()),
+				format.EscapeMarkdown(to.Name), to.RoomID.EventURI(resp.EventID).MatrixToURL(),
+			)
+		}
+		ce.Reply("%s", strings.Join(results, "\n"))
+	},
+}
+
+// movePolicy sends an equivalent copy of the given policy to another list and then removes the original.
+// The original is only removed if sending the copy succeeds.
+func (pe *PolicyEvaluator) movePolicy(ctx context.Context, policy *policylist.Policy, to *config.WatchedPolicyList) (*mautrix.RespSendEvent, error) {
+	content := *policy.ModPolicyContent
+	resp, err := pe.sendPolicyWithExtra(ctx, to.RoomID, policy.EntityType, policy.StateKey, policy.EntityOrHash(), &content, policyExtra(policy))
+	if err != nil {
+		return nil, fmt.Errorf("failed to send policy to destination list: %w", err)
+	}
+	_, err = pe.RemovePolicy(ctx, policy)
+	if err != nil {
+		return resp, fmt.Errorf("sent policy to destination list, but failed to remove original: %w", err)
+	}
+	zerolog.Ctx(ctx).Info().
+		Stringer("from_policy_list", policy.RoomID).
+		Stringer("to_policy_list", to.RoomID).
+		Any("policy", policy).
+		Stringer("policy_event_id", resp.EventID).
+		Msg("Moved policy")
+	return resp, nil
+}
+
+// policyExtra returns the unstable extra fields of the given policy, so that it can be resent without losing them.
+func policyExtra(policy *policylist.Policy) map[string]any {
+	extra := make(map[string]any)
+	if policy.Expiry != 0 {
+		extra[policylist.UnstableExpiryKey] = policy.Expiry
+	}
+	if policy.IsRegex {
+		extra[policylist.UnstableRegexKey] = true
+	}
+	if policy.BanSender {
+		extra[policylist.UnstableBanSenderKey] = true
+	}
+	return extra
+}
+
 var cmdAddUnban = &CommandHandler{
 	Name: "add-unban",
 	Func: func(ce *CommandEvent) {
 		if len(ce.Args) < 2 {
-			ce.Reply("Usage: `!add-unban <list shortcode> <entity> <reason>`")
+			replyUsage(ce)
 			return
 		}
 		list := ce.Meta.FindListByShortcode(ce.Args[0])
@@ -509,20 +1728,115 @@ var cmdAddUnban = &CommandHandler{
 	},
 }
 
+var cmdUnban = &CommandHandler{
+	Name: "unban",
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) < 2 {
+			replyUsage(ce)
+			return
+		}
+		list := ce.Meta.FindListByShortcode(ce.Args[0])
+		if list == nil {
+			ce.Reply("List %s not found", format.SafeMarkdownCode(ce.Args[0]))
+			return
+		}
+		target := ce.Args[1]
+		entityType, ok := validateEntity(target)
+		if !ok {
+			ce.Reply("Invalid entity %s", format.SafeMarkdownCode(target))
+			return
+		}
+		match := ce.Meta.Store.MatchExact([]id.RoomID{list.RoomID}, entityType, target)
+		if len(match) == 0 {
+			ce.Reply("No ban policy for %s found in %s", format.SafeMarkdownCode(target), format.EscapeMarkdown(list.Name))
+		}
+		// The store isn't updated until the removals come back through sync, so track them to exclude them below
+		removed := make(map[*policylist.Policy]struct{}, len(match))
+		for _, policy := range match {
+			switch policy.Recommendation {
+			case event.PolicyRecommendationUnban:
+				ce.Reply("%s already has an unban recommendation in %s", format.SafeMarkdownCode(target), format.EscapeMarkdown(list.Name))
+				continue
+			case event.PolicyRecommendationBan, event.PolicyRecommendationUnstableTakedown:
+			default:
+				continue
+			}
+			resp, err := ce.Meta.RemovePolicy(ce.Ctx, policy)
+			if err != nil {
+				ce.Reply("Failed to remove %s policy for %s: %v", format.SafeMarkdownCode(policy.Recommendation), format.SafeMarkdownCode(policy.EntityOrHash()), err)
+				return
+			}
+			zerolog.Ctx(ce.Ctx).Info().
+				Stringer("policy_list", list.RoomID).
+				Any("removed_policy", policy).
+				Stringer("policy_event_id", resp.EventID).
+				Msg("Removed ban policy from unban command")
+			removed[policy] = struct{}{}
+			ce.Reply(
+				"Removed %s policy for %s from %s (reason was %s)",
+				format.SafeMarkdownCode(policy.Recommendation), format.SafeMarkdownCode(policy.EntityOrHash()),
+				format.EscapeMarkdown(list.Name), format.SafeMarkdownCode(policy.Reason),
+			)
+		}
+		if entityType != policylist.EntityTypeUser {
+			ce.React(SuccessReaction)
+			return
+		}
+		userID := id.UserID(target)
+		var remaining policylist.Match
+		for _, policy := range ce.Meta.Store.MatchUser(ce.Meta.GetWatchedLists(), userID) {
+			if _, ok := removed[policy]; !ok {
+				remaining = append(remaining, policy)
+			}
+		}
+		stillBanned := remaining.Recommendations().BanOrUnban
+		if stillBanned != nil && stillBanned.Recommendation != event.PolicyRecommendationUnban {
+			listName := stillBanned.RoomID.String()
+			if meta := ce.Meta.GetWatchedListMeta(stillBanned.RoomID); meta != nil {
+				listName = meta.Name
+			}
+			ce.Reply(
+				"Not unbanning %s from rooms, as they're still banned by %s in %s",
+				format.SafeMarkdownCode(userID), format.SafeMarkdownCode(stillBanned.EntityOrHash()), format.EscapeMarkdown(listName),
+			)
+			return
+		}
+		var unbannedFrom []string
+		for _, roomID := range ce.Meta.GetProtectedRooms() {
+			if !ce.Meta.Bot.StateStore.IsMembership(ce.Ctx, roomID, userID, event.MembershipBan) {
+				continue
+			}
+			if ce.Meta.UndoBan(ce.Ctx, userID, roomID) {
+				unbannedFrom = append(unbannedFrom, fmt.Sprintf("[%s](%s)", roomID, roomID.URI().MatrixToURL()))
+				err := ce.Meta.DB.TakenAction.Delete(ce.Ctx, userID, roomID, database.TakenActionTypeBanOrUnban)
+				if err != nil {
+					zerolog.Ctx(ce.Ctx).Err(err).Stringer("room_id", roomID).Msg("Failed to delete taken action after unbanning")
+				}
+			}
+		}
+		if len(unbannedFrom) == 0 {
+			ce.Reply("%s wasn't banned in any protected rooms", format.SafeMarkdownCode(userID))
+		} else {
+			ce.Reply("Unbanned %s from %s: %s", format.SafeMarkdownCode(userID), pluralize(len(unbannedFrom), "room"), strings.Join(unbannedFrom, ", "))
+		}
+		ce.React(SuccessReaction)
+	},
+}
+
 var cmdMatch = &CommandHandler{
 	Name: "match",
 	Func: func(ce *CommandEvent) {
-		target := ce.Args[0]
+		target := normalizeIDArg(ce.Args[0])
 		targetUser := id.UserID(target)
 		userIDHash, ok := util.DecodeBase64Hash(target)
 		if ok {
-			targetUser, ok = ce.Meta.getUserIDFromHash(*userIDHash)
+			targetUser, ok = ce.Meta.resolveUserHash(ce.Ctx, *userIDHash)
 			if !ok {
 				ce.Reply("No user found for hash %s", format.SafeMarkdownCode(target))
 				return
 			}
-			target = targetUser.String()
 			ce.Reply("Matched user %s for hash %s", format.SafeMarkdownCode(targetUser.String()), format.SafeMarkdownCode(target))
+			target = targetUser.String()
 		}
 		entityType, _ := validateEntity(target)
 		var dur time.Duration
@@ -550,6 +1864,11 @@ var cmdMatch = &CommandHandler{
 			start := time.Now()
 			match = ce.Meta.Store.MatchRoom(nil, id.RoomID(target))
 			dur = time.Since(start)
+			if ce.Meta.IsProtectedRoom(id.RoomID(target)) {
+				ce.Reply("Room %s is currently protected", ce.Meta.formatRoomLink(id.RoomID(target)))
+			} else {
+				ce.Reply("Room %s is not protected", format.SafeMarkdownCode(target))
+			}
 		} else if entityType == policylist.EntityTypeServer {
 			start := time.Now()
 			match = ce.Meta.Store.MatchServer(nil, target)
@@ -558,6 +1877,12 @@ var cmdMatch = &CommandHandler{
 			ce.Reply("Invalid entity %s", format.SafeMarkdownCode(target))
 			return
 		}
+		var targetNotes string
+		if normalizedTarget, ok := normalizeNoteEntity(target); ok && !slices.ContainsFunc(match, func(policy *policylist.Policy) bool {
+			return policy.EntityOrHash() == normalizedTarget
+		}) {
+			targetNotes = ce.Meta.formatEntityNotesSuffix(ce.Ctx, normalizedTarget)
+		}
 		if match != nil {
 			eventStrings := make([]string, len(match))
 			for i, policy := range match {
@@ -566,34 +1891,329 @@ var cmdMatch = &CommandHandler{
 					policyRoomName = meta.Name
 				}
 				eventStrings[i] = fmt.Sprintf(
-					"* [%s] [%s](%s) set recommendation %s for %s at %s for %s",
+					"* [%s] [%s](%s) set recommendation %s for %s at %s%s",
 					format.EscapeMarkdown(policyRoomName),
 					policy.Sender,
 					policy.Sender.URI().MatrixToURL(),
-					format.SafeMarkdownCode(policy.Recommendation),
-					format.SafeMarkdownCode(policy.EntityOrHash()),
+					formatMatchRecommendation(policy),
+					formatMatchEntity(policy),
 					format.EscapeMarkdown(time.UnixMilli(policy.Timestamp).String()),
-					format.SafeMarkdownCode(policy.Reason),
+					formatMatchReason(policy),
 				)
+				if notes := ce.Meta.formatEntityNotes(ce.Ctx, policy.EntityOrHash()); notes != "" {
+					eventStrings[i] += "\n" + notes
+				}
 			}
 			ce.Reply(
-				"Matched in %s with recommendation %s\n\n%s",
+				"Matched in %s. %s\n\nAll matching policies:\n\n%s%s",
 				dur.String(),
-				format.SafeMarkdownCode(match.Recommendations().String()),
+				ce.Meta.formatRecommendations(match.Recommendations()),
 				strings.Join(eventStrings, "\n"),
+				targetNotes,
 			)
 		} else {
-			ce.Reply("No match in %s", dur)
+			ce.Reply("No match in %s%s", dur, targetNotes)
+		}
+	},
+}
+
+// formatMatchRecommendation formats the recommendation of a policy, making takedowns stand out from regular bans.
+func formatMatchRecommendation(policy *policylist.Policy) string {
+	if policy.Recommendation == event.PolicyRecommendationUnstableTakedown {
+		return "**[takedown]**"
+	}
+	return format.SafeMarkdownCode(policy.Recommendation)
+}
+
+// formatMatchEntity formats the entity of a policy, falling back to the parsed hash if the event didn't include one.
+func formatMatchEntity(policy *policylist.Policy) string {
+	if entity := policy.EntityOrHash(); entity != "" {
+		return format.SafeMarkdownCode(entity)
+	} else if policy.EntityHash != nil {
+		return format.SafeMarkdownCode(base64.StdEncoding.EncodeToString(policy.EntityHash[:]))
+	}
+	return "an unknown entity"
+}
+
+// formatMatchReason formats the reason of a policy as a suffix. Takedowns intentionally don't have reasons,
+// so nothing is included for them.
+func formatMatchReason(policy *policylist.Policy) string {
+	if policy.Recommendation == event.PolicyRecommendationUnstableTakedown {
+		return ""
+	} else if policy.Reason == "" {
+		return " with no reason"
+	}
+	return " for " + format.SafeMarkdownCode(policy.Reason)
+}
+
+// formatRecommendations describes the resolved recommendations of a match, including where they came from.
+func (pe *PolicyEvaluator) formatRecommendations(recs policylist.Recommendations) string {
+	policy := recs.BanOrUnban
+	var warning string
+	if recs.Warn != nil {
+		warning = fmt.Sprintf(
+			"\n\nFlagged by a warn policy from [%s](%s) for %s",
+			recs.Warn.Sender, recs.Warn.Sender.URI().MatrixToURL(), format.SafeMarkdownCode(recs.Warn.Reason),
+		)
+	}
+	if policy == nil {
+		return "No ban, unban or takedown recommendation applies" + warning
+	}
+	listName := recs.PolicyList().String()
+	if meta := pe.GetWatchedListMeta(recs.PolicyList()); meta != nil {
+		listName = meta.Name
+	}
+	return fmt.Sprintf(
+		"Resolved recommendation: %s from **%s** ([%s](%s)), sent by [%s](%s)%s",
+		formatMatchRecommendation(policy),
+		format.EscapeMarkdown(listName),
+		policy.RoomID,
+		policy.RoomID.EventURI(policy.ID).MatrixToURL(),
+		policy.Sender,
+		policy.Sender.URI().MatrixToURL(),
+		formatMatchReason(policy),
+	) + warning
+}
+
+const maxListedServers = 50
+
+var cmdMatchServers = &CommandHandler{
+	Name: "match-servers",
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) < 1 {
+			replyUsage(ce)
+			return
+		}
+		if entityType, _ := validateEntity(ce.Args[0]); entityType != policylist.EntityTypeServer && ce.Args[0] != "*" {
+			ce.Reply("%s is not a valid server name pattern", format.SafeMarkdownCode(ce.Args[0]))
+			return
+		}
+		pattern := glob.Compile(ce.Args[0])
+		memberCounts := make(map[string]int)
+		for _, userID := range ce.Meta.getAllUsers() {
+			if server := userID.Homeserver(); pattern.Match(server) && len(ce.Meta.getRoomsUserIsIn(userID)) > 0 {
+				memberCounts[server]++
+			}
+		}
+		if len(memberCounts) == 0 {
+			ce.Reply("No servers with members in protected rooms match %s", format.SafeMarkdownCode(ce.Args[0]))
+			return
+		}
+		servers := slices.SortedFunc(maps.Keys(memberCounts), func(a, b string) int {
+			return cmp.Or(cmp.Compare(memberCounts[b], memberCounts[a]), cmp.Compare(a, b))
+		})
+		var totalMembers int
+		for _, count := range memberCounts {
+			totalMembers += count
+		}
+		lines := make([]string, 0, min(len(servers), maxListedServers))
+		for _, server := range servers[:min(len(servers), maxListedServers)] {
+			line := fmt.Sprintf("* %s - %s", format.SafeMarkdownCode(server), pluralize(memberCounts[server], "member"))
+			if server == ce.Meta.Bot.ServerName {
+				line += " (**this server**)"
+			}
+			lines = append(lines, line)
+		}
+		if len(servers) > maxListedServers {
+			lines = append(lines, fmt.Sprintf("* ...and %d more", len(servers)-maxListedServers))
+		}
+		ce.Reply(
+			"%s matches %s with %s in protected rooms:\n\n%s",
+			format.SafeMarkdownCode(ce.Args[0]), pluralize(len(servers), "server"),
+			pluralize(totalMembers, "member"), strings.Join(lines, "\n"),
+		)
+	},
+}
+
+var cmdExplainPrecedence = &CommandHandler{
+	Name:    "explain-precedence",
+	Aliases: []string{"explain"},
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) < 1 {
+			replyUsage(ce)
+			return
+		}
+		target := ce.Args[0]
+		entityType, ok := validateEntity(target)
+		if !ok {
+			ce.Reply("Invalid entity %s", format.SafeMarkdownCode(target))
+			return
+		}
+		steps := []string{
+			"Lists are checked in priority order (the order in the watched lists config). " +
+				"Within a list, exact matches come first, then hashed entities, then wildcard patterns in the order they were added. " +
+				"The first ban, unban or takedown policy that applies determines the verdict.",
+			"",
+		}
+		var effective *policylist.Policy
+		var effectiveList *config.WatchedPolicyList
+		var matchedLists int
+		for priority, list := range ce.Meta.GetWatchedListsInOrder() {
+			listIDs := []id.RoomID{list.RoomID}
+			var match policylist.Match
+			switch entityType {
+			case policylist.EntityTypeUser:
+				match = ce.Meta.Store.MatchUser(listIDs, id.UserID(target))
+			case policylist.EntityTypeRoom:
+				match = ce.Meta.Store.MatchRoom(listIDs, id.RoomID(target))
+			case policylist.EntityTypeServer:
+				match = ce.Meta.Store.MatchServer(listIDs, target)
+			}
+			if len(match) == 0 {
+				continue
+			}
+			listApplies := !list.DontApply && (entityType != policylist.EntityTypeServer || !list.DontApplyACL)
+			listNote := ""
+			if !listApplies {
+				listNote = " (list is not applied)"
+			}
+			matchedLists++
+			steps = append(steps, fmt.Sprintf(
+				"%d. [%s](%s) (priority %d)%s:",
+				matchedLists, format.EscapeMarkdown(list.Name), list.RoomID.URI().MatrixToURL(), priority+1, listNote,
+			))
+			for _, policy := range match {
+				var outcome string
+				switch {
+				case !policylist.IsBanOrUnban(policy.Recommendation):
+					outcome = "doesn't affect bans"
+				case !listApplies:
+					outcome = "skipped, list is not applied"
+				case effective != nil:
+					outcome = fmt.Sprintf("overridden by the %s policy in %s", format.SafeMarkdownCode(effective.Recommendation), format.EscapeMarkdown(effectiveList.Name))
+				default:
+					outcome = "**wins**"
+					effective = policy
+					effectiveList = list
+				}
+				steps = append(steps, fmt.Sprintf(
+					"    * %s for %s by %s at %s: %s (reason: %s)",
+					format.SafeMarkdownCode(policy.Recommendation),
+					format.SafeMarkdownCode(policy.EntityOrHash()),
+					format.EscapeMarkdown(policy.Sender.String()),
+					time.UnixMilli(policy.Timestamp).UTC().Format(time.RFC3339),
+					outcome,
+					format.SafeMarkdownCode(policy.Reason),
+				))
+			}
+		}
+		if matchedLists == 0 {
+			ce.Reply("No policies in watched lists match %s", format.SafeMarkdownCode(target))
+			return
+		}
+		var verdict string
+		switch {
+		case effective == nil:
+			verdict = "no action, none of the matching policies apply"
+		case effective.Recommendation == event.PolicyRecommendationUnban:
+			verdict = "not banned, the first matching policy in list priority order is an unban"
+		case effective.Recommendation == event.PolicyRecommendationUnstableTakedown:
+			verdict = "banned and content removed (takedown)"
+		default:
+			verdict = "banned"
+		}
+		steps = append(steps, "", fmt.Sprintf("Effective verdict for %s: %s", format.SafeMarkdownCode(target), verdict))
+		ce.Reply("%s", strings.Join(steps, "\n"))
+	},
+}
+
+var cmdSimulatePolicy = &CommandHandler{
+	Name:    "simulate-policy",
+	Aliases: []string{"simulate"},
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) < 3 {
+			replyUsage(ce)
+			return
+		}
+		entityType := policylist.EntityType(strings.ToLower(ce.Args[0]))
+		switch entityType {
+		case policylist.EntityTypeUser, policylist.EntityTypeRoom, policylist.EntityTypeServer:
+		default:
+			ce.Reply("Invalid entity type %s, must be `user`, `room` or `server`", format.SafeMarkdownCode(ce.Args[0]))
+			return
+		}
+		entity := ce.Args[1]
+		rec, ok := ce.Meta.parseRecommendation(ce, ce.Args[2])
+		if !ok {
+			return
+		}
+		page := 1
+		if len(ce.Args) > 3 {
+			var err error
+			page, err = strconv.Atoi(ce.Args[3])
+			if err != nil || page < 1 {
+				ce.Reply("Invalid page number %s", format.SafeMarkdownCode(ce.Args[3]))
+				return
+			}
+		}
+		if rec == event.PolicyRecommendationBan {
+			entityGlob := glob.Compile(entity)
+			for _, entry := range policylist.HackyRuleFilter {
+				if entityGlob.Match(entry) {
+					ce.Reply("The policy would be ignored, because it matches %s in the rule filter", format.SafeMarkdownCode(entry))
+					return
+				}
+			}
+		}
+		results := ce.Meta.simulatePolicy(entityType, entity, rec)
+		if len(results) == 0 {
+			ce.Reply("A %s policy for %s would not match anything in protected rooms", format.SafeMarkdownCode(rec), format.SafeMarkdownCode(entity))
+			return
+		}
+		var affectedCount int
+		for _, result := range results {
+			if result.Affected {
+				affectedCount++
+			}
+		}
+		pageCount := (len(results) + simulatePageSize - 1) / simulatePageSize
+		if page > pageCount {
+			ce.Reply("Page %d doesn't exist, there are only %d pages", page, pageCount)
+			return
+		}
+		pageResults := results[(page-1)*simulatePageSize : min(page*simulatePageSize, len(results))]
+		lines := make([]string, len(pageResults))
+		for i, result := range pageResults {
+			lines[i] = fmt.Sprintf("* %s: %s", result.Target, result.Outcome)
 		}
+		ce.Reply(
+			"Simulated %s policy for %s (not sent): %d matches, %d would be affected. Page %d/%d:\n\n%s",
+			format.SafeMarkdownCode(rec), format.SafeMarkdownCode(entity), len(results), affectedCount,
+			page, pageCount, strings.Join(lines, "\n"),
+		)
 	},
 }
 
 var cmdSearch = &CommandHandler{
 	Name: "search",
 	Func: func(ce *CommandEvent) {
+		var listIDs []id.RoomID
+		if listIdx := slices.Index(ce.Args, "--list"); listIdx >= 0 && listIdx+1 < len(ce.Args) {
+			list := ce.Meta.FindListByShortcode(ce.Args[listIdx+1])
+			if list == nil {
+				ce.Reply("List %s not found", format.SafeMarkdownCode(ce.Args[listIdx+1]))
+				return
+			}
+			listIDs = []id.RoomID{list.RoomID}
+			ce.Args = slices.Delete(ce.Args, listIdx, listIdx+2)
+		}
+		searchReason := slices.Contains(ce.Args, "--reason")
+		if searchReason || slices.Contains(ce.Args, "--entity") {
+			ce.Args = slices.DeleteFunc(ce.Args, func(arg string) bool { return arg == "--reason" || arg == "--entity" })
+		}
+		if len(ce.Args) < 1 {
+			replyUsage(ce)
+			return
+		}
 		target := ce.Args[0]
 		start := time.Now()
-		match := ce.Meta.Store.Search(nil, target)
+		var match policylist.Match
+		if searchReason {
+			target = strings.Join(ce.Args, " ")
+			match = ce.Meta.Store.SearchReason(listIDs, target)
+		} else {
+			match = ce.Meta.Store.Search(listIDs, target)
+		}
 		dur := time.Since(start)
 		if len(match) > 25 {
 			ce.Reply("Too many results (%d) in %s, please narrow your search", len(match), dur)
@@ -620,7 +2240,7 @@ var cmdSearch = &CommandHandler{
 		} else {
 			ce.Reply("No results in %s", dur)
 		}
-		if strings.HasPrefix(target, "@") {
+		if !searchReason && strings.HasPrefix(target, "@") {
 			users := slices.Collect(ce.Meta.findMatchingUsers(glob.Compile(target), nil, true))
 			if len(users) > 25 {
 				ce.Reply("Found %d users matching %s in protected rooms (too many to list)", len(users), format.SafeMarkdownCode(target))
@@ -642,51 +2262,309 @@ var cmdSearch = &CommandHandler{
 	},
 }
 
-var cmdSendAsBot = &CommandHandler{
-	Name: "send-as-bot",
+var cmdSendAsBot = &CommandHandler{
+	Name: "send-as-bot",
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) < 2 {
+			replyUsage(ce)
+			return
+		}
+		target := resolveRoom(ce, ce.Args[0])
+		if target == "" {
+			return
+		}
+		resp, err := ce.Meta.Bot.SendMessageEvent(ce.Ctx, target, event.EventMessage, &event.MessageEventContent{
+			MsgType: event.MsgText,
+			Body:    strings.Join(ce.Args[1:], " "),
+		})
+		if err != nil {
+			ce.Reply("Failed to send message to [%s](%s): %v", target, target.URI().MatrixToURL(), err)
+		} else {
+			ce.Reply("Sent message to [%s](%s): [%s](%s)", target, target.URI().MatrixToURL(), resp.EventID, target.EventURI(resp.EventID).MatrixToURL())
+		}
+	},
+}
+
+// cmdUnknown is the same as commands.MakeUnknownCommandHandler, but uses the configured command prefix.
+var cmdUnknown = &CommandHandler{
+	Name: commands.UnknownCommandName,
+	Func: func(ce *CommandEvent) {
+		if len(ce.ParentCommands) == 0 {
+			ce.Reply("Unknown command `%s%s`", ce.Meta.commandPrefix(), ce.Command)
+		} else {
+			ce.Reply("Unknown subcommand `%s%s %s`", ce.Meta.commandPrefix(), strings.Join(ce.ParentCommands, " "), ce.Command)
+		}
+	},
+}
+
+var cmdRooms = &CommandHandler{
+	Name:    "rooms",
+	Aliases: []string{"room"},
+	Subcommands: []*CommandHandler{
+		cmdListProtectedRooms,
+		cmdProtectRoom,
+		cmdUnknown,
+	},
+	Func: cmdListProtectedRooms.Func,
+}
+
+const maxListedProtectedRooms = 50
+
+var cmdListProtectedRooms = &CommandHandler{
+	Name: "list",
+	Func: func(ce *CommandEvent) {
+		type roomWithCount struct {
+			RoomID      id.RoomID
+			Name        string
+			MemberCount int
+			ObserveOnly bool
+		}
+		ce.Meta.protectedRoomsLock.RLock()
+		memberCounts := make(map[id.RoomID]int, len(ce.Meta.protectedRooms))
+		for _, rooms := range ce.Meta.protectedRoomMembers {
+			for _, roomID := range rooms {
+				memberCounts[roomID]++
+			}
+		}
+		rooms := make([]roomWithCount, 0, len(ce.Meta.protectedRooms))
+		for roomID, meta := range ce.Meta.protectedRooms {
+			name := roomID.String()
+			if meta != nil && meta.Name != "" {
+				name = meta.Name
+			}
+			rooms = append(rooms, roomWithCount{
+				RoomID:      roomID,
+				Name:        name,
+				MemberCount: memberCounts[roomID],
+				ObserveOnly: meta != nil && meta.ObserveOnly,
+			})
+		}
+		ce.Meta.protectedRoomsLock.RUnlock()
+		if len(rooms) == 0 {
+			ce.Reply("No protected rooms")
+			return
+		}
+		slices.SortFunc(rooms, func(a, b roomWithCount) int {
+			return cmp.Or(cmp.Compare(b.MemberCount, a.MemberCount), strings.Compare(a.Name, b.Name))
+		})
+		var buf strings.Builder
+		_, _ = fmt.Fprintf(&buf, "Protecting %s:\n\n", pluralize(len(rooms), "room"))
+		for _, room := range rooms[:min(len(rooms), maxListedProtectedRooms)] {
+			_, _ = fmt.Fprintf(
+				&buf, "* [%s](%s) (%s) - %s",
+				format.EscapeMarkdown(room.Name), room.RoomID.URI(ce.Meta.Bot.ServerName).MatrixToURL(),
+				format.SafeMarkdownCode(room.RoomID), pluralize(room.MemberCount, "member"),
+			)
+			if room.ObserveOnly {
+				buf.WriteString(" (observe-only)\n")
+			} else {
+				buf.WriteString("\n")
+			}
+		}
+		if len(rooms) > maxListedProtectedRooms {
+			_, _ = fmt.Fprintf(&buf, "\n...and %d more rooms", len(rooms)-maxListedProtectedRooms)
+		}
+		ce.Reply(buf.String())
+	},
+}
+
+var cmdHistoryRoom = &CommandHandler{
+	Name: "history-room",
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) < 1 {
+			replyUsage(ce)
+			return
+		}
+		roomID := resolveRoom(ce, ce.Args[0])
+		if roomID == "" {
+			return
+		}
+		limit := 50
+		if len(ce.Args) > 1 {
+			var err error
+			limit, err = strconv.Atoi(ce.Args[1])
+			if err != nil || limit <= 0 {
+				ce.Reply("Invalid limit %s", format.SafeMarkdownCode(ce.Args[1]))
+				return
+			}
+		}
+		entries, err := ce.Meta.DB.AuditLog.GetByRoom(ce.Ctx, ce.Meta.ManagementRoom, roomID, limit)
+		if err != nil {
+			zerolog.Ctx(ce.Ctx).Err(err).Msg("Failed to get audit log entries")
+			ce.Reply("Failed to get audit log: %v", err)
+			return
+		} else if len(entries) == 0 {
+			ce.Reply("No moderation actions recorded in [%s](%s)", roomID, roomID.URI().MatrixToURL())
+			return
+		}
+		entryStrings := make([]string, len(entries))
+		for i, entry := range entries {
+			entryStrings[i] = formatAuditLogEntry(entry)
+		}
+		ce.Reply("Last %d moderation actions in [%s](%s):\n\n%s", len(entries), roomID, roomID.URI().MatrixToURL(), strings.Join(entryStrings, "\n"))
+	},
+}
+
+func formatAuditLogEntry(entry *database.AuditLogEntry) string {
+	var buf strings.Builder
+	_, _ = fmt.Fprintf(&buf, "* %s: %s", format.EscapeMarkdown(entry.CreatedAt.String()), format.SafeMarkdownCode(entry.Action))
+	if entry.TargetUser != "" {
+		_, _ = fmt.Fprintf(&buf, " [%s](%s)", entry.TargetUser, entry.TargetUser.URI().MatrixToURL())
+	}
+	if entry.TargetEvent != "" {
+		_, _ = fmt.Fprintf(&buf, " [event](%s)", entry.InRoomID.EventURI(entry.TargetEvent).MatrixToURL())
+	}
+	if entry.Actor != "" {
+		_, _ = fmt.Fprintf(&buf, " by [%s](%s)", entry.Actor, entry.Actor.URI().MatrixToURL())
+	}
+	if entry.Reason != "" {
+		_, _ = fmt.Fprintf(&buf, " for %s", format.SafeMarkdownCode(entry.Reason))
+	}
+	return buf.String()
+}
+
+var cmdExportAudit = &CommandHandler{
+	Name:    "export-audit",
+	Aliases: []string{"export-audit-signed"},
+	Func: func(ce *CommandEvent) {
+		chained := ce.Command == "export-audit-signed" || (len(ce.Args) > 0 && ce.Args[0] == "--signed")
+		data, count, head, err := ce.Meta.exportAuditLog(ce.Ctx, chained)
+		if err != nil {
+			ce.Reply("Failed to export audit log: %v", err)
+			return
+		} else if count == 0 {
+			ce.Reply("Audit log is empty")
+			return
+		}
+		fileName := fmt.Sprintf("meowlnir-audit-%s.jsonl", time.Now().Format("2006-01-02"))
+		err = ce.Meta.sendFile(ce.Ctx, fileName, "application/jsonl", data)
+		if err != nil {
+			ce.Reply("Failed to send audit log export: %v", err)
+			return
+		}
+		if chained {
+			ce.Reply(
+				"Exported %d audit log entries as a hash chain. Head hash: %s\n\n"+
+					"To verify the export, check each line in order: `prev_hash` must equal the `hash` of the previous line "+
+					"(empty for the first line), and `hash` must equal the hex-encoded SHA-256 of `prev_hash` concatenated with `entry`. "+
+					"Compare the final hash with the head hash in this message to detect truncation. For example:\n\n"+
+					"```sh\nprev=\"\"; while read -r line; do\n"+
+					"  [ \"$(echo \"$line\" | jq -r .prev_hash)\" = \"$prev\" ] || echo \"broken chain\"\n"+
+					"  hash=$(printf '%%s%%s' \"$prev\" \"$(echo \"$line\" | jq -r .entry)\" | sha256sum | cut -d' ' -f1)\n"+
+					"  [ \"$hash\" = \"$(echo \"$line\" | jq -r .hash)\" ] || echo \"tampered entry\"\n"+
+					"  prev=$hash\ndone < %s; echo \"head: $prev\"\n```",
+				count, format.SafeMarkdownCode(head), fileName,
+			)
+		} else {
+			ce.Reply("Exported %d audit log entries", count)
+		}
+	},
+}
+
+var cmdSecureList = &CommandHandler{
+	Name: "secure-list",
 	Func: func(ce *CommandEvent) {
-		if len(ce.Args) < 2 {
-			ce.Reply("Usage: `!send-as-bot <room ID> <message>`")
+		if len(ce.Args) < 1 {
+			replyUsage(ce)
 			return
 		}
-		target := resolveRoom(ce, ce.Args[0])
-		if target == "" {
+		list := ce.Meta.FindListByShortcode(ce.Args[0])
+		if list == nil {
+			ce.Reply("List %s not found", format.SafeMarkdownCode(ce.Args[0]))
 			return
 		}
-		resp, err := ce.Meta.Bot.SendMessageEvent(ce.Ctx, target, event.EventMessage, &event.MessageEventContent{
-			MsgType: event.MsgText,
-			Body:    strings.Join(ce.Args[1:], " "),
-		})
+		security, err := ce.Meta.getListRoomSecurity(ce.Ctx, list.RoomID)
 		if err != nil {
-			ce.Reply("Failed to send message to [%s](%s): %v", target, target.URI().MatrixToURL(), err)
-		} else {
-			ce.Reply("Sent message to [%s](%s): [%s](%s)", target, target.URI().MatrixToURL(), resp.EventID, target.EventURI(resp.EventID).MatrixToURL())
+			ce.Reply("Failed to get current settings of %s: %v", format.EscapeMarkdown(list.Name), err)
+			return
+		}
+		ce.Reply("Current settings of [%s](%s): %s", format.EscapeMarkdown(list.Name), list.RoomID.URI().MatrixToURL(), security)
+		if security.JoinRule == recommendedListJoinRule && security.HistoryVisibility == recommendedListHistoryVisibility {
+			ce.Reply("List is already secured")
+			return
+		}
+		// The bot must be joined to keep reading the list after outsiders are locked out
+		joinedRooms, err := ce.Meta.Bot.JoinedRooms(ce.Ctx)
+		if err != nil {
+			ce.Reply("Failed to get joined rooms: %v", err)
+			return
+		} else if !slices.Contains(joinedRooms.JoinedRooms, list.RoomID) {
+			ce.Reply("Bot is not joined to the list room, refusing to change settings as it would lose access to the list")
+			return
+		} else if ce.Meta.DryRun {
+			ce.Reply(
+				"Dry run: would have set the join rule to %s and history visibility to %s",
+				format.SafeMarkdownCode(recommendedListJoinRule), format.SafeMarkdownCode(recommendedListHistoryVisibility),
+			)
+			return
+		}
+		if security.JoinRule != recommendedListJoinRule {
+			_, err = ce.Meta.Bot.SendStateEvent(ce.Ctx, list.RoomID, event.StateJoinRules, "", &event.JoinRulesEventContent{
+				JoinRule: recommendedListJoinRule,
+			})
+			if err != nil {
+				ce.Reply("Failed to set join rule: %v", err)
+				return
+			}
+		}
+		if security.HistoryVisibility != recommendedListHistoryVisibility {
+			_, err = ce.Meta.Bot.SendStateEvent(ce.Ctx, list.RoomID, event.StateHistoryVisibility, "", &event.HistoryVisibilityEventContent{
+				HistoryVisibility: recommendedListHistoryVisibility,
+			})
+			if err != nil {
+				ce.Reply("Failed to set history visibility: %v", err)
+				return
+			}
 		}
+		ce.React(SuccessReaction)
 	},
 }
 
-var cmdRooms = &CommandHandler{
-	Name:    "rooms",
-	Aliases: []string{"room"},
-	Subcommands: []*CommandHandler{
-		cmdListProtectedRooms,
-		cmdProtectRoom,
-		commands.MakeUnknownCommandHandler[*PolicyEvaluator]("!"),
-	},
-	Func: cmdListProtectedRooms.Func,
-}
+var likelyBotLocalpartRegex = regexp.MustCompile(`(?i)bot|mjolnir|draupnir|meowlnir|moderat`)
 
-var cmdListProtectedRooms = &CommandHandler{
-	Name: "list",
+var cmdListSubscribers = &CommandHandler{
+	Name: "list-subscribers",
 	Func: func(ce *CommandEvent) {
-		var buf strings.Builder
-		buf.WriteString("Protected rooms:\n\n")
-		ce.Meta.protectedRoomsLock.RLock()
-		for roomID, meta := range ce.Meta.protectedRooms {
-			_, _ = fmt.Fprintf(&buf, "* [%s](%s) (%s)\n", format.EscapeMarkdown(meta.Name), roomID.URI(ce.Meta.Bot.ServerName).MatrixToURL(), format.SafeMarkdownCode(roomID))
+		if len(ce.Args) < 1 {
+			replyUsage(ce)
+			return
 		}
-		ce.Meta.protectedRoomsLock.RUnlock()
-		ce.Reply(buf.String())
+		list := ce.Meta.FindListByShortcode(ce.Args[0])
+		if list == nil {
+			ce.Reply("List %s not found", format.SafeMarkdownCode(ce.Args[0]))
+			return
+		}
+		members, err := ce.Meta.Bot.JoinedMembers(ce.Ctx, list.RoomID)
+		if err != nil {
+			ce.Reply("Failed to get members of %s: %v", format.EscapeMarkdown(list.Name), err)
+			return
+		}
+		userIDs := slices.Sorted(maps.Keys(members.Joined))
+		servers := make(map[string]struct{})
+		var botCount int
+		memberStrings := make([]string, 0, len(userIDs))
+		for _, userID := range userIDs {
+			servers[userID.Homeserver()] = struct{}{}
+			localpart, _, _ := userID.Parse()
+			var suffix string
+			if likelyBotLocalpartRegex.MatchString(localpart) {
+				suffix = " 🤖"
+				botCount++
+			}
+			memberStrings = append(memberStrings, fmt.Sprintf("* [%s](%s)%s", userID, userID.URI().MatrixToURL(), suffix))
+		}
+		const maxListed = 100
+		if len(memberStrings) > maxListed {
+			memberStrings = append(memberStrings[:maxListed], fmt.Sprintf("* ...and %d more", len(userIDs)-maxListed))
+		}
+		ce.Reply(
+			"[%s](%s) has %s from %s, %d of which look like bots (🤖).\n\n"+
+				"This only reflects current room members. Lists may also be consumed by users who left, "+
+				"via other members sharing the data, or by anyone if the room history is world-readable.\n\n%s",
+			format.EscapeMarkdown(list.Name), list.RoomID.URI().MatrixToURL(),
+			pluralize(len(userIDs), "joined member"), pluralize(len(servers), "server"), botCount,
+			strings.Join(memberStrings, "\n"),
+		)
 	},
 }
 
@@ -694,6 +2572,13 @@ var cmdSuspend = &CommandHandler{
 	Name:    "suspend",
 	Aliases: []string{"unsuspend"},
 	Func: func(ce *CommandEvent) {
+		if len(ce.Args) < 1 {
+			replyUsage(ce)
+			return
+		} else if ce.Meta.DryRun {
+			ce.Reply("Dry run: would have %sed %s", ce.Command, format.SafeMarkdownCode(ce.Args[0]))
+			return
+		}
 		err := ce.Meta.Bot.SynapseAdmin.SuspendAccount(ce.Ctx, id.UserID(ce.Args[0]), synapseadmin.ReqSuspendUser{
 			Suspend: ce.Command != "unsuspend",
 		})
@@ -708,32 +2593,114 @@ var cmdSuspend = &CommandHandler{
 var cmdDeactivate = &CommandHandler{
 	Name: "deactivate",
 	Func: func(ce *CommandEvent) {
-		if len(ce.Args) > 1 && ce.Args[1] != "--erase" {
-			ce.Reply("Usage: `!deactivate <user ID> [--erase]`")
+		if !ce.Meta.Deactivation.Enabled {
+			ce.Reply("Deactivating users is disabled in the config")
+			return
+		}
+		var erase bool
+		if eraseIdx := slices.Index(ce.Args, "--erase"); eraseIdx >= 0 {
+			erase = true
+			ce.Args = slices.Delete(ce.Args, eraseIdx, eraseIdx+1)
+		}
+		if len(ce.Args) < 1 {
+			replyUsage(ce)
+			return
+		}
+		userID := id.UserID(ce.Args[0])
+		reason := strings.Join(ce.Args[1:], " ")
+		if _, homeserver, err := userID.ParseAndValidate(); err != nil {
+			ce.Reply("Invalid user ID %s: %v", format.SafeMarkdownCode(userID), err)
+			return
+		} else if homeserver != ce.Meta.Bot.ServerName {
+			ce.Reply("%s is not a local user, only users on %s can be deactivated", format.SafeMarkdownCode(userID), format.SafeMarkdownCode(ce.Meta.Bot.ServerName))
+			return
+		}
+		if ce.Meta.Deactivation.BanList != "" {
+			list := ce.Meta.FindListByShortcode(ce.Meta.Deactivation.BanList)
+			if list == nil {
+				ce.Reply("Configured deactivation ban list %s not found", format.SafeMarkdownCode(ce.Meta.Deactivation.BanList))
+				return
+			}
+			_, err := ce.Meta.sendBanPolicy(ce, string(userID), &banParams{
+				List:           list,
+				Reason:         reason,
+				Recommendation: event.PolicyRecommendationBan,
+			})
+			if err != nil {
+				ce.Reply("Failed to send ban policy, not deactivating: %v", err)
+				return
+			}
+		}
+		if ce.Meta.DryRun {
+			ce.Reply("Dry run: would deactivate %s (erase: %t)", format.SafeMarkdownCode(userID), erase)
 			return
 		}
-		err := ce.Meta.Bot.SynapseAdmin.DeactivateAccount(ce.Ctx, id.UserID(ce.Args[0]), synapseadmin.ReqDeleteUser{
-			Erase: len(ce.Args) > 1 && ce.Args[1] == "--erase",
+		err := ce.Meta.synapseAdmin().DeactivateAccount(ce.Ctx, userID, synapseadmin.ReqDeleteUser{
+			Erase: erase,
 		})
 		if err != nil {
 			ce.Reply("Failed to deactivate: %v", err)
-		} else {
-			ce.React(SuccessReaction)
+			return
+		}
+		ce.Meta.logAction(ce.Ctx, &database.AuditLogEntry{
+			Action:     database.AuditLogActionDeactivate,
+			TargetUser: userID,
+			Reason:     reason,
+		})
+		ce.React(SuccessReaction)
+	},
+}
+
+var cmdQuarantine = &CommandHandler{
+	Name: "quarantine",
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) < 1 {
+			replyUsage(ce)
+			return
+		}
+		userID := id.UserID(ce.Args[0])
+		if _, _, err := userID.ParseAndValidate(); err != nil {
+			ce.Reply("Invalid user ID %s: %v", format.SafeMarkdownCode(userID), err)
+			return
+		}
+		var reportedMedia []id.ContentURI
+		if userID.Homeserver() != ce.Meta.Bot.ServerName {
+			reportedMedia = ce.Meta.getReportedMedia(ce.Ctx, userID)
+			if len(reportedMedia) == 0 {
+				ce.Reply("%s is a remote user and no media was found in events reported from them", format.SafeMarkdownCode(userID))
+				return
+			}
 		}
+		ce.Meta.quarantineAndNotify(ce.Ctx, userID, reportedMedia)
 	},
 }
 
+// unprotectRoom removes a single room from the protected rooms state event in the management room.
+func (pe *PolicyEvaluator) unprotectRoom(ctx context.Context, roomID id.RoomID) error {
+	pe.protectedRoomsLock.RLock()
+	contentCopy := *pe.protectedRoomsEvent
+	contentCopy.Rooms = slices.DeleteFunc(slices.Clone(contentCopy.Rooms), func(room id.RoomID) bool { return room == roomID })
+	pe.protectedRoomsLock.RUnlock()
+	_, err := pe.Bot.SendStateEvent(ctx, pe.ManagementRoom, config.StateProtectedRooms, "", &contentCopy)
+	return err
+}
+
 var cmdProtectRoom = &CommandHandler{
 	Name:    "protect",
 	Aliases: []string{"unprotect"},
 	Func: func(ce *CommandEvent) {
-		if len(ce.Args) < 1 {
-			ce.Reply("Usage: `!rooms <protect/unprotect> <room ID or alias>...`")
+		observe := slices.Contains(ce.Args, "--observe")
+		if observe {
+			ce.Args = slices.DeleteFunc(ce.Args, func(arg string) bool { return arg == "--observe" })
+		}
+		if len(ce.Args) < 1 || (observe && ce.Command != "protect") {
+			replyUsage(ce)
 			return
 		}
 		ce.Meta.protectedRoomsLock.RLock()
 		contentCopy := *ce.Meta.protectedRoomsEvent
 		contentCopy.Rooms = slices.Clone(contentCopy.Rooms)
+		contentCopy.ObserveOnly = slices.Clone(contentCopy.ObserveOnly)
 		ce.Meta.protectedRoomsLock.RUnlock()
 		changed := false
 		for _, room := range ce.Args {
@@ -747,7 +2714,13 @@ var cmdProtectRoom = &CommandHandler{
 					ce.Reply("%s is already protected", format.SafeMarkdownCode(roomID))
 					continue
 				}
+				if policy := ce.Meta.getRoomBanPolicy(roomID); policy != nil {
+					ce.Reply("Warning: %s is %s", format.SafeMarkdownCode(roomID), ce.Meta.formatRoomBanPolicy(policy))
+				}
 				contentCopy.Rooms = append(contentCopy.Rooms, roomID)
+				if observe && !slices.Contains(contentCopy.ObserveOnly, roomID) {
+					contentCopy.ObserveOnly = append(contentCopy.ObserveOnly, roomID)
+				}
 				changed = true
 			} else {
 				if itemIdx < 0 {
@@ -755,6 +2728,8 @@ var cmdProtectRoom = &CommandHandler{
 					continue
 				}
 				contentCopy.Rooms = slices.Delete(contentCopy.Rooms, itemIdx, itemIdx+1)
+				// Observe-only mode is reset when unprotecting, so protecting the room again enforces policies by default
+				contentCopy.ObserveOnly = slices.DeleteFunc(contentCopy.ObserveOnly, func(item id.RoomID) bool { return item == roomID })
 				changed = true
 			}
 		}
@@ -769,42 +2744,243 @@ var cmdProtectRoom = &CommandHandler{
 	},
 }
 
-var cmdHelp = &CommandHandler{
-	Name: "help",
+var cmdReview = &CommandHandler{
+	Name:    "review",
+	Aliases: []string{"queue-report-review"},
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) > 0 {
+			if strings.ToLower(ce.Args[0]) != "restart" {
+				replyUsage(ce)
+				return
+			}
+			ce.Meta.setReviewPosition(actorFromContext(ce.Ctx), 0)
+		}
+		ce.Meta.showNextReport(ce.Ctx, actorFromContext(ce.Ctx))
+	},
+}
+
+var cmdFlapping = &CommandHandler{
+	Name: "flapping",
 	Func: func(ce *CommandEvent) {
 		if len(ce.Args) == 0 {
-			ce.Reply("Available commands:\n" +
-				"* `!join <rooms...>` - Join a room\n" +
-				"* `!knock <rooms...>` - Ask to join a room\n" +
-				"* `!leave <rooms...>` - Leave a room\n" +
-				"* `!powerlevel <room|all> <key> <level>` - Set a power level\n" +
-				"* `!redact <event link or user ID> [reason]` - Redact all messages from a user\n" +
-				"* `!redact-recent <room> <since duration> [reason]` - Redact all recent messages in a room\n" +
-				"* `!kick <user ID> [reason]` - Kick a user from all rooms\n" +
-				"* `!ban [--hash] <list shortcode> <entity> [reason]` - Add a ban policy\n" +
-				"* `!takedown [--hash] <list shortcode> <entity>` - Add a takedown policy\n" +
-				"* `!remove-ban <list shortcode> <entity>` - Remove a ban policy\n" +
-				"* `!add-unban <list shortcode> <entity> [reason]` - Add a ban exclusion policy\n" +
-				"* `!match <entity>` - Match an entity against all lists\n" +
-				"* `!search <pattern>` - Search for rules by a pattern in all lists\n" +
-				"* `!send-as-bot <room> <message>` - Send a message as the bot\n" +
-				"* `![un]suspend <user ID>` - Suspend or unsuspend a user\n" +
-				"* `!rooms <protect/unprotect> <room ID or alias>...` - Protect or unprotect a room\n" +
-				// "* `!help <command>` - Show detailed help for a command\n" +
-				"* `!help` - Show this help message\n" +
-				"\n" +
-				"All fields that want a room will accept both room IDs and aliases.\n",
-			)
+			entities := ce.Meta.getFlappingEntities()
+			if len(entities) == 0 {
+				ce.Reply("No flapping entities detected")
+			} else {
+				ce.Reply("Flapping entities:\n\n%s", strings.Join(entities, "\n"))
+			}
+			return
+		} else if len(ce.Args) < 2 || strings.ToLower(ce.Args[0]) != "resolve" {
+			replyUsage(ce)
+			return
+		}
+		policies := ce.Meta.resolveFlapping(ce.Args[1])
+		for _, policy := range policies {
+			ce.Meta.reevaluateEntity(ce.Ctx, policy)
+		}
+		if len(policies) > 0 {
+			ce.Reply("Resumed automatic enforcement for %s and re-evaluated it against current policies", format.SafeMarkdownCode(ce.Args[1]))
 		} else {
-			switch strings.ToLower(strings.TrimLeft(ce.Args[0], "!")) {
-			case "join":
-				// TODO
+			ce.Reply("Cleared flapping state for %s", format.SafeMarkdownCode(ce.Args[1]))
+		}
+	},
+}
+
+var cmdLists = &CommandHandler{
+	Name: "lists",
+	Func: func(ce *CommandEvent) {
+		lists := ce.Meta.GetWatchedListsInOrder()
+		if len(lists) == 0 {
+			ce.Reply("Not watching any policy lists")
+			return
+		}
+		total := make(policylist.PolicyCounts)
+		var buf strings.Builder
+		buf.WriteString("Watched policy lists:\n\n")
+		for _, list := range lists {
+			_, _ = fmt.Fprintf(
+				&buf, "* [%s](%s) (shortcode %s)",
+				format.EscapeMarkdown(list.Name), list.RoomID.URI().MatrixToURL(), format.SafeMarkdownCode(list.Shortcode),
+			)
+			counts := ce.Meta.Store.CountPolicies(list.RoomID)
+			if counts == nil {
+				buf.WriteString(" - not loaded\n")
+				continue
 			}
+			total.Add(counts)
+			buf.WriteString("\n")
+			buf.WriteString(formatPolicyCounts(counts, "  "))
+		}
+		buf.WriteString("\nTotal:\n\n")
+		buf.WriteString(formatPolicyCounts(total, ""))
+		ce.Reply(buf.String())
+	},
+}
+
+// copyWatchedListsEvent returns a copy of the current watched lists event content that can be safely modified.
+func (pe *PolicyEvaluator) copyWatchedListsEvent() *config.WatchedListsEventContent {
+	pe.watchedListsLock.RLock()
+	defer pe.watchedListsLock.RUnlock()
+	if pe.watchedListsEvent == nil {
+		return &config.WatchedListsEventContent{}
+	}
+	return &config.WatchedListsEventContent{Lists: slices.Clone(pe.watchedListsEvent.Lists)}
+}
+
+var shortcodeCleaner = regexp.MustCompile(`[^a-z0-9]+`)
+
+var cmdWatch = &CommandHandler{
+	Name: "watch",
+	Func: func(ce *CommandEvent) {
+		dontApply := slices.Contains(ce.Args, "--no-apply")
+		if dontApply {
+			ce.Args = slices.DeleteFunc(ce.Args, func(arg string) bool { return arg == "--no-apply" })
+		}
+		if len(ce.Args) < 1 {
+			replyUsage(ce)
+			return
+		}
+		roomID := resolveRoom(ce, ce.Args[0])
+		if roomID == "" {
+			return
+		} else if ce.Meta.IsWatchingList(roomID) {
+			ce.Reply("Already watching %s", format.SafeMarkdownCode(roomID))
+			return
+		}
+		_, err := ce.Meta.Bot.JoinRoomByID(ce.Ctx, roomID)
+		if err != nil {
+			ce.Reply("Failed to join %s: %v", format.SafeMarkdownCode(roomID), err)
+			return
+		}
+		state, err := ce.Meta.Bot.State(ce.Ctx, roomID)
+		if err != nil {
+			ce.Reply("Failed to get state of %s: %v", format.SafeMarkdownCode(roomID), err)
+			return
 		}
+		var name string
+		if nameEvt, ok := state[event.StateRoomName][""]; ok {
+			name = nameEvt.Content.AsRoomName().Name
+		}
+		if name == "" {
+			name = roomID.String()
+		}
+		var shortcode string
+		if len(ce.Args) > 1 {
+			shortcode = ce.Args[1]
+		} else {
+			shortcode = strings.Trim(shortcodeCleaner.ReplaceAllString(strings.ToLower(name), "-"), "-")
+		}
+		if shortcode == "" {
+			ce.Reply("Couldn't generate a shortcode for the list, please specify one manually")
+			return
+		} else if ce.Meta.FindListByShortcode(shortcode) != nil {
+			ce.Reply("Shortcode %s is already in use, please specify a different one", format.SafeMarkdownCode(shortcode))
+			return
+		}
+		if !ce.Meta.Store.Contains(roomID) {
+			ce.Meta.Store.Add(roomID, state)
+		}
+		content := ce.Meta.copyWatchedListsEvent()
+		content.Lists = append(content.Lists, config.WatchedPolicyList{
+			RoomID:    roomID,
+			Name:      name,
+			Shortcode: shortcode,
+			DontApply: dontApply,
+		})
+		_, err = ce.Meta.Bot.SendStateEvent(ce.Ctx, ce.Meta.ManagementRoom, config.StateWatchedLists, "", content)
+		if err != nil {
+			ce.Reply("Failed to update watched lists: %v", err)
+			return
+		}
+		ce.Reply(
+			"Now watching [%s](%s) with shortcode %s. The list contains:\n\n%s",
+			format.EscapeMarkdown(name), roomID.URI().MatrixToURL(), format.SafeMarkdownCode(shortcode),
+			formatPolicyCounts(ce.Meta.Store.CountPolicies(roomID), ""),
+		)
+		ce.React(SuccessReaction)
+	},
+}
+
+var cmdUnwatch = &CommandHandler{
+	Name: "unwatch",
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) < 1 {
+			replyUsage(ce)
+			return
+		}
+		var roomID id.RoomID
+		if list := ce.Meta.FindListByShortcode(ce.Args[0]); list != nil {
+			roomID = list.RoomID
+		} else if roomID = resolveRoom(ce, ce.Args[0]); roomID == "" {
+			return
+		}
+		content := ce.Meta.copyWatchedListsEvent()
+		oldLen := len(content.Lists)
+		content.Lists = slices.DeleteFunc(content.Lists, func(list config.WatchedPolicyList) bool {
+			return list.RoomID == roomID
+		})
+		if len(content.Lists) == oldLen {
+			ce.Reply("Not watching %s", format.SafeMarkdownCode(roomID))
+			return
+		}
+		_, err := ce.Meta.Bot.SendStateEvent(ce.Ctx, ce.Meta.ManagementRoom, config.StateWatchedLists, "", content)
+		if err != nil {
+			ce.Reply("Failed to update watched lists: %v", err)
+			return
+		}
+		ce.React(SuccessReaction)
 	},
 }
 
+func formatPolicyCounts(counts policylist.PolicyCounts, indent string) string {
+	var buf strings.Builder
+	for _, entityType := range []policylist.EntityType{policylist.EntityTypeUser, policylist.EntityTypeRoom, policylist.EntityTypeServer} {
+		recCounts := counts[entityType]
+		var other int
+		for rec, count := range recCounts {
+			if !policylist.IsBanOrUnban(rec) {
+				other += count
+			}
+		}
+		_, _ = fmt.Fprintf(
+			&buf, "%s* %s rules: %d bans, %d unbans, %d takedowns",
+			indent, entityType, recCounts[event.PolicyRecommendationBan],
+			recCounts[event.PolicyRecommendationUnban], recCounts[event.PolicyRecommendationUnstableTakedown],
+		)
+		if other > 0 {
+			_, _ = fmt.Fprintf(&buf, ", %d other", other)
+		}
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+// parseJoinTarget parses a room ID, room alias, matrix.to link or matrix: URI into a room ID or alias
+// that can be passed to the join endpoint, along with any via servers included in the link.
+func parseJoinTarget(arg string) (target string, via []string, ok bool) {
+	if isMatrixLink(arg) {
+		uri, err := id.ParseMatrixURIOrMatrixToURL(arg)
+		if err != nil || (uri.Sigil1 != '!' && uri.Sigil1 != '#') {
+			return "", nil, false
+		}
+		arg = uri.PrimaryIdentifier()
+		via = uri.Via
+	}
+	switch {
+	case strings.HasPrefix(arg, "!") && len(arg) > 1:
+		return arg, via, true
+	case strings.HasPrefix(arg, "#"):
+		localpart, server, found := strings.Cut(arg[1:], ":")
+		return arg, via, found && localpart != "" && server != ""
+	default:
+		return "", nil, false
+	}
+}
+
+// resolveRoom converts a room ID, room alias, matrix.to link or matrix: URI into a room ID.
+// If resolving an alias fails, the error has already been replied and the returned room ID is empty.
 func resolveRoom(ce *CommandEvent, room string) id.RoomID {
+	room = normalizeIDArg(room)
 	if strings.HasPrefix(room, "#") {
 		resp, err := ce.Meta.Bot.ResolveAlias(ce.Ctx, id.RoomAlias(room))
 		if err != nil {
@@ -820,6 +2996,59 @@ func resolveRoom(ce *CommandEvent, room string) id.RoomID {
 }
 
 var homeserverPatternRegex = regexp.MustCompile(`^[a-zA-Z0-9.*?-]+\.[a-zA-Z0-9*?-]+$`)
+var userServerPatternRegex = regexp.MustCompile(`^[a-z0-9.*?-]+$`)
+
+// normalizeUserEntity validates a user ID or user ID glob and lowercases the server name,
+// so that policies match the form user IDs have in events.
+func normalizeUserEntity(entity string) (string, error) {
+	localpart, server, found := strings.Cut(strings.TrimPrefix(entity, "@"), ":")
+	if !strings.HasPrefix(entity, "@") || !found || localpart == "" || server == "" {
+		return "", fmt.Errorf("must be in the format @localpart:server")
+	}
+	for _, char := range localpart {
+		if char < 0x21 || char > 0x7e {
+			return "", fmt.Errorf("localpart contains invalid character %q", char)
+		}
+	}
+	server = strings.ToLower(server)
+	hostname := policylist.CleanupServerNameForMatch(server)
+	if !userServerPatternRegex.MatchString(hostname) && !policylist.IsIPLiteral(hostname) {
+		return "", fmt.Errorf("invalid server name %q", server)
+	}
+	return "@" + localpart + ":" + server, nil
+}
+
+// validateRegexEntity checks that a regex entity compiles and determines the entity type from the first character.
+// Regexes starting with `@` are user policies, regexes starting with `!` are room policies and anything else is
+// a server policy. If the regex is invalid, the error has already been replied and ok is false.
+func validateRegexEntity(ce *CommandEvent, entity string) (entityType policylist.EntityType, ok bool) {
+	if _, err := policylist.CompileEntityRegex(entity); err != nil {
+		ce.Reply("Invalid regex entity %s: %v", format.SafeMarkdownCode(entity), err)
+		return "", false
+	}
+	trimmed := strings.TrimPrefix(entity, "^")
+	switch {
+	case strings.HasPrefix(trimmed, "@"):
+		return policylist.EntityTypeUser, true
+	case strings.HasPrefix(trimmed, "!"):
+		return policylist.EntityTypeRoom, true
+	default:
+		return policylist.EntityTypeServer, true
+	}
+}
+
+func containsGlobWildcard(entity string) bool {
+	return strings.ContainsAny(entity, "*?")
+}
+
+// compileUserPattern compiles a user ID glob given to a command. If exact is true, the pattern is treated
+// as a literal user ID even if it contains `*` or `?`. Globs always have to match the entire user ID.
+func compileUserPattern(pattern string, exact bool) glob.Glob {
+	if exact {
+		return glob.ExactGlob(pattern)
+	}
+	return glob.Compile(pattern)
+}
 
 func validateEntity(entity string) (policylist.EntityType, bool) {
 	if len(entity) == 0 {
@@ -835,10 +3064,149 @@ func validateEntity(entity string) (policylist.EntityType, bool) {
 	return "", false
 }
 
+// findExactPolicies finds the policies in the given lists for an exact entity or base64 hash.
+// Hashes are looked up across all entity types, and anything that isn't a user, room or server is treated as a content pattern.
+func (pe *PolicyEvaluator) findExactPolicies(listIDs []id.RoomID, target string) (match policylist.Match) {
+	if hashEntity, ok := util.DecodeBase64Hash(target); ok {
+		for _, entityType := range []policylist.EntityType{policylist.EntityTypeUser, policylist.EntityTypeRoom, policylist.EntityTypeServer} {
+			match = append(match, pe.Store.MatchHash(listIDs, entityType, *hashEntity)...)
+		}
+		return append(match, pe.Store.MatchExact(listIDs, policylist.EntityTypeMedia, target)...)
+	} else if entityType, ok := validateEntity(target); ok {
+		return pe.Store.MatchExact(listIDs, entityType, target)
+	}
+	return pe.Store.MatchExact(listIDs, policylist.EntityTypeContent, target)
+}
+
 func (pe *PolicyEvaluator) SendPolicy(ctx context.Context, policyList id.RoomID, entityType policylist.EntityType, stateKey, rawEntity string, content *event.ModPolicyContent) (*mautrix.RespSendEvent, error) {
+	return pe.SendExpiringPolicy(ctx, policyList, entityType, stateKey, rawEntity, content, time.Time{})
+}
+
+// SendExpiringPolicy sends a policy like SendPolicy, but also includes an expiry timestamp if expiry is non-zero.
+func (pe *PolicyEvaluator) SendExpiringPolicy(ctx context.Context, policyList id.RoomID, entityType policylist.EntityType, stateKey, rawEntity string, content *event.ModPolicyContent, expiry time.Time) (*mautrix.RespSendEvent, error) {
+	var extra map[string]any
+	if !expiry.IsZero() {
+		extra = map[string]any{policylist.UnstableExpiryKey: expiry.UnixMilli()}
+	}
+	return pe.sendPolicyWithExtra(ctx, policyList, entityType, stateKey, rawEntity, content, extra)
+}
+
+// sendPolicyWithExtra sends a policy like SendPolicy, but also includes the given extra fields in the event content.
+// Ban and takedown policies that would affect the bot, admins or never_ban entities are refused.
+// In dry run mode, the policy is only logged and a fake event ID is returned.
+func (pe *PolicyEvaluator) sendPolicyWithExtra(ctx context.Context, policyList id.RoomID, entityType policylist.EntityType, stateKey, rawEntity string, content *event.ModPolicyContent, extra map[string]any) (*mautrix.RespSendEvent, error) {
+	isRegex, _ := extra[policylist.UnstableRegexKey].(bool)
+	if err := pe.checkBanSafeguards(entityType, rawEntity, content.Recommendation, isRegex); err != nil {
+		return nil, err
+	}
 	if stateKey == "" {
 		stateKeyHash := sha256.Sum256(append([]byte(rawEntity), []byte(content.Recommendation)...))
 		stateKey = base64.StdEncoding.EncodeToString(stateKeyHash[:])
 	}
-	return pe.Bot.SendStateEvent(ctx, policyList, entityType.EventType(), stateKey, content)
+	var wrappedContent any = content
+	if len(extra) > 0 {
+		wrappedContent = &event.Content{
+			Parsed: content,
+			Raw:    extra,
+		}
+	}
+	var resp *mautrix.RespSendEvent
+	var err error
+	if pe.DryRun {
+		zerolog.Ctx(ctx).Info().
+			Stringer("policy_list", policyList).
+			Str("state_key", stateKey).
+			Any("policy", wrappedContent).
+			Msg("Dry run: not sending policy")
+		resp = &mautrix.RespSendEvent{EventID: "$fake-policy-id"}
+	} else {
+		resp, err = pe.Bot.SendStateEvent(ctx, policyList, entityType.EventType(), stateKey, wrappedContent)
+	}
+	if err == nil {
+		entity := content.Entity
+		if entity == "" && content.UnstableHashes != nil {
+			entity = content.UnstableHashes.SHA256
+		}
+		pe.logAction(ctx, &database.AuditLogEntry{
+			Action:         database.AuditLogActionSendPolicy,
+			TargetEvent:    resp.EventID,
+			Reason:         content.Reason,
+			PolicyList:     policyList,
+			Entity:         entity,
+			Recommendation: string(content.Recommendation),
+		})
+	}
+	return resp, err
+}
+
+// RemovePolicy removes the given policy by sending an empty event with the same type and state key.
+// In dry run mode, the removal is only logged and a fake event ID is returned.
+func (pe *PolicyEvaluator) RemovePolicy(ctx context.Context, policy *policylist.Policy) (*mautrix.RespSendEvent, error) {
+	var resp *mautrix.RespSendEvent
+	var err error
+	if pe.DryRun {
+		zerolog.Ctx(ctx).Info().
+			Stringer("policy_list", policy.RoomID).
+			Stringer("policy_event_id", policy.ID).
+			Msg("Dry run: not removing policy")
+		resp = &mautrix.RespSendEvent{EventID: "$fake-policy-id"}
+	} else {
+		resp, err = pe.Bot.SendStateEvent(ctx, policy.RoomID, policy.Type, policy.StateKey, &event.ModPolicyContent{})
+	}
+	if err == nil {
+		pe.logAction(ctx, &database.AuditLogEntry{
+			Action:         database.AuditLogActionRemovePolicy,
+			TargetEvent:    resp.EventID,
+			PolicyList:     policy.RoomID,
+			Entity:         policy.EntityOrHash(),
+			Recommendation: string(policy.Recommendation),
+		})
+	}
+	return resp, err
+}
+
+func (pe *PolicyEvaluator) addEntityNote(ce *CommandEvent, entity, note string) bool {
+	err := pe.DB.EntityNote.Put(ce.Ctx, &database.EntityNote{
+		ManagementRoom: pe.ManagementRoom,
+		Entity:         entity,
+		Note:           note,
+		Author:         actorFromContext(ce.Ctx),
+		CreatedAt:      time.Now(),
+	})
+	if err != nil {
+		zerolog.Ctx(ce.Ctx).Err(err).Str("entity", entity).Msg("Failed to save internal note")
+		ce.Reply("Failed to save internal note: %v", err)
+		return false
+	}
+	return true
+}
+
+func (pe *PolicyEvaluator) formatEntityNotes(ctx context.Context, entity string) string {
+	notes, err := pe.DB.EntityNote.GetAllByEntity(ctx, pe.ManagementRoom, entity)
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Str("entity", entity).Msg("Failed to get internal notes")
+		return ""
+	} else if len(notes) == 0 {
+		return ""
+	}
+	noteStrings := make([]string, len(notes))
+	for i, note := range notes {
+		noteStrings[i] = fmt.Sprintf(
+			"  * 🔒 Internal note by [%s](%s) at %s: %s",
+			note.Author, note.Author.URI().MatrixToURL(),
+			format.EscapeMarkdown(note.CreatedAt.String()),
+			format.EscapeMarkdown(note.Note),
+		)
+	}
+	return strings.Join(noteStrings, "\n")
+}
+
+// formatEntityNotesSuffix formats the internal notes about an entity as a separate section to append to messages.
+// An empty string is returned if there are no notes.
+func (pe *PolicyEvaluator) formatEntityNotesSuffix(ctx context.Context, entity string) string {
+	notes := pe.formatEntityNotes(ctx, entity)
+	if notes == "" {
+		return ""
+	}
+	return fmt.Sprintf("\n\nInternal notes about %s:\n\n%s", format.SafeMarkdownCode(entity), notes)
 }
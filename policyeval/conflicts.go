@@ -0,0 +1,103 @@
+package policyeval
+
+import (
+	"fmt"
+	"strings"
+
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+
+	"go.mau.fi/meowlnir/policylist"
+	"go.mau.fi/meowlnir/util"
+)
+
+type conflictKey struct {
+	EntityType policylist.EntityType
+	Hash       [util.HashSize]byte
+}
+
+// findPolicyConflicts finds entities that have both a ban (or takedown) and an unban policy in different watched lists.
+// The returned matches are in list priority order, so the first ban or unban policy in each match is the one that applies.
+func (pe *PolicyEvaluator) findPolicyConflicts() (conflicts []policylist.Match) {
+	groups := make(map[conflictKey]policylist.Match)
+	var order []conflictKey
+	for _, listID := range pe.GetWatchedLists() {
+		for _, policy := range pe.Store.GetAllPolicies(listID) {
+			if !policylist.IsBanOrUnban(policy.Recommendation) {
+				continue
+			}
+			key := conflictKey{EntityType: policy.EntityType}
+			if policy.Entity != "" {
+				key.Hash = util.SHA256String(policy.Entity)
+			} else if policy.EntityHash != nil {
+				key.Hash = *policy.EntityHash
+			} else {
+				continue
+			}
+			if _, exists := groups[key]; !exists {
+				order = append(order, key)
+			}
+			groups[key] = append(groups[key], policy)
+		}
+	}
+	for _, key := range order {
+		group := groups[key]
+		var hasBan, hasUnban, multipleLists bool
+		for _, policy := range group {
+			if policy.Recommendation == event.PolicyRecommendationUnban {
+				hasUnban = true
+			} else {
+				hasBan = true
+			}
+			multipleLists = multipleLists || policy.RoomID != group[0].RoomID
+		}
+		if hasBan && hasUnban && multipleLists {
+			conflicts = append(conflicts, group)
+		}
+	}
+	return
+}
+
+const maxListedConflicts = 25
+
+var cmdConflicts = &CommandHandler{
+	Name: "conflicts",
+	Func: func(ce *CommandEvent) {
+		conflicts := ce.Meta.findPolicyConflicts()
+		if len(conflicts) == 0 {
+			ce.Reply("No conflicting policies found in watched lists")
+			return
+		}
+		var buf strings.Builder
+		_, _ = fmt.Fprintf(&buf, "Found %d entities with conflicting recommendations:\n\n", len(conflicts))
+		for _, group := range conflicts[:min(len(conflicts), maxListedConflicts)] {
+			entity := group[0].EntityOrHash()
+			for _, policy := range group {
+				if policy.Entity != "" {
+					entity = policy.Entity
+					break
+				}
+			}
+			winner := group.Recommendations().BanOrUnban
+			_, _ = fmt.Fprintf(
+				&buf, "* %s %s - %s applies\n",
+				group[0].EntityType, format.SafeMarkdownCode(entity), format.SafeMarkdownCode(winner.Recommendation),
+			)
+			for _, policy := range group {
+				listName := policy.RoomID.String()
+				if meta := ce.Meta.GetWatchedListMeta(policy.RoomID); meta != nil {
+					listName = meta.Name
+				}
+				_, _ = fmt.Fprintf(
+					&buf, "    * %s: %s by [%s](%s) for %s\n",
+					format.EscapeMarkdown(listName), format.SafeMarkdownCode(policy.Recommendation),
+					policy.Sender, policy.Sender.URI().MatrixToURL(), format.SafeMarkdownCode(policy.Reason),
+				)
+			}
+		}
+		if len(conflicts) > maxListedConflicts {
+			_, _ = fmt.Fprintf(&buf, "* ...and %d more\n", len(conflicts)-maxListedConflicts)
+		}
+		ce.Reply("%s", buf.String())
+	},
+}
@@ -0,0 +1,128 @@
+package policyeval
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"maunium.net/go/mautrix/format"
+
+	"go.mau.fi/meowlnir/policylist"
+	"go.mau.fi/meowlnir/util"
+)
+
+// maxDiffLines is the maximum number of entities listed in each section of the diff-lists output.
+const maxDiffLines = 50
+
+type diffKey struct {
+	EntityType policylist.EntityType
+	Hash       [util.HashSize]byte
+}
+
+// policiesByEntity groups policies by entity type and entity hash, so that plaintext and hashed
+// policies for the same entity are treated as the same entity.
+func policiesByEntity(policies policylist.Match) map[diffKey]*policylist.Policy {
+	output := make(map[diffKey]*policylist.Policy, len(policies))
+	for _, policy := range policies {
+		key := diffKey{EntityType: policy.EntityType}
+		if policy.Entity != "" {
+			key.Hash = util.SHA256String(policy.Entity)
+		} else if policy.EntityHash != nil {
+			key.Hash = *policy.EntityHash
+		} else {
+			continue
+		}
+		if _, exists := output[key]; !exists {
+			output[key] = policy
+		}
+	}
+	return output
+}
+
+func sortPoliciesForDiff(policies []*policylist.Policy) {
+	slices.SortFunc(policies, func(a, b *policylist.Policy) int {
+		return cmp.Or(cmp.Compare(a.EntityType, b.EntityType), cmp.Compare(a.EntityOrHash(), b.EntityOrHash()))
+	})
+}
+
+func writeDiffSection(buf *strings.Builder, title string, lines []string) {
+	_, _ = fmt.Fprintf(buf, "\n\n**%s** (%d):\n\n", title, len(lines))
+	if len(lines) == 0 {
+		buf.WriteString("* nothing")
+		return
+	}
+	if len(lines) > maxDiffLines {
+		lines = append(lines[:maxDiffLines:maxDiffLines], fmt.Sprintf("* ...and %d more", len(lines)-maxDiffLines))
+	}
+	buf.WriteString(strings.Join(lines, "\n"))
+}
+
+var cmdDiffLists = &CommandHandler{
+	Name: "diff-lists",
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) != 2 {
+			replyUsage(ce)
+			return
+		}
+		listA := ce.Meta.FindListByShortcode(ce.Args[0])
+		if listA == nil {
+			ce.Reply("List %s not found", format.SafeMarkdownCode(ce.Args[0]))
+			return
+		}
+		listB := ce.Meta.FindListByShortcode(ce.Args[1])
+		if listB == nil {
+			ce.Reply("List %s not found", format.SafeMarkdownCode(ce.Args[1]))
+			return
+		}
+		if listA.RoomID == listB.RoomID {
+			ce.Reply("Can't compare a list with itself")
+			return
+		}
+		policiesA := policiesByEntity(ce.Meta.Store.GetAllPolicies(listA.RoomID))
+		policiesB := policiesByEntity(ce.Meta.Store.GetAllPolicies(listB.RoomID))
+		var onlyA, onlyB []*policylist.Policy
+		var differs [][2]*policylist.Policy
+		for key, policyA := range policiesA {
+			policyB, ok := policiesB[key]
+			if !ok {
+				onlyA = append(onlyA, policyA)
+			} else if policyA.Recommendation != policyB.Recommendation {
+				differs = append(differs, [2]*policylist.Policy{policyA, policyB})
+			}
+		}
+		for key, policyB := range policiesB {
+			if _, ok := policiesA[key]; !ok {
+				onlyB = append(onlyB, policyB)
+			}
+		}
+		sortPoliciesForDiff(onlyA)
+		sortPoliciesForDiff(onlyB)
+		slices.SortFunc(differs, func(a, b [2]*policylist.Policy) int {
+			return cmp.Or(cmp.Compare(a[0].EntityType, b[0].EntityType), cmp.Compare(a[0].EntityOrHash(), b[0].EntityOrHash()))
+		})
+		formatOnly := func(policies []*policylist.Policy) []string {
+			lines := make([]string, len(policies))
+			for i, policy := range policies {
+				lines[i] = fmt.Sprintf("* %s %s %s", policy.EntityType, formatMatchEntity(policy), formatMatchRecommendation(policy))
+			}
+			return lines
+		}
+		differsLines := make([]string, len(differs))
+		for i, pair := range differs {
+			differsLines[i] = fmt.Sprintf(
+				"* %s %s: %s in A, %s in B",
+				pair[0].EntityType, formatMatchEntity(pair[0]), formatMatchRecommendation(pair[0]), formatMatchRecommendation(pair[1]),
+			)
+		}
+		var buf strings.Builder
+		_, _ = fmt.Fprintf(
+			&buf, "Comparing **%s** (A, %d entities) with **%s** (B, %d entities)",
+			format.EscapeMarkdown(listA.Name), len(policiesA), format.EscapeMarkdown(listB.Name), len(policiesB),
+		)
+		writeDiffSection(&buf, "Only in A", formatOnly(onlyA))
+		writeDiffSection(&buf, "Only in B", formatOnly(onlyB))
+		writeDiffSection(&buf, "Differs", differsLines)
+		ce.Reply(buf.String())
+	},
+}
@@ -0,0 +1,266 @@
+package policyeval
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.mau.fi/util/exsync"
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/commands"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+	"maunium.net/go/mautrix/synapseadmin"
+
+	"go.mau.fi/meowlnir/bot"
+	"go.mau.fi/meowlnir/config"
+	"go.mau.fi/meowlnir/policylist"
+)
+
+const (
+	testPolicyList     id.RoomID = "!list:example.com"
+	testRoom           id.RoomID = "!room:example.com"
+	testManagementRoom id.RoomID = "!management:example.com"
+	testBot            id.UserID = "@bot:example.com"
+	testSpammer        id.UserID = "@spammer:example.com"
+)
+
+// dryRunHomeserver records the messages sent to the management room by a dry-run policy evaluator.
+type dryRunHomeserver struct {
+	lock     sync.Mutex
+	messages []string
+}
+
+func (hs *dryRunHomeserver) findMessage(substring string) bool {
+	hs.lock.Lock()
+	defer hs.lock.Unlock()
+	for _, msg := range hs.messages {
+		if strings.Contains(msg, substring) {
+			return true
+		}
+	}
+	return false
+}
+
+// newDryRunEvaluator returns a dry-run policy evaluator whose bot client talks to a test server.
+// The handler is called for read requests and messages to the management room are recorded,
+// while any other request fails the test.
+func newDryRunEvaluator(t *testing.T, handleRead http.HandlerFunc) (*PolicyEvaluator, *dryRunHomeserver) {
+	t.Helper()
+	hs := &dryRunHomeserver{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/rooms/"+testManagementRoom.String()+"/send/") {
+			var content event.MessageEventContent
+			_ = json.NewDecoder(r.Body).Decode(&content)
+			hs.lock.Lock()
+			hs.messages = append(hs.messages, content.Body)
+			hs.lock.Unlock()
+			_, _ = w.Write([]byte(`{"event_id":"$notice"}`))
+			return
+		} else if r.Method != http.MethodGet || handleRead == nil {
+			t.Errorf("Unexpected %s request to %s in dry run mode", r.Method, r.URL.Path)
+			http.Error(w, `{"errcode":"M_FORBIDDEN","error":"dry run"}`, http.StatusForbidden)
+			return
+		}
+		handleRead(w, r)
+	}))
+	t.Cleanup(server.Close)
+	cli, err := mautrix.NewClient(server.URL, testBot, "token")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return &PolicyEvaluator{
+		Bot: &bot.Bot{
+			Client:       cli,
+			SynapseAdmin: &synapseadmin.Client{Client: cli},
+			ServerName:   "example.com",
+		},
+		Store:           policylist.NewStore(),
+		Admins:          exsync.NewSet[id.UserID](),
+		ManagementRoom:  testManagementRoom,
+		DryRun:          true,
+		protectedRooms:  make(map[id.RoomID]*protectedRoomMeta),
+		watchedListsMap: make(map[id.RoomID]*config.WatchedPolicyList),
+	}, hs
+}
+
+// newDryRunCommand returns a command event for running a command handler directly against the given evaluator.
+func newDryRunCommand(pe *PolicyEvaluator, command string, args ...string) *CommandEvent {
+	return &CommandEvent{
+		Event: &event.Event{
+			ID:     "$command",
+			RoomID: testManagementRoom,
+			Sender: "@admin:example.com",
+		},
+		Command: command,
+		Args:    args,
+		Ctx:     context.Background(),
+		Proc:    commands.NewProcessor[*PolicyEvaluator](pe.Bot.Client),
+		Meta:    pe,
+	}
+}
+
+// writeJSONForPath writes the response for the first path suffix that matches the request.
+func writeJSONForPath(t *testing.T, w http.ResponseWriter, r *http.Request, responses map[string]any) {
+	for suffix, resp := range responses {
+		if strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), suffix) {
+			_ = json.NewEncoder(w).Encode(resp)
+			return
+		}
+	}
+	t.Errorf("Unexpected read request to %s", r.URL.Path)
+	http.NotFound(w, r)
+}
+
+func TestDryRun_SendPolicy(t *testing.T) {
+	pe, _ := newDryRunEvaluator(t, nil)
+	resp, err := pe.SendPolicy(context.Background(), testPolicyList, policylist.EntityTypeUser, "", testSpammer.String(), &event.ModPolicyContent{
+		Entity:         testSpammer.String(),
+		Reason:         "spam",
+		Recommendation: event.PolicyRecommendationBan,
+	})
+	if err != nil {
+		t.Fatalf("SendPolicy returned error in dry run mode: %v", err)
+	} else if resp == nil || resp.EventID == "" {
+		t.Fatalf("SendPolicy didn't return a fake event ID in dry run mode")
+	}
+	if actions := pe.recentActions; len(actions) != 1 || !actions[0].DryRun {
+		t.Errorf("Expected one dry run audit log entry, got %+v", actions)
+	}
+}
+
+func TestDryRun_RemovePolicy(t *testing.T) {
+	pe, _ := newDryRunEvaluator(t, nil)
+	policy := &policylist.Policy{
+		ModPolicyContent: &event.ModPolicyContent{
+			Entity:         testSpammer.String(),
+			Recommendation: event.PolicyRecommendationBan,
+		},
+		EntityType: policylist.EntityTypeUser,
+		RoomID:     testPolicyList,
+		StateKey:   "spammer",
+		Type:       event.StatePolicyUser,
+		ID:         "$policy",
+	}
+	resp, err := pe.RemovePolicy(context.Background(), policy)
+	if err != nil {
+		t.Fatalf("RemovePolicy returned error in dry run mode: %v", err)
+	} else if resp == nil || resp.EventID == "" {
+		t.Fatalf("RemovePolicy didn't return a fake event ID in dry run mode")
+	}
+}
+
+func TestDryRun_RedactRecentMessages(t *testing.T) {
+	pe, _ := newDryRunEvaluator(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSONForPath(t, w, r, map[string]any{
+			"/state/m.room.power_levels": &event.PowerLevelsEventContent{},
+			"/messages": map[string]any{
+				"start": "start",
+				"chunk": []map[string]any{
+					{"type": "m.room.message", "event_id": "$1", "sender": testSpammer, "origin_server_ts": 2, "content": map[string]any{"body": "spam"}},
+					{"type": "m.room.message", "event_id": "$2", "sender": "@innocent:example.com", "origin_server_ts": 1, "content": map[string]any{"body": "hi"}},
+				},
+			},
+		})
+	})
+	count, err := pe.redactRecentMessages(context.Background(), testRoom, testSpammer, 0, true, 0, "spam")
+	if err != nil {
+		t.Fatalf("redactRecentMessages returned error in dry run mode: %v", err)
+	} else if count != 1 {
+		t.Errorf("Expected 1 event to be counted as redacted, got %d", count)
+	}
+}
+
+func TestDryRun_UpdateACL(t *testing.T) {
+	pe, _ := newDryRunEvaluator(t, nil)
+	pe.ServerACL.Enabled = true
+	pe.protectedRooms[testRoom] = &protectedRoomMeta{ApplyACL: true}
+	changed, succeeded := pe.UpdateACL(context.Background())
+	if changed != 1 || succeeded != 1 {
+		t.Errorf("Expected ACL to be simulated in 1/1 rooms, got %d/%d", succeeded, changed)
+	}
+	if pe.protectedRooms[testRoom].ACL != nil {
+		t.Errorf("Cached ACL was updated in dry run mode")
+	}
+}
+
+func TestDryRun_DenyServerInProtectedRooms(t *testing.T) {
+	pe, _ := newDryRunEvaluator(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSONForPath(t, w, r, map[string]any{
+			"/state/m.room.server_acl":   &event.ServerACLEventContent{Allow: []string{"*"}},
+			"/state/m.room.power_levels": &event.PowerLevelsEventContent{Users: map[id.UserID]int{testBot: 100}},
+		})
+	})
+	pe.protectedRooms[testRoom] = &protectedRoomMeta{Name: "Test", ApplyACL: true}
+	results := pe.DenyServerInProtectedRooms(context.Background(), "evil.example")
+	if len(results) != 1 || !strings.Contains(results[0], "would update ACL") {
+		t.Errorf("Expected a dry run result, got %v", results)
+	}
+}
+
+func TestDryRun_PowerLevel(t *testing.T) {
+	pe, hs := newDryRunEvaluator(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSONForPath(t, w, r, map[string]any{
+			"/state/m.room.power_levels": &event.PowerLevelsEventContent{Users: map[id.UserID]int{testBot: 100}},
+		})
+	})
+	cmdPowerLevel.Func(newDryRunCommand(pe, "powerlevel", testRoom.String(), testSpammer.String(), "-1"))
+	if !hs.findMessage("Dry run: would have set power level") {
+		t.Errorf("Expected a dry run reply, got %v", hs.messages)
+	}
+}
+
+func TestDryRun_Suspend(t *testing.T) {
+	pe, hs := newDryRunEvaluator(t, nil)
+	cmdSuspend.Func(newDryRunCommand(pe, "suspend", testSpammer.String()))
+	if !hs.findMessage("Dry run: would have suspended") {
+		t.Errorf("Expected a dry run reply, got %v", hs.messages)
+	}
+}
+
+func TestDryRun_AutoSuspend(t *testing.T) {
+	pe, hs := newDryRunEvaluator(t, nil)
+	pe.watchedListsMap[testPolicyList] = &config.WatchedPolicyList{RoomID: testPolicyList, AutoSuspend: true}
+	pe.maybeApplySuspend(context.Background(), testSpammer, &policylist.Policy{
+		ModPolicyContent: &event.ModPolicyContent{Recommendation: event.PolicyRecommendationBan},
+		RoomID:           testPolicyList,
+	})
+	if !hs.findMessage("Dry run: would have suspended") {
+		t.Errorf("Expected a dry run notice, got %v", hs.messages)
+	}
+}
+
+func TestDryRun_UnbanStillBannedBySameList(t *testing.T) {
+	pe, hs := newDryRunEvaluator(t, nil)
+	pe.watchedListsMap[testPolicyList] = &config.WatchedPolicyList{RoomID: testPolicyList, Shortcode: "test", Name: "Test"}
+	pe.watchedListsList = []id.RoomID{testPolicyList}
+	newPolicy := func(stateKey, entity string) *event.Event {
+		return &event.Event{
+			Type:     event.StatePolicyUser,
+			StateKey: &stateKey,
+			RoomID:   testPolicyList,
+			ID:       id.EventID("$" + stateKey),
+			Content: event.Content{Parsed: &event.ModPolicyContent{
+				Entity:         entity,
+				Recommendation: event.PolicyRecommendationBan,
+			}},
+		}
+	}
+	pe.Store.Add(testPolicyList, map[event.Type]map[string]*event.Event{
+		event.StatePolicyUser: {
+			"exact": newPolicy("exact", testSpammer.String()),
+			"glob":  newPolicy("glob", "@spam*:example.com"),
+		},
+	})
+	cmdUnban.Func(newDryRunCommand(pe, "unban", "test", testSpammer.String()))
+	if !hs.findMessage("Removed `m.ban` policy for `@spammer:example.com`") {
+		t.Errorf("Expected the exact policy to be removed, got %v", hs.messages)
+	}
+	if !hs.findMessage("still banned by `@spam*:example.com`") {
+		t.Errorf("Expected the glob policy in the same list to prevent unbanning, got %v", hs.messages)
+	}
+}
@@ -0,0 +1,128 @@
+package policyeval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/rs/zerolog"
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+)
+
+type relatesToOnly struct {
+	RelatesTo *event.RelatesTo `json:"m.relates_to,omitempty"`
+}
+
+type respRelations struct {
+	Chunk     []*event.Event `json:"chunk"`
+	NextBatch string         `json:"next_batch"`
+}
+
+// getEdits returns the IDs of m.replace relations to the given event. Only edits sent by the given sender
+// are included, as edits from anyone else are invalid and must not be redacted along with the original.
+func (pe *PolicyEvaluator) getEdits(ctx context.Context, roomID id.RoomID, eventID id.EventID, sender id.UserID) ([]id.EventID, error) {
+	var edits []id.EventID
+	var from string
+	for {
+		query := map[string]string{"limit": "100"}
+		if from != "" {
+			query["from"] = from
+		}
+		var resp respRelations
+		reqURL := pe.Bot.BuildURLWithQuery(mautrix.ClientURLPath{"v1", "rooms", roomID, "relations", eventID, event.RelReplace}, query)
+		_, err := pe.Bot.MakeRequest(ctx, http.MethodGet, reqURL, nil, &resp)
+		if err != nil {
+			return edits, err
+		}
+		for _, evt := range resp.Chunk {
+			if evt.Sender == sender {
+				edits = append(edits, evt.ID)
+			}
+		}
+		if resp.NextBatch == "" || len(resp.Chunk) == 0 {
+			return edits, nil
+		}
+		from = resp.NextBatch
+	}
+}
+
+// redactEventAndEdits redacts the given event. If edit redaction is enabled, the original event
+// (if the target is an edit) and all edits of the original are redacted too.
+// The returned count only includes the related events, not the target event itself.
+func (pe *PolicyEvaluator) redactEventAndEdits(ctx context.Context, roomID id.RoomID, eventID id.EventID, reason string) (relatedCount int, err error) {
+	targets := []id.EventID{eventID}
+	if pe.RedactEdits {
+		targets = pe.findEditChain(ctx, roomID, eventID)
+	}
+	for _, target := range targets {
+		if !pe.DryRun {
+			_, err = pe.Bot.RedactEvent(ctx, roomID, target, mautrix.ReqRedact{Reason: reason})
+		}
+		if err != nil {
+			if target == eventID {
+				return relatedCount, err
+			}
+			zerolog.Ctx(ctx).Err(err).
+				Stringer("room_id", roomID).
+				Stringer("event_id", target).
+				Msg("Failed to redact related edit event")
+			err = nil
+		} else if target != eventID {
+			relatedCount++
+		}
+	}
+	return relatedCount, nil
+}
+
+// findEditChain returns the given event, the original event it edits (if any) and all edits of the original.
+// Only events from the same sender as the original are included.
+// Lookup failures are logged and the found events are returned anyway.
+func (pe *PolicyEvaluator) findEditChain(ctx context.Context, roomID id.RoomID, eventID id.EventID) []id.EventID {
+	log := zerolog.Ctx(ctx).With().
+		Stringer("room_id", roomID).
+		Stringer("event_id", eventID).
+		Logger()
+	chain := []id.EventID{eventID}
+	originalID := eventID
+	evt, err := pe.Bot.GetEvent(ctx, roomID, eventID)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to get event to check for edits")
+		return chain
+	}
+	var content relatesToOnly
+	if err = json.Unmarshal(evt.Content.VeryRaw, &content); err != nil {
+		log.Warn().Err(err).Msg("Failed to parse relation of event")
+	} else if replaceID := content.RelatesTo.GetReplaceID(); replaceID != "" {
+		original, err := pe.Bot.GetEvent(ctx, roomID, replaceID)
+		if err != nil {
+			log.Warn().Err(err).Stringer("original_event_id", replaceID).Msg("Failed to get original event of edit")
+			return chain
+		} else if original.Sender != evt.Sender {
+			log.Debug().Stringer("original_event_id", replaceID).Msg("Not redacting original event as the edit is from a different sender")
+			return chain
+		}
+		originalID = replaceID
+		chain = append(chain, originalID)
+	}
+	edits, err := pe.getEdits(ctx, roomID, originalID, evt.Sender)
+	if err != nil {
+		log.Warn().Err(err).Stringer("original_event_id", originalID).Msg("Failed to get edits of event")
+	}
+	for _, edit := range edits {
+		if !slices.Contains(chain, edit) {
+			chain = append(chain, edit)
+		}
+	}
+	return chain
+}
+
+func formatRelatedRedactions(count int) string {
+	if count == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" and %s", pluralize(count, "related edit event"))
+}
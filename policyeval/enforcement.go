@@ -0,0 +1,56 @@
+package policyeval
+
+import (
+	"slices"
+	"strings"
+
+	"maunium.net/go/mautrix/format"
+
+	"go.mau.fi/meowlnir/config"
+)
+
+var cmdSetEnforcement = &CommandHandler{
+	Name: "set-enforcement",
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) != 2 {
+			replyUsage(ce)
+			return
+		}
+		var observeOnly bool
+		switch strings.ToLower(ce.Args[1]) {
+		case "observe":
+			observeOnly = true
+		case "enforce":
+			observeOnly = false
+		default:
+			replyUsage(ce)
+			return
+		}
+		roomID := resolveRoom(ce, ce.Args[0])
+		if roomID == "" {
+			return
+		} else if !ce.Meta.IsProtectedRoom(roomID) {
+			ce.Reply("%s is not a protected room", format.SafeMarkdownCode(ce.Args[0]))
+			return
+		}
+		ce.Meta.protectedRoomsLock.RLock()
+		contentCopy := *ce.Meta.protectedRoomsEvent
+		contentCopy.ObserveOnly = slices.Clone(contentCopy.ObserveOnly)
+		ce.Meta.protectedRoomsLock.RUnlock()
+		itemIdx := slices.Index(contentCopy.ObserveOnly, roomID)
+		if observeOnly == (itemIdx >= 0) {
+			ce.Reply("%s is already in %s mode", ce.Meta.formatRoomLink(roomID), strings.ToLower(ce.Args[1]))
+			return
+		} else if observeOnly {
+			contentCopy.ObserveOnly = append(contentCopy.ObserveOnly, roomID)
+		} else {
+			contentCopy.ObserveOnly = slices.Delete(contentCopy.ObserveOnly, itemIdx, itemIdx+1)
+		}
+		_, err := ce.Meta.Bot.SendStateEvent(ce.Ctx, ce.Meta.ManagementRoom, config.StateProtectedRooms, "", &contentCopy)
+		if err != nil {
+			ce.Reply("Failed to update protected rooms: %v", err)
+			return
+		}
+		ce.React(SuccessReaction)
+	},
+}
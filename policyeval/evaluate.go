@@ -5,6 +5,9 @@ import (
 	"iter"
 	"maps"
 	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/rs/zerolog"
 	"go.mau.fi/util/glob"
@@ -85,6 +88,53 @@ func (pe *PolicyEvaluator) EvaluateAll(ctx context.Context) {
 	pe.UpdateACL(ctx)
 }
 
+const startupScanConcurrency = 8
+
+// StartupScan evaluates all known users against all policies with bounded concurrency
+// to reconcile enforcement after downtime, then sends a summary to the management room.
+func (pe *PolicyEvaluator) StartupScan(ctx context.Context) {
+	start := time.Now()
+	users := pe.getAllUsers()
+	lists := pe.GetWatchedLists()
+	userChan := make(chan id.UserID)
+	var actionCount atomic.Int64
+	var wg sync.WaitGroup
+	wg.Add(startupScanConcurrency)
+	for range startupScanConcurrency {
+		go func() {
+			defer wg.Done()
+			for userID := range userChan {
+				match := pe.Store.MatchUser(lists, userID)
+				if match == nil {
+					continue
+				}
+				actionCount.Add(int64(pe.ApplyPolicy(ctx, userID, match, false)))
+			}
+		}()
+	}
+	for _, userID := range users {
+		userChan <- userID
+	}
+	close(userChan)
+	wg.Wait()
+	pe.UpdateACL(ctx)
+	dur := time.Since(start)
+	zerolog.Ctx(ctx).Info().
+		Int("user_count", len(users)).
+		Int64("action_count", actionCount.Load()).
+		Dur("duration", dur).
+		Msg("Finished startup scan")
+	actionsTaken := "taken"
+	if pe.DryRun {
+		actionsTaken = "simulated (dry run)"
+	}
+	pe.sendNotice(ctx,
+		"Startup scan finished in %s: %s, %s, %d enforcement actions %s",
+		dur, pluralize(len(pe.GetProtectedRooms()), "room"), pluralize(len(users), "user"),
+		actionCount.Load(), actionsTaken,
+	)
+}
+
 func (pe *PolicyEvaluator) EvaluateAllMembers(ctx context.Context, members []id.UserID) {
 	for _, member := range members {
 		pe.EvaluateUser(ctx, member, false)
@@ -92,6 +142,7 @@ func (pe *PolicyEvaluator) EvaluateAllMembers(ctx context.Context, members []id.
 }
 
 func (pe *PolicyEvaluator) EvaluateUser(ctx context.Context, userID id.UserID, isNewRule bool) {
+	userEvaluations.Inc()
 	match := pe.Store.MatchUser(pe.GetWatchedLists(), userID)
 	if match == nil {
 		return
@@ -105,7 +156,7 @@ func (pe *PolicyEvaluator) EvaluateRemovedRule(ctx context.Context, policy *poli
 		if policy.Recommendation == event.PolicyRecommendationUnban {
 			// When an unban rule is removed, evaluate all joined users against the removed rule
 			// to see if they should be re-evaluated against all rules (and possibly banned)
-			for userID := range pe.findMatchingUsers(policy.Pattern, policy.EntityHash, false) {
+			for userID := range pe.findMatchingUsers(policy.UserMatchPattern(), policy.EntityHash, false) {
 				pe.EvaluateUser(ctx, userID, false)
 			}
 		} else {
@@ -114,7 +165,7 @@ func (pe *PolicyEvaluator) EvaluateRemovedRule(ctx context.Context, policy *poli
 			if err != nil {
 				zerolog.Ctx(ctx).Err(err).Str("policy_entity", policy.EntityOrHash()).
 					Msg("Failed to get actions taken for removed policy")
-				pe.sendNotice(ctx, "Database error in EvaluateRemovedRule (GetAllByRuleEntity): %v", err)
+				pe.sendNoticeNow(ctx, "Database error in EvaluateRemovedRule (GetAllByRuleEntity): %v", err)
 			} else if len(reevalTargets) > 0 {
 				zerolog.Ctx(ctx).Debug().
 					Int("reeval_targets", len(reevalTargets)).
@@ -133,7 +184,7 @@ func (pe *PolicyEvaluator) EvaluateAddedRule(ctx context.Context, policy *policy
 	switch policy.EntityType {
 	case policylist.EntityTypeUser:
 		didEval := false
-		for userID := range pe.findMatchingUsers(policy.Pattern, policy.EntityHash, false) {
+		for userID := range pe.findMatchingUsers(policy.UserMatchPattern(), policy.EntityHash, false) {
 			didEval = true
 			// Do a full evaluation to ensure new policies don't bypass existing higher priority policies
 			pe.EvaluateUser(ctx, userID, true)
@@ -147,7 +198,7 @@ func (pe *PolicyEvaluator) EvaluateAddedRule(ctx context.Context, policy *policy
 	case policylist.EntityTypeServer:
 		pe.DeferredUpdateACL()
 	case policylist.EntityTypeRoom:
-		// Ignored for now, could hook up to room deletion later
+		pe.EvaluateAddedRoomRule(ctx, policy)
 	}
 }
 
@@ -158,7 +209,7 @@ func (pe *PolicyEvaluator) ReevaluateAffectedByLists(ctx context.Context, policy
 		if err != nil {
 			zerolog.Ctx(ctx).Err(err).Stringer("policy_list_id", list).
 				Msg("Failed to get actions taken from policy list")
-			pe.sendNotice(ctx, "Database error in ReevaluateAffectedByLists (GetAllByPolicyList): %v", err)
+			pe.sendNoticeNow(ctx, "Database error in ReevaluateAffectedByLists (GetAllByPolicyList): %v", err)
 			continue
 		}
 		if reevalTargets == nil {
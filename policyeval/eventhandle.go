@@ -57,13 +57,13 @@ func (pe *PolicyEvaluator) HandleMember(ctx context.Context, evt *event.Event) {
 			return
 		}
 		if isProtecting && (content.Membership == event.MembershipLeave || content.Membership == event.MembershipBan) {
-			pe.sendNotice(ctx, "⚠️ Bot was removed from [%s](%s)", evt.RoomID, evt.RoomID.URI().MatrixToURL())
+			pe.sendNoticeNow(ctx, "⚠️ Bot was removed from [%s](%s)", evt.RoomID, evt.RoomID.URI().MatrixToURL())
 		} else if wantToProtect && (content.Membership == event.MembershipJoin || content.Membership == event.MembershipInvite) {
 			_, err := pe.Bot.JoinRoomByID(ctx, evt.RoomID)
 			if err != nil {
-				pe.sendNotice(ctx, "Failed to join room [%s](%s): %v", evt.RoomID, evt.RoomID.URI().MatrixToURL(), err)
+				pe.sendNoticeNow(ctx, "Failed to join room [%s](%s): %v", evt.RoomID, evt.RoomID.URI().MatrixToURL(), err)
 			} else if _, errMsg := pe.tryProtectingRoom(ctx, nil, evt.RoomID, true); errMsg != "" {
-				pe.sendNotice(ctx, "Retried protecting room after joining room, but failed: %s", strings.TrimPrefix(errMsg, "* "))
+				pe.sendNoticeNow(ctx, "Retried protecting room after joining room, but failed: %s", strings.TrimPrefix(errMsg, "* "))
 			} else {
 				pe.sendNotice(ctx, "Bot was invited to room, now protecting [%s](%s)", evt.RoomID, evt.RoomID.URI().MatrixToURL())
 			}
@@ -72,6 +72,9 @@ func (pe *PolicyEvaluator) HandleMember(ctx context.Context, evt *event.Event) {
 		checkRules := pe.updateUser(userID, evt.RoomID, content.Membership)
 		if checkRules {
 			pe.EvaluateUser(ctx, userID, false)
+			if content.Membership == event.MembershipJoin {
+				pe.notifyWarnPolicy(ctx, userID, evt.RoomID)
+			}
 		}
 	}
 }
@@ -122,10 +125,14 @@ func (pe *PolicyEvaluator) HandlePolicyListChange(ctx context.Context, policyRoo
 		Any("removed", removed).
 		Msg("Policy list change")
 	removedAndAddedAreEquivalent := removed != nil && added != nil && removed.EntityOrHash() == added.EntityOrHash() && removed.Recommendation == added.Recommendation
-	sendNotice := pe.sendNotice
+	var notices []string
+	sendNotice := func(_ context.Context, message string, args ...any) {
+		notices = append(notices, fmt.Sprintf(message, args...))
+	}
 	if policyRoomMeta.DontNotifyOnChange {
 		sendNotice = noopSendNotice
 	}
+	var evalAdded, evalRemoved *policylist.Policy
 	if removedAndAddedAreEquivalent {
 		if removed.Reason == added.Reason {
 			sendNotice(ctx,
@@ -139,14 +146,23 @@ func (pe *PolicyEvaluator) HandlePolicyListChange(ctx context.Context, policyRoo
 				changeActionString(added.Recommendation), added.EntityOrHash(), removed.Reason, added.Reason)
 		}
 	} else {
+		var paused bool
+		if removed != nil {
+			paused = pe.recordPolicyChange(ctx, removed)
+		} else if added != nil {
+			paused = pe.recordPolicyChange(ctx, added)
+		}
+		if paused {
+			zerolog.Ctx(ctx).Warn().Msg("Not applying policy change as enforcement is paused due to flapping")
+		}
 		if removed != nil {
 			sendNotice(ctx,
 				"[%s] [%s](%s) %s %ss matching `%s` for `%s`",
 				policyRoomMeta.Name, removed.Sender, removed.Sender.URI().MatrixToURL(),
 				removeActionString(removed.Recommendation), removed.EntityType, removed.EntityOrHash(), removed.Reason,
 			)
-			if !policyRoomMeta.DontApply {
-				pe.EvaluateRemovedRule(ctx, removed)
+			if !policyRoomMeta.DontApply && !paused {
+				evalRemoved = removed
 			}
 		}
 		if added != nil {
@@ -160,9 +176,24 @@ func (pe *PolicyEvaluator) HandlePolicyListChange(ctx context.Context, policyRoo
 				addActionString(added.Recommendation), added.EntityType, added.EntityOrHash(), added.Reason,
 				suffix,
 			)
-			if !policyRoomMeta.DontApply {
-				pe.EvaluateAddedRule(ctx, added)
+			if !policyRoomMeta.DontApply && !paused {
+				evalAdded = added
 			}
 		}
 	}
+	if pe.ReevaluationDebounce > 0 {
+		if len(notices) > 0 || evalAdded != nil || evalRemoved != nil {
+			pe.queueRuleChange(ctx, notices, evalAdded, evalRemoved)
+		}
+		return
+	}
+	for _, notice := range notices {
+		pe.sendNotice(ctx, notice)
+	}
+	if evalRemoved != nil {
+		pe.EvaluateRemovedRule(ctx, evalRemoved)
+	}
+	if evalAdded != nil {
+		pe.EvaluateAddedRule(ctx, evalAdded)
+	}
 }
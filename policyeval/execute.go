@@ -11,6 +11,7 @@ import (
 	"github.com/rs/zerolog"
 	"maunium.net/go/mautrix"
 	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
 	"maunium.net/go/mautrix/id"
 	"maunium.net/go/mautrix/synapseadmin"
 
@@ -25,7 +26,8 @@ func (pe *PolicyEvaluator) getRoomsUserIsIn(userID id.UserID) []id.RoomID {
 	return rooms
 }
 
-func (pe *PolicyEvaluator) ApplyPolicy(ctx context.Context, userID id.UserID, policy policylist.Match, isNew bool) {
+// ApplyPolicy enforces the given policy match on the user and returns the number of bans that were executed.
+func (pe *PolicyEvaluator) ApplyPolicy(ctx context.Context, userID id.UserID, policy policylist.Match, isNew bool) (banCount int) {
 	if userID == pe.Bot.UserID {
 		return
 	}
@@ -39,13 +41,27 @@ func (pe *PolicyEvaluator) ApplyPolicy(ctx context.Context, userID id.UserID, po
 		return
 	}
 	if recs.BanOrUnban != nil {
+		policyMatches.WithLabelValues(string(recs.BanOrUnban.Recommendation)).Inc()
 		if recs.BanOrUnban.Recommendation == event.PolicyRecommendationBan || recs.BanOrUnban.Recommendation == event.PolicyRecommendationUnstableTakedown {
 			zerolog.Ctx(ctx).Info().
 				Stringer("user_id", userID).
 				Any("matches", policy).
 				Msg("Applying ban recommendation")
+			var isExempt bool
 			for _, room := range rooms {
-				pe.ApplyBan(ctx, userID, room, recs.BanOrUnban)
+				if level, exempt := pe.getExemptPowerLevel(ctx, room, userID); exempt {
+					isExempt = true
+					pe.notifyExemptUser(ctx, userID, room, level, recs.BanOrUnban)
+				} else if pe.isObserveOnlyRoom(room) {
+					pe.notifyObservedBan(ctx, userID, room, recs.BanOrUnban)
+				} else if pe.ApplyBan(ctx, userID, room, recs.BanOrUnban) {
+					banCount++
+				}
+			}
+			if isExempt {
+				// Privileged users are only skipped in rooms where they have a high power level,
+				// but they shouldn't have their messages redacted or their account suspended.
+				return
 			}
 			shouldRedact := recs.BanOrUnban.Recommendation == event.PolicyRecommendationUnstableTakedown
 			if !shouldRedact && recs.BanOrUnban.Reason != "" {
@@ -56,8 +72,11 @@ func (pe *PolicyEvaluator) ApplyPolicy(ctx context.Context, userID id.UserID, po
 					}
 				}
 			}
-			if shouldRedact {
-				go pe.RedactUser(context.WithoutCancel(ctx), userID, recs.BanOrUnban.Reason, true)
+			if recs.BanOrUnban.Recommendation == event.PolicyRecommendationUnstableTakedown && pe.TakedownRedactAllRooms {
+				go pe.RedactUserEverywhere(context.WithoutCancel(ctx), userID, recs.BanOrUnban.Reason, true)
+			} else if shouldRedact {
+				rooms := slices.DeleteFunc(pe.GetProtectedRooms(), pe.isObserveOnlyRoom)
+				go pe.redactUserInRooms(context.WithoutCancel(ctx), userID, rooms, recs.BanOrUnban.Reason, true)
 			}
 			if isNew {
 				go pe.RejectPendingInvites(context.WithoutCancel(ctx), userID, recs.BanOrUnban)
@@ -73,6 +92,50 @@ func (pe *PolicyEvaluator) ApplyPolicy(ctx context.Context, userID id.UserID, po
 			//}
 		}
 	}
+	return
+}
+
+// notifyWarnPolicy notifies the management room if a user who joined a protected room matches a warn policy.
+// Users who are banned by another policy aren't reported, as they'll be removed anyway.
+func (pe *PolicyEvaluator) notifyWarnPolicy(ctx context.Context, userID id.UserID, roomID id.RoomID) {
+	recs := pe.Store.MatchUser(pe.GetWatchedLists(), userID).Recommendations()
+	if recs.Warn == nil || (recs.BanOrUnban != nil && recs.BanOrUnban.Recommendation != event.PolicyRecommendationUnban) {
+		return
+	}
+	listName := recs.Warn.RoomID.String()
+	if meta := pe.GetWatchedListMeta(recs.Warn.RoomID); meta != nil {
+		listName = meta.Name
+	}
+	pe.sendNotice(
+		ctx, "⚠️ [%s](%s) joined [%s](%s) and matches a warn policy for %s in %s: %s",
+		userID, userID.URI().MatrixToURL(),
+		format.EscapeMarkdown(pe.getProtectedRoomName(roomID)), roomID.URI().MatrixToURL(),
+		format.SafeMarkdownCode(recs.Warn.EntityOrHash()), format.EscapeMarkdown(listName),
+		format.SafeMarkdownCode(recs.Warn.Reason),
+	)
+}
+
+// notifyObservedBan notifies the management room about a user who would have been banned in an observe-only room.
+func (pe *PolicyEvaluator) notifyObservedBan(ctx context.Context, userID id.UserID, roomID id.RoomID, policy *policylist.Policy) {
+	listName := policy.RoomID.String()
+	if meta := pe.GetWatchedListMeta(policy.RoomID); meta != nil {
+		listName = meta.Name
+	}
+	pe.sendNotice(
+		ctx, "👀 [%s](%s) in %s matches a %s policy for %s in %s, but the room is observe-only: %s",
+		userID, userID.URI().MatrixToURL(), pe.formatRoomLink(roomID),
+		format.SafeMarkdownCode(policy.Recommendation), format.SafeMarkdownCode(policy.EntityOrHash()),
+		format.EscapeMarkdown(listName), format.SafeMarkdownCode(policy.Reason),
+	)
+}
+
+// notifyExemptUser notifies the management room about a user who wasn't banned due to their power level.
+func (pe *PolicyEvaluator) notifyExemptUser(ctx context.Context, userID id.UserID, roomID id.RoomID, level int, policy *policylist.Policy) {
+	pe.sendNotice(
+		ctx, "🛡️ [%s](%s) in %s matches a %s policy for %s, but wasn't banned as they have power level %d",
+		userID, userID.URI().MatrixToURL(), pe.formatRoomLink(roomID),
+		format.SafeMarkdownCode(policy.Recommendation), format.SafeMarkdownCode(policy.EntityOrHash()), level,
+	)
 }
 
 func filterReason(reason string) string {
@@ -89,18 +152,23 @@ func (pe *PolicyEvaluator) maybeApplySuspend(ctx context.Context, userID id.User
 	plist := pe.GetWatchedListMeta(policy.RoomID)
 	if !plist.AutoSuspend {
 		return
+	} else if pe.DryRun {
+		pe.sendNotice(ctx, "Dry run: would have suspended [%s](%s) due to received ban policy", userID, userID.URI().MatrixToURL())
+		return
 	}
 	err := pe.Bot.SynapseAdmin.SuspendAccount(ctx, userID, synapseadmin.ReqSuspendUser{Suspend: true})
 	if err != nil {
 		zerolog.Ctx(ctx).Err(err).Stringer("user_id", userID).Msg("Failed to suspend user")
-		pe.sendNotice(ctx, "Failed to suspend [%s](%s): %v", userID, userID.URI().MatrixToURL(), err)
+		pe.sendNoticeNow(ctx, "Failed to suspend [%s](%s): %v", userID, userID.URI().MatrixToURL(), err)
 	} else {
 		zerolog.Ctx(ctx).Info().Stringer("user_id", userID).Msg("Suspended user")
 		pe.sendNotice(ctx, "Suspended [%s](%s) due to received ban policy", userID, userID.URI().MatrixToURL())
 	}
 }
 
-func (pe *PolicyEvaluator) ApplyBan(ctx context.Context, userID id.UserID, roomID id.RoomID, policy *policylist.Policy) {
+// ApplyBan bans the user from the given room based on the policy. Returns true if the ban was executed
+// (or simulated in dry run mode), and false if it failed.
+func (pe *PolicyEvaluator) ApplyBan(ctx context.Context, userID id.UserID, roomID id.RoomID, policy *policylist.Policy) bool {
 	ta := &database.TakenAction{
 		TargetUser: userID,
 		InRoomID:   roomID,
@@ -123,17 +191,43 @@ func (pe *PolicyEvaluator) ApplyBan(ctx context.Context, userID id.UserID, roomI
 			err = respErr
 		}
 		zerolog.Ctx(ctx).Err(err).Any("attempted_action", ta).Msg("Failed to ban user")
-		pe.sendNotice(ctx, "Failed to ban [%s](%s) in [%s](%s) for %s: %v", userID, userID.URI().MatrixToURL(), roomID, roomID.URI().MatrixToURL(), policy.Reason, err)
-		return
+		if pe.queueActionRetry(ctx, &database.ActionRetry{
+			Action:         database.ActionRetryTypeBan,
+			TargetUser:     userID,
+			RoomID:         roomID,
+			Reason:         filterReason(policy.Reason),
+			PolicyList:     policy.RoomID,
+			Entity:         policy.EntityOrHash(),
+			Recommendation: policy.Recommendation,
+		}, err) {
+			return false
+		}
+		pe.sendNoticeNow(ctx, "Failed to ban [%s](%s) in [%s](%s) for %s: %v", userID, userID.URI().MatrixToURL(), roomID, roomID.URI().MatrixToURL(), policy.Reason, err)
+		return false
 	}
 	err = pe.DB.TakenAction.Put(ctx, ta)
 	if err != nil {
 		zerolog.Ctx(ctx).Err(err).Any("taken_action", ta).Msg("Failed to save taken action")
-		pe.sendNotice(ctx, "Banned [%s](%s) in [%s](%s) for %s, but failed to save to database: %v", userID, userID.URI().MatrixToURL(), roomID, roomID.URI().MatrixToURL(), policy.Reason, err)
+		pe.sendNoticeNow(ctx, "Banned [%s](%s) in [%s](%s) for %s, but failed to save to database: %v", userID, userID.URI().MatrixToURL(), roomID, roomID.URI().MatrixToURL(), policy.Reason, err)
 	} else {
 		zerolog.Ctx(ctx).Info().Any("taken_action", ta).Msg("Took action")
 		pe.sendNotice(ctx, "Banned [%s](%s) in [%s](%s) for %s", userID, userID.URI().MatrixToURL(), roomID, roomID.URI().MatrixToURL(), policy.Reason)
 	}
+	action := database.AuditLogActionBan
+	if policy.Recommendation == event.PolicyRecommendationUnstableTakedown {
+		action = database.AuditLogActionTakedown
+	}
+	pe.logAction(ctx, &database.AuditLogEntry{
+		Action:         action,
+		TargetUser:     userID,
+		InRoomID:       roomID,
+		Actor:          policy.Sender,
+		Reason:         policy.Reason,
+		PolicyList:     policy.RoomID,
+		Entity:         policy.EntityOrHash(),
+		Recommendation: string(policy.Recommendation),
+	})
+	return true
 }
 
 func (pe *PolicyEvaluator) UndoBan(ctx context.Context, userID id.UserID, roomID id.RoomID) bool {
@@ -154,14 +248,105 @@ func (pe *PolicyEvaluator) UndoBan(ctx context.Context, userID id.UserID, roomID
 			err = respErr
 		}
 		zerolog.Ctx(ctx).Err(err).Msg("Failed to unban user")
-		pe.sendNotice(ctx, "Failed to unban [%s](%s) in [%s](%s): %v", userID, userID.URI().MatrixToURL(), roomID, roomID.URI().MatrixToURL(), err)
+		pe.sendNoticeNow(ctx, "Failed to unban [%s](%s) in [%s](%s): %v", userID, userID.URI().MatrixToURL(), roomID, roomID.URI().MatrixToURL(), err)
 		return false
 	}
 	zerolog.Ctx(ctx).Debug().Msg("Unbanned user")
 	pe.sendNotice(ctx, "Unbanned [%s](%s) in [%s](%s)", userID, userID.URI().MatrixToURL(), roomID, roomID.URI().MatrixToURL())
+	pe.logAction(ctx, &database.AuditLogEntry{
+		Action:     database.AuditLogActionUnban,
+		TargetUser: userID,
+		InRoomID:   roomID,
+	})
 	return true
 }
 
+const mutedPowerLevel = -1
+
+var (
+	errCantEditPowerLevels = errors.New("bot doesn't have permission to edit power levels")
+	errTargetTooPowerful   = errors.New("target's power level is not lower than the bot's")
+	errNotMuted            = errors.New("user is not muted")
+	errAlreadyMuted        = errors.New("user is already muted")
+)
+
+// kickFromRooms kicks the given user from the given rooms and logs each successful kick.
+func (pe *PolicyEvaluator) kickFromRooms(ctx context.Context, userID id.UserID, rooms []id.RoomID, reason string) (kicked []id.RoomID, failed map[id.RoomID]error) {
+	failed = make(map[id.RoomID]error)
+	for _, roomID := range rooms {
+		var err error
+		for attempt := 0; !pe.DryRun; attempt++ {
+			_, err = pe.Bot.KickUser(ctx, roomID, &mautrix.ReqKickUser{
+				Reason: reason,
+				UserID: userID,
+			})
+			if delay, isRateLimit := rateLimitBackoff(err); !isRateLimit || attempt >= maxRateLimitWaits {
+				break
+			} else if !waitForRateLimit(ctx, delay) {
+				break
+			}
+		}
+		if err != nil {
+			zerolog.Ctx(ctx).Err(err).
+				Stringer("user_id", userID).
+				Stringer("room_id", roomID).
+				Msg("Failed to kick user")
+			if pe.queueActionRetry(ctx, &database.ActionRetry{
+				Action:     database.ActionRetryTypeKick,
+				TargetUser: userID,
+				RoomID:     roomID,
+				Reason:     reason,
+			}, err) {
+				err = fmt.Errorf("%w (queued for retrying)", err)
+			}
+			failed[roomID] = err
+			continue
+		}
+		kicked = append(kicked, roomID)
+		pe.logAction(ctx, &database.AuditLogEntry{
+			Action:     database.AuditLogActionKick,
+			TargetUser: userID,
+			InRoomID:   roomID,
+			Reason:     reason,
+		})
+	}
+	return
+}
+
+// setUserMuted mutes a user in the given room by setting their power level to -1,
+// or unmutes them by restoring their power level to the room default.
+func (pe *PolicyEvaluator) setUserMuted(ctx context.Context, roomID id.RoomID, userID id.UserID, muted bool) error {
+	var pls event.PowerLevelsEventContent
+	err := pe.Bot.StateEvent(ctx, roomID, event.StatePowerLevels, "", &pls)
+	if err != nil {
+		return fmt.Errorf("failed to get power levels: %w", err)
+	}
+	ownLevel := pls.GetUserLevel(pe.Bot.UserID)
+	currentLevel := pls.GetUserLevel(userID)
+	if ownLevel < pls.GetEventLevel(event.StatePowerLevels) {
+		return errCantEditPowerLevels
+	} else if currentLevel >= ownLevel {
+		return errTargetTooPowerful
+	} else if muted && currentLevel == mutedPowerLevel {
+		return errAlreadyMuted
+	} else if !muted && currentLevel != mutedPowerLevel {
+		return errNotMuted
+	}
+	if muted {
+		pls.SetUserLevel(userID, mutedPowerLevel)
+	} else {
+		pls.SetUserLevel(userID, pls.UsersDefault)
+	}
+	if pe.DryRun {
+		return nil
+	}
+	_, err = pe.Bot.SendStateEvent(ctx, roomID, event.StatePowerLevels, "", &pls)
+	if err != nil {
+		return fmt.Errorf("failed to send power levels: %w", err)
+	}
+	return nil
+}
+
 func pluralize(value int, unit string) string {
 	if value == 1 {
 		return "1 " + unit
@@ -169,8 +354,7 @@ func pluralize(value int, unit string) string {
 	return fmt.Sprintf("%d %ss", value, unit)
 }
 
-func (pe *PolicyEvaluator) redactUserMSC4194(ctx context.Context, userID id.UserID, reason string) {
-	rooms := pe.GetProtectedRooms()
+func (pe *PolicyEvaluator) redactUserMSC4194(ctx context.Context, userID id.UserID, rooms []id.RoomID, reason string) int {
 	var errorMessages []string
 	var redactedCount, roomCount int
 Outer:
@@ -192,26 +376,28 @@ Outer:
 				if !roomCounted {
 					roomCount++
 					roomCounted = true
+					pe.logRedaction(ctx, userID, roomID, reason)
 				}
 			}
 		}
 	}
 	pe.sendRedactResult(ctx, redactedCount, roomCount, userID, errorMessages)
+	return redactedCount
 }
 
-func (pe *PolicyEvaluator) redactUserSynapse(ctx context.Context, userID id.UserID, reason string, allowReredact bool) {
+func (pe *PolicyEvaluator) redactUserSynapse(ctx context.Context, userID id.UserID, rooms []id.RoomID, reason string, allowReredact bool) (int, bool) {
 	start := time.Now()
-	events, maxTS, err := pe.SynapseDB.GetEventsToRedact(ctx, userID, pe.GetProtectedRooms())
+	events, maxTS, err := pe.SynapseDB.GetEventsToRedact(ctx, userID, rooms)
 	dur := time.Since(start)
 	if err != nil {
 		zerolog.Ctx(ctx).Err(err).
 			Stringer("user_id", userID).
 			Dur("query_duration", dur).
 			Msg("Failed to get events to redact")
-		pe.sendNotice(ctx,
+		pe.sendNoticeNow(ctx,
 			"Failed to get events to redact for [%s](%s): %v",
 			userID, userID.URI().MatrixToURL(), err)
-		return
+		return 0, false
 	} else if len(events) == 0 {
 		zerolog.Ctx(ctx).Debug().
 			Stringer("user_id", userID).
@@ -219,10 +405,10 @@ func (pe *PolicyEvaluator) redactUserSynapse(ctx context.Context, userID id.User
 			Bool("allow_redact", allowReredact).
 			Dur("query_duration", dur).
 			Msg("No events found to redact")
-		return
+		return 0, true
 	}
 	reason = filterReason(reason)
-	needsReredact := allowReredact && time.Since(maxTS) < 5*time.Minute
+	needsReredact := allowReredact && !pe.DryRun && time.Since(maxTS) < 5*time.Minute
 	zerolog.Ctx(ctx).Debug().
 		Stringer("user_id", userID).
 		Int("event_count", len(events)).
@@ -248,13 +434,19 @@ func (pe *PolicyEvaluator) redactUserSynapse(ctx context.Context, userID id.User
 		zerolog.Ctx(ctx).Debug().
 			Stringer("user_id", userID).
 			Msg("Re-redacting user to ensure soft-failed events get redacted")
-		pe.RedactUser(ctx, userID, reason, false)
+		reredactedCount, _ := pe.redactUserInRooms(ctx, userID, rooms, reason, false)
+		redactedCount += reredactedCount
 	}
+	return redactedCount, true
 }
 
 func (pe *PolicyEvaluator) sendRedactResult(ctx context.Context, events, rooms int, userID id.UserID, errorMessages []string) {
-	output := fmt.Sprintf("Redacted %s across %s from [%s](%s)",
-		pluralize(events, "event"), pluralize(rooms, "room"),
+	verb := "Redacted"
+	if pe.DryRun {
+		verb = "Dry run: would have redacted"
+	}
+	output := fmt.Sprintf("%s %s across %s from [%s](%s) using client redaction",
+		verb, pluralize(events, "event"), pluralize(rooms, "room"),
 		userID, userID.URI().MatrixToURL())
 	if len(errorMessages) > 0 {
 		output += "\n\n" + strings.Join(errorMessages, "\n")
@@ -262,17 +454,174 @@ func (pe *PolicyEvaluator) sendRedactResult(ctx context.Context, events, rooms i
 	pe.sendNotice(ctx, output)
 }
 
-func (pe *PolicyEvaluator) RedactUser(ctx context.Context, userID id.UserID, reason string, allowReredact bool) {
+// redactConfirmThreshold is the number of events above which manual user redactions require explicit confirmation.
+const redactConfirmThreshold = 50
+
+// countEventsToRedact returns the number of unredacted events the given user has in protected rooms.
+// The count is only available when the Synapse database is configured.
+func (pe *PolicyEvaluator) countEventsToRedact(ctx context.Context, userID id.UserID) (int, bool) {
+	if pe.SynapseDB == nil {
+		return 0, false
+	}
+	events, _, err := pe.SynapseDB.GetEventsToRedact(ctx, userID, pe.GetProtectedRooms())
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Stringer("user_id", userID).Msg("Failed to count events to redact")
+		return 0, false
+	}
+	var count int
+	for _, roomEvents := range events {
+		count += len(roomEvents)
+	}
+	return count, true
+}
+
+func (pe *PolicyEvaluator) RedactUser(ctx context.Context, userID id.UserID, reason string, allowReredact bool) (int, bool) {
+	return pe.redactUserInRooms(ctx, userID, pe.GetProtectedRooms(), reason, allowReredact)
+}
+
+// RedactUserEverywhere redacts events from the given user in every room the bot is joined to,
+// not just protected rooms. Rooms where the bot can't redact are skipped and listed in the notice.
+// Observe-only protected rooms are always skipped, as this is only used for automatic takedown enforcement.
+func (pe *PolicyEvaluator) RedactUserEverywhere(ctx context.Context, userID id.UserID, reason string, allowReredact bool) {
+	if pe.AdminAPI != nil && !pe.DryRun {
+		err := pe.redactUserAdminAPI(ctx, userID, nil, reason)
+		if err == nil {
+			return
+		}
+		zerolog.Ctx(ctx).Err(err).Stringer("user_id", userID).Msg("Failed to redact user with admin API")
+		pe.sendNoticeNow(ctx, "Failed to redact [%s](%s) using the homeserver admin API, falling back to client redaction: %v", userID, userID.URI().MatrixToURL(), err)
+	}
+	rooms, unreachable, err := pe.getRedactableJoinedRooms(ctx)
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Stringer("user_id", userID).Msg("Failed to get rooms for takedown redaction")
+		pe.sendNoticeNow(ctx, "Failed to get joined rooms for redacting [%s](%s), falling back to protected rooms: %v", userID, userID.URI().MatrixToURL(), err)
+		pe.redactUserInRooms(ctx, userID, slices.DeleteFunc(pe.GetProtectedRooms(), pe.isObserveOnlyRoom), reason, allowReredact)
+		return
+	}
+	rooms = slices.DeleteFunc(rooms, pe.isObserveOnlyRoom)
+	output := fmt.Sprintf("Redacting events from [%s](%s) in %s due to takedown policy",
+		userID, userID.URI().MatrixToURL(), pluralize(len(rooms), "room"))
+	if len(unreachable) > 0 {
+		unreachableStrings := make([]string, len(unreachable))
+		for i, roomID := range unreachable {
+			unreachableStrings[i] = fmt.Sprintf("* [%s](%s)", roomID, roomID.URI().MatrixToURL())
+		}
+		output += fmt.Sprintf("\n\nOut of reach (insufficient power level or state unavailable):\n\n%s", strings.Join(unreachableStrings, "\n"))
+	}
+	pe.sendNotice(ctx, output)
+	pe.redactUserInRooms(ctx, userID, rooms, reason, allowReredact)
+}
+
+// RedactUserFiltered redacts only some events from the given user in protected rooms.
+// If maxAge is non-zero, only events sent within that time are redacted,
+// and if limit is non-zero, at most that many of the most recent events are redacted in each room.
+// The number of redacted events is returned.
+func (pe *PolicyEvaluator) RedactUserFiltered(ctx context.Context, userID id.UserID, maxAge time.Duration, limit int, reason string) int {
+	rooms := pe.GetProtectedRooms()
+	var errorMessages []string
+	var redactedCount, roomCount int
+	if pe.SynapseDB != nil {
+		var minTS int64
+		if maxAge > 0 {
+			minTS = time.Now().Add(-maxAge).UnixMilli()
+		}
+		events, err := pe.SynapseDB.GetRecentEventsToRedact(ctx, userID, rooms, minTS, limit)
+		if err != nil {
+			zerolog.Ctx(ctx).Err(err).Stringer("user_id", userID).Msg("Failed to get recent events to redact")
+			pe.sendNoticeNow(ctx, "Failed to get events to redact for [%s](%s): %v", userID, userID.URI().MatrixToURL(), err)
+			return 0
+		}
+		reason = filterReason(reason)
+		for roomID, roomEvents := range events {
+			successCount, failedCount := pe.redactEventsInRoom(ctx, userID, roomID, roomEvents, reason)
+			if failedCount > 0 {
+				errorMessages = append(errorMessages, fmt.Sprintf(
+					"* Failed to redact %d/%d events from [%s](%s) in [%s](%s)",
+					failedCount, failedCount+successCount, userID, userID.URI().MatrixToURL(), roomID, roomID.URI().MatrixToURL()))
+			}
+			if successCount > 0 {
+				roomCount++
+			}
+			redactedCount += successCount
+		}
+	} else {
+		for _, roomID := range rooms {
+			successCount, err := pe.redactRecentMessages(ctx, roomID, userID, maxAge, true, limit, reason)
+			if err != nil {
+				zerolog.Ctx(ctx).Err(err).
+					Stringer("user_id", userID).
+					Stringer("room_id", roomID).
+					Msg("Failed to redact recent messages")
+				errorMessages = append(errorMessages, fmt.Sprintf(
+					"* Failed to redact events from [%s](%s) in [%s](%s): %v",
+					userID, userID.URI().MatrixToURL(), roomID, roomID.URI().MatrixToURL(), err))
+			}
+			if successCount > 0 {
+				roomCount++
+				pe.logRedaction(ctx, userID, roomID, reason)
+			}
+			redactedCount += successCount
+		}
+	}
+	if redactedCount == 0 && len(errorMessages) == 0 {
+		var filter string
+		if maxAge > 0 {
+			filter = fmt.Sprintf(" in the last %s", maxAge)
+		}
+		pe.sendNotice(ctx, "Nothing to redact: [%s](%s) hasn't sent any events%s in protected rooms", userID, userID.URI().MatrixToURL(), filter)
+		return 0
+	}
+	pe.sendRedactResult(ctx, redactedCount, roomCount, userID, errorMessages)
+	return redactedCount
+}
+
+func (pe *PolicyEvaluator) getRedactableJoinedRooms(ctx context.Context) (rooms, unreachable []id.RoomID, err error) {
+	joinedRooms, err := pe.Bot.JoinedRooms(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get joined rooms: %w", err)
+	}
+	for _, roomID := range joinedRooms.JoinedRooms {
+		if roomID == pe.ManagementRoom {
+			continue
+		}
+		var pls event.PowerLevelsEventContent
+		err = pe.Bot.StateEvent(ctx, roomID, event.StatePowerLevels, "", &pls)
+		if err != nil {
+			zerolog.Ctx(ctx).Warn().Err(err).Stringer("room_id", roomID).Msg("Failed to get power levels for takedown redaction")
+			unreachable = append(unreachable, roomID)
+		} else if pls.GetUserLevel(pe.Bot.UserID) < pls.Redact() && !pe.DryRun {
+			unreachable = append(unreachable, roomID)
+		} else {
+			rooms = append(rooms, roomID)
+		}
+	}
+	return rooms, unreachable, nil
+}
+
+// redactUserInRooms redacts all events from the given user in the given rooms and returns the number of redacted events.
+// The boolean is false if the count isn't known, which happens when the admin API is used or finding events fails.
+func (pe *PolicyEvaluator) redactUserInRooms(ctx context.Context, userID id.UserID, rooms []id.RoomID, reason string, allowReredact bool) (int, bool) {
+	if pe.AdminAPI != nil && !pe.DryRun && len(rooms) > 0 {
+		err := pe.redactUserAdminAPI(ctx, userID, rooms, reason)
+		if err == nil {
+			return 0, false
+		}
+		zerolog.Ctx(ctx).Err(err).Stringer("user_id", userID).Msg("Failed to redact user with admin API")
+		pe.sendNoticeNow(ctx, "Failed to redact [%s](%s) using the homeserver admin API, falling back to client redaction: %v", userID, userID.URI().MatrixToURL(), err)
+	}
 	if pe.SynapseDB != nil {
-		pe.redactUserSynapse(ctx, userID, reason, allowReredact)
-	} else if pe.Bot.Client.SpecVersions.Supports(mautrix.FeatureUserRedaction) {
-		pe.redactUserMSC4194(ctx, userID, reason)
+		return pe.redactUserSynapse(ctx, userID, rooms, reason, allowReredact)
+	} else if pe.Bot.Client.SpecVersions.Supports(mautrix.FeatureUserRedaction) && !pe.DryRun {
+		// MSC4194 can't preview what would be redacted, so dry runs fall back to history iteration
+		return pe.redactUserMSC4194(ctx, userID, rooms, reason), true
 	} else {
 		zerolog.Ctx(ctx).Warn().
 			Stringer("user_id", userID).
 			Msg("Falling back to history iteration based event discovery for redaction. This is slow.")
-		for _, roomID := range pe.GetProtectedRooms() {
-			redactedCount, err := pe.redactRecentMessages(ctx, roomID, userID, 24*time.Hour, true, reason)
+		var totalCount int
+		for _, roomID := range rooms {
+			redactedCount, err := pe.redactRecentMessages(ctx, roomID, userID, 24*time.Hour, true, 0, reason)
+			totalCount += redactedCount
 			if err != nil {
 				zerolog.Ctx(ctx).Err(err).
 					Stringer("user_id", userID).
@@ -280,8 +629,16 @@ func (pe *PolicyEvaluator) RedactUser(ctx context.Context, userID id.UserID, rea
 					Msg("Failed to redact recent messages")
 				continue
 			}
-			pe.sendNotice(ctx, "Redacted %d events from [%s](%s) in [%s](%s)", redactedCount, userID, userID.URI().MatrixToURL(), roomID, roomID.URI().MatrixToURL())
+			if redactedCount > 0 {
+				pe.logRedaction(ctx, userID, roomID, reason)
+			}
+			verb := "Redacted"
+			if pe.DryRun {
+				verb = "Dry run: would have redacted"
+			}
+			pe.sendNotice(ctx, "%s %d events from [%s](%s) in [%s](%s)", verb, redactedCount, userID, userID.URI().MatrixToURL(), roomID, roomID.URI().MatrixToURL())
 		}
+		return totalCount, true
 	}
 }
 
@@ -300,6 +657,13 @@ func (pe *PolicyEvaluator) redactEventsInRoom(ctx context.Context, userID id.Use
 				Stringer("room_id", roomID).
 				Stringer("event_id", evtID).
 				Msg("Failed to redact event")
+			pe.queueActionRetry(ctx, &database.ActionRetry{
+				Action:     database.ActionRetryTypeRedact,
+				TargetUser: userID,
+				RoomID:     roomID,
+				EventID:    evtID,
+				Reason:     reason,
+			}, err)
 			failedCount++
 		} else {
 			zerolog.Ctx(ctx).Debug().
@@ -311,16 +675,34 @@ func (pe *PolicyEvaluator) redactEventsInRoom(ctx context.Context, userID id.Use
 			successCount++
 		}
 	}
+	if successCount > 0 {
+		pe.logRedaction(ctx, userID, roomID, reason)
+	}
 	return
 }
 
-func (pe *PolicyEvaluator) redactRecentMessages(ctx context.Context, roomID id.RoomID, sender id.UserID, maxAge time.Duration, redactState bool, reason string) (int, error) {
+func (pe *PolicyEvaluator) logRedaction(ctx context.Context, userID id.UserID, roomID id.RoomID, reason string) {
+	pe.logAction(ctx, &database.AuditLogEntry{
+		Action:     database.AuditLogActionRedact,
+		TargetUser: userID,
+		InRoomID:   roomID,
+		Reason:     reason,
+	})
+}
+
+// redactRecentMessages iterates the history of the given room and redacts events sent within maxAge.
+// If maxAge is zero, history is iterated until the beginning of the room. If limit is non-zero,
+// iteration stops after that many events have been redacted.
+func (pe *PolicyEvaluator) redactRecentMessages(ctx context.Context, roomID id.RoomID, sender id.UserID, maxAge time.Duration, redactState bool, limit int, reason string) (int, error) {
 	var pls event.PowerLevelsEventContent
 	err := pe.Bot.StateEvent(ctx, roomID, event.StatePowerLevels, "", &pls)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get power levels: %w", err)
 	}
-	minTS := time.Now().Add(-maxAge).UnixMilli()
+	var minTS int64
+	if maxAge > 0 {
+		minTS = time.Now().Add(-maxAge).UnixMilli()
+	}
 	var sinceToken string
 	var redactedCount int
 	for {
@@ -343,13 +725,27 @@ func (pe *PolicyEvaluator) redactRecentMessages(ctx context.Context, roomID id.R
 			}
 			if sender != "" && evt.Sender != sender {
 				continue
+			} else if limit > 0 && redactedCount >= limit {
+				return redactedCount, nil
+			}
+			var resp *mautrix.RespSendEvent
+			if !pe.DryRun {
+				resp, err = pe.Bot.RedactEvent(ctx, roomID, evt.ID, mautrix.ReqRedact{Reason: reason})
+			} else {
+				resp = &mautrix.RespSendEvent{EventID: "$fake-redaction-id"}
 			}
-			resp, err := pe.Bot.RedactEvent(ctx, roomID, evt.ID, mautrix.ReqRedact{Reason: reason})
 			if err != nil {
 				zerolog.Ctx(ctx).Err(err).
 					Stringer("room_id", roomID).
 					Stringer("event_id", evt.ID).
 					Msg("Failed to redact event")
+				pe.queueActionRetry(ctx, &database.ActionRetry{
+					Action:     database.ActionRetryTypeRedact,
+					TargetUser: evt.Sender,
+					RoomID:     roomID,
+					EventID:    evt.ID,
+					Reason:     reason,
+				}, err)
 			} else {
 				zerolog.Ctx(ctx).Debug().
 					Stringer("room_id", roomID).
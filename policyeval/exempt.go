@@ -0,0 +1,67 @@
+package policyeval
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"maunium.net/go/mautrix/id"
+)
+
+// getExemptPowerLevel returns the power level of the given user in the given room and whether
+// it's high enough to exempt the user from automatic enforcement (see ExemptPowerLevel).
+func (pe *PolicyEvaluator) getExemptPowerLevel(ctx context.Context, roomID id.RoomID, userID id.UserID) (int, bool) {
+	if pe.ExemptPowerLevel <= 0 {
+		return 0, false
+	}
+	pls, err := pe.Bot.StateStore.GetPowerLevels(ctx, roomID)
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Stringer("room_id", roomID).Msg("Failed to get power levels to check for exempt users")
+		return 0, false
+	} else if pls == nil {
+		return 0, false
+	}
+	level := pls.GetUserLevel(userID)
+	return level, level >= pe.ExemptPowerLevel
+}
+
+// isExemptUser returns true if the given user has an exempt power level in any protected room they're in.
+func (pe *PolicyEvaluator) isExemptUser(ctx context.Context, userID id.UserID) bool {
+	if pe.ExemptPowerLevel <= 0 {
+		return false
+	}
+	for _, roomID := range pe.getRoomsUserIsIn(userID) {
+		if _, exempt := pe.getExemptPowerLevel(ctx, roomID, userID); exempt {
+			return true
+		}
+	}
+	return false
+}
+
+// filterExemptUsers removes users with an exempt power level from the given list and returns them separately.
+func (pe *PolicyEvaluator) filterExemptUsers(ctx context.Context, users []id.UserID) (targets, exempt []id.UserID) {
+	if pe.ExemptPowerLevel <= 0 {
+		return users, nil
+	}
+	targets = make([]id.UserID, 0, len(users))
+	for _, userID := range users {
+		if pe.isExemptUser(ctx, userID) {
+			exempt = append(exempt, userID)
+		} else {
+			targets = append(targets, userID)
+		}
+	}
+	return
+}
+
+func (pe *PolicyEvaluator) formatExemptUsers(users []id.UserID) string {
+	userStrings := make([]string, len(users))
+	for i, userID := range users {
+		userStrings[i] = fmt.Sprintf("* [%s](%s)", userID, userID.URI().MatrixToURL())
+	}
+	return fmt.Sprintf(
+		"Skipped %d privileged users with power level %d or higher in protected rooms (use `--force` to include them):\n\n%s",
+		len(users), pe.ExemptPowerLevel, strings.Join(userStrings, "\n"),
+	)
+}
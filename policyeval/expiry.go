@@ -0,0 +1,81 @@
+package policyeval
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+	"maunium.net/go/mautrix/format"
+
+	"go.mau.fi/meowlnir/policylist"
+)
+
+const policyExpiryCheckInterval = 1 * time.Minute
+
+func (pe *PolicyEvaluator) policyExpiryLoop() {
+	ctx := pe.Bot.Log.With().
+		Str("action", "policy expiry").
+		Stringer("management_room", pe.ManagementRoom).
+		Logger().
+		WithContext(context.Background())
+	ticker := time.NewTicker(policyExpiryCheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		pe.removeExpiredPolicies(ctx)
+	}
+}
+
+func (pe *PolicyEvaluator) removeExpiredPolicies(ctx context.Context) {
+	now := time.Now()
+	for _, policy := range pe.Store.GetExpired(pe.GetWatchedLists(), now) {
+		pe.expiryFailuresLock.Lock()
+		_, alreadyFailed := pe.expiryFailures[policy.ID]
+		pe.expiryFailuresLock.Unlock()
+		if alreadyFailed {
+			continue
+		}
+		pe.removeExpiredPolicy(ctx, policy)
+	}
+}
+
+func (pe *PolicyEvaluator) removeExpiredPolicy(ctx context.Context, policy *policylist.Policy) {
+	log := zerolog.Ctx(ctx).With().
+		Stringer("policy_list", policy.RoomID).
+		Stringer("policy_event_id", policy.ID).
+		Str("entity", policy.EntityOrHash()).
+		Logger()
+	listName := policy.RoomID.String()
+	if meta := pe.GetWatchedListMeta(policy.RoomID); meta != nil {
+		listName = meta.Name
+	}
+	expiredAt := time.UnixMilli(policy.Expiry)
+	_, err := pe.RemovePolicy(ctx, policy)
+	if err != nil {
+		log.Err(err).Msg("Failed to remove expired policy")
+		pe.expiryFailuresLock.Lock()
+		pe.expiryFailures[policy.ID] = struct{}{}
+		pe.expiryFailuresLock.Unlock()
+		pe.sendNoticeNow(ctx,
+			"Failed to remove expired %s policy for %s in %s: %v",
+			format.SafeMarkdownCode(policy.Recommendation), format.SafeMarkdownCode(policy.EntityOrHash()),
+			format.EscapeMarkdown(listName), err,
+		)
+		return
+	}
+	verb := "Removed"
+	if pe.DryRun {
+		// The policy isn't actually removed in dry run mode, so skip it on later checks to avoid repeating the notice
+		pe.expiryFailuresLock.Lock()
+		pe.expiryFailures[policy.ID] = struct{}{}
+		pe.expiryFailuresLock.Unlock()
+		verb = "Dry run: would have removed"
+	}
+	log.Info().Time("expired_at", expiredAt).Bool("dry_run", pe.DryRun).Msg("Removed expired policy")
+	pe.sendNotice(ctx,
+		"%s expired %s policy for %s in %s: it was set by [%s](%s) at %s to expire at %s (reason was %s)",
+		verb, format.SafeMarkdownCode(policy.Recommendation), format.SafeMarkdownCode(policy.EntityOrHash()),
+		format.EscapeMarkdown(listName), policy.Sender, policy.Sender.URI().MatrixToURL(),
+		time.UnixMilli(policy.Timestamp).Format(time.RFC3339), expiredAt.Format(time.RFC3339),
+		format.SafeMarkdownCode(policy.Reason),
+	)
+}
@@ -0,0 +1,71 @@
+package policyeval
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+	"maunium.net/go/mautrix/id"
+)
+
+// exportedPolicy is a single policy in the export format, which matches the format of state events
+// in the policy list room. Mjolnir and Draupnir can import the same format.
+type exportedPolicy struct {
+	Type           string                  `json:"type"`
+	StateKey       string                  `json:"state_key"`
+	Content        *event.ModPolicyContent `json:"content"`
+	Sender         id.UserID               `json:"sender,omitempty"`
+	EventID        id.EventID              `json:"event_id,omitempty"`
+	OriginServerTS int64                   `json:"origin_server_ts,omitempty"`
+}
+
+var cmdExport = &CommandHandler{
+	Name: "export",
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) < 1 {
+			replyUsage(ce)
+			return
+		}
+		list := ce.Meta.FindListByShortcode(ce.Args[0])
+		if list == nil {
+			ce.Reply("List %s not found", format.SafeMarkdownCode(ce.Args[0]))
+			return
+		}
+		policies := ce.Meta.Store.GetAllPolicies(list.RoomID)
+		if policies == nil {
+			ce.Reply("List %s is not loaded", format.SafeMarkdownCode(list.Shortcode))
+			return
+		}
+		exported := make([]*exportedPolicy, len(policies))
+		for i, policy := range policies {
+			exported[i] = &exportedPolicy{
+				// Always use the stable event type, even if the policy was sent with a legacy type
+				Type:           policy.EntityType.EventType().Type,
+				StateKey:       policy.StateKey,
+				Content:        policy.ModPolicyContent,
+				Sender:         policy.Sender,
+				EventID:        policy.ID,
+				OriginServerTS: policy.Timestamp,
+			}
+		}
+		slices.SortFunc(exported, func(a, b *exportedPolicy) int {
+			return cmp.Or(cmp.Compare(a.Type, b.Type), cmp.Compare(a.StateKey, b.StateKey))
+		})
+		data, err := json.MarshalIndent(exported, "", "  ")
+		if err != nil {
+			ce.Reply("Failed to marshal policies: %v", err)
+			return
+		}
+		fileName := fmt.Sprintf("meowlnir-%s-%s.json", list.Shortcode, time.Now().Format("2006-01-02"))
+		err = ce.Meta.sendFile(ce.Ctx, fileName, "application/json", data)
+		if err != nil {
+			ce.Reply("Failed to send policy list export: %v", err)
+			return
+		}
+		ce.Reply("Exported %d policies from %s", len(exported), format.EscapeMarkdown(list.Name))
+	},
+}
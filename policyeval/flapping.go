@@ -0,0 +1,138 @@
+package policyeval
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog"
+	"maunium.net/go/mautrix/format"
+
+	"go.mau.fi/meowlnir/policylist"
+)
+
+type flapKey struct {
+	EntityType policylist.EntityType
+	Entity     string
+}
+
+type flapState struct {
+	changes    []time.Time
+	alerted    bool
+	paused     bool
+	lastPolicy *policylist.Policy
+}
+
+// recordPolicyChange records a recommendation change for the entity of the given policy and
+// returns true if automatic enforcement for the entity is currently paused due to flapping.
+func (pe *PolicyEvaluator) recordPolicyChange(ctx context.Context, policy *policylist.Policy) (paused bool) {
+	cfg := pe.FlapDetection
+	if cfg.Threshold <= 0 {
+		return false
+	}
+	window := time.Duration(cfg.WindowMinutes) * time.Minute
+	key := flapKey{EntityType: policy.EntityType, Entity: policy.EntityOrHash()}
+	now := time.Now()
+
+	pe.flapStatesLock.Lock()
+	state, ok := pe.flapStates[key]
+	if !ok {
+		state = &flapState{}
+		pe.flapStates[key] = state
+	}
+	cutoff := now.Add(-window)
+	state.changes = slices.DeleteFunc(append(state.changes, now), func(ts time.Time) bool {
+		return ts.Before(cutoff)
+	})
+	state.lastPolicy = policy
+	changeCount := len(state.changes)
+	shouldAlert := changeCount >= cfg.Threshold && !state.alerted
+	if shouldAlert {
+		state.alerted = true
+		state.paused = cfg.PauseEnforcement
+	} else if !state.paused && changeCount == 1 {
+		state.alerted = false
+	}
+	paused = state.paused
+	for otherKey, otherState := range pe.flapStates {
+		if !otherState.paused && len(otherState.changes) > 0 && otherState.changes[len(otherState.changes)-1].Before(cutoff) {
+			delete(pe.flapStates, otherKey)
+		}
+	}
+	pe.flapStatesLock.Unlock()
+
+	if shouldAlert {
+		zerolog.Ctx(ctx).Warn().
+			Str("entity", key.Entity).
+			Str("entity_type", string(key.EntityType)).
+			Int("change_count", changeCount).
+			Msg("Detected flapping policy entity")
+		var pauseNote string
+		if paused {
+			pauseNote = fmt.Sprintf(
+				" Automatic enforcement for this entity is paused until an admin runs `%sflapping resolve %s`.",
+				pe.commandPrefix(), key.Entity,
+			)
+		}
+		pe.sendNotice(ctx,
+			"⚠️ The status of %s %s changed %s in %s, possible conflict between lists or moderators.%s",
+			key.EntityType, format.SafeMarkdownCode(key.Entity), pluralize(changeCount, "time"), window, pauseNote,
+		)
+	}
+	return
+}
+
+// resolveFlapping clears the flapping state of an entity and returns the last seen policies
+// for it if automatic enforcement was paused.
+func (pe *PolicyEvaluator) resolveFlapping(entity string) (policies []*policylist.Policy) {
+	pe.flapStatesLock.Lock()
+	defer pe.flapStatesLock.Unlock()
+	for key, state := range pe.flapStates {
+		if key.Entity == entity {
+			if state.paused {
+				policies = append(policies, state.lastPolicy)
+			}
+			delete(pe.flapStates, key)
+		}
+	}
+	return
+}
+
+func (pe *PolicyEvaluator) getFlappingEntities() (output []string) {
+	pe.flapStatesLock.Lock()
+	defer pe.flapStatesLock.Unlock()
+	for key, state := range pe.flapStates {
+		if state.alerted {
+			var paused string
+			if state.paused {
+				paused = " (enforcement paused)"
+			}
+			output = append(output, fmt.Sprintf(
+				"* %s %s: %s in the current window%s",
+				key.EntityType, format.SafeMarkdownCode(key.Entity), pluralize(len(state.changes), "change"), paused,
+			))
+		}
+	}
+	slices.Sort(output)
+	return
+}
+
+// reevaluateEntity applies the current state of all lists to everything matching the given policy's entity.
+func (pe *PolicyEvaluator) reevaluateEntity(ctx context.Context, policy *policylist.Policy) {
+	switch policy.EntityType {
+	case policylist.EntityTypeUser:
+		for userID := range pe.findMatchingUsers(policy.UserMatchPattern(), policy.EntityHash, false) {
+			pe.EvaluateUser(ctx, userID, false)
+		}
+		reevalTargets, err := pe.DB.TakenAction.GetAllByRuleEntity(ctx, policy.RoomID, policy.EntityOrHash())
+		if err != nil {
+			zerolog.Ctx(ctx).Err(err).Str("policy_entity", policy.EntityOrHash()).
+				Msg("Failed to get actions taken for resolved policy")
+		} else {
+			pe.ReevaluateActions(ctx, reevalTargets)
+		}
+	case policylist.EntityTypeServer:
+		pe.DeferredUpdateACL()
+	}
+}
@@ -0,0 +1,575 @@
+package policyeval
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"maunium.net/go/mautrix/format"
+)
+
+type commandHelp struct {
+	Name        string
+	Aliases     []string
+	Usage       string
+	Description string
+	Details     []string
+	Examples    []string
+}
+
+// commandHelps contains the usage and description of every command in the order they're shown in `!help`.
+// The usage strings here are also used for the usage replies of the commands themselves.
+var commandHelps = []*commandHelp{{
+	Name:        "join",
+	Usage:       "<rooms...>",
+	Description: "Join a room",
+	Details:     []string{"Rooms may be given as room IDs, aliases, matrix.to links or `matrix:` URIs"},
+}, {
+	Name:        "knock",
+	Usage:       "<rooms...>",
+	Description: "Ask to join a room",
+}, {
+	Name:        "leave",
+	Usage:       "<rooms...>",
+	Description: "Leave a room",
+	Details:     []string{"Rooms may be given as room IDs, aliases, matrix.to links or `matrix:` URIs"},
+}, {
+	Name:        "powerlevel",
+	Aliases:     []string{"pl"},
+	Usage:       "<room|all> <key> <level>",
+	Description: "Set a power level",
+	Examples:    []string{"!powerlevel all @user:example.com 50", "!powerlevel !room:example.com m.room.message 10"},
+}, {
+	Name:        "redact",
+	Usage:       "[--confirm-count] [--exact] <event link, user ID or glob> [--since <duration>] [--limit <count>] [reason]",
+	Description: "Redact a single event or all messages from a user",
+	Details: []string{
+		"Redacting all messages from a user requires `--confirm-count` if there are many events or they can't be counted (i.e. the Synapse database isn't configured)",
+		"Use `--since <duration>` or `--limit <count>` after a user ID to only redact messages from the given time or the last messages in each room",
+		"A user ID glob redacts messages from all matching users in protected rooms, `--confirm-count` is required unless `--since` or `--limit` is used, and confirmation is required if more than 10 users match",
+		"Use `--exact` to treat `*` and `?` in the user ID literally instead of as wildcards",
+	},
+	Examples: []string{"!redact @spammer:example.com spam", "!redact @spammer:example.com --since 1h spam", "!redact @spam*:example.com spam"},
+}, {
+	Name:        "redact-recent",
+	Usage:       "<room> <since duration> [reason]",
+	Description: "Redact all recent messages in a room",
+	Examples:    []string{"!redact-recent #room:example.com 10m raid"},
+}, {
+	Name:        "redact-event",
+	Usage:       "<event link | room> [event ID] [reason]",
+	Description: "Redact a single event",
+	Details:     []string{"The event may be given as a matrix.to link, a `matrix:` URI or a room followed by an event ID"},
+	Examples:    []string{"!redact-event https://matrix.to/#/!room:example.com/$event spam", "!redact-event #room:example.com $event spam"},
+}, {
+	Name:        "kick",
+	Usage:       "[--room <room>]... [--exact] [--force] <user ID> [reason]",
+	Description: "Kick a user from all rooms",
+	Details: []string{
+		"Users with at least `exempt_power_level` in a protected room they're in are skipped unless `--force` is given",
+		"Use `--room <room>` one or more times to only kick from specific protected rooms",
+		"The user ID may be a glob pattern, kicking more than 10 users requires confirmation. Globs always have to match the entire user ID",
+		"Use `--exact` to treat `*` and `?` in the user ID literally instead of as wildcards",
+		"Multiple users are kicked in parallel with the rate limits from the `bulk_kick` config, and progress is shown by editing a single message",
+	},
+}, {
+	Name:        "mute",
+	Aliases:     []string{"unmute"},
+	Usage:       "[--force] <user ID or glob> [reason]",
+	Description: "Mute or unmute a user in all rooms by changing their power level",
+	Details: []string{
+		"Users with at least `exempt_power_level` in a protected room they're in aren't muted unless `--force` is given",
+		"A user ID glob mutes all matching users, confirmation is required if more than 10 users match",
+	},
+}, {
+	Name:        "ban",
+	Usage:       "[--hash | --confirm-hash | --regex] [--exact] [--duration <duration>] [--redact] [--redact-event] <list shortcode> <entity>... [--rec <recommendation>] [reason] [--internal-note <note>]",
+	Description: "Add a ban policy for one or more entities",
+	Details: []string{
+		"Use `--rec <recommendation>` to send a policy with a different recommendation. `--rec warn` doesn't ban, but notifies this room when a matching user joins a protected room",
+		"Use `--duration <duration>` (e.g. `12h`, `7d` or `2w`) to automatically remove the policy after the given time",
+		"Use `--hash` to only include the hash of the entity in the policy. Exact user IDs, room IDs and server names can be hashed, but wildcards and regexes can't. The entity may also be the hash of a previously seen user, a protected room or a server with members in protected rooms",
+		"Hashed server policies are only added to server ACLs for servers that have members in protected rooms",
+		"Use `--confirm-hash` instead of `--hash` to see the resulting hashes and what they belong to before confirming",
+		"Append `--internal-note <note>` to store a note that is only visible in this room",
+		"Start the reason with `:name` to use a reason template of the list, see `!reasons`",
+		"Wildcard entities that match many users in protected rooms require confirmation",
+		"Use `--regex` to send a single policy whose entity is a regular expression instead of a glob. Regex policies starting with `@` are user policies, ones starting with `!` are room policies and other ones are server policies, which aren't added to server ACLs",
+		"The first entity may be an event link to ban the sender of the event, add `--redact-event` to also redact the event",
+		"User and room entities may also be given as matrix.to links or `matrix:` URIs",
+		"Use `--exact` to treat `*` and `?` in entities literally. Since globs can't escape wildcards, such entities are sent as escaped regex policies, which only Meowlnir understands. They must be banned one at a time",
+		"Use `--redact` to also redact recent messages from the banned users in protected rooms. The time window is set by `ban_redact_window_minutes` in the config",
+	},
+	Examples: []string{"!ban spam @spammer:example.com spam", "!ban --duration 7d spam @a:example.com @b:example.com raid"},
+}, {
+	Name:        "takedown",
+	Usage:       "[--hash | --confirm-hash] [--exact] [--duration <duration>] <list shortcode> <entity>... [reason] [--internal-note <note>]",
+	Description: "Add a takedown policy",
+	Details:     []string{"Takedowns also redact all events from the target"},
+}, {
+	Name:        "reasons",
+	Usage:       "<list shortcode>",
+	Description: "List the reason templates of a policy list",
+	Details: []string{
+		"Templates are defined in the `reason_templates` object of the list in the watched lists state event",
+		"Unknown template names are used as literal reasons",
+	},
+	Examples: []string{"!ban spam @spammer:example.com :spam", "!ban spam @spammer:example.com :raid in #room:example.com"},
+}, {
+	Name:        "reason",
+	Usage:       "[--force] <list shortcode> <entity or hash> <new reason>",
+	Description: "Change the reason of existing policies for an exact entity",
+	Details: []string{
+		"The recommendation, state key, expiry and hashing of the policies are preserved",
+		"Content policies can be edited by giving the exact pattern as the entity",
+		"Takedown policies are skipped unless `--force` is used, as they're meant to have no reason",
+		"Reason templates of the list can be used like in the ban command",
+	},
+	Examples: []string{"!reason spam @user:example.com harassment in #room:example.com"},
+}, {
+	Name:        "ban-server",
+	Usage:       "[--acl] <list shortcode> <server> [reason]",
+	Description: "Add a ban policy for a server",
+	Details: []string{
+		"The server may be a glob pattern, but it must not match the bot's own server",
+		"Use `--acl` to also add the server to the ACL deny list in all protected rooms immediately",
+	},
+	Examples: []string{"!ban-server spam evil.example spam", "!ban-server --acl spam *.evil.example"},
+}, {
+	Name:        "ban-room",
+	Usage:       "[--leave] <list shortcode> <room ID or alias> [reason]",
+	Description: "Add a ban policy for a room",
+	Details: []string{
+		"Reports whether the bot is in the room",
+		"Use `--leave` to also make the bot leave the room and remove it from protected rooms if it was protected",
+		"Protected rooms banned by exact policies in watched lists are unprotected and left after an admin confirms it, or automatically if `leave_banned_rooms` is enabled. Observe-only rooms, the management room and rooms only matched by wildcard policies are never left",
+		"The bot refuses to join banned rooms and warns when protecting them",
+	},
+	Examples: []string{"!ban-room --leave spam #spam:evil.example spam"},
+}, {
+	Name:        "ban-content",
+	Usage:       "[--regex] [--ban-sender] <list shortcode> <pattern> [reason]",
+	Description: "Add a policy that redacts messages whose body matches a glob or regex",
+	Details: []string{
+		"Content policies are checked against new messages in protected rooms. Newlines in messages are treated as spaces, and the pattern must match the entire body, so use e.g. `*spam*` to match anywhere",
+		"The pattern can't contain spaces, use `?` or `*` in their place. Use `--regex` to send a regular expression instead, e.g. `(?i).*free\\s+nitro.*` for case-insensitive matching",
+		"Use `--ban-sender` to also ban the sender of matching messages from protected rooms",
+		"Content policies are a Meowlnir extension and aren't understood by other policy list consumers",
+	},
+	Examples: []string{"!ban-content spam *discord.gg/* invite spam", "!ban-content --regex --ban-sender spam (?i).*free\\s+nitro.* scam"},
+}, {
+	Name:        "ban-media",
+	Usage:       "<list shortcode> <mxc URI or SHA-256 hash> [reason]",
+	Description: "Add a policy that blocks a specific media file by its hash",
+	Details: []string{
+		"The bot downloads and hashes the media, sends a policy with the hash and quarantines the media using the admin API",
+		"Media sent to protected rooms later is hashed and compared against media policies. Matching media is quarantined and the message is redacted",
+		"If the bot can't download the media, the base64 SHA-256 hash of the file can be given instead of the mxc URI",
+		"Media policies are a Meowlnir extension and aren't understood by other policy list consumers",
+	},
+}, {
+	Name:        "purge-user",
+	Usage:       "<list shortcode> <user ID> [reason]",
+	Description: "Send a ban policy for a user, kick them from all protected rooms and redact their messages",
+	Details: []string{
+		"Requires confirmation by reacting to the prompt",
+		"The ban policy bans the user from all protected rooms after it's sent",
+	},
+	Examples: []string{"!purge-user spam @spammer:example.com :spam"},
+}, {
+	Name:        "sync-acl",
+	Description: "Refetch the server ACLs of all protected rooms and reapply server ban policies",
+}, {
+	Name:        "remove-policy",
+	Aliases:     []string{"remove-ban", "remove-unban"},
+	Usage:       "<list shortcode> <entity>",
+	Description: "Remove all policies matching an entity from a list",
+	Details: []string{
+		"Policies with wildcards that match the entity are removed too",
+		"If the entity itself contains wildcards, only the policy with that exact wildcard is removed",
+		"`!remove-ban` and `!remove-unban` only remove ban or unban policies respectively",
+	},
+}, {
+	Name:        "move-policy",
+	Usage:       "<from list shortcode> <to list shortcode> <entity or hash>",
+	Description: "Move the policies for an exact entity from one list to another",
+	Details:     []string{"The recommendation, reason, expiry and hashing of the policies are preserved"},
+}, {
+	Name:        "add-unban",
+	Usage:       "<list shortcode> <entity> [reason]",
+	Description: "Add a ban exclusion policy",
+}, {
+	Name:        "unban",
+	Usage:       "<list shortcode> <entity> [reason]",
+	Description: "Remove a ban policy and unban the user from protected rooms",
+}, {
+	Name:        "unban-server",
+	Usage:       "<server> [reason]",
+	Description: "Remove server ban policies and unban all users from the server in protected rooms",
+	Details: []string{
+		"Server ban policies for the exact entity are removed from all watched lists",
+		"Users who are still banned by a user policy are not unbanned",
+		"Requires confirmation, as it may unban a large number of users",
+	},
+	Examples: []string{"!unban-server evil.example.com"},
+}, {
+	Name:        "match",
+	Usage:       "<entity>",
+	Description: "Match an entity against all lists",
+	Details: []string{
+		"Users and rooms may also be given as matrix.to links or `matrix:` URIs",
+		"For users, the protected rooms they're in are listed, and for rooms, whether the room is protected is reported",
+	},
+}, {
+	Name:        "match-servers",
+	Usage:       "<server glob>",
+	Description: "List the servers with members in protected rooms that match a server name glob",
+	Details:     []string{"Use this to check the impact of a server ban before sending it"},
+	Examples:    []string{"!match-servers *.evil.*"},
+}, {
+	Name:        "explain-precedence",
+	Aliases:     []string{"explain"},
+	Usage:       "<entity>",
+	Description: "Explain step by step which policy determines the verdict for an entity",
+}, {
+	Name:        "conflicts",
+	Description: "List entities that are banned in one watched list and unbanned in another",
+	Details:     []string{"Only policies for the exact same entity (or its hash) are compared, overlapping globs aren't detected"},
+}, {
+	Name:        "why",
+	Usage:       "<user ID or hash>",
+	Description: "Explain every policy affecting a user, which one wins and where the user is banned",
+}, {
+	Name:        "note",
+	Usage:       "[--remove] <entity> [text]",
+	Description: "Add, view or remove internal moderator notes about a user, room or server",
+	Details: []string{
+		"Without text, the existing notes for the entity are shown",
+		"`--remove` deletes all notes for the entity",
+		"Notes are only stored in Meowlnir's database and are shown in `!match`, `!why` and report notices",
+	},
+	Examples: []string{"!note @user:example.com warned about spam in #general", "!note --remove @user:example.com"},
+}, {
+	Name:        "resolve",
+	Usage:       "<hash>...",
+	Description: "Find the user IDs matching one or more base64-encoded SHA-256 hashes",
+	Details:     []string{"Hashes are resolved using current members of protected rooms and the index of previously seen users"},
+}, {
+	Name:        "whois",
+	Usage:       "<user ID or hash>",
+	Description: "Show everything the bot knows about a user",
+	Details: []string{
+		"Includes matching policies, the protected rooms the user is in along with their profile and power level in each room",
+		"For local users, account details are fetched using the Synapse admin API",
+	},
+}, {
+	Name:        "search",
+	Usage:       "[--list <list shortcode>] [--entity | --reason] <pattern>",
+	Description: "Search for rules by a pattern in all lists",
+	Details: []string{
+		"By default, the pattern is a glob matched against policy entities, and policies whose entity matches the pattern are included too",
+		"Use `--reason` to instead find policies whose reason contains the given text (case-insensitive). The text may contain spaces",
+		"Use `--list <list shortcode>` to only search a single list",
+	},
+	Examples: []string{"!search @*:evil.example", "!search --list spam --reason crypto scam"},
+}, {
+	Name:        "simulate-policy",
+	Aliases:     []string{"simulate"},
+	Usage:       "<user|room|server> <entity> <recommendation> [page]",
+	Description: "Preview the effect of a policy without sending it",
+	Examples:    []string{"!simulate-policy user @*:evil.example ban"},
+}, {
+	Name:        "test-rule",
+	Usage:       "<entity> [recommendation]",
+	Description: "Show what a rule would match and how it interacts with existing policies, without choosing a list",
+	Details:     []string{"The entity type is detected automatically and the recommendation defaults to `ban`"},
+	Examples:    []string{"!test-rule @*:evil.example", "!test-rule *.evil.example takedown"},
+}, {
+	Name:        "send-as-bot",
+	Usage:       "<room> <message>",
+	Description: "Send a message as the bot",
+}, {
+	Name:        "suspend",
+	Aliases:     []string{"unsuspend"},
+	Usage:       "<user ID>",
+	Description: "Suspend or unsuspend a user",
+}, {
+	Name:        "deactivate",
+	Usage:       "[--erase] <user ID> [reason]",
+	Description: "Ban a local user and deactivate their account",
+	Details: []string{
+		"Must be enabled in the config, which also defines the list the ban policy is sent to",
+		"Use `--erase` to also erase the user's data (GDPR erasure)",
+	},
+}, {
+	Name:        "quarantine",
+	Usage:       "<user ID>",
+	Description: "Quarantine all media uploaded by a user using the Synapse admin API",
+	Details:     []string{"Media of remote users can't be listed, so only media in events reported from them is quarantined"},
+}, {
+	Name:        "rooms",
+	Aliases:     []string{"room"},
+	Description: "List protected rooms and their member counts",
+}, {
+	Name:        "protect",
+	Aliases:     []string{"unprotect"},
+	Usage:       "[--observe] <room ID or alias>...",
+	Description: "Protect or unprotect a room",
+	Details: []string{
+		"Also available as `!rooms protect` and `!rooms unprotect`",
+		"The bot joins the room if necessary and evaluates all members once the room is protected",
+		"Use `--observe` when protecting to only report matching users instead of banning them, see `!set-enforcement`",
+		"Protected rooms are stored in the management room state, so they persist across restarts",
+	},
+}, {
+	Name:        "lists",
+	Description: "List watched policy lists and the number of rules in them",
+}, {
+	Name:        "stats",
+	Usage:       "<list shortcode>",
+	Description: "Show how many rules each moderator has created in a list and when",
+	Details:     []string{"Also includes the oldest and newest rule and the number of rules per recommendation"},
+}, {
+	Name:        "diff-lists",
+	Usage:       "<list A shortcode> <list B shortcode>",
+	Description: "Compare the entities in two policy lists",
+	Details: []string{
+		"Lists entities that are only in one of the lists and entities whose recommendation differs",
+		"Plaintext and hashed policies for the same entity are treated as the same entity",
+	},
+	Examples: []string{"!diff-lists mylist upstream"},
+}, {
+	Name:        "watch",
+	Usage:       "[--no-apply] <room ID or alias> [shortcode]",
+	Description: "Join a policy list and start watching it",
+	Details: []string{
+		"If the shortcode is omitted, it's generated from the room name",
+		"Use `--no-apply` to only track the list's policies without enforcing them",
+	},
+}, {
+	Name:        "unwatch",
+	Usage:       "<list shortcode or room>",
+	Description: "Stop watching a policy list",
+	Details:     []string{"Bans that were only caused by policies in the list are lifted"},
+}, {
+	Name:        "history-room",
+	Usage:       "<room> [limit]",
+	Description: "Show moderation actions taken in a room",
+}, {
+	Name:        "set-enforcement",
+	Usage:       "<room> <observe|enforce>",
+	Description: "Choose whether policies are enforced automatically in a protected room",
+	Details: []string{
+		"In `observe` mode, users matching ban policies are only reported to this room instead of being banned",
+		"Observe-only rooms are also skipped when automatically redacting banned users and when updating server ACLs",
+		"The mode is stored in the `observe_only` field of the protected rooms state event",
+	},
+}, {
+	Name:        "resync-room",
+	Usage:       "<room>",
+	Description: "Refetch the member list of a protected room and ban members who match ban policies",
+	Details:     []string{"Members who are already banned are skipped, so it's safe to run repeatedly"},
+}, {
+	Name:        "orphan-bans",
+	Usage:       "[--create <list shortcode>] [room]",
+	Description: "List users banned in protected rooms who don't match any policy",
+	Details: []string{
+		"Without a room, all protected rooms are checked",
+		"Use `--create <list shortcode>` to create ban policies for the found users using the reason of the room ban. This requires confirmation",
+	},
+}, {
+	Name:        "unenforced",
+	Usage:       "[--enforce] [room]",
+	Description: "List users matching ban policies who are still in protected rooms",
+	Details: []string{
+		"This can happen if the bot missed an event or didn't have permission to ban",
+		"Use `--enforce` to retry banning the users after confirmation",
+	},
+}, {
+	Name:        "recent",
+	Usage:       "[count]",
+	Description: "Show the most recent moderation actions taken by the bot",
+	Details:     []string{"Shows 20 actions by default. Policy changes are not included, see `!export-audit` for the full log"},
+}, {
+	Name:        "undo",
+	Description: "Reverse the most recent action",
+	Details: []string{
+		"Sent policies are removed and bans are lifted. Other actions like redactions and kicks can't be undone, but they're skipped so that running `!undo` again undoes the action before them",
+		"Only your own actions are undone unless `undo_any_admin` is enabled in the config",
+		"Actions are remembered in memory, so only the last 200 actions can be undone",
+	},
+}, {
+	Name:        "secure-list",
+	Usage:       "<list shortcode>",
+	Description: "Make a policy list room invite-only with shared history",
+}, {
+	Name:        "export-audit",
+	Usage:       "[--signed]",
+	Description: "Export the audit log, optionally as a tamper-evident hash chain",
+}, {
+	Name:        "export",
+	Usage:       "<list shortcode>",
+	Description: "Export all policies in a list as a JSON file of policy state events",
+	Details:     []string{"The file uses the standard `m.policy.rule.*` event format, which Mjolnir and Draupnir can import too"},
+}, {
+	Name:        "import",
+	Usage:       "<list shortcode> [mxc:// or https:// URL]",
+	Description: "Import policies from a JSON file into a list",
+	Details: []string{
+		"Reply to a file message or include a URL to the file",
+		"The file must be in the same format as `!export` produces",
+		"Policies that already exist in the list are skipped",
+	},
+}, {
+	Name:        "list-subscribers",
+	Usage:       "<list shortcode>",
+	Description: "Show the members of a policy list room",
+}, {
+	Name:        "review",
+	Aliases:     []string{"queue-report-review"},
+	Usage:       "[restart]",
+	Description: "Go through unhandled reports one by one",
+}, {
+	Name:        "reports",
+	Usage:       "[limit]",
+	Description: "List the most recent unhandled reports",
+	Details:     []string{"Shows 10 reports by default and at most 50"},
+}, {
+	Name:        "report-action",
+	Usage:       "<report ID> <ban <list> <reason> | takedown <list> | redact | kick <reason> | dismiss>",
+	Description: "Act on a report from `!reports` and mark it as handled",
+	Details: []string{
+		"`redact` redacts the reported event and all recent messages from the reported user",
+		"Reports about rooms can only be dismissed",
+	},
+	Examples: []string{"!report-action 42 ban mylist spam", "!report-action 43 dismiss"},
+}, {
+	Name:        "flapping",
+	Usage:       "[resolve <entity>]",
+	Description: "List entities with rapidly changing policies or resume enforcement for one",
+}, {
+	Name:        "retries",
+	Usage:       "[flush | cancel [ID]]",
+	Description: "Show moderation actions queued for retrying after transient failures",
+	Details: []string{
+		"Failed bans, kicks and redactions are retried with exponential backoff if the error was a rate limit, server error or network error",
+		"`flush` retries all queued actions immediately, `cancel` removes one or all actions from the queue",
+	},
+}, {
+	Name:        "status",
+	Usage:       "[--verbose]",
+	Description: "Show the current state of the bot",
+	Details:     []string{"Use `--verbose` to also include memory and goroutine statistics"},
+}, {
+	Name:        "ping",
+	Description: "Check that the bot is alive and show how long commands take to reach it",
+	Details: []string{
+		"Receive latency is the time between the command being sent and the bot handling it",
+		"Send latency is how long the bot took to send the reply",
+	},
+}, {
+	Name:        "help",
+	Usage:       "[command]",
+	Description: "Show this help message or detailed help for a command",
+}}
+
+var commandHelpsByName = make(map[string]*commandHelp)
+
+func init() {
+	for _, help := range commandHelps {
+		commandHelpsByName[help.Name] = help
+		for _, alias := range help.Aliases {
+			if _, alreadySet := commandHelpsByName[alias]; !alreadySet {
+				commandHelpsByName[alias] = help
+			}
+		}
+	}
+}
+
+func (ch *commandHelp) formatUsage(prefix, command string) string {
+	if ch.Usage == "" {
+		return prefix + command
+	}
+	return fmt.Sprintf("%s%s %s", prefix, command, ch.Usage)
+}
+
+// commandReferenceRegex matches references to other commands in help texts, which are written with the default prefix.
+var commandReferenceRegex = regexp.MustCompile("`!([a-z-]+)")
+
+func withCommandPrefix(prefix, text string) string {
+	if prefix == defaultCommandPrefix {
+		return text
+	}
+	return commandReferenceRegex.ReplaceAllString(text, "`"+strings.ReplaceAll(prefix, "$", "$$")+"$1")
+}
+
+func (ch *commandHelp) formatDetailed(prefix string, customAliases map[string]string) string {
+	sections := []string{fmt.Sprintf("`%s` - %s", ch.formatUsage(prefix, ch.Name), withCommandPrefix(prefix, ch.Description))}
+	aliases := make([]string, 0, len(ch.Aliases))
+	for _, alias := range ch.Aliases {
+		aliases = append(aliases, format.SafeMarkdownCode(prefix+alias))
+	}
+	for _, alias := range slices.Sorted(maps.Keys(customAliases)) {
+		if target := strings.ToLower(customAliases[alias]); target == ch.Name || slices.Contains(ch.Aliases, target) {
+			aliases = append(aliases, format.SafeMarkdownCode(prefix+alias))
+		}
+	}
+	if len(aliases) > 0 {
+		sections = append(sections, "Aliases: "+strings.Join(aliases, ", "))
+	}
+	if len(ch.Details) > 0 {
+		details := make([]string, len(ch.Details))
+		for i, detail := range ch.Details {
+			details[i] = "* " + withCommandPrefix(prefix, detail)
+		}
+		sections = append(sections, strings.Join(details, "\n"))
+	}
+	if len(ch.Examples) > 0 {
+		examples := make([]string, len(ch.Examples))
+		for i, example := range ch.Examples {
+			examples[i] = "* " + format.SafeMarkdownCode(prefix+strings.TrimPrefix(example, "!"))
+		}
+		sections = append(sections, "Examples:\n\n"+strings.Join(examples, "\n"))
+	}
+	return strings.Join(sections, "\n\n")
+}
+
+// replyUsage replies with the usage string of the command that is currently being executed.
+func replyUsage(ce *CommandEvent) {
+	help, ok := commandHelpsByName[ce.Command]
+	if !ok {
+		ce.Reply("Invalid arguments for `%s%s`", ce.Meta.commandPrefix(), ce.Command)
+		return
+	}
+	command := strings.Join(append(slices.Clone(ce.ParentCommands), ce.Command), " ")
+	ce.Reply("Usage: %s", format.SafeMarkdownCode(help.formatUsage(ce.Meta.commandPrefix(), command)))
+}
+
+var cmdHelp = &CommandHandler{
+	Name: "help",
+	Func: func(ce *CommandEvent) {
+		prefix := ce.Meta.commandPrefix()
+		if len(ce.Args) > 0 {
+			name := strings.ToLower(strings.TrimPrefix(ce.Args[0], prefix))
+			if target, isAlias := ce.Meta.CommandAliases[name]; isAlias {
+				name = strings.ToLower(target)
+			}
+			help, ok := commandHelpsByName[name]
+			if !ok {
+				ce.Reply("Unknown command %s, use `%shelp` to list all commands", format.SafeMarkdownCode(ce.Args[0]), prefix)
+				return
+			}
+			ce.Reply(help.formatDetailed(prefix, ce.Meta.CommandAliases))
+			return
+		}
+		var buf strings.Builder
+		buf.WriteString("Available commands:\n")
+		for _, help := range commandHelps {
+			_, _ = fmt.Fprintf(&buf, "* `%s` - %s\n", help.formatUsage(prefix, help.Name), withCommandPrefix(prefix, help.Description))
+		}
+		_, _ = fmt.Fprintf(&buf, "\nUse `%shelp <command>` for details and examples. All fields that want a room will accept both room IDs and aliases.\n", prefix)
+		ce.Reply(buf.String())
+	},
+}
@@ -0,0 +1,277 @@
+package policyeval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/meowlnir/config"
+	"go.mau.fi/meowlnir/policylist"
+	"go.mau.fi/meowlnir/util"
+)
+
+const (
+	maxImportFileSize     = 32 * 1024 * 1024
+	maxListedImportErrors = 10
+	importDownloadTimeout = 2 * time.Minute
+)
+
+var importHTTPClient = &http.Client{Timeout: importDownloadTimeout}
+
+// downloadImportFile downloads the policy file from the given mxc:// or https:// URL,
+// or from the file message that the command is replying to if the URL is empty.
+func (pe *PolicyEvaluator) downloadImportFile(ce *CommandEvent, source string) ([]byte, error) {
+	if source == "" {
+		return pe.downloadRepliedFile(ce)
+	} else if strings.HasPrefix(source, "mxc://") {
+		uri, err := id.ParseContentURI(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mxc URI: %w", err)
+		}
+		return pe.downloadMatrixFile(ce.Ctx, uri)
+	} else if strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "http://") {
+		return downloadHTTPFile(ce.Ctx, source)
+	}
+	return nil, fmt.Errorf("unsupported URL %q", source)
+}
+
+func downloadHTTPFile(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare request: %w", err)
+	}
+	resp, err := importHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return readImportFile(resp.Body)
+}
+
+// downloadMatrixFile downloads the given media, refusing to read more than maxImportFileSize bytes.
+func (pe *PolicyEvaluator) downloadMatrixFile(ctx context.Context, uri id.ContentURI) ([]byte, error) {
+	resp, err := pe.Bot.Download(ctx, uri)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file: %w", err)
+	}
+	defer resp.Body.Close()
+	return readImportFile(resp.Body)
+}
+
+func readImportFile(body io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(body, maxImportFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	} else if len(data) > maxImportFileSize {
+		return nil, errors.New("file is too large")
+	}
+	return data, nil
+}
+
+func (pe *PolicyEvaluator) downloadRepliedFile(ce *CommandEvent) ([]byte, error) {
+	replyTo := ce.Content.AsMessage().RelatesTo.GetReplyTo()
+	if replyTo == "" {
+		return nil, errors.New("no file given: reply to a file or include a URL")
+	}
+	evt, err := pe.Bot.GetEvent(ce.Ctx, ce.RoomID, replyTo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get replied event: %w", err)
+	}
+	if evt.Type == event.EventEncrypted && pe.Bot.CryptoHelper != nil {
+		evt, err = pe.Bot.CryptoHelper.Decrypt(ce.Ctx, evt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt replied event: %w", err)
+		}
+	}
+	_ = evt.Content.ParseRaw(evt.Type)
+	content := evt.Content.AsMessage()
+	if content.MsgType != event.MsgFile {
+		return nil, errors.New("replied event is not a file")
+	} else if content.Info != nil && content.Info.Size > maxImportFileSize {
+		return nil, errors.New("file is too large")
+	}
+	if content.File != nil {
+		uri, err := content.File.URL.Parse()
+		if err != nil {
+			return nil, fmt.Errorf("invalid file URL: %w", err)
+		}
+		data, err := pe.downloadMatrixFile(ce.Ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		err = content.File.DecryptInPlace(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt file: %w", err)
+		}
+		return data, nil
+	}
+	uri, err := content.URL.Parse()
+	if err != nil {
+		return nil, fmt.Errorf("invalid file URL: %w", err)
+	}
+	return pe.downloadMatrixFile(ce.Ctx, uri)
+}
+
+type importResult struct {
+	Imported   int
+	Duplicates int
+	Invalid    []string
+	Failed     []string
+}
+
+// validateImportedPolicy checks an imported policy and returns its entity type,
+// as well as the entity or hash used to generate the state key.
+func validateImportedPolicy(policy *exportedPolicy) (entityType policylist.EntityType, rawEntity string, err error) {
+	content := policy.Content
+	if content == nil {
+		return "", "", errors.New("missing content")
+	} else if content.Recommendation == "" {
+		return "", "", errors.New("missing recommendation")
+	}
+	if policy.Type != "" {
+		var ok bool
+		entityType, ok = policylist.EntityTypeFromEventType(event.Type{Type: policy.Type, Class: event.StateEventType})
+		if !ok {
+			return "", "", fmt.Errorf("unknown policy type %q", policy.Type)
+		}
+	}
+	if content.Entity != "" {
+		validatedType, ok := validateEntity(content.Entity)
+		if !ok {
+			return "", "", fmt.Errorf("invalid entity %q", content.Entity)
+		} else if entityType != "" && validatedType != entityType {
+			return "", "", fmt.Errorf("entity %q is not a %s", content.Entity, entityType)
+		}
+		return validatedType, content.Entity, nil
+	} else if content.UnstableHashes == nil || content.UnstableHashes.SHA256 == "" {
+		return "", "", errors.New("missing entity and hash")
+	} else if _, ok := util.DecodeBase64Hash(content.UnstableHashes.SHA256); !ok {
+		return "", "", fmt.Errorf("invalid hash %q", content.UnstableHashes.SHA256)
+	} else if entityType == "" {
+		return "", "", errors.New("hashed policies must include the event type")
+	}
+	return entityType, content.UnstableHashes.SHA256, nil
+}
+
+func (pe *PolicyEvaluator) isDuplicateImport(list *config.WatchedPolicyList, entityType policylist.EntityType, content *event.ModPolicyContent) bool {
+	listIDs := []id.RoomID{list.RoomID}
+	var existing policylist.Match
+	if content.Entity != "" {
+		existing = pe.Store.MatchExact(listIDs, entityType, content.Entity)
+	} else {
+		hash, _ := util.DecodeBase64Hash(content.UnstableHashes.SHA256)
+		existing = pe.Store.MatchHash(listIDs, entityType, *hash)
+	}
+	for _, policy := range existing {
+		if policy.Recommendation == content.Recommendation {
+			return true
+		}
+	}
+	return false
+}
+
+func (pe *PolicyEvaluator) importPolicies(ctx context.Context, list *config.WatchedPolicyList, policies []*exportedPolicy) *importResult {
+	result := &importResult{}
+	for i, policy := range policies {
+		entityType, rawEntity, err := validateImportedPolicy(policy)
+		if err != nil {
+			result.Invalid = append(result.Invalid, fmt.Sprintf("#%d: %v", i+1, err))
+			continue
+		}
+		content := &event.ModPolicyContent{
+			Entity:         policy.Content.Entity,
+			Reason:         policy.Content.Reason,
+			Recommendation: policy.Content.Recommendation,
+			UnstableHashes: policy.Content.UnstableHashes,
+		}
+		if pe.isDuplicateImport(list, entityType, content) {
+			result.Duplicates++
+			continue
+		} else if pe.DryRun {
+			result.Imported++
+			continue
+		}
+		_, err = pe.SendPolicy(ctx, list.RoomID, entityType, "", rawEntity, content)
+		if err != nil {
+			zerolog.Ctx(ctx).Err(err).Str("entity", rawEntity).Msg("Failed to send imported policy")
+			result.Failed = append(result.Failed, fmt.Sprintf("%s: %v", format.SafeMarkdownCode(rawEntity), err))
+		} else {
+			result.Imported++
+		}
+	}
+	return result
+}
+
+func formatImportErrors(errs []string) string {
+	var buf strings.Builder
+	for _, err := range errs[:min(len(errs), maxListedImportErrors)] {
+		_, _ = fmt.Fprintf(&buf, "\n* %s", err)
+	}
+	if len(errs) > maxListedImportErrors {
+		_, _ = fmt.Fprintf(&buf, "\n* ...and %d more", len(errs)-maxListedImportErrors)
+	}
+	return buf.String()
+}
+
+var cmdImport = &CommandHandler{
+	Name: "import",
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) < 1 {
+			replyUsage(ce)
+			return
+		}
+		list := ce.Meta.FindListByShortcode(ce.Args[0])
+		if list == nil {
+			ce.Reply("List %s not found", format.SafeMarkdownCode(ce.Args[0]))
+			return
+		}
+		var source string
+		if len(ce.Args) > 1 {
+			source = ce.Args[1]
+		}
+		data, err := ce.Meta.downloadImportFile(ce, source)
+		if err != nil {
+			ce.Reply("Failed to download policy file: %v", err)
+			return
+		}
+		var policies []*exportedPolicy
+		err = json.Unmarshal(data, &policies)
+		if err != nil {
+			ce.Reply("Failed to parse policy file: %v", err)
+			return
+		}
+		result := ce.Meta.importPolicies(ce.Ctx, list, policies)
+		verb := "Imported"
+		if ce.Meta.DryRun {
+			verb = "Dry run: would have imported"
+		}
+		output := fmt.Sprintf(
+			"%s %d/%d policies to %s. %d were skipped as duplicates, %d were invalid and %d failed to send.",
+			verb, result.Imported, len(policies), format.EscapeMarkdown(list.Name),
+			result.Duplicates, len(result.Invalid), len(result.Failed),
+		)
+		if len(result.Invalid) > 0 {
+			output += "\n\nInvalid policies:\n" + formatImportErrors(result.Invalid)
+		}
+		if len(result.Failed) > 0 {
+			output += "\n\nFailed policies:\n" + formatImportErrors(result.Failed)
+		}
+		ce.Reply("%s", output)
+		if result.Imported > 0 {
+			ce.React(SuccessReaction)
+		}
+	},
+}
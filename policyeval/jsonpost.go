@@ -0,0 +1,53 @@
+package policyeval
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const jsonPostTimeout = 30 * time.Second
+
+var jsonPostHTTPClient = &http.Client{Timeout: jsonPostTimeout}
+
+// postJSONWithRetries sends a JSON POST request to the given URL, retrying with exponential backoff
+// up to maxRetries times. It returns the number of attempts made and the error of the last attempt.
+func postJSONWithRetries(ctx context.Context, url string, headers http.Header, body []byte, maxRetries int) (attempts int, err error) {
+	maxAttempts := max(maxRetries, 0) + 1
+	backoff := 5 * time.Second
+	for attempts = 1; ; attempts++ {
+		err = postJSON(ctx, url, headers, body)
+		if err == nil || attempts >= maxAttempts {
+			return
+		}
+		zerolog.Ctx(ctx).Warn().Err(err).Int("attempt", attempts).Msg("POST request failed, retrying")
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func postJSON(ctx context.Context, url string, headers http.Header, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to prepare request: %w", err)
+	}
+	for key, values := range headers {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := jsonPostHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
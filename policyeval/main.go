@@ -14,6 +14,7 @@ import (
 	"maunium.net/go/mautrix/commands"
 	"maunium.net/go/mautrix/event"
 	"maunium.net/go/mautrix/id"
+	"maunium.net/go/mautrix/synapseadmin"
 
 	"go.mau.fi/meowlnir/bot"
 	"go.mau.fi/meowlnir/config"
@@ -23,15 +24,17 @@ import (
 )
 
 type protectedRoomMeta struct {
-	Name     string
-	ACL      *event.ServerACLEventContent
-	ApplyACL bool
+	Name        string
+	ACL         *event.ServerACLEventContent
+	ApplyACL    bool
+	ObserveOnly bool
 }
 
 type PolicyEvaluator struct {
 	Bot       *bot.Bot
 	Store     *policylist.Store
 	SynapseDB *synapsedb.SynapseDB
+	AdminAPI  *synapseadmin.Client
 	DB        *database.Database
 	DryRun    bool
 
@@ -50,6 +53,8 @@ type PolicyEvaluator struct {
 	aclLock    sync.Mutex
 
 	aclDeferChan chan struct{}
+	// mediaCheckSem limits how many messages are downloaded concurrently to check against media policies
+	mediaCheckSem chan struct{}
 
 	claimProtected       func(roomID id.RoomID, eval *PolicyEvaluator, claim bool) *PolicyEvaluator
 	protectedRoomsEvent  *config.ProtectedRoomsEventContent
@@ -58,15 +63,67 @@ type PolicyEvaluator struct {
 	isJoining            map[id.RoomID]struct{}
 	protectedRoomMembers map[id.UserID][]id.RoomID
 	memberHashes         map[[32]byte]id.UserID
+	dirtyUserHashes      map[id.UserID]struct{}
 	skipACLForRooms      []id.RoomID
+	observeOnlyRooms     []id.RoomID
 	protectedRoomsLock   sync.RWMutex
 
-	pendingInvites     map[pendingInvite]struct{}
-	pendingInvitesLock sync.Mutex
-	AutoRejectInvites  bool
-	FilterLocalInvites bool
-	createPuppetClient func(userID id.UserID) *mautrix.Client
-	autoRedactPatterns []glob.Glob
+	pendingInvites              map[pendingInvite]struct{}
+	pendingInvitesLock          sync.Mutex
+	AutoRejectInvites           bool
+	FilterLocalInvites          bool
+	TakedownRedactAllRooms      bool
+	LeaveBannedRooms            bool
+	ReportBanList               string
+	NeverBan                    []string
+	AllowCustomRecommendations  bool
+	UndoAnyAdmin                bool
+	FlapDetection               config.FlapDetectionConfig
+	RedactEdits                 bool
+	UpstreamReporting           *config.UpstreamReportingConfig
+	Webhook                     *config.WebhookConfig
+	ConfirmationTimeout         time.Duration
+	WildcardBanConfirmThreshold int
+	ExemptPowerLevel            int
+	BanRedactWindow             time.Duration
+	NoticeCoalesceWindow        time.Duration
+	ReevaluationDebounce        time.Duration
+	CommandPrefix               string
+	CommandAliases              map[string]string
+	Deactivation                config.DeactivationConfig
+	ServerACL                   config.ServerACLConfig
+	BulkKick                    config.BulkKickConfig
+	AuditRoom                   id.RoomID
+	GetLastEventReceived        func() time.Time
+	createPuppetClient          func(userID id.UserID) *mautrix.Client
+	autoRedactPatterns          []glob.Glob
+
+	reactionActions     map[id.EventID]*reactionActionSet
+	reactionActionsLock sync.Mutex
+
+	reviewPositions     map[id.UserID]int64
+	reviewPositionsLock sync.Mutex
+
+	pendingAppeals map[id.EventID]*pendingAppeal
+	appealsByUser  map[id.UserID]id.EventID
+	appealsLock    sync.Mutex
+
+	flapStates     map[flapKey]*flapState
+	flapStatesLock sync.Mutex
+
+	expiryFailures     map[id.EventID]struct{}
+	expiryFailuresLock sync.Mutex
+
+	actionRetryLock sync.Mutex
+
+	ruleChangeBatch     *ruleChangeBatch
+	ruleChangeBatchLock sync.Mutex
+
+	recentActions     []*database.AuditLogEntry
+	recentActionsLock sync.Mutex
+
+	noticeBatch     *noticeBatch
+	noticeBatchLock sync.Mutex
 }
 
 func NewPolicyEvaluator(
@@ -77,38 +134,49 @@ func NewPolicyEvaluator(
 	synapseDB *synapsedb.SynapseDB,
 	claimProtected func(roomID id.RoomID, eval *PolicyEvaluator, claim bool) *PolicyEvaluator,
 	createPuppetClient func(userID id.UserID) *mautrix.Client,
-	autoRejectInvites, filterLocalInvites, dryRun bool,
+	autoRejectInvites, filterLocalInvites, dryRun, takedownRedactAllRooms bool,
 	hackyAutoRedactPatterns []glob.Glob,
 ) *PolicyEvaluator {
 	pe := &PolicyEvaluator{
-		Bot:                  bot,
-		DB:                   db,
-		SynapseDB:            synapseDB,
-		Store:                store,
-		ManagementRoom:       managementRoom,
-		Admins:               exsync.NewSet[id.UserID](),
-		commandProcessor:     commands.NewProcessor[*PolicyEvaluator](bot.Client),
-		protectedRoomMembers: make(map[id.UserID][]id.RoomID),
-		memberHashes:         make(map[[32]byte]id.UserID),
-		watchedListsMap:      make(map[id.RoomID]*config.WatchedPolicyList),
-		protectedRooms:       make(map[id.RoomID]*protectedRoomMeta),
-		wantToProtect:        make(map[id.RoomID]struct{}),
-		isJoining:            make(map[id.RoomID]struct{}),
-		aclDeferChan:         make(chan struct{}, 1),
-		claimProtected:       claimProtected,
-		pendingInvites:       make(map[pendingInvite]struct{}),
-		createPuppetClient:   createPuppetClient,
-		AutoRejectInvites:    autoRejectInvites,
-		FilterLocalInvites:   filterLocalInvites,
-		DryRun:               dryRun,
-		autoRedactPatterns:   hackyAutoRedactPatterns,
+		Bot:                    bot,
+		DB:                     db,
+		SynapseDB:              synapseDB,
+		Store:                  store,
+		ManagementRoom:         managementRoom,
+		Admins:                 exsync.NewSet[id.UserID](),
+		commandProcessor:       commands.NewProcessor[*PolicyEvaluator](bot.Client),
+		protectedRoomMembers:   make(map[id.UserID][]id.RoomID),
+		memberHashes:           make(map[[32]byte]id.UserID),
+		dirtyUserHashes:        make(map[id.UserID]struct{}),
+		watchedListsMap:        make(map[id.RoomID]*config.WatchedPolicyList),
+		protectedRooms:         make(map[id.RoomID]*protectedRoomMeta),
+		wantToProtect:          make(map[id.RoomID]struct{}),
+		isJoining:              make(map[id.RoomID]struct{}),
+		aclDeferChan:           make(chan struct{}, 1),
+		mediaCheckSem:          make(chan struct{}, maxConcurrentMediaChecks),
+		claimProtected:         claimProtected,
+		pendingInvites:         make(map[pendingInvite]struct{}),
+		createPuppetClient:     createPuppetClient,
+		AutoRejectInvites:      autoRejectInvites,
+		FilterLocalInvites:     filterLocalInvites,
+		DryRun:                 dryRun,
+		TakedownRedactAllRooms: takedownRedactAllRooms,
+		autoRedactPatterns:     hackyAutoRedactPatterns,
+		reactionActions:        make(map[id.EventID]*reactionActionSet),
+		reviewPositions:        make(map[id.UserID]int64),
+		pendingAppeals:         make(map[id.EventID]*pendingAppeal),
+		appealsByUser:          make(map[id.UserID]id.EventID),
+		flapStates:             make(map[flapKey]*flapState),
+		expiryFailures:         make(map[id.EventID]struct{}),
 	}
 	pe.commandProcessor.LogArgs = true
 	pe.commandProcessor.Meta = pe
-	pe.commandProcessor.PreValidator = commands.AnyPreValidator[*PolicyEvaluator]{
-		commands.ValidatePrefixCommand[*PolicyEvaluator](pe.Bot.UserID.String()),
-		commands.ValidatePrefixCommand[*PolicyEvaluator]("!meowlnir"),
-		commands.ValidatePrefixSubstring[*PolicyEvaluator]("!"),
+	pe.commandProcessor.PreValidator = commands.AllPreValidator[*PolicyEvaluator]{
+		commands.AnyPreValidator[*PolicyEvaluator]{
+			commands.ValidatePrefixCommand[*PolicyEvaluator](pe.Bot.UserID.String()),
+			commands.FuncPreValidator[*PolicyEvaluator](validateCommandPrefix),
+		},
+		commands.FuncPreValidator[*PolicyEvaluator](resolveCommandAlias),
 	}
 	pe.commandProcessor.Register(
 		cmdJoin,
@@ -117,32 +185,79 @@ func NewPolicyEvaluator(
 		cmdPowerLevel,
 		cmdRedact,
 		cmdRedactRecent,
+		cmdRedactEvent,
 		cmdKick,
+		cmdMute,
 		cmdBan,
+		cmdBanServer,
+		cmdBanRoom,
+		cmdBanContent,
+		cmdBanMedia,
+		cmdPurgeUser,
+		cmdReasons,
+		cmdReason,
+		cmdSyncACL,
 		cmdRemovePolicy,
+		cmdMovePolicy,
 		cmdAddUnban,
+		cmdUnban,
+		cmdUnbanServer,
 		cmdMatch,
+		cmdMatchServers,
+		cmdExplainPrecedence,
+		cmdConflicts,
+		cmdWhy,
+		cmdNote,
+		cmdWhois,
+		cmdResolve,
+		cmdSimulatePolicy,
+		cmdTestRule,
 		cmdSearch,
 		cmdSendAsBot,
 		cmdSuspend,
 		cmdDeactivate,
+		cmdQuarantine,
 		cmdRooms,
+		cmdLists,
+		cmdStats,
+		cmdDiffLists,
+		cmdWatch,
+		cmdUnwatch,
 		cmdProtectRoom,
+		cmdSetEnforcement,
+		cmdHistoryRoom,
+		cmdResyncRoom,
+		cmdOrphanBans,
+		cmdUnenforced,
+		cmdRecent,
+		cmdUndo,
+		cmdSecureList,
+		cmdExportAudit,
+		cmdExport,
+		cmdImport,
+		cmdListSubscribers,
+		cmdReview,
+		cmdReports,
+		cmdReportAction,
+		cmdFlapping,
+		cmdRetries,
+		cmdStatus,
+		cmdPing,
 		cmdHelp,
 	)
 	go pe.aclDeferLoop()
+	go pe.policyExpiryLoop()
+	go pe.aclResyncLoop()
+	go pe.userHashIndexLoop()
+	go pe.actionRetryLoop()
 	return pe
 }
 
-func (pe *PolicyEvaluator) sendNotice(ctx context.Context, message string, args ...any) {
-	pe.Bot.SendNotice(ctx, pe.ManagementRoom, message, args...)
-}
-
 func (pe *PolicyEvaluator) Load(ctx context.Context) {
 	err := pe.tryLoad(ctx)
 	if err != nil {
 		zerolog.Ctx(ctx).Err(err).Msg("Failed to load initial state")
-		pe.sendNotice(ctx, "Failed to load initial state: %v", err)
+		pe.sendNoticeNow(ctx, "Failed to load initial state: %v", err)
 	} else {
 		zerolog.Ctx(ctx).Info().Msg("Loaded initial state")
 	}
@@ -175,10 +290,8 @@ func (pe *PolicyEvaluator) tryLoad(ctx context.Context) error {
 		_, errorMsgs := pe.handleProtectedRooms(ctx, evt, true)
 		errors = append(errors, errorMsgs...)
 	}
+	pe.loadRecentActions(ctx)
 	initDuration := time.Since(start)
-	start = time.Now()
-	pe.EvaluateAll(ctx)
-	evalDuration := time.Since(start)
 	pe.protectedRoomsLock.Lock()
 	userCount := len(pe.protectedRoomMembers)
 	var joinedUserCount int
@@ -195,10 +308,11 @@ func (pe *PolicyEvaluator) tryLoad(ctx context.Context) error {
 			strings.Join(errors, "\n"), protectedRoomsCount, joinedUserCount, userCount, len(pe.GetWatchedLists()))
 	} else {
 		pe.sendNotice(ctx,
-			"Initialization completed successfully (took %s to load data and %s to evaluate rules). "+
-				"Protecting %d rooms with %d users (%d all time) using %d lists.",
-			initDuration, evalDuration, protectedRoomsCount, joinedUserCount, userCount, len(pe.GetWatchedLists()))
+			"Initialization completed successfully (took %s to load data). "+
+				"Protecting %d rooms with %d users (%d all time) using %d lists. Evaluating rules in the background...",
+			initDuration, protectedRoomsCount, joinedUserCount, userCount, len(pe.GetWatchedLists()))
 	}
+	go pe.StartupScan(context.WithoutCancel(ctx))
 	return nil
 }
 
@@ -0,0 +1,25 @@
+package policyeval
+
+import (
+	"strings"
+
+	"maunium.net/go/mautrix/id"
+)
+
+// isMatrixLink returns true if the given command argument is a matrix.to link or a matrix: URI.
+func isMatrixLink(arg string) bool {
+	return strings.HasPrefix(arg, "https://matrix.to/") || strings.HasPrefix(arg, "matrix:")
+}
+
+// normalizeIDArg converts a matrix.to link or matrix: URI into the user ID, room ID or room alias it points at.
+// Event links are converted into the room ID or alias. Other arguments, like bare IDs and globs, are returned as-is.
+func normalizeIDArg(arg string) string {
+	if !isMatrixLink(arg) {
+		return arg
+	}
+	uri, err := id.ParseMatrixURIOrMatrixToURL(arg)
+	if err != nil {
+		return arg
+	}
+	return uri.PrimaryIdentifier()
+}
@@ -5,9 +5,14 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/rs/zerolog"
 	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+	"maunium.net/go/mautrix/id"
 
 	"go.mau.fi/meowlnir/bot"
+	"go.mau.fi/meowlnir/database"
+	"go.mau.fi/meowlnir/policylist"
 )
 
 func (pe *PolicyEvaluator) isMention(content *event.MessageEventContent) bool {
@@ -35,4 +40,90 @@ func (pe *PolicyEvaluator) HandleMessage(ctx context.Context, evt *event.Event)
 			&bot.SendNoticeOpts{Mentions: &event.Mentions{Room: true}, SendAsText: true},
 		)
 	}
+	pe.checkMessageContent(ctx, evt, content)
+	// Checking media requires downloading it, so don't block event handling
+	go pe.checkMessageMedia(context.WithoutCancel(ctx), evt, content)
+}
+
+// checkMessageContent matches the body of a message in a protected room against content policies
+// in watched lists, redacting the message (and banning the sender if the policy says so) on match.
+func (pe *PolicyEvaluator) checkMessageContent(ctx context.Context, evt *event.Event, content *event.MessageEventContent) {
+	if content.Body == "" || pe.Admins.Has(evt.Sender) {
+		return
+	}
+	// Newlines are replaced with spaces so that wildcards can match across lines.
+	body := strings.ReplaceAll(content.Body, "\n", " ")
+	policy := pe.Store.MatchContent(pe.GetWatchedLists(), body).Recommendations().BanOrUnban
+	if policy == nil || (policy.Recommendation != event.PolicyRecommendationBan && policy.Recommendation != event.PolicyRecommendationUnstableTakedown) {
+		return
+	}
+	log := zerolog.Ctx(ctx).With().
+		Stringer("room_id", evt.RoomID).
+		Stringer("event_id", evt.ID).
+		Stringer("sender", evt.Sender).
+		Str("content_policy", policy.EntityOrHash()).
+		Stringer("policy_list", policy.RoomID).
+		Logger()
+	listName := policy.RoomID.String()
+	if meta := pe.GetWatchedListMeta(policy.RoomID); meta != nil {
+		listName = meta.Name
+	}
+	if pe.isObserveOnlyRoom(evt.RoomID) {
+		log.Info().Msg("Message matches content policy in observe-only room")
+		pe.sendNotice(
+			ctx, "👀 [A message](%s) from [%s](%s) in %s matches content policy %s in %s, but the room is observe-only: %s",
+			evt.RoomID.EventURI(evt.ID).MatrixToURL(), evt.Sender, evt.Sender.URI().MatrixToURL(), pe.formatRoomLink(evt.RoomID),
+			format.SafeMarkdownCode(policy.EntityOrHash()), format.EscapeMarkdown(listName), format.SafeMarkdownCode(policy.Reason),
+		)
+		return
+	}
+	log.Info().Msg("Redacting message matching content policy")
+	reason := filterReason(policy.Reason)
+	relatedCount, err := pe.redactEventAndEdits(ctx, evt.RoomID, evt.ID, reason)
+	if err != nil {
+		log.Err(err).Msg("Failed to redact message matching content policy")
+		pe.sendNotice(
+			ctx, "Failed to redact [a message](%s) from [%s](%s) matching content policy %s in %s: %v",
+			evt.RoomID.EventURI(evt.ID).MatrixToURL(), evt.Sender, evt.Sender.URI().MatrixToURL(),
+			format.SafeMarkdownCode(policy.EntityOrHash()), format.EscapeMarkdown(listName), err,
+		)
+		return
+	}
+	pe.logAction(ctx, &database.AuditLogEntry{
+		Action:         database.AuditLogActionRedact,
+		TargetUser:     evt.Sender,
+		TargetEvent:    evt.ID,
+		InRoomID:       evt.RoomID,
+		Actor:          policy.Sender,
+		Reason:         policy.Reason,
+		PolicyList:     policy.RoomID,
+		Entity:         policy.EntityOrHash(),
+		Recommendation: string(policy.Recommendation),
+	})
+	pe.sendNotice(
+		ctx, "Redacted [a message](%s) from [%s](%s) in %s matching content policy %s in %s%s",
+		evt.RoomID.EventURI(evt.ID).MatrixToURL(), evt.Sender, evt.Sender.URI().MatrixToURL(), pe.formatRoomLink(evt.RoomID),
+		format.SafeMarkdownCode(policy.EntityOrHash()), format.EscapeMarkdown(listName), formatRelatedRedactions(relatedCount),
+	)
+	if policy.BanSender {
+		pe.banContentPolicySender(ctx, evt, policy)
+	}
+}
+
+// banContentPolicySender bans the sender of a message matching a content policy from all protected rooms they're in.
+func (pe *PolicyEvaluator) banContentPolicySender(ctx context.Context, evt *event.Event, policy *policylist.Policy) {
+	rooms := pe.getRoomsUserIsIn(evt.Sender)
+	if len(rooms) == 0 {
+		// The membership cache may not be populated yet, but the user must be in the room they sent the message to.
+		rooms = []id.RoomID{evt.RoomID}
+	}
+	for _, roomID := range rooms {
+		if level, exempt := pe.getExemptPowerLevel(ctx, roomID, evt.Sender); exempt {
+			pe.notifyExemptUser(ctx, evt.Sender, roomID, level, policy)
+		} else if pe.isObserveOnlyRoom(roomID) {
+			pe.notifyObservedBan(ctx, evt.Sender, roomID, policy)
+		} else {
+			pe.ApplyBan(ctx, evt.Sender, roomID, policy)
+		}
+	}
 }
@@ -0,0 +1,25 @@
+package policyeval
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	userEvaluations = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "meowlnir_user_evaluations_total",
+		Help: "Number of times a user was evaluated against all watched policy lists",
+	})
+	policyMatches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "meowlnir_policy_matches_total",
+		Help: "Number of user evaluations that resulted in a ban or unban recommendation being applied",
+	}, []string{"recommendation"})
+	actionsPerformed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "meowlnir_actions_total",
+		Help: "Number of moderation actions and policy changes performed (including dry run actions)",
+	}, []string{"action", "dry_run"})
+	failedActions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "meowlnir_failed_actions_total",
+		Help: "Number of bans, kicks and redactions that failed",
+	}, []string{"action"})
+)
@@ -0,0 +1,72 @@
+package policyeval
+
+import (
+	"strings"
+
+	"maunium.net/go/mautrix/format"
+
+	"go.mau.fi/meowlnir/policylist"
+)
+
+// normalizeNoteEntity validates an entity for notes and normalizes it the same way as policy entities,
+// so that notes added with `!note` are shown next to policies for the same entity.
+func normalizeNoteEntity(entity string) (string, bool) {
+	entity = normalizeIDArg(entity)
+	entityType, ok := validateEntity(entity)
+	if !ok {
+		return "", false
+	}
+	switch entityType {
+	case policylist.EntityTypeUser:
+		normalized, err := normalizeUserEntity(entity)
+		if err != nil {
+			return "", false
+		}
+		return normalized, true
+	case policylist.EntityTypeServer:
+		return strings.ToLower(entity), true
+	default:
+		return entity, true
+	}
+}
+
+var cmdNote = &CommandHandler{
+	Name: "note",
+	Func: func(ce *CommandEvent) {
+		remove := len(ce.Args) > 0 && ce.Args[0] == "--remove"
+		if remove {
+			ce.Args = ce.Args[1:]
+		}
+		if len(ce.Args) < 1 || (remove && len(ce.Args) != 1) {
+			replyUsage(ce)
+			return
+		}
+		entity, ok := normalizeNoteEntity(ce.Args[0])
+		if !ok {
+			ce.Reply("Invalid entity %s", format.SafeMarkdownCode(ce.Args[0]))
+			return
+		}
+		if remove {
+			err := ce.Meta.DB.EntityNote.DeleteAllByEntity(ce.Ctx, ce.Meta.ManagementRoom, entity)
+			if err != nil {
+				ce.Reply("Failed to remove notes: %v", err)
+				return
+			}
+			ce.React(SuccessReaction)
+			return
+		}
+		text := strings.Join(ce.Args[1:], " ")
+		if text == "" {
+			notes := ce.Meta.formatEntityNotes(ce.Ctx, entity)
+			if notes == "" {
+				ce.Reply("No notes found for %s", format.SafeMarkdownCode(entity))
+			} else {
+				ce.Reply("Notes for %s:\n\n%s", format.SafeMarkdownCode(entity), notes)
+			}
+			return
+		}
+		if ce.Meta.addEntityNote(ce, entity, text) {
+			ce.React(SuccessReaction)
+		}
+	},
+}
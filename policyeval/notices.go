@@ -0,0 +1,110 @@
+package policyeval
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"maunium.net/go/mautrix/id"
+)
+
+const (
+	// maxCoalescedNotices is the maximum number of notices combined into a single management room message.
+	maxCoalescedNotices = 25
+	// maxCoalescedNoticeLength is the maximum length of a combined notice before a new message is started.
+	maxCoalescedNoticeLength = 16000
+)
+
+type noticeBatch struct {
+	EventID  id.EventID
+	Messages []string
+	Length   int
+	Dirty    bool
+	Timer    *time.Timer
+}
+
+func (nb *noticeBatch) canAppend(message string) bool {
+	return len(nb.Messages) < maxCoalescedNotices && nb.Length+len(message) < maxCoalescedNoticeLength
+}
+
+// sendNotice sends a notice to the management room. If notice coalescing is enabled, the first notice is
+// sent immediately and any notices sent within the coalescing window are appended to it by editing it.
+func (pe *PolicyEvaluator) sendNotice(ctx context.Context, message string, args ...any) {
+	if len(args) > 0 {
+		message = fmt.Sprintf(message, args...)
+	}
+	if pe.NoticeCoalesceWindow <= 0 {
+		pe.Bot.SendNotice(ctx, pe.ManagementRoom, message)
+		return
+	}
+	pe.noticeBatchLock.Lock()
+	defer pe.noticeBatchLock.Unlock()
+	if batch := pe.noticeBatch; batch != nil && batch.canAppend(message) {
+		batch.Messages = append(batch.Messages, message)
+		batch.Length += len(message)
+		batch.Dirty = true
+		return
+	}
+	pe.flushNoticeBatch(ctx)
+	eventID := pe.Bot.SendNotice(ctx, pe.ManagementRoom, message)
+	if eventID == "" {
+		return
+	}
+	batch := &noticeBatch{
+		EventID:  eventID,
+		Messages: []string{message},
+		Length:   len(message),
+	}
+	ctx = context.WithoutCancel(ctx)
+	batch.Timer = time.AfterFunc(pe.NoticeCoalesceWindow, func() {
+		pe.tickNoticeBatch(ctx, batch)
+	})
+	pe.noticeBatch = batch
+}
+
+// sendNoticeNow sends a notice to the management room as a new message without coalescing it with other notices.
+// It should be used for important errors and interactive messages that must not be hidden inside an earlier message.
+func (pe *PolicyEvaluator) sendNoticeNow(ctx context.Context, message string, args ...any) id.EventID {
+	if len(args) > 0 {
+		message = fmt.Sprintf(message, args...)
+	}
+	pe.noticeBatchLock.Lock()
+	defer pe.noticeBatchLock.Unlock()
+	pe.flushNoticeBatch(ctx)
+	return pe.Bot.SendNotice(ctx, pe.ManagementRoom, message)
+}
+
+// tickNoticeBatch is called when the coalescing window of a batch ends. If new notices were added to the batch,
+// the message is edited and the batch stays open for another window, otherwise the batch is closed.
+func (pe *PolicyEvaluator) tickNoticeBatch(ctx context.Context, batch *noticeBatch) {
+	pe.noticeBatchLock.Lock()
+	defer pe.noticeBatchLock.Unlock()
+	if pe.noticeBatch != batch {
+		return
+	} else if !batch.Dirty {
+		pe.noticeBatch = nil
+		return
+	}
+	pe.editNoticeBatch(ctx, batch)
+	batch.Timer.Reset(pe.NoticeCoalesceWindow)
+}
+
+// flushNoticeBatch writes any pending notices of the current batch and closes it.
+// The caller must hold noticeBatchLock.
+func (pe *PolicyEvaluator) flushNoticeBatch(ctx context.Context) {
+	batch := pe.noticeBatch
+	if batch == nil {
+		return
+	}
+	pe.noticeBatch = nil
+	batch.Timer.Stop()
+	if batch.Dirty {
+		pe.editNoticeBatch(ctx, batch)
+	}
+}
+
+func (pe *PolicyEvaluator) editNoticeBatch(ctx context.Context, batch *noticeBatch) {
+	pe.Bot.EditNotice(ctx, pe.ManagementRoom, batch.EventID, strings.Join(batch.Messages, "\n\n"))
+	batch.Dirty = false
+}
@@ -0,0 +1,139 @@
+package policyeval
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+	"maunium.net/go/mautrix/id"
+)
+
+type orphanBan struct {
+	UserID id.UserID
+	Rooms  []id.RoomID
+	Reason string
+}
+
+// findOrphanBans finds users who are banned in the given rooms, but don't match any policy in the watched lists.
+func (pe *PolicyEvaluator) findOrphanBans(ctx context.Context, rooms []id.RoomID) (bans []*orphanBan, errors []string) {
+	byUser := make(map[id.UserID]*orphanBan)
+	watchedLists := pe.GetWatchedLists()
+	for _, roomID := range rooms {
+		members, err := pe.Bot.Members(ctx, roomID, mautrix.ReqMembers{Membership: event.MembershipBan})
+		if err != nil {
+			zerolog.Ctx(ctx).Err(err).Stringer("room_id", roomID).Msg("Failed to get banned members")
+			errors = append(errors, fmt.Sprintf("* Failed to get banned members of [%s](%s): %v", roomID, roomID.URI().MatrixToURL(), err))
+			continue
+		}
+		for _, evt := range members.Chunk {
+			content, ok := evt.Content.Parsed.(*event.MemberEventContent)
+			if !ok || content.Membership != event.MembershipBan {
+				continue
+			}
+			userID := id.UserID(evt.GetStateKey())
+			ban, seen := byUser[userID]
+			if !seen {
+				if pe.Store.MatchUser(watchedLists, userID).Recommendations().BanOrUnban != nil {
+					continue
+				}
+				ban = &orphanBan{UserID: userID, Reason: content.Reason}
+				byUser[userID] = ban
+				bans = append(bans, ban)
+			}
+			ban.Rooms = append(ban.Rooms, roomID)
+		}
+	}
+	slices.SortFunc(bans, func(a, b *orphanBan) int {
+		return strings.Compare(string(a.UserID), string(b.UserID))
+	})
+	return
+}
+
+const maxListedOrphanBans = 50
+
+var cmdOrphanBans = &CommandHandler{
+	Name: "orphan-bans",
+	Func: func(ce *CommandEvent) {
+		var createList string
+		if createIdx := slices.Index(ce.Args, "--create"); createIdx >= 0 && createIdx+1 < len(ce.Args) {
+			createList = ce.Args[createIdx+1]
+			ce.Args = slices.Delete(ce.Args, createIdx, createIdx+2)
+		}
+		rooms := ce.Meta.GetProtectedRooms()
+		if len(ce.Args) > 0 {
+			roomID := resolveRoom(ce, ce.Args[0])
+			if roomID == "" {
+				return
+			} else if !ce.Meta.IsProtectedRoom(roomID) {
+				ce.Reply("%s is not a protected room", format.SafeMarkdownCode(ce.Args[0]))
+				return
+			}
+			rooms = []id.RoomID{roomID}
+		}
+		bans, errors := ce.Meta.findOrphanBans(ce.Ctx, rooms)
+		if len(errors) > 0 {
+			ce.Reply("%s", strings.Join(errors, "\n"))
+		}
+		if len(bans) == 0 {
+			ce.Reply("All banned users in %s are covered by policies", pluralize(len(rooms), "room"))
+			return
+		}
+		lines := make([]string, 0, min(len(bans), maxListedOrphanBans))
+		for _, ban := range bans[:min(len(bans), maxListedOrphanBans)] {
+			roomLinks := make([]string, len(ban.Rooms))
+			for i, roomID := range ban.Rooms {
+				roomLinks[i] = fmt.Sprintf("[%s](%s)", format.EscapeMarkdown(ce.Meta.getProtectedRoomName(roomID)), roomID.URI().MatrixToURL())
+			}
+			line := fmt.Sprintf("* [%s](%s) in %s", ban.UserID, ban.UserID.URI().MatrixToURL(), strings.Join(roomLinks, ", "))
+			if ban.Reason != "" {
+				line += fmt.Sprintf(" for %s", format.SafeMarkdownCode(ban.Reason))
+			}
+			lines = append(lines, line)
+		}
+		if len(bans) > maxListedOrphanBans {
+			lines = append(lines, fmt.Sprintf("* ...and %d more", len(bans)-maxListedOrphanBans))
+		}
+		summary := fmt.Sprintf("Found %s banned without a matching policy:\n\n%s", pluralize(len(bans), "user"), strings.Join(lines, "\n"))
+		if createList == "" {
+			ce.Reply("%s\n\nUse `%sorphan-bans --create <list shortcode>` to create ban policies for them", summary, ce.Meta.commandPrefix())
+			return
+		}
+		list := ce.Meta.FindListByShortcode(createList)
+		if list == nil {
+			ce.Reply("List %s not found", format.SafeMarkdownCode(createList))
+			return
+		}
+		ce.Meta.requestConfirmation(
+			ce.Ctx,
+			fmt.Sprintf("%s\n\nAre you sure you want to create ban policies for them in %s?", summary, format.EscapeMarkdown(list.Name)),
+			func(ctx context.Context, _ id.UserID) {
+				ce.Ctx = ctx
+				var sentCount, failedCount int
+				for _, ban := range bans {
+					sent, err := ce.Meta.sendBanPolicy(ce, ban.UserID.String(), &banParams{
+						List:           list,
+						Reason:         ban.Reason,
+						Recommendation: event.PolicyRecommendationBan,
+					})
+					if err != nil {
+						failedCount++
+						ce.Reply("Failed to send ban policy for %s: %v", format.SafeMarkdownCode(ban.UserID), err)
+					} else if sent {
+						sentCount++
+					}
+				}
+				ce.Reply("Sent %d/%d ban policies to %s", sentCount, len(bans), format.EscapeMarkdown(list.Name))
+				if failedCount > 0 {
+					ce.React(FailureReaction)
+				} else {
+					ce.React(SuccessReaction)
+				}
+			},
+		)
+	},
+}
@@ -0,0 +1,36 @@
+package policyeval
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"maunium.net/go/mautrix/commands"
+)
+
+var cmdPing = &CommandHandler{
+	Name: "ping",
+	Func: func(ce *CommandEvent) {
+		receiveLatency := time.Since(time.UnixMilli(ce.Timestamp)).Truncate(time.Millisecond)
+		sendStart := time.Now()
+		pongID := ce.Reply("Pong!")
+		sendLatency := time.Since(sendStart).Truncate(time.Millisecond)
+		if pongID == "" {
+			return
+		}
+		var buf strings.Builder
+		buf.WriteString("Pong!\n\n")
+		_, _ = fmt.Fprintf(&buf, "* Receive latency: %s\n", receiveLatency)
+		_, _ = fmt.Fprintf(&buf, "* Send latency: %s\n", sendLatency)
+		var lastEvent time.Time
+		if ce.Meta.GetLastEventReceived != nil {
+			lastEvent = ce.Meta.GetLastEventReceived()
+		}
+		if lastEvent.IsZero() {
+			buf.WriteString("* Last event received: never\n")
+		} else {
+			_, _ = fmt.Fprintf(&buf, "* Last event received: %s ago\n", time.Since(lastEvent).Truncate(time.Millisecond))
+		}
+		ce.Respond(buf.String(), commands.ReplyOpts{AllowMarkdown: true, Reply: true, Edit: pongID})
+	},
+}
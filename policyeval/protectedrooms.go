@@ -11,6 +11,7 @@ import (
 	"github.com/rs/zerolog"
 	"maunium.net/go/mautrix"
 	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
 	"maunium.net/go/mautrix/id"
 
 	"go.mau.fi/meowlnir/config"
@@ -31,6 +32,30 @@ func (pe *PolicyEvaluator) IsProtectedRoom(roomID id.RoomID) bool {
 	return protected
 }
 
+// getProtectedRoomName returns the name of the given protected room, or the room ID if it doesn't have a name.
+func (pe *PolicyEvaluator) getProtectedRoomName(roomID id.RoomID) string {
+	pe.protectedRoomsLock.RLock()
+	meta := pe.protectedRooms[roomID]
+	pe.protectedRoomsLock.RUnlock()
+	if meta != nil && meta.Name != "" {
+		return meta.Name
+	}
+	return roomID.String()
+}
+
+// isObserveOnlyRoom returns true if policies shouldn't be enforced automatically in the given protected room.
+func (pe *PolicyEvaluator) isObserveOnlyRoom(roomID id.RoomID) bool {
+	pe.protectedRoomsLock.RLock()
+	meta := pe.protectedRooms[roomID]
+	pe.protectedRoomsLock.RUnlock()
+	return meta != nil && meta.ObserveOnly
+}
+
+// formatRoomLink returns a markdown link to the given room, using the protected room name as the link text if known.
+func (pe *PolicyEvaluator) formatRoomLink(roomID id.RoomID) string {
+	return fmt.Sprintf("[%s](%s)", format.EscapeMarkdown(pe.getProtectedRoomName(roomID)), roomID.URI().MatrixToURL())
+}
+
 func (pe *PolicyEvaluator) HandleProtectedRoomMeta(ctx context.Context, evt *event.Event) {
 	switch evt.Type {
 	case event.StatePowerLevels:
@@ -76,7 +101,7 @@ func (pe *PolicyEvaluator) handleProtectedRoomPowerLevels(ctx context.Context, e
 	} else if wantToProtect && ownLevel >= minLevel {
 		_, errMsg := pe.tryProtectingRoom(ctx, nil, evt.RoomID, true)
 		if errMsg != "" {
-			pe.sendNotice(ctx, "Retried protecting room after power level change, but failed: %s", strings.TrimPrefix(errMsg, "* "))
+			pe.sendNoticeNow(ctx, "Retried protecting room after power level change, but failed: %s", strings.TrimPrefix(errMsg, "* "))
 		} else {
 			pe.sendNotice(ctx, "Power levels corrected, now protecting [%s](%s)", evt.RoomID, evt.RoomID.URI().MatrixToURL())
 		}
@@ -180,9 +205,12 @@ func (pe *PolicyEvaluator) handleProtectedRooms(ctx context.Context, evt *event.
 	pe.protectedRoomsLock.Lock()
 	pe.protectedRoomsEvent = content
 	pe.skipACLForRooms = content.SkipACL
-	for roomID := range pe.protectedRooms {
+	pe.observeOnlyRooms = content.ObserveOnly
+	for roomID, meta := range pe.protectedRooms {
+		meta.ObserveOnly = slices.Contains(content.ObserveOnly, roomID)
 		if !slices.Contains(content.Rooms, roomID) {
 			delete(pe.protectedRooms, roomID)
+			pe.unlockedRemoveRoomMembers(roomID)
 			pe.claimProtected(roomID, pe, false)
 			output = append(output, fmt.Sprintf("* Stopped protecting room [%s](%s)", roomID, roomID.URI().MatrixToURL()))
 		}
@@ -212,7 +240,16 @@ func (pe *PolicyEvaluator) handleProtectedRooms(ctx context.Context, evt *event.
 				for _, member := range members.Chunk {
 					reevalMembers[id.UserID(member.GetStateKey())] = struct{}{}
 				}
-				output = append(output, fmt.Sprintf("* Started protecting room [%s](%s)", roomID, roomID.URI().MatrixToURL()))
+				var joinedCount int
+				for _, member := range members.Chunk {
+					if member.Content.AsMember().Membership == event.MembershipJoin {
+						joinedCount++
+					}
+				}
+				output = append(output, fmt.Sprintf(
+					"* Started protecting room [%s](%s) with %s",
+					roomID, roomID.URI().MatrixToURL(), pluralize(joinedCount, "joined member"),
+				))
 			}
 		}()
 	}
@@ -233,13 +270,28 @@ func (pe *PolicyEvaluator) markAsWantToProtect(roomID id.RoomID) {
 func (pe *PolicyEvaluator) markAsProtectedRoom(roomID id.RoomID, name string, acl *event.ServerACLEventContent, evts []*event.Event) {
 	pe.protectedRoomsLock.Lock()
 	defer pe.protectedRoomsLock.Unlock()
-	pe.protectedRooms[roomID] = &protectedRoomMeta{Name: name, ACL: acl, ApplyACL: !slices.Contains(pe.skipACLForRooms, roomID)}
+	pe.protectedRooms[roomID] = &protectedRoomMeta{
+		Name:        name,
+		ACL:         acl,
+		ApplyACL:    !slices.Contains(pe.skipACLForRooms, roomID),
+		ObserveOnly: slices.Contains(pe.observeOnlyRooms, roomID),
+	}
 	delete(pe.wantToProtect, roomID)
 	for _, evt := range evts {
 		pe.unlockedUpdateUser(id.UserID(evt.GetStateKey()), evt.RoomID, evt.Content.AsMember().Membership)
 	}
 }
 
+// unlockedRemoveRoomMembers removes a room that is no longer protected from the tracked rooms of all users,
+// so that policies aren't applied there anymore. The caller must hold protectedRoomsLock.
+func (pe *PolicyEvaluator) unlockedRemoveRoomMembers(roomID id.RoomID) {
+	for userID, rooms := range pe.protectedRoomMembers {
+		if idx := slices.Index(rooms, roomID); idx >= 0 {
+			pe.protectedRoomMembers[userID] = slices.Delete(rooms, idx, idx+1)
+		}
+	}
+}
+
 func isInRoom(membership event.Membership) bool {
 	switch membership {
 	case event.MembershipJoin, event.MembershipInvite, event.MembershipKnock:
@@ -264,6 +316,7 @@ func (pe *PolicyEvaluator) unlockedUpdateUser(userID id.UserID, roomID id.RoomID
 	if !ok {
 		pe.memberHashes[util.SHA256String(string(userID))] = userID
 	}
+	pe.dirtyUserHashes[userID] = struct{}{}
 	if add {
 		if !slices.Contains(existingList, roomID) {
 			pe.protectedRoomMembers[userID] = append(existingList, roomID)
@@ -0,0 +1,100 @@
+package policyeval
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/meowlnir/config"
+	"go.mau.fi/meowlnir/policylist"
+)
+
+var cmdPurgeUser = &CommandHandler{
+	Name: "purge-user",
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) < 2 {
+			replyUsage(ce)
+			return
+		}
+		list := ce.Meta.FindListByShortcode(ce.Args[0])
+		if list == nil {
+			ce.Reply("List %s not found", format.SafeMarkdownCode(ce.Args[0]))
+			return
+		}
+		userID := id.UserID(ce.Args[1])
+		if _, _, err := userID.ParseAndValidate(); err != nil {
+			ce.Reply("Invalid user ID %s: %v", format.SafeMarkdownCode(ce.Args[1]), err)
+			return
+		} else if userID == ce.Meta.Bot.UserID {
+			ce.Reply("Refusing to purge the bot itself")
+			return
+		} else if err = ce.Meta.checkBanSafeguards(policylist.EntityTypeUser, userID.String(), event.PolicyRecommendationBan, false); err != nil {
+			// Check the safeguards before kicking and redacting, as the policy would only be refused afterwards
+			ce.Reply("Refusing to purge %s: %v", format.SafeMarkdownCode(userID), err)
+			return
+		}
+		reason := expandReasonTemplate(list, strings.Join(ce.Args[2:], " "))
+		rooms := ce.Meta.getRoomsUserIsIn(userID)
+		prompt := fmt.Sprintf(
+			"This will send a ban policy for %s to %s, kick them from %s and redact their messages in all protected rooms with reason %s.",
+			format.SafeMarkdownCode(userID), format.EscapeMarkdown(list.Name), pluralize(len(rooms), "room"), format.SafeMarkdownCode(reason),
+		)
+		if ce.Meta.DryRun {
+			prompt += " Dry run is enabled, so nothing will actually be changed."
+		}
+		ce.Meta.requestConfirmation(ce.Ctx, prompt+" Are you sure?", func(ctx context.Context, _ id.UserID) {
+			ce.Ctx = ctx
+			purgeUser(ce, list, userID, reason)
+		})
+	},
+}
+
+func purgeUser(ce *CommandEvent, list *config.WatchedPolicyList, userID id.UserID, reason string) {
+	var summary []string
+	rooms := ce.Meta.getRoomsUserIsIn(userID)
+	if len(rooms) == 0 {
+		summary = append(summary, "* Kick: user is not in any protected rooms")
+	} else {
+		// Kick before sending the policy, as kicking fails once the policy has caused a ban
+		kicked, failed := ce.Meta.kickFromRooms(ce.Ctx, userID, rooms, reason)
+		line := fmt.Sprintf("* Kick: kicked from %d/%d rooms", len(kicked), len(rooms))
+		for roomID, err := range failed {
+			line += fmt.Sprintf("\n  * failed in %s: %v", format.SafeMarkdownCode(roomID), err)
+		}
+		summary = append(summary, line)
+	}
+
+	sent, err := ce.Meta.sendBanPolicy(ce, userID.String(), &banParams{
+		List:           list,
+		Reason:         reason,
+		Recommendation: event.PolicyRecommendationBan,
+	})
+	switch {
+	case err != nil:
+		summary = append(summary, fmt.Sprintf("* Ban policy: failed: %v", err))
+	case !sent:
+		summary = append(summary, "* Ban policy: skipped")
+	case ce.Meta.DryRun:
+		summary = append(summary, "* Ban policy: would be sent (dry run)")
+	default:
+		summary = append(summary, fmt.Sprintf("* Ban policy: sent to %s", format.EscapeMarkdown(list.Name)))
+	}
+
+	redactedCount, counted := ce.Meta.RedactUser(ce.Ctx, userID, reason, false)
+	if counted {
+		redactVerb := "redacted"
+		if ce.Meta.DryRun {
+			redactVerb = "would redact"
+		}
+		summary = append(summary, fmt.Sprintf("* Redaction: %s %s", redactVerb, pluralize(redactedCount, "event")))
+	} else {
+		summary = append(summary, "* Redaction: event count unknown, see the messages above for details")
+	}
+
+	ce.Reply("Purge of %s completed:\n\n%s", format.SafeMarkdownCode(userID), strings.Join(summary, "\n"))
+	ce.React(SuccessReaction)
+}
@@ -0,0 +1,191 @@
+package policyeval
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog"
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+)
+
+type adminUserMedia struct {
+	MediaID       string `json:"media_id"`
+	QuarantinedBy string `json:"quarantined_by"`
+}
+
+type respAdminListUserMedia struct {
+	Media     []adminUserMedia `json:"media"`
+	NextToken int              `json:"next_token"`
+	Total     int              `json:"total"`
+}
+
+const (
+	quarantinePageSize = 100
+	// quarantineRequestDelay is the delay between quarantine requests to avoid hammering the homeserver.
+	quarantineRequestDelay = 100 * time.Millisecond
+)
+
+type quarantineResult struct {
+	Quarantined        int
+	AlreadyQuarantined int
+	Failed             int
+	// FromEvents is true if the user's media couldn't be enumerated and only media referenced
+	// in reported events was quarantined.
+	FromEvents bool
+}
+
+// listUserMediaAdminAPI lists the media uploaded by the given local user, following pagination.
+//
+// https://element-hq.github.io/synapse/latest/admin_api/user_admin_api.html#list-media-uploaded-by-a-user
+func (pe *PolicyEvaluator) listUserMediaAdminAPI(ctx context.Context, userID id.UserID) (media, alreadyQuarantined []id.ContentURI, err error) {
+	cli := pe.synapseAdmin()
+	from := 0
+	for {
+		var resp respAdminListUserMedia
+		_, err = cli.MakeRequest(ctx, http.MethodGet, cli.BuildURLWithQuery(
+			mautrix.SynapseAdminURLPath{"v1", "users", userID, "media"},
+			map[string]string{"from": strconv.Itoa(from), "limit": strconv.Itoa(quarantinePageSize)},
+		), nil, &resp)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list media: %w", err)
+		}
+		for _, item := range resp.Media {
+			uri := id.ContentURI{Homeserver: pe.Bot.ServerName, FileID: item.MediaID}
+			if item.QuarantinedBy != "" {
+				alreadyQuarantined = append(alreadyQuarantined, uri)
+			} else {
+				media = append(media, uri)
+			}
+		}
+		if resp.NextToken == 0 || len(resp.Media) == 0 {
+			return
+		}
+		from = resp.NextToken
+	}
+}
+
+// quarantineMediaAdminAPI quarantines a single piece of media.
+//
+// https://element-hq.github.io/synapse/latest/admin_api/media_admin_api.html#quarantining-media-by-id
+func (pe *PolicyEvaluator) quarantineMediaAdminAPI(ctx context.Context, uri id.ContentURI) error {
+	cli := pe.synapseAdmin()
+	_, err := cli.MakeRequest(ctx, http.MethodPost, cli.BuildAdminURL("v1", "media", "quarantine", uri.Homeserver, uri.FileID), struct{}{}, nil)
+	return err
+}
+
+// getMediaFromEvent returns the content URIs referenced in the given event's content.
+func getMediaFromEvent(evt *event.Event) (uris []id.ContentURI) {
+	addURI := func(raw any) {
+		str, ok := raw.(string)
+		if !ok {
+			return
+		}
+		uri, err := id.ParseContentURI(str)
+		if err == nil && !uri.IsEmpty() {
+			uris = append(uris, uri)
+		}
+	}
+	addURI(evt.Content.Raw["url"])
+	if file, ok := evt.Content.Raw["file"].(map[string]any); ok {
+		addURI(file["url"])
+	}
+	if info, ok := evt.Content.Raw["info"].(map[string]any); ok {
+		addURI(info["thumbnail_url"])
+		if thumbnailFile, ok := info["thumbnail_file"].(map[string]any); ok {
+			addURI(thumbnailFile["url"])
+		}
+	}
+	return
+}
+
+// getReportedMedia finds media referenced in events from the given user that have been reported to this management room.
+func (pe *PolicyEvaluator) getReportedMedia(ctx context.Context, userID id.UserID) []id.ContentURI {
+	reports, err := pe.DB.Report.GetAllByTargetUser(ctx, pe.ManagementRoom, userID)
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Stringer("user_id", userID).Msg("Failed to get reports for quarantining media")
+		return nil
+	}
+	var uris []id.ContentURI
+	for _, report := range reports {
+		if report.EventID == "" {
+			continue
+		}
+		evt, err := pe.Bot.GetEvent(ctx, report.RoomID, report.EventID)
+		if err != nil {
+			zerolog.Ctx(ctx).Warn().Err(err).
+				Stringer("room_id", report.RoomID).
+				Stringer("event_id", report.EventID).
+				Msg("Failed to get reported event for quarantining media")
+			continue
+		}
+		uris = append(uris, getMediaFromEvent(evt)...)
+	}
+	return uris
+}
+
+// QuarantineUserMedia quarantines all media uploaded by the given user. For remote users, the admin API
+// can't enumerate media, so only the given media (e.g. from reported events) is quarantined instead.
+func (pe *PolicyEvaluator) QuarantineUserMedia(ctx context.Context, userID id.UserID, fallbackMedia []id.ContentURI) (*quarantineResult, error) {
+	log := zerolog.Ctx(ctx).With().Stringer("user_id", userID).Logger()
+	result := &quarantineResult{}
+	var media []id.ContentURI
+	if userID.Homeserver() == pe.Bot.ServerName {
+		var alreadyQuarantined []id.ContentURI
+		var err error
+		media, alreadyQuarantined, err = pe.listUserMediaAdminAPI(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		result.AlreadyQuarantined = len(alreadyQuarantined)
+	} else {
+		media = fallbackMedia
+		result.FromEvents = true
+	}
+	for i, uri := range media {
+		if pe.DryRun {
+			result.Quarantined++
+			continue
+		} else if i > 0 {
+			time.Sleep(quarantineRequestDelay)
+		}
+		err := pe.quarantineMediaAdminAPI(ctx, uri)
+		if err != nil {
+			log.Err(err).Stringer("mxc", uri).Msg("Failed to quarantine media")
+			result.Failed++
+		} else {
+			log.Debug().Stringer("mxc", uri).Msg("Quarantined media")
+			result.Quarantined++
+		}
+	}
+	return result, nil
+}
+
+// quarantineAndNotify quarantines media from the given user and sends the result to the management room.
+func (pe *PolicyEvaluator) quarantineAndNotify(ctx context.Context, userID id.UserID, fallbackMedia []id.ContentURI) {
+	result, err := pe.QuarantineUserMedia(ctx, userID, fallbackMedia)
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Stringer("user_id", userID).Msg("Failed to quarantine media")
+		pe.sendNoticeNow(ctx, "Failed to quarantine media from [%s](%s): %v", userID, userID.URI().MatrixToURL(), err)
+		return
+	}
+	var source string
+	if result.FromEvents {
+		source = " referenced in reported events (media of remote users can't be listed)"
+	}
+	output := fmt.Sprintf("Quarantined %s%s from [%s](%s)", pluralize(result.Quarantined, "media item"), source, userID, userID.URI().MatrixToURL())
+	if result.AlreadyQuarantined > 0 {
+		output += fmt.Sprintf(", %d were already quarantined", result.AlreadyQuarantined)
+	}
+	if result.Failed > 0 {
+		output += fmt.Sprintf(", failed to quarantine %d", result.Failed)
+	}
+	if pe.DryRun {
+		output += " (dry run)"
+	}
+	pe.sendNotice(ctx, output)
+}
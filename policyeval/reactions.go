@@ -0,0 +1,141 @@
+package policyeval
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+)
+
+// ReactionActionFunc is called when an admin reacts to a management room message with the corresponding key.
+type ReactionActionFunc func(ctx context.Context, sender id.UserID)
+
+const reactionActionTTL = 24 * time.Hour
+
+type reactionActionSet struct {
+	actions map[string]ReactionActionFunc
+	expiry  time.Time
+}
+
+// addReactionActions binds the given actions to a management room message and adds the reaction keys to it,
+// so that admins can trigger an action by clicking the corresponding reaction.
+//
+// Actions are single-use: once one of them is triggered, all actions for the message are removed.
+func (pe *PolicyEvaluator) addReactionActions(ctx context.Context, eventID id.EventID, actions map[string]ReactionActionFunc) {
+	pe.addReactionActionsWithTTL(ctx, eventID, actions, reactionActionTTL)
+}
+
+func (pe *PolicyEvaluator) addReactionActionsWithTTL(ctx context.Context, eventID id.EventID, actions map[string]ReactionActionFunc, ttl time.Duration) {
+	if eventID == "" {
+		return
+	}
+	pe.reactionActionsLock.Lock()
+	now := time.Now()
+	for evtID, set := range pe.reactionActions {
+		if set.expiry.Before(now) {
+			delete(pe.reactionActions, evtID)
+		}
+	}
+	pe.reactionActions[eventID] = &reactionActionSet{actions: actions, expiry: now.Add(ttl)}
+	pe.reactionActionsLock.Unlock()
+	for key := range actions {
+		_, err := pe.Bot.SendReaction(ctx, pe.ManagementRoom, eventID, key)
+		if err != nil {
+			zerolog.Ctx(ctx).Err(err).
+				Stringer("event_id", eventID).
+				Str("key", key).
+				Msg("Failed to add reaction action")
+		}
+	}
+}
+
+// removeReactionActions removes all actions bound to the given message.
+// It returns true if there were any unexpired actions to remove.
+func (pe *PolicyEvaluator) removeReactionActions(eventID id.EventID) bool {
+	pe.reactionActionsLock.Lock()
+	defer pe.reactionActionsLock.Unlock()
+	set, ok := pe.reactionActions[eventID]
+	delete(pe.reactionActions, eventID)
+	return ok && !set.expiry.Before(time.Now())
+}
+
+const (
+	confirmReaction            = "✅"
+	cancelReaction             = "❌"
+	defaultConfirmationTimeout = 60 * time.Second
+)
+
+// requestConfirmation sends a prompt to the management room and calls onConfirm if an admin reacts with ✅
+// before the confirmation timeout. This should be used for destructive bulk operations.
+func (pe *PolicyEvaluator) requestConfirmation(ctx context.Context, prompt string, onConfirm ReactionActionFunc) {
+	ctx = context.WithoutCancel(ctx)
+	timeout := pe.ConfirmationTimeout
+	if timeout <= 0 {
+		timeout = defaultConfirmationTimeout
+	}
+	eventID := pe.sendNoticeNow(
+		ctx, "%s\n\nReact with %s to confirm or %s to cancel within %s.",
+		prompt, confirmReaction, cancelReaction, timeout,
+	)
+	if eventID == "" {
+		return
+	}
+	pe.addReactionActionsWithTTL(ctx, eventID, map[string]ReactionActionFunc{
+		confirmReaction: onConfirm,
+		cancelReaction: func(ctx context.Context, sender id.UserID) {
+			pe.sendNotice(ctx, "Cancelled by [%s](%s)", sender, sender.URI().MatrixToURL())
+		},
+	}, timeout)
+	time.AfterFunc(timeout, func() {
+		if pe.removeReactionActions(eventID) {
+			pe.sendNotice(ctx, "Confirmation timed out, the action was not performed")
+		}
+	})
+}
+
+// countPendingConfirmations returns the number of confirmation prompts that haven't been answered or timed out yet.
+func (pe *PolicyEvaluator) countPendingConfirmations() (count int) {
+	pe.reactionActionsLock.Lock()
+	defer pe.reactionActionsLock.Unlock()
+	now := time.Now()
+	for _, set := range pe.reactionActions {
+		if _, isConfirmation := set.actions[confirmReaction]; isConfirmation && set.expiry.After(now) {
+			count++
+		}
+	}
+	return
+}
+
+func (pe *PolicyEvaluator) popReactionAction(eventID id.EventID, key string) ReactionActionFunc {
+	pe.reactionActionsLock.Lock()
+	defer pe.reactionActionsLock.Unlock()
+	set, ok := pe.reactionActions[eventID]
+	if !ok || set.expiry.Before(time.Now()) {
+		return nil
+	}
+	fn, ok := set.actions[key]
+	if !ok {
+		return nil
+	}
+	delete(pe.reactionActions, eventID)
+	return fn
+}
+
+func (pe *PolicyEvaluator) HandleReaction(ctx context.Context, evt *event.Event) {
+	if !pe.Admins.Has(evt.Sender) || !pe.isTrustedEvent(ctx, evt) {
+		return
+	}
+	content := evt.Content.AsReaction()
+	fn := pe.popReactionAction(content.RelatesTo.EventID, content.RelatesTo.Key)
+	if fn == nil {
+		return
+	}
+	zerolog.Ctx(ctx).Info().
+		Stringer("sender", evt.Sender).
+		Stringer("target_event_id", content.RelatesTo.EventID).
+		Str("key", content.RelatesTo.Key).
+		Msg("Handling reaction action")
+	fn(withActor(ctx, evt.Sender), evt.Sender)
+}
@@ -0,0 +1,118 @@
+package policyeval
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/meowlnir/config"
+)
+
+// expandReasonTemplate replaces a leading `:name` in the reason with the matching reason template of the list.
+// Any text after the template name is appended to the expanded reason. Unknown template names are kept as-is.
+func expandReasonTemplate(list *config.WatchedPolicyList, reason string) string {
+	if !strings.HasPrefix(reason, ":") || len(list.ReasonTemplates) == 0 {
+		return reason
+	}
+	name, rest, _ := strings.Cut(reason[1:], " ")
+	template, ok := list.ReasonTemplates[strings.ToLower(name)]
+	if !ok {
+		return reason
+	} else if rest = strings.TrimSpace(rest); rest != "" {
+		return template + " " + rest
+	}
+	return template
+}
+
+var cmdReasons = &CommandHandler{
+	Name: "reasons",
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) != 1 {
+			replyUsage(ce)
+			return
+		}
+		list := ce.Meta.FindListByShortcode(ce.Args[0])
+		if list == nil {
+			ce.Reply("List %s not found", format.SafeMarkdownCode(ce.Args[0]))
+			return
+		} else if len(list.ReasonTemplates) == 0 {
+			ce.Reply("No reason templates defined for %s", format.EscapeMarkdown(list.Name))
+			return
+		}
+		var buf strings.Builder
+		_, _ = fmt.Fprintf(&buf, "Reason templates for %s:\n\n", format.EscapeMarkdown(list.Name))
+		for _, name := range slices.Sorted(maps.Keys(list.ReasonTemplates)) {
+			_, _ = fmt.Fprintf(
+				&buf, "* %s - %s\n",
+				format.SafeMarkdownCode(":"+name), format.EscapeMarkdown(list.ReasonTemplates[name]),
+			)
+		}
+		ce.Reply(buf.String())
+	},
+}
+
+var cmdReason = &CommandHandler{
+	Name: "reason",
+	Func: func(ce *CommandEvent) {
+		force := slices.Contains(ce.Args, "--force")
+		if force {
+			ce.Args = slices.DeleteFunc(ce.Args, func(arg string) bool { return arg == "--force" })
+		}
+		if len(ce.Args) < 3 {
+			replyUsage(ce)
+			return
+		}
+		list := ce.Meta.FindListByShortcode(ce.Args[0])
+		if list == nil {
+			ce.Reply("List %s not found", format.SafeMarkdownCode(ce.Args[0]))
+			return
+		}
+		target := ce.Args[1]
+		newReason := expandReasonTemplate(list, strings.Join(ce.Args[2:], " "))
+		match := ce.Meta.findExactPolicies([]id.RoomID{list.RoomID}, target)
+		if len(match) == 0 {
+			ce.Reply("No rule for %s found in [%s](%s)", format.SafeMarkdownCode(target), format.EscapeMarkdown(list.Name), list.RoomID.URI().MatrixToURL())
+			return
+		}
+		results := make([]string, len(match))
+		for i, policy := range match {
+			entity := format.SafeMarkdownCode(policy.EntityOrHash())
+			if policy.Recommendation == event.PolicyRecommendationUnstableTakedown && !force {
+				results[i] = fmt.Sprintf("* Skipped %s rule for %s (use `--force` to edit takedown reasons)", format.SafeMarkdownCode(policy.Recommendation), entity)
+				continue
+			} else if policy.Reason == newReason {
+				results[i] = fmt.Sprintf("* %s rule for %s already has that reason", format.SafeMarkdownCode(policy.Recommendation), entity)
+				continue
+			}
+			content := *policy.ModPolicyContent
+			content.Reason = newReason
+			resp, err := ce.Meta.sendPolicyWithExtra(ce.Ctx, list.RoomID, policy.EntityType, policy.StateKey, policy.EntityOrHash(), &content, policyExtra(policy))
+			if err != nil {
+				results[i] = fmt.Sprintf("* Failed to update %s rule for %s: %v", format.SafeMarkdownCode(policy.Recommendation), entity, err)
+				continue
+			}
+			zerolog.Ctx(ce.Ctx).Info().
+				Stringer("policy_list", list.RoomID).
+				Any("policy", policy).
+				Str("new_reason", newReason).
+				Stringer("policy_event_id", resp.EventID).
+				Msg("Updated policy reason")
+			verb := "Changed"
+			if ce.Meta.DryRun {
+				verb = "Dry run: would change"
+			}
+			results[i] = fmt.Sprintf(
+				"* %s reason of %s rule for %s from %s to %s",
+				verb, format.SafeMarkdownCode(policy.Recommendation), entity,
+				format.SafeMarkdownCode(policy.Reason), format.SafeMarkdownCode(newReason),
+			)
+		}
+		ce.Reply("%s", strings.Join(results, "\n"))
+	},
+}
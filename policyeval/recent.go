@@ -0,0 +1,102 @@
+package policyeval
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"maunium.net/go/mautrix/format"
+
+	"go.mau.fi/meowlnir/database"
+)
+
+const (
+	maxRecentActions     = 200
+	defaultRecentActions = 20
+)
+
+func isModerationAction(action database.AuditLogAction) bool {
+	return action != database.AuditLogActionSendPolicy && action != database.AuditLogActionRemovePolicy
+}
+
+// recordRecentAction adds an action to the in-memory buffer of recent actions used by `!recent` and `!undo`.
+// Unlike the database, the buffer also includes actions taken in dry run mode.
+func (pe *PolicyEvaluator) recordRecentAction(entry *database.AuditLogEntry) {
+	pe.recentActionsLock.Lock()
+	defer pe.recentActionsLock.Unlock()
+	if len(pe.recentActions) >= maxRecentActions {
+		pe.recentActions = slices.Delete(pe.recentActions, 0, len(pe.recentActions)-maxRecentActions+1)
+	}
+	pe.recentActions = append(pe.recentActions, entry)
+}
+
+// loadRecentActions fills the recent action buffer from the persisted audit log.
+func (pe *PolicyEvaluator) loadRecentActions(ctx context.Context) {
+	entries, err := pe.DB.AuditLog.GetLatest(ctx, pe.ManagementRoom, maxRecentActions)
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Msg("Failed to load recent actions from audit log")
+		return
+	}
+	slices.Reverse(entries)
+	pe.recentActionsLock.Lock()
+	pe.recentActions = entries
+	pe.recentActionsLock.Unlock()
+}
+
+// getRecentActions returns up to the given number of the most recent moderation actions, newest first.
+func (pe *PolicyEvaluator) getRecentActions(count int) []*database.AuditLogEntry {
+	pe.recentActionsLock.Lock()
+	defer pe.recentActionsLock.Unlock()
+	output := make([]*database.AuditLogEntry, 0, min(count, len(pe.recentActions)))
+	for i := len(pe.recentActions) - 1; i >= 0 && len(output) < count; i-- {
+		if isModerationAction(pe.recentActions[i].Action) {
+			output = append(output, pe.recentActions[i])
+		}
+	}
+	return output
+}
+
+var cmdRecent = &CommandHandler{
+	Name: "recent",
+	Func: func(ce *CommandEvent) {
+		count := defaultRecentActions
+		if len(ce.Args) > 0 {
+			var err error
+			count, err = strconv.Atoi(ce.Args[0])
+			if err != nil || count <= 0 {
+				ce.Reply("Invalid count %s", format.SafeMarkdownCode(ce.Args[0]))
+				return
+			}
+			count = min(count, maxRecentActions)
+		}
+		entries := ce.Meta.getRecentActions(count)
+		if len(entries) == 0 {
+			ce.Reply("No moderation actions recorded")
+			return
+		}
+		entryStrings := make([]string, len(entries))
+		for i, entry := range entries {
+			entryStrings[i] = formatAuditLogEntry(entry)
+			if entry.InRoomID != "" && entry.TargetEvent == "" {
+				entryStrings[i] += fmt.Sprintf(
+					" in [%s](%s)",
+					format.EscapeMarkdown(ce.Meta.getProtectedRoomName(entry.InRoomID)), entry.InRoomID.URI().MatrixToURL(),
+				)
+			}
+			if entry.PolicyList != "" {
+				listName := entry.PolicyList.String()
+				if meta := ce.Meta.GetWatchedListMeta(entry.PolicyList); meta != nil {
+					listName = meta.Name
+				}
+				entryStrings[i] += fmt.Sprintf(" (list %s)", format.EscapeMarkdown(listName))
+			}
+			if entry.DryRun {
+				entryStrings[i] += " (dry run)"
+			}
+		}
+		ce.Reply("Last %d moderation actions:\n\n%s", len(entries), strings.Join(entryStrings, "\n"))
+	},
+}
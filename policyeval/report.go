@@ -2,15 +2,18 @@ package policyeval
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"net/http"
 	"strings"
+	"time"
 
 	"github.com/rs/zerolog"
 	"maunium.net/go/mautrix"
 	"maunium.net/go/mautrix/event"
 	"maunium.net/go/mautrix/id"
 
+	"go.mau.fi/meowlnir/config"
+	"go.mau.fi/meowlnir/database"
 	"go.mau.fi/meowlnir/policylist"
 )
 
@@ -23,32 +26,48 @@ func (pe *PolicyEvaluator) HandleReport(ctx context.Context, senderClient *mautr
 		if err != nil {
 			zerolog.Ctx(ctx).Err(err).Msg("Failed to get report target event with user's token")
 			pe.sendNotice(
-				ctx, `[%s](%s) reported [an event](%s) for %s, but the event could not be fetched: %v`,
-				sender, sender.URI().MatrixToURL(), roomID.EventURI(eventID).MatrixToURL(), reason, err,
+				ctx, `[%s](%s) reported [an event](%s) in %s for %s, but the event could not be fetched: %v`,
+				sender, sender.URI().MatrixToURL(), roomID.EventURI(eventID).MatrixToURL(), pe.formatRoomLink(roomID), reason, err,
 			)
-			return fmt.Errorf("failed to fetch event: %w", err)
+			if errors.Is(err, mautrix.MForbidden) {
+				return ErrReportPermissionDenied.WithMessage("You don't have access to the reported event")
+			}
+			return ErrReportEventNotFound.WithMessage("Failed to fetch reported event: %v", err)
 		}
 		targetUserID = evt.Sender
 	}
 	if !pe.Admins.Has(sender) || !strings.HasPrefix(reason, "/") || targetUserID == "" {
+		report := pe.trackReport(ctx, sender, targetUserID, roomID, eventID, reason)
+		var notes string
+		if targetUserID != "" {
+			notes = pe.formatEntityNotesSuffix(ctx, targetUserID.String())
+		} else if roomID != "" {
+			notes = pe.formatEntityNotesSuffix(ctx, roomID.String())
+		}
 		if eventID != "" {
-			pe.sendNotice(
-				ctx, `[%s](%s) reported [an event](%s) from [%s](%s) for %s`,
-				sender, sender.URI().MatrixToURL(), roomID.EventURI(eventID).MatrixToURL(),
+			reportList := pe.getReportBanList()
+			quickActionsHelp := "React with 🧹 to redact all messages from the user"
+			if reportList != nil {
+				quickActionsHelp = fmt.Sprintf("React with 🔨 to ban the user in %s or 🧹 to redact all messages from the user", reportList.Name)
+			}
+			noticeID := pe.Bot.SendNotice(
+				ctx, pe.ManagementRoom, "[%s](%s) reported [an event](%s) in %s from [%s](%s) for %s%s\n\n%s",
+				sender, sender.URI().MatrixToURL(), roomID.EventURI(eventID).MatrixToURL(), pe.formatRoomLink(roomID),
 				evt.Sender, evt.Sender.URI().MatrixToURL(),
-				reason,
+				reason, notes, quickActionsHelp,
 			)
+			go pe.addReactionActions(context.WithoutCancel(ctx), noticeID, pe.getReportActions(reportList, report))
 		} else if roomID != "" {
 			pe.sendNotice(
-				ctx, `[%s](%s) reported [a room](%s) for %s`,
-				sender, sender.URI().MatrixToURL(), roomID.URI().MatrixToURL(),
-				reason,
+				ctx, `[%s](%s) reported %s for %s%s`,
+				sender, sender.URI().MatrixToURL(), pe.formatRoomLink(roomID),
+				reason, notes,
 			)
 		} else if targetUserID != "" {
 			pe.sendNotice(
-				ctx, `[%s](%s) reported [%s](%s) for %s`,
-				sender, sender.URI().MatrixToURL(), targetUserID.URI().MatrixToURL(),
-				reason,
+				ctx, `[%s](%s) reported [%s](%s) for %s%s`,
+				sender, sender.URI().MatrixToURL(), targetUserID, targetUserID.URI().MatrixToURL(),
+				reason, notes,
 			)
 		}
 		return nil
@@ -57,52 +76,225 @@ func (pe *PolicyEvaluator) HandleReport(ctx context.Context, senderClient *mautr
 	cmd := strings.TrimPrefix(fields[0], "/")
 	args := fields[1:]
 	switch strings.ToLower(cmd) {
-	case "ban":
-		if len(args) < 2 {
-			return mautrix.MInvalidParam.WithMessage("Not enough arguments for ban")
+	case "ban", "takedown":
+		rec := event.PolicyRecommendationBan
+		var policyReason string
+		if strings.ToLower(cmd) == "takedown" {
+			if len(args) < 1 {
+				return ErrReportInvalidArguments.WithMessage("Not enough arguments for takedown")
+			}
+			rec = event.PolicyRecommendationUnstableTakedown
+		} else if len(args) < 2 {
+			return ErrReportInvalidArguments.WithMessage("Not enough arguments for ban")
+		} else {
+			policyReason = strings.Join(args[1:], " ")
 		}
 		list := pe.FindListByShortcode(args[0])
 		if list == nil {
-			pe.sendNotice(ctx, `Failed to handle [%s](%s)'s report of [%s](%s): list %q not found`,
+			pe.sendNoticeNow(ctx, `Failed to handle [%s](%s)'s report of [%s](%s): list %q not found`,
 				sender, sender.URI().MatrixToURL(), targetUserID, targetUserID.URI().MatrixToURL(), args[0])
-			return mautrix.MNotFound.WithMessage(fmt.Sprintf("List with shortcode %q not found", args[0]))
-		}
-		match := pe.Store.MatchUser([]id.RoomID{list.RoomID}, targetUserID)
-		if rec := match.Recommendations().BanOrUnban; rec != nil {
-			if rec.Recommendation == event.PolicyRecommendationUnban {
-				return mautrix.RespError{
-					ErrCode:    "FI.MAU.MEOWLNIR.UNBAN_RECOMMENDED",
-					Err:        fmt.Sprintf("%s has an unban recommendation: %s", targetUserID, rec.Reason),
-					StatusCode: http.StatusConflict,
-				}
-			} else {
-				return mautrix.RespError{
-					ErrCode:    "FI.MAU.MEOWLNIR.ALREADY_BANNED",
-					Err:        fmt.Sprintf("%s is already banned for: %s", targetUserID, rec.Reason),
-					StatusCode: http.StatusConflict,
-				}
-			}
+			return ErrReportListNotFound.WithMessage("List with shortcode %q not found", args[0])
 		}
-		policy := &event.ModPolicyContent{
-			Entity:         string(targetUserID),
-			Reason:         strings.Join(args[1:], " "),
-			Recommendation: event.PolicyRecommendationBan,
-		}
-		resp, err := pe.SendPolicy(ctx, list.RoomID, policylist.EntityTypeUser, "", string(targetUserID), policy)
-		if err != nil {
-			pe.sendNotice(ctx, `Failed to handle [%s](%s)'s report of [%s](%s) for %s ([%s](%s)): %v`,
+		policy, resp, err := pe.sendReportBanPolicy(ctx, list, targetUserID, policyReason, rec)
+		if errors.Is(err, ErrReportActionFailed) || errors.Is(err, ErrReportPermissionDenied) {
+			pe.sendNoticeNow(ctx, `Failed to handle [%s](%s)'s report of [%s](%s) for %s ([%s](%s)): %v`,
 				sender, sender.URI().MatrixToURL(), targetUserID, targetUserID.URI().MatrixToURL(),
 				list.Name, list.RoomID, list.RoomID.URI().MatrixToURL(), err)
-			return fmt.Errorf("failed to send policy: %w", err)
+			return err
+		} else if err != nil {
+			return err
 		}
 		zerolog.Ctx(ctx).Info().
 			Stringer("policy_list", list.RoomID).
 			Any("policy", policy).
 			Stringer("policy_event_id", resp.EventID).
 			Msg("Sent ban policy from report")
-		pe.sendNotice(ctx, `Processed [%s](%s)'s report of [%s](%s) and sent a ban policy to %s ([%s](%s)) for %s`,
+		var reasonSuffix string
+		if policy.Reason != "" {
+			reasonSuffix = " for " + policy.Reason
+		}
+		sentVerb := "sent"
+		if pe.DryRun {
+			sentVerb = "would have sent"
+		}
+		pe.sendNotice(ctx, `Processed [%s](%s)'s report of [%s](%s) and %s a %s policy to %s ([%s](%s))%s`,
+			sender, sender.URI().MatrixToURL(), targetUserID, targetUserID.URI().MatrixToURL(), sentVerb, changeActionString(rec),
+			list.Name, list.RoomID, list.RoomID.URI().MatrixToURL(), reasonSuffix)
+	case "redact":
+		redactReason := strings.Join(args, " ")
+		if eventID != "" {
+			relatedCount, err := pe.redactEventAndEdits(ctx, roomID, eventID, redactReason)
+			if err != nil {
+				return ErrReportActionFailed.WithMessage("Failed to redact reported event: %v", err)
+			}
+			pe.logAction(ctx, &database.AuditLogEntry{
+				Action:      database.AuditLogActionRedact,
+				TargetUser:  targetUserID,
+				TargetEvent: eventID,
+				InRoomID:    roomID,
+				Reason:      redactReason,
+			})
+			pe.sendNotice(ctx, `Processed [%s](%s)'s report and redacted [the event](%s) from [%s](%s)%s`,
+				sender, sender.URI().MatrixToURL(), roomID.EventURI(eventID).MatrixToURL(),
+				targetUserID, targetUserID.URI().MatrixToURL(), formatRelatedRedactions(relatedCount))
+		} else if roomID != "" {
+			// When the report is about a specific room, only redact the user's messages in that room
+			if !pe.IsProtectedRoom(roomID) {
+				return ErrReportNotInProtectedRooms.WithMessage("%s is not a protected room", roomID)
+			}
+			go pe.redactUserInRooms(context.WithoutCancel(ctx), targetUserID, []id.RoomID{roomID}, redactReason, false)
+		} else {
+			go pe.RedactUser(context.WithoutCancel(ctx), targetUserID, redactReason, false)
+		}
+	case "kick":
+		if len(args) < 1 {
+			return ErrReportInvalidArguments.WithMessage("Kicking requires a reason")
+		}
+		kickReason := strings.Join(args, " ")
+		rooms := pe.getRoomsUserIsIn(targetUserID)
+		if len(rooms) == 0 {
+			return ErrReportNotInProtectedRooms.WithMessage("%s is not in any protected rooms", targetUserID)
+		}
+		kicked, failed := pe.kickFromRooms(ctx, targetUserID, rooms, kickReason)
+		pe.sendNotice(ctx, `Processed [%s](%s)'s report and kicked [%s](%s) from %s for %s`,
 			sender, sender.URI().MatrixToURL(), targetUserID, targetUserID.URI().MatrixToURL(),
-			list.Name, list.RoomID, list.RoomID.URI().MatrixToURL(), policy.Reason)
+			pluralize(len(kicked), "room"), kickReason)
+		if len(kicked) == 0 {
+			return ErrReportActionFailed.WithMessage("Failed to kick %s from %s", targetUserID, pluralize(len(failed), "room"))
+		}
+	case "quarantine":
+		var reportedMedia []id.ContentURI
+		if evt != nil {
+			reportedMedia = getMediaFromEvent(evt)
+		}
+		if targetUserID.Homeserver() != pe.Bot.ServerName {
+			reportedMedia = append(reportedMedia, pe.getReportedMedia(ctx, targetUserID)...)
+			if len(reportedMedia) == 0 {
+				return ErrReportNoMedia.WithMessage("No media found to quarantine from remote user")
+			}
+		}
+		pe.sendNotice(ctx, `Quarantining media from [%s](%s) as requested in [%s](%s)'s report`,
+			targetUserID, targetUserID.URI().MatrixToURL(), sender, sender.URI().MatrixToURL())
+		go pe.quarantineAndNotify(context.WithoutCancel(ctx), targetUserID, reportedMedia)
+	default:
+		return ErrReportUnknownCommand.WithMessage("Unknown report command %q", cmd)
 	}
 	return nil
 }
+
+func (pe *PolicyEvaluator) sendReportBanPolicy(
+	ctx context.Context, list *config.WatchedPolicyList, targetUserID id.UserID, reason string, recommendation event.PolicyRecommendation,
+) (*event.ModPolicyContent, *mautrix.RespSendEvent, error) {
+	normalized, err := normalizeUserEntity(string(targetUserID))
+	if err != nil {
+		return nil, nil, ErrReportInvalidEntity.WithMessage("Invalid user ID %s: %v", targetUserID, err)
+	}
+	targetUserID = id.UserID(normalized)
+	match := pe.Store.MatchUser([]id.RoomID{list.RoomID}, targetUserID)
+	if rec := match.Recommendations().BanOrUnban; rec != nil {
+		if rec.Recommendation == event.PolicyRecommendationUnban {
+			return nil, nil, ErrReportUnbanRecommended.WithMessage("%s has an unban recommendation: %s", targetUserID, rec.Reason)
+		} else if recommendation != event.PolicyRecommendationUnstableTakedown || rec.Recommendation == recommendation {
+			// Existing bans only block new takedowns if they're already takedowns, so that bans can be escalated
+			return nil, nil, ErrReportAlreadyBanned.WithMessage("%s is already banned for: %s", targetUserID, rec.Reason)
+		}
+	}
+	policy := &event.ModPolicyContent{
+		Entity:         string(targetUserID),
+		Reason:         reason,
+		Recommendation: recommendation,
+	}
+	resp, err := pe.SendPolicy(ctx, list.RoomID, policylist.EntityTypeUser, "", string(targetUserID), policy)
+	if isPermissionError(err) {
+		return nil, nil, ErrReportPermissionDenied.WithMessage("Failed to send policy: %v", err)
+	} else if err != nil {
+		return nil, nil, ErrReportActionFailed.WithMessage("Failed to send policy: %v", err)
+	}
+	if !pe.DryRun {
+		pe.maybeSubmitUpstreamReport(ctx, policylist.EntityTypeUser, policy, list.RoomID, resp.EventID)
+	}
+	return policy, resp, nil
+}
+
+func (pe *PolicyEvaluator) getReportBanList() *config.WatchedPolicyList {
+	if pe.ReportBanList == "" {
+		return nil
+	}
+	return pe.FindListByShortcode(pe.ReportBanList)
+}
+
+func (pe *PolicyEvaluator) trackReport(
+	ctx context.Context, reporter, targetUserID id.UserID, roomID id.RoomID, eventID id.EventID, reason string,
+) *database.Report {
+	report := &database.Report{
+		ManagementRoom: pe.ManagementRoom,
+		Reporter:       reporter,
+		TargetUser:     targetUserID,
+		RoomID:         roomID,
+		EventID:        eventID,
+		Reason:         reason,
+		CreatedAt:      time.Now(),
+	}
+	err := pe.DB.Report.Put(ctx, report)
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Msg("Failed to save report to database")
+	}
+	return report
+}
+
+func (pe *PolicyEvaluator) markReportHandled(ctx context.Context, report *database.Report, admin id.UserID) {
+	if report.ID == 0 {
+		return
+	}
+	err := pe.DB.Report.MarkHandled(ctx, pe.ManagementRoom, report.ID, admin)
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Int64("report_id", report.ID).Msg("Failed to mark report as handled")
+	}
+}
+
+// getReportActions returns the reaction actions for acting on a report.
+// The ban action is only included if a report ban list is configured and the report targets a user.
+func (pe *PolicyEvaluator) getReportActions(list *config.WatchedPolicyList, report *database.Report) map[string]ReactionActionFunc {
+	actions := make(map[string]ReactionActionFunc, 2)
+	if report.TargetUser == "" {
+		return actions
+	}
+	actions["🧹"] = func(ctx context.Context, admin id.UserID) {
+		if report.EventID != "" {
+			relatedCount, err := pe.redactEventAndEdits(ctx, report.RoomID, report.EventID, report.Reason)
+			if err != nil {
+				pe.sendNoticeNow(ctx, "Failed to redact [reported event](%s): %v", report.RoomID.EventURI(report.EventID).MatrixToURL(), err)
+			} else {
+				if relatedCount > 0 {
+					pe.sendNotice(ctx, "Redacted [reported event](%s)%s", report.RoomID.EventURI(report.EventID).MatrixToURL(), formatRelatedRedactions(relatedCount))
+				}
+				pe.logAction(ctx, &database.AuditLogEntry{
+					Action:      database.AuditLogActionRedact,
+					TargetUser:  report.TargetUser,
+					TargetEvent: report.EventID,
+					InRoomID:    report.RoomID,
+					Reason:      report.Reason,
+				})
+			}
+		}
+		pe.RedactUser(ctx, report.TargetUser, report.Reason, false)
+		pe.markReportHandled(ctx, report, admin)
+	}
+	if list != nil {
+		actions["🔨"] = func(ctx context.Context, admin id.UserID) {
+			policy, resp, err := pe.sendReportBanPolicy(ctx, list, report.TargetUser, report.Reason, event.PolicyRecommendationBan)
+			if err != nil {
+				pe.sendNoticeNow(ctx, "Failed to ban [%s](%s) in %s: %v", report.TargetUser, report.TargetUser.URI().MatrixToURL(), list.Name, err)
+				return
+			}
+			zerolog.Ctx(ctx).Info().
+				Stringer("policy_list", list.RoomID).
+				Any("policy", policy).
+				Stringer("policy_event_id", resp.EventID).
+				Stringer("admin", admin).
+				Msg("Sent ban policy from report reaction")
+			pe.markReportHandled(ctx, report, admin)
+		}
+	}
+	return actions
+}
@@ -0,0 +1,64 @@
+package policyeval
+
+import (
+	"errors"
+	"net/http"
+
+	"maunium.net/go/mautrix"
+)
+
+// Error codes returned by [PolicyEvaluator.HandleReport], so that reporting clients can handle failures
+// programmatically. All codes are in the FI.MAU.MEOWLNIR namespace:
+//
+//   - EVENT_NOT_FOUND (404): the reported event couldn't be fetched with the reporter's access token.
+//   - INVALID_ARGUMENTS (400): a report command was missing required arguments.
+//   - UNKNOWN_COMMAND (400): an admin report reason started with / but wasn't a known command.
+//   - LIST_NOT_FOUND (404): the policy list shortcode in a ban or takedown command isn't watched.
+//   - INVALID_ENTITY (400): the reported user ID is not valid.
+//   - PERMISSION_DENIED (403): the reporter can't see the event, the bot isn't allowed to send the policy,
+//     or the target is protected from bans (the bot, admins or never_ban entries).
+//   - ALREADY_BANNED (409): the list already has a ban policy for the user.
+//   - UNBAN_RECOMMENDED (409): the list has an unban policy for the user.
+//   - NOT_IN_PROTECTED_ROOMS (404): the user to kick isn't in any protected rooms.
+//   - NO_MEDIA (404): there was no media to quarantine.
+//   - ACTION_FAILED (500): the requested action failed for some other reason.
+var (
+	ErrReportEventNotFound = mautrix.RespError{
+		ErrCode: "FI.MAU.MEOWLNIR.EVENT_NOT_FOUND", StatusCode: http.StatusNotFound,
+	}
+	ErrReportInvalidArguments = mautrix.RespError{
+		ErrCode: "FI.MAU.MEOWLNIR.INVALID_ARGUMENTS", StatusCode: http.StatusBadRequest,
+	}
+	ErrReportUnknownCommand = mautrix.RespError{
+		ErrCode: "FI.MAU.MEOWLNIR.UNKNOWN_COMMAND", StatusCode: http.StatusBadRequest,
+	}
+	ErrReportListNotFound = mautrix.RespError{
+		ErrCode: "FI.MAU.MEOWLNIR.LIST_NOT_FOUND", StatusCode: http.StatusNotFound,
+	}
+	ErrReportInvalidEntity = mautrix.RespError{
+		ErrCode: "FI.MAU.MEOWLNIR.INVALID_ENTITY", StatusCode: http.StatusBadRequest,
+	}
+	ErrReportPermissionDenied = mautrix.RespError{
+		ErrCode: "FI.MAU.MEOWLNIR.PERMISSION_DENIED", StatusCode: http.StatusForbidden,
+	}
+	ErrReportAlreadyBanned = mautrix.RespError{
+		ErrCode: "FI.MAU.MEOWLNIR.ALREADY_BANNED", StatusCode: http.StatusConflict,
+	}
+	ErrReportUnbanRecommended = mautrix.RespError{
+		ErrCode: "FI.MAU.MEOWLNIR.UNBAN_RECOMMENDED", StatusCode: http.StatusConflict,
+	}
+	ErrReportNotInProtectedRooms = mautrix.RespError{
+		ErrCode: "FI.MAU.MEOWLNIR.NOT_IN_PROTECTED_ROOMS", StatusCode: http.StatusNotFound,
+	}
+	ErrReportNoMedia = mautrix.RespError{
+		ErrCode: "FI.MAU.MEOWLNIR.NO_MEDIA", StatusCode: http.StatusNotFound,
+	}
+	ErrReportActionFailed = mautrix.RespError{
+		ErrCode: "FI.MAU.MEOWLNIR.ACTION_FAILED", StatusCode: http.StatusInternalServerError,
+	}
+)
+
+// isPermissionError returns true if the given error means the bot or user isn't allowed to do something.
+func isPermissionError(err error) bool {
+	return errors.Is(err, mautrix.MForbidden) || errors.Is(err, ErrSafeguardedEntity)
+}
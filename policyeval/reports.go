@@ -0,0 +1,162 @@
+package policyeval
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+
+	"go.mau.fi/meowlnir/database"
+)
+
+const (
+	defaultReportsLimit = 10
+	maxReportsLimit     = 50
+)
+
+func formatReportSummary(report *database.Report) string {
+	var target string
+	if report.TargetUser != "" {
+		target = fmt.Sprintf("[%s](%s)", report.TargetUser, report.TargetUser.URI().MatrixToURL())
+		if report.EventID != "" {
+			target += fmt.Sprintf(" ([event](%s))", report.RoomID.EventURI(report.EventID).MatrixToURL())
+		}
+	} else if report.RoomID != "" {
+		target = fmt.Sprintf("[%s](%s)", report.RoomID, report.RoomID.URI().MatrixToURL())
+	}
+	return fmt.Sprintf(
+		"* **#%d** at %s: [%s](%s) reported %s for %s",
+		report.ID, report.CreatedAt.Format("2006-01-02 15:04"),
+		report.Reporter, report.Reporter.URI().MatrixToURL(), target, format.SafeMarkdownCode(report.Reason),
+	)
+}
+
+var cmdReports = &CommandHandler{
+	Name: "reports",
+	Func: func(ce *CommandEvent) {
+		limit := defaultReportsLimit
+		if len(ce.Args) > 0 {
+			var err error
+			limit, err = strconv.Atoi(ce.Args[0])
+			if err != nil || limit <= 0 {
+				replyUsage(ce)
+				return
+			}
+			limit = min(limit, maxReportsLimit)
+		}
+		reports, err := ce.Meta.DB.Report.GetRecentUnhandled(ce.Ctx, ce.Meta.ManagementRoom, limit)
+		if err != nil {
+			zerolog.Ctx(ce.Ctx).Err(err).Msg("Failed to get recent reports")
+			ce.Reply("Failed to get recent reports: %v", err)
+			return
+		} else if len(reports) == 0 {
+			ce.Reply("No unhandled reports :3")
+			return
+		}
+		total, err := ce.Meta.DB.Report.CountUnhandled(ce.Ctx, ce.Meta.ManagementRoom)
+		if err != nil {
+			zerolog.Ctx(ce.Ctx).Err(err).Msg("Failed to count unhandled reports")
+		}
+		lines := make([]string, len(reports))
+		for i, report := range reports {
+			lines[i] = formatReportSummary(report)
+		}
+		ce.Reply(
+			"Showing %d of %d unhandled reports, newest first:\n\n%s\n\nUse `%sreport-action <id> <action>` to act on a report",
+			len(reports), total, strings.Join(lines, "\n"), ce.Meta.commandPrefix(),
+		)
+	},
+}
+
+var cmdReportAction = &CommandHandler{
+	Name: "report-action",
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) < 2 {
+			replyUsage(ce)
+			return
+		}
+		reportID, err := strconv.ParseInt(strings.TrimPrefix(ce.Args[0], "#"), 10, 64)
+		if err != nil {
+			replyUsage(ce)
+			return
+		}
+		report, err := ce.Meta.DB.Report.GetByID(ce.Ctx, ce.Meta.ManagementRoom, reportID)
+		if err != nil {
+			zerolog.Ctx(ce.Ctx).Err(err).Int64("report_id", reportID).Msg("Failed to get report")
+			ce.Reply("Failed to get report: %v", err)
+			return
+		} else if report == nil {
+			ce.Reply("Report #%d not found", reportID)
+			return
+		} else if report.IsHandled() {
+			ce.Reply(
+				"Report #%d was already handled by [%s](%s) at %s",
+				report.ID, report.HandledBy, report.HandledBy.URI().MatrixToURL(), report.HandledAt.Format("2006-01-02 15:04"),
+			)
+			return
+		}
+		action := strings.ToLower(ce.Args[1])
+		args := ce.Args[2:]
+		admin := actorFromContext(ce.Ctx)
+		if action == "dismiss" {
+			ce.Meta.markReportHandled(ce.Ctx, report, admin)
+			ce.React(SuccessReaction)
+			return
+		} else if report.TargetUser == "" {
+			ce.Reply("Report #%d doesn't target a user, it can only be dismissed", report.ID)
+			return
+		}
+		switch action {
+		case "ban", "takedown":
+			rec := event.PolicyRecommendationBan
+			if action == "takedown" {
+				rec = event.PolicyRecommendationUnstableTakedown
+			}
+			if len(args) < 1 || (rec == event.PolicyRecommendationBan && len(args) < 2) {
+				replyUsage(ce)
+				return
+			}
+			list := ce.Meta.FindListByShortcode(args[0])
+			if list == nil {
+				ce.Reply("List %s not found", format.SafeMarkdownCode(args[0]))
+				return
+			}
+			policy, resp, err := ce.Meta.sendReportBanPolicy(ce.Ctx, list, report.TargetUser, strings.Join(args[1:], " "), rec)
+			if err != nil {
+				ce.Reply("Failed to %s %s: %v", action, format.SafeMarkdownCode(report.TargetUser), err)
+				return
+			}
+			zerolog.Ctx(ce.Ctx).Info().
+				Int64("report_id", report.ID).
+				Stringer("policy_list", list.RoomID).
+				Any("policy", policy).
+				Stringer("policy_event_id", resp.EventID).
+				Msg("Sent ban policy from report action")
+		case "redact":
+			ce.Meta.getReportActions(nil, report)["🧹"](ce.Ctx, admin)
+		case "kick":
+			if len(args) < 1 {
+				replyUsage(ce)
+				return
+			}
+			rooms := ce.Meta.getRoomsUserIsIn(report.TargetUser)
+			if len(rooms) == 0 {
+				ce.Reply("%s is not in any protected rooms", format.SafeMarkdownCode(report.TargetUser))
+				return
+			}
+			kicked, failed := ce.Meta.kickFromRooms(ce.Ctx, report.TargetUser, rooms, strings.Join(args, " "))
+			ce.Reply("Kicked %s from %s, failed in %d", format.SafeMarkdownCode(report.TargetUser), pluralize(len(kicked), "room"), len(failed))
+			if len(kicked) == 0 {
+				return
+			}
+		default:
+			replyUsage(ce)
+			return
+		}
+		ce.Meta.markReportHandled(ce.Ctx, report, admin)
+		ce.React(SuccessReaction)
+	},
+}
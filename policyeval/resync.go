@@ -0,0 +1,67 @@
+package policyeval
+
+import (
+	"context"
+	"fmt"
+
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+	"maunium.net/go/mautrix/id"
+)
+
+// resyncRoom refetches the member list of a protected room, updates the cached membership
+// and bans all current members who match a ban or takedown policy. In observe-only rooms,
+// matching members are only reported.
+func (pe *PolicyEvaluator) resyncRoom(ctx context.Context, roomID id.RoomID) (checked, actioned int, err error) {
+	members, err := pe.Bot.Members(ctx, roomID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get room members: %w", err)
+	}
+	watchedLists := pe.GetWatchedLists()
+	for _, evt := range members.Chunk {
+		userID := id.UserID(evt.GetStateKey())
+		membership := evt.Content.AsMember().Membership
+		pe.updateUser(userID, roomID, membership)
+		if !isInRoom(membership) || userID == pe.Bot.UserID {
+			continue
+		}
+		checked++
+		userEvaluations.Inc()
+		rec := pe.Store.MatchUser(watchedLists, userID).Recommendations().BanOrUnban
+		if rec == nil || (rec.Recommendation != event.PolicyRecommendationBan && rec.Recommendation != event.PolicyRecommendationUnstableTakedown) {
+			continue
+		}
+		policyMatches.WithLabelValues(string(rec.Recommendation)).Inc()
+		if pe.isObserveOnlyRoom(roomID) {
+			pe.notifyObservedBan(ctx, userID, roomID, rec)
+			continue
+		}
+		pe.ApplyBan(ctx, userID, roomID, rec)
+		actioned++
+	}
+	return
+}
+
+var cmdResyncRoom = &CommandHandler{
+	Name: "resync-room",
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) != 1 {
+			replyUsage(ce)
+			return
+		}
+		roomID := resolveRoom(ce, ce.Args[0])
+		if roomID == "" {
+			return
+		} else if !ce.Meta.IsProtectedRoom(roomID) {
+			ce.Reply("%s is not a protected room", format.SafeMarkdownCode(ce.Args[0]))
+			return
+		}
+		checked, actioned, err := ce.Meta.resyncRoom(ce.Ctx, roomID)
+		if err != nil {
+			ce.Reply("Failed to resync %s: %v", ce.Meta.formatRoomLink(roomID), err)
+			return
+		}
+		ce.Reply("Checked %s in %s, took action against %d", pluralize(checked, "member"), ce.Meta.formatRoomLink(roomID), actioned)
+		ce.React(SuccessReaction)
+	},
+}
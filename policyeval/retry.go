@@ -0,0 +1,303 @@
+package policyeval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.mau.fi/util/retryafter"
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+
+	"go.mau.fi/meowlnir/database"
+)
+
+const (
+	actionRetryCheckInterval = 15 * time.Second
+	actionRetryBaseDelay     = 30 * time.Second
+	maxActionRetryAttempts   = 6
+
+	maxRateLimitWaits       = 3
+	defaultRateLimitBackoff = 5 * time.Second
+	maxRateLimitBackoff     = 1 * time.Minute
+)
+
+// rateLimitBackoff returns how long to wait before retrying a request that failed with M_LIMIT_EXCEEDED,
+// based on the Retry-After header or the retry_after_ms field in the response.
+func rateLimitBackoff(err error) (time.Duration, bool) {
+	if !errors.Is(err, mautrix.MLimitExceeded) {
+		return 0, false
+	}
+	backoff := defaultRateLimitBackoff
+	var httpErr mautrix.HTTPError
+	if errors.As(err, &httpErr) {
+		if httpErr.RespError != nil {
+			if retryAfterMS, ok := httpErr.RespError.ExtraData["retry_after_ms"].(float64); ok && retryAfterMS > 0 {
+				backoff = time.Duration(retryAfterMS) * time.Millisecond
+			}
+		}
+		if httpErr.Response != nil {
+			backoff = retryafter.Parse(httpErr.Response.Header.Get("Retry-After"), backoff)
+		}
+	}
+	return min(backoff, maxRateLimitBackoff), true
+}
+
+// waitForRateLimit sleeps for the given duration or until the context is canceled.
+func waitForRateLimit(ctx context.Context, delay time.Duration) bool {
+	zerolog.Ctx(ctx).Debug().Stringer("delay", delay).Msg("Rate limited, waiting before retrying")
+	select {
+	case <-time.After(delay):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// isTransientError returns true if the error is likely to go away by itself, i.e. rate limits,
+// server errors and network errors. Other errors like missing permissions won't be retried.
+func isTransientError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	} else if errors.Is(err, mautrix.MLimitExceeded) {
+		return true
+	}
+	var httpErr mautrix.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Response == nil || httpErr.Response.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
+
+// queueActionRetry records a failed action in metrics and stores it in the retry queue if the error is transient.
+// If the action was queued, true is returned and the caller shouldn't report the failure.
+func (pe *PolicyEvaluator) queueActionRetry(ctx context.Context, retry *database.ActionRetry, err error) bool {
+	failedActions.WithLabelValues(string(retry.Action)).Inc()
+	if !isTransientError(err) {
+		return false
+	}
+	retry.ManagementRoom = pe.ManagementRoom
+	retry.Attempts = 1
+	retry.LastError = err.Error()
+	retry.CreatedAt = time.Now()
+	retry.NextAttemptAt = retry.CreatedAt.Add(actionRetryBaseDelay)
+	dbErr := pe.DB.ActionRetry.Put(ctx, retry)
+	if dbErr != nil {
+		zerolog.Ctx(ctx).Err(dbErr).Any("action_retry", retry).Msg("Failed to queue action for retrying")
+		return false
+	}
+	zerolog.Ctx(ctx).Debug().
+		Int64("retry_id", retry.ID).
+		Str("retry_action", string(retry.Action)).
+		Time("next_attempt_at", retry.NextAttemptAt).
+		Msg("Queued failed action for retrying")
+	return true
+}
+
+func (pe *PolicyEvaluator) actionRetryLoop() {
+	ctx := pe.Bot.Log.With().
+		Str("action", "retry failed actions").
+		Stringer("management_room", pe.ManagementRoom).
+		Logger().
+		WithContext(context.Background())
+	ticker := time.NewTicker(actionRetryCheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		pe.retryDueActions(ctx)
+	}
+}
+
+// retryDueActions retries all queued actions whose next attempt time has passed.
+// Actions are dropped from the queue when they succeed, fail permanently or run out of attempts.
+func (pe *PolicyEvaluator) retryDueActions(ctx context.Context) (succeeded, failed int) {
+	pe.actionRetryLock.Lock()
+	defer pe.actionRetryLock.Unlock()
+	retries, err := pe.DB.ActionRetry.GetDue(ctx, pe.ManagementRoom, time.Now())
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Msg("Failed to get queued actions to retry")
+		return
+	}
+	for _, retry := range retries {
+		if retry.Action == database.ActionRetryTypeBan && !pe.isBanStillRecommended(retry) {
+			zerolog.Ctx(ctx).Debug().Any("action_retry", retry).Msg("Dropping queued ban as no ban policy matches anymore")
+			pe.sendNotice(ctx, "Not retrying %s: the user is no longer banned by any policy", formatActionRetry(retry))
+			err = pe.DB.ActionRetry.Delete(ctx, pe.ManagementRoom, retry.ID)
+			if err != nil {
+				zerolog.Ctx(ctx).Err(err).Int64("retry_id", retry.ID).Msg("Failed to remove obsolete action from retry queue")
+			}
+			continue
+		}
+		err = pe.executeActionRetry(ctx, retry)
+		if err == nil {
+			succeeded++
+			err = pe.DB.ActionRetry.Delete(ctx, pe.ManagementRoom, retry.ID)
+			if err != nil {
+				zerolog.Ctx(ctx).Err(err).Int64("retry_id", retry.ID).Msg("Failed to remove successful action from retry queue")
+			}
+			continue
+		}
+		failed++
+		retry.Attempts++
+		retry.LastError = err.Error()
+		if !isTransientError(err) || retry.Attempts >= maxActionRetryAttempts {
+			zerolog.Ctx(ctx).Err(err).Any("action_retry", retry).Msg("Giving up on retrying action")
+			pe.sendNotice(
+				ctx, "Giving up on %s after %d attempts: %v",
+				formatActionRetry(retry), retry.Attempts, err,
+			)
+			err = pe.DB.ActionRetry.Delete(ctx, pe.ManagementRoom, retry.ID)
+		} else {
+			retry.NextAttemptAt = time.Now().Add(actionRetryBaseDelay << (retry.Attempts - 1))
+			err = pe.DB.ActionRetry.Update(ctx, retry)
+		}
+		if err != nil {
+			zerolog.Ctx(ctx).Err(err).Int64("retry_id", retry.ID).Msg("Failed to update retry queue")
+		}
+	}
+	return
+}
+
+// isBanStillRecommended checks that the watched lists still recommend banning the target of a queued ban,
+// as the policy may have been removed or overridden by an unban since the ban failed.
+func (pe *PolicyEvaluator) isBanStillRecommended(retry *database.ActionRetry) bool {
+	rec := pe.Store.MatchUser(pe.GetWatchedLists(), retry.TargetUser).Recommendations().BanOrUnban
+	return rec != nil && (rec.Recommendation == event.PolicyRecommendationBan || rec.Recommendation == event.PolicyRecommendationUnstableTakedown)
+}
+
+func (pe *PolicyEvaluator) executeActionRetry(ctx context.Context, retry *database.ActionRetry) error {
+	var err error
+	switch retry.Action {
+	case database.ActionRetryTypeBan:
+		_, err = pe.Bot.BanUser(ctx, retry.RoomID, &mautrix.ReqBanUser{
+			Reason: retry.Reason,
+			UserID: retry.TargetUser,
+		})
+		if err != nil {
+			return err
+		}
+		ta := &database.TakenAction{
+			TargetUser: retry.TargetUser,
+			InRoomID:   retry.RoomID,
+			ActionType: database.TakenActionTypeBanOrUnban,
+			PolicyList: retry.PolicyList,
+			RuleEntity: retry.Entity,
+			Action:     retry.Recommendation,
+			TakenAt:    time.Now(),
+		}
+		if dbErr := pe.DB.TakenAction.Put(ctx, ta); dbErr != nil {
+			zerolog.Ctx(ctx).Err(dbErr).Any("taken_action", ta).Msg("Failed to save taken action")
+		}
+		pe.sendNotice(ctx, "Retried and succeeded: %s", formatActionRetry(retry))
+		pe.logAction(ctx, &database.AuditLogEntry{
+			Action:         database.AuditLogActionBan,
+			TargetUser:     retry.TargetUser,
+			InRoomID:       retry.RoomID,
+			Reason:         retry.Reason,
+			PolicyList:     retry.PolicyList,
+			Entity:         retry.Entity,
+			Recommendation: string(retry.Recommendation),
+		})
+	case database.ActionRetryTypeKick:
+		_, err = pe.Bot.KickUser(ctx, retry.RoomID, &mautrix.ReqKickUser{
+			Reason: retry.Reason,
+			UserID: retry.TargetUser,
+		})
+		if err != nil {
+			return err
+		}
+		pe.sendNotice(ctx, "Retried and succeeded: %s", formatActionRetry(retry))
+		pe.logAction(ctx, &database.AuditLogEntry{
+			Action:     database.AuditLogActionKick,
+			TargetUser: retry.TargetUser,
+			InRoomID:   retry.RoomID,
+			Reason:     retry.Reason,
+		})
+	case database.ActionRetryTypeRedact:
+		_, err = pe.Bot.RedactEvent(ctx, retry.RoomID, retry.EventID, mautrix.ReqRedact{Reason: retry.Reason})
+		if err != nil {
+			return err
+		}
+		pe.logRedaction(ctx, retry.TargetUser, retry.RoomID, retry.Reason)
+	default:
+		return fmt.Errorf("unknown action %q", retry.Action)
+	}
+	return nil
+}
+
+func formatActionRetry(retry *database.ActionRetry) string {
+	switch retry.Action {
+	case database.ActionRetryTypeRedact:
+		return fmt.Sprintf(
+			"redact [event](%s) from [%s](%s)",
+			retry.RoomID.EventURI(retry.EventID).MatrixToURL(), retry.TargetUser, retry.TargetUser.URI().MatrixToURL(),
+		)
+	default:
+		return fmt.Sprintf(
+			"%s [%s](%s) in [%s](%s)",
+			retry.Action, retry.TargetUser, retry.TargetUser.URI().MatrixToURL(), retry.RoomID, retry.RoomID.URI().MatrixToURL(),
+		)
+	}
+}
+
+var cmdRetries = &CommandHandler{
+	Name: "retries",
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) == 0 {
+			retries, err := ce.Meta.DB.ActionRetry.GetAll(ce.Ctx, ce.Meta.ManagementRoom)
+			if err != nil {
+				ce.Reply("Failed to get retry queue: %v", err)
+				return
+			} else if len(retries) == 0 {
+				ce.Reply("No failed actions are queued for retrying")
+				return
+			}
+			var buf strings.Builder
+			buf.WriteString("Queued actions:\n\n")
+			for _, retry := range retries {
+				_, _ = fmt.Fprintf(
+					&buf, "* #%d: %s - %d attempts, next in %s, last error: %s\n",
+					retry.ID, formatActionRetry(retry), retry.Attempts,
+					max(time.Until(retry.NextAttemptAt), 0).Truncate(time.Second), format.SafeMarkdownCode(retry.LastError),
+				)
+			}
+			ce.Reply(buf.String())
+			return
+		}
+		switch strings.ToLower(ce.Args[0]) {
+		case "flush":
+			err := ce.Meta.DB.ActionRetry.RescheduleAll(ce.Ctx, ce.Meta.ManagementRoom, time.Now())
+			if err != nil {
+				ce.Reply("Failed to reschedule queued actions: %v", err)
+				return
+			}
+			succeeded, failed := ce.Meta.retryDueActions(ce.Ctx)
+			ce.Reply("Retried %d actions: %d succeeded, %d failed", succeeded+failed, succeeded, failed)
+		case "cancel":
+			var err error
+			if len(ce.Args) > 1 {
+				retryID, parseErr := strconv.ParseInt(strings.TrimPrefix(ce.Args[1], "#"), 10, 64)
+				if parseErr != nil {
+					ce.Reply("Invalid retry ID %s", format.SafeMarkdownCode(ce.Args[1]))
+					return
+				}
+				err = ce.Meta.DB.ActionRetry.Delete(ce.Ctx, ce.Meta.ManagementRoom, retryID)
+			} else {
+				err = ce.Meta.DB.ActionRetry.DeleteAll(ce.Ctx, ce.Meta.ManagementRoom)
+			}
+			if err != nil {
+				ce.Reply("Failed to cancel queued actions: %v", err)
+				return
+			}
+		default:
+			replyUsage(ce)
+			return
+		}
+		ce.React(SuccessReaction)
+	},
+}
@@ -0,0 +1,104 @@
+package policyeval
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/meowlnir/database"
+)
+
+const (
+	reviewIgnoreReaction = "🙈"
+	reviewNextReaction   = "⏭️"
+)
+
+func (pe *PolicyEvaluator) getReviewPosition(admin id.UserID) int64 {
+	pe.reviewPositionsLock.Lock()
+	defer pe.reviewPositionsLock.Unlock()
+	return pe.reviewPositions[admin]
+}
+
+func (pe *PolicyEvaluator) setReviewPosition(admin id.UserID, reportID int64) {
+	pe.reviewPositionsLock.Lock()
+	defer pe.reviewPositionsLock.Unlock()
+	if reportID == 0 {
+		delete(pe.reviewPositions, admin)
+	} else {
+		pe.reviewPositions[admin] = reportID
+	}
+}
+
+// showNextReport sends a review card for the next unhandled report after the admin's current position.
+// Each admin has their own position, so multiple admins can review the queue independently.
+func (pe *PolicyEvaluator) showNextReport(ctx context.Context, admin id.UserID) {
+	position := pe.getReviewPosition(admin)
+	report, err := pe.DB.Report.GetNextUnhandled(ctx, pe.ManagementRoom, position)
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Msg("Failed to get next unhandled report")
+		pe.sendNoticeNow(ctx, "Failed to get next unhandled report: %v", err)
+		return
+	}
+	remaining, err := pe.DB.Report.CountUnhandled(ctx, pe.ManagementRoom)
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Msg("Failed to count unhandled reports")
+	}
+	if report == nil {
+		if position != 0 && remaining > 0 {
+			pe.sendNotice(ctx, "[%s](%s): reached the end of the report queue, but %d skipped reports are still unhandled. "+
+				"Use `%sreview restart` to go through them again.", admin, admin.URI().MatrixToURL(), remaining, pe.commandPrefix())
+		} else {
+			pe.sendNotice(ctx, "[%s](%s): no unhandled reports :3", admin, admin.URI().MatrixToURL())
+		}
+		pe.setReviewPosition(admin, 0)
+		return
+	}
+	pe.setReviewPosition(admin, report.ID)
+	reportList := pe.getReportBanList()
+	actions := pe.getReportActions(reportList, report)
+	for key, fn := range actions {
+		actions[key] = func(ctx context.Context, sender id.UserID) {
+			fn(ctx, sender)
+			pe.showNextReport(ctx, sender)
+		}
+	}
+	actions[reviewIgnoreReaction] = func(ctx context.Context, sender id.UserID) {
+		pe.markReportHandled(ctx, report, sender)
+		pe.showNextReport(ctx, sender)
+	}
+	actions[reviewNextReaction] = pe.showNextReport
+	noticeID := pe.sendNoticeNow(ctx, "%s\n\n%s", formatReviewCard(report, remaining), formatReviewActions(actions))
+	pe.addReactionActions(ctx, noticeID, actions)
+}
+
+func formatReviewCard(report *database.Report, remaining int) string {
+	var buf strings.Builder
+	_, _ = fmt.Fprintf(&buf, "**Report #%d** (%d unhandled)\n\n", report.ID, remaining)
+	_, _ = fmt.Fprintf(&buf, "* Reporter: [%s](%s)\n", report.Reporter, report.Reporter.URI().MatrixToURL())
+	if report.TargetUser != "" {
+		_, _ = fmt.Fprintf(&buf, "* Target user: [%s](%s)\n", report.TargetUser, report.TargetUser.URI().MatrixToURL())
+	}
+	if report.EventID != "" {
+		_, _ = fmt.Fprintf(&buf, "* Event: [%s](%s)\n", report.EventID, report.RoomID.EventURI(report.EventID).MatrixToURL())
+	} else if report.RoomID != "" {
+		_, _ = fmt.Fprintf(&buf, "* Room: [%s](%s)\n", report.RoomID, report.RoomID.URI().MatrixToURL())
+	}
+	_, _ = fmt.Fprintf(&buf, "* Reason: %s\n", report.Reason)
+	_, _ = fmt.Fprintf(&buf, "* Reported at: %s", report.CreatedAt.Format("2006-01-02 15:04:05"))
+	return buf.String()
+}
+
+func formatReviewActions(actions map[string]ReactionActionFunc) string {
+	parts := make([]string, 0, 4)
+	if _, ok := actions["🔨"]; ok {
+		parts = append(parts, "🔨 to ban the user")
+	}
+	if _, ok := actions["🧹"]; ok {
+		parts = append(parts, "🧹 to redact the user's messages")
+	}
+	parts = append(parts, reviewIgnoreReaction+" to dismiss the report", reviewNextReaction+" to skip to the next report")
+	return "React with " + strings.Join(parts, ", ")
+}
@@ -0,0 +1,102 @@
+package policyeval
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/meowlnir/policylist"
+)
+
+// getRoomBanPolicy returns the policy banning the given room in the watched lists, or nil if the room isn't banned.
+func (pe *PolicyEvaluator) getRoomBanPolicy(roomID id.RoomID) *policylist.Policy {
+	rec := pe.Store.MatchRoom(pe.GetWatchedLists(), roomID).Recommendations().BanOrUnban
+	if rec == nil || rec.Recommendation == event.PolicyRecommendationUnban {
+		return nil
+	}
+	return rec
+}
+
+func (pe *PolicyEvaluator) formatRoomBanPolicy(policy *policylist.Policy) string {
+	listName := policy.RoomID.String()
+	if meta := pe.GetWatchedListMeta(policy.RoomID); meta != nil {
+		listName = meta.Name
+	}
+	return fmt.Sprintf(
+		"banned by the %s policy for %s in %s (reason: %s)",
+		format.SafeMarkdownCode(policy.Recommendation), format.SafeMarkdownCode(policy.EntityOrHash()),
+		format.EscapeMarkdown(listName), format.SafeMarkdownCode(policy.Reason),
+	)
+}
+
+// EvaluateAddedRoomRule handles protected rooms where the given new policy is the winning ban policy.
+// The rooms are only left automatically if leave_banned_rooms is enabled, otherwise an admin has to confirm it.
+// Wildcard policies are only reported, as a single bad glob could otherwise make the bot leave every room.
+func (pe *PolicyEvaluator) EvaluateAddedRoomRule(ctx context.Context, policy *policylist.Policy) {
+	var matchedRooms []id.RoomID
+	for _, roomID := range pe.GetProtectedRooms() {
+		if roomID == pe.ManagementRoom {
+			continue
+		} else if rec := pe.getRoomBanPolicy(roomID); rec != nil && rec.ID == policy.ID {
+			matchedRooms = append(matchedRooms, roomID)
+		}
+	}
+	if len(matchedRooms) == 0 {
+		return
+	} else if policy.IsRegex || containsGlobWildcard(policy.Entity) {
+		pe.sendNotice(
+			ctx, "Found %s %s, but the bot won't leave them as wildcard room policies are never applied automatically",
+			pluralize(len(matchedRooms), "protected room"), pe.formatRoomBanPolicy(policy),
+		)
+		return
+	}
+	for _, roomID := range matchedRooms {
+		pe.handleBannedRoom(ctx, roomID, policy)
+	}
+}
+
+func (pe *PolicyEvaluator) handleBannedRoom(ctx context.Context, roomID id.RoomID, policy *policylist.Policy) {
+	room := pe.formatRoomLink(roomID)
+	banReason := pe.formatRoomBanPolicy(policy)
+	if pe.isObserveOnlyRoom(roomID) {
+		pe.sendNotice(ctx, "Protected room %s is %s, but it's in observe-only mode, so the bot is staying", room, banReason)
+	} else if pe.DryRun {
+		pe.sendNotice(ctx, "Dry run: would have unprotected and left %s, as it's %s", room, banReason)
+	} else if !pe.LeaveBannedRooms {
+		pe.requestConfirmation(
+			ctx, fmt.Sprintf("Protected room %s is %s. Do you want to unprotect and leave it?", room, banReason),
+			func(ctx context.Context, _ id.UserID) {
+				pe.leaveBannedRoom(ctx, roomID, policy)
+			},
+		)
+	} else {
+		pe.leaveBannedRoom(ctx, roomID, policy)
+	}
+}
+
+func (pe *PolicyEvaluator) leaveBannedRoom(ctx context.Context, roomID id.RoomID, policy *policylist.Policy) {
+	room := pe.formatRoomLink(roomID)
+	banReason := pe.formatRoomBanPolicy(policy)
+	log := zerolog.Ctx(ctx).With().
+		Stringer("room_id", roomID).
+		Str("policy_entity", policy.EntityOrHash()).
+		Logger()
+	err := pe.unprotectRoom(ctx, roomID)
+	if err != nil {
+		log.Err(err).Msg("Failed to unprotect banned room")
+		pe.sendNoticeNow(ctx, "Failed to unprotect %s, which is %s: %v", room, banReason, err)
+		return
+	}
+	_, err = pe.Bot.LeaveRoom(ctx, roomID)
+	if err != nil {
+		log.Err(err).Msg("Failed to leave banned room")
+		pe.sendNoticeNow(ctx, "Unprotected %s, which is %s, but failed to leave it: %v", room, banReason, err)
+		return
+	}
+	log.Info().Msg("Unprotected and left banned room")
+	pe.sendNotice(ctx, "Unprotected and left %s, as it's %s", room, banReason)
+}
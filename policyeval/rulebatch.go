@@ -0,0 +1,169 @@
+package policyeval
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.mau.fi/util/glob"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/meowlnir/database"
+	"go.mau.fi/meowlnir/policylist"
+)
+
+// maxBatchedChangeNotices is the maximum number of policy change notices sent individually for a batch.
+// Larger batches only send a summary of the changes.
+const maxBatchedChangeNotices = 10
+
+// maxRuleChangeBatchDelay is how many debounce windows a batch can be extended by new changes before it's
+// evaluated anyway, so that a steady stream of changes doesn't postpone evaluation indefinitely.
+const maxRuleChangeBatchDelay = 10
+
+// ruleChangeBatch collects policy changes received within the re-evaluation debounce window,
+// so that a burst of changes (e.g. a mass import) only evaluates each affected user once.
+type ruleChangeBatch struct {
+	Notices []string
+	Added   []*policylist.Policy
+	Removed []*policylist.Policy
+	Changes int
+	Started time.Time
+	Timer   *time.Timer
+}
+
+// queueRuleChange adds a policy change to the current batch and restarts the debounce timer.
+// The added and removed policies may be nil if they shouldn't be evaluated.
+func (pe *PolicyEvaluator) queueRuleChange(ctx context.Context, notices []string, added, removed *policylist.Policy) {
+	pe.ruleChangeBatchLock.Lock()
+	defer pe.ruleChangeBatchLock.Unlock()
+	batch := pe.ruleChangeBatch
+	if batch == nil {
+		batch = &ruleChangeBatch{Started: time.Now()}
+		ctx = context.WithoutCancel(ctx)
+		batch.Timer = time.AfterFunc(pe.ReevaluationDebounce, func() {
+			pe.flushRuleChangeBatch(ctx, batch)
+		})
+		pe.ruleChangeBatch = batch
+	} else if time.Since(batch.Started) < maxRuleChangeBatchDelay*pe.ReevaluationDebounce {
+		batch.Timer.Reset(pe.ReevaluationDebounce)
+	}
+	batch.Changes++
+	batch.Notices = append(batch.Notices, notices...)
+	if added != nil {
+		batch.Added = append(batch.Added, added)
+	}
+	if removed != nil {
+		batch.Removed = append(batch.Removed, removed)
+	}
+}
+
+func (pe *PolicyEvaluator) flushRuleChangeBatch(ctx context.Context, batch *ruleChangeBatch) {
+	pe.ruleChangeBatchLock.Lock()
+	if pe.ruleChangeBatch != batch {
+		pe.ruleChangeBatchLock.Unlock()
+		return
+	}
+	pe.ruleChangeBatch = nil
+	pe.ruleChangeBatchLock.Unlock()
+
+	if len(batch.Notices) > maxBatchedChangeNotices {
+		pe.sendNotice(
+			ctx, "Received %d policy changes, including:\n\n%s\n\n...and %d more",
+			len(batch.Notices), strings.Join(batch.Notices[:maxBatchedChangeNotices], "\n"), len(batch.Notices)-maxBatchedChangeNotices,
+		)
+	} else {
+		for _, notice := range batch.Notices {
+			pe.sendNotice(ctx, notice)
+		}
+	}
+	if len(batch.Added) == 0 && len(batch.Removed) == 0 {
+		return
+	}
+	pe.evaluateRuleChangeBatch(ctx, batch)
+}
+
+// evaluateRuleChangeBatch evaluates all users affected by the policies in the batch once.
+// It's equivalent to calling EvaluateRemovedRule and EvaluateAddedRule for each policy.
+func (pe *PolicyEvaluator) evaluateRuleChangeBatch(ctx context.Context, batch *ruleChangeBatch) {
+	start := time.Now()
+	// The value is the isNewRule parameter for ApplyPolicy
+	users := make(map[id.UserID]bool)
+	var reevalTargets []*database.TakenAction
+	var updateACL bool
+	for _, policy := range batch.Removed {
+		switch policy.EntityType {
+		case policylist.EntityTypeUser:
+			if policy.Recommendation == event.PolicyRecommendationUnban {
+				for userID := range pe.findMatchingUsers(policy.UserMatchPattern(), policy.EntityHash, false) {
+					if _, alreadyQueued := users[userID]; !alreadyQueued {
+						users[userID] = false
+					}
+				}
+			} else {
+				targets, err := pe.DB.TakenAction.GetAllByRuleEntity(ctx, policy.RoomID, policy.EntityOrHash())
+				if err != nil {
+					zerolog.Ctx(ctx).Err(err).Str("policy_entity", policy.EntityOrHash()).
+						Msg("Failed to get actions taken for removed policy")
+					pe.sendNoticeNow(ctx, "Database error in evaluateRuleChangeBatch (GetAllByRuleEntity): %v", err)
+					continue
+				}
+				reevalTargets = append(reevalTargets, targets...)
+			}
+		case policylist.EntityTypeServer:
+			updateACL = true
+		}
+	}
+	for _, policy := range batch.Added {
+		switch policy.EntityType {
+		case policylist.EntityTypeUser:
+			didMatch := false
+			for userID := range pe.findMatchingUsers(policy.UserMatchPattern(), policy.EntityHash, false) {
+				didMatch = true
+				users[userID] = true
+			}
+			if exact, ok := policy.Pattern.(glob.ExactGlob); !didMatch && ok && id.UserID(exact).Homeserver() == pe.Bot.ServerName {
+				users[id.UserID(exact)] = true
+			}
+		case policylist.EntityTypeServer:
+			updateACL = true
+		case policylist.EntityTypeRoom:
+			pe.EvaluateAddedRoomRule(ctx, policy)
+		}
+	}
+	if updateACL {
+		pe.DeferredUpdateACL()
+	}
+	if len(reevalTargets) > 0 {
+		pe.ReevaluateActions(ctx, reevalTargets)
+	}
+	watchedLists := pe.GetWatchedLists()
+	var actionCount int
+	for userID, isNewRule := range users {
+		userEvaluations.Inc()
+		match := pe.Store.MatchUser(watchedLists, userID)
+		if match == nil {
+			continue
+		}
+		actionCount += pe.ApplyPolicy(ctx, userID, match, isNewRule)
+	}
+	dur := time.Since(start)
+	zerolog.Ctx(ctx).Info().
+		Int("change_count", batch.Changes).
+		Int("user_count", len(users)).
+		Int("reeval_action_count", len(reevalTargets)).
+		Int("action_count", actionCount).
+		Dur("duration", dur).
+		Msg("Finished evaluating batch of policy changes")
+	if batch.Changes > 1 {
+		actionsTaken := "taken"
+		if pe.DryRun {
+			actionsTaken = "simulated (dry run)"
+		}
+		pe.sendNotice(
+			ctx, "Evaluated %d policy changes in %s: %s evaluated, %d previous bans re-evaluated, %d enforcement actions %s",
+			batch.Changes, dur, pluralize(len(users), "user"), len(reevalTargets), actionCount, actionsTaken,
+		)
+	}
+}
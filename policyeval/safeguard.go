@@ -0,0 +1,85 @@
+package policyeval
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mau.fi/util/glob"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/meowlnir/policylist"
+)
+
+var ErrSafeguardedEntity = errors.New("refusing to ban safeguarded entity")
+
+type safeguardedEntity struct {
+	Entity      string
+	Description string
+}
+
+// getSafeguardedUsers returns the users that must never be banned: the bot itself, admins and users in never_ban.
+func (pe *PolicyEvaluator) getSafeguardedUsers() []safeguardedEntity {
+	users := []safeguardedEntity{{Entity: pe.Bot.UserID.String(), Description: "the bot itself"}}
+	for _, admin := range pe.Admins.AsList() {
+		users = append(users, safeguardedEntity{Entity: admin.String(), Description: "admin " + admin.String()})
+	}
+	for _, entity := range pe.NeverBan {
+		if strings.HasPrefix(entity, "@") {
+			users = append(users, safeguardedEntity{Entity: entity, Description: "never_ban entry " + entity})
+		}
+	}
+	return users
+}
+
+// getSafeguardedServers returns the servers that must never be banned: the servers of safeguarded users
+// and servers in never_ban.
+func (pe *PolicyEvaluator) getSafeguardedServers() []safeguardedEntity {
+	var servers []safeguardedEntity
+	for _, user := range pe.getSafeguardedUsers() {
+		servers = append(servers, safeguardedEntity{
+			Entity:      id.UserID(user.Entity).Homeserver(),
+			Description: "the server of " + user.Description,
+		})
+	}
+	for _, entity := range pe.NeverBan {
+		if !strings.HasPrefix(entity, "@") {
+			servers = append(servers, safeguardedEntity{Entity: entity, Description: "never_ban entry " + entity})
+		}
+	}
+	return servers
+}
+
+// checkBanSafeguards returns an error if a policy with the given recommendation would ban the bot,
+// an admin or anything in the never_ban config option.
+func (pe *PolicyEvaluator) checkBanSafeguards(entityType policylist.EntityType, entity string, rec event.PolicyRecommendation, isRegex bool) error {
+	if rec != event.PolicyRecommendationBan && rec != event.PolicyRecommendationUnstableTakedown {
+		return nil
+	}
+	var pattern glob.Glob
+	if isRegex {
+		var err error
+		pattern, err = policylist.CompileEntityRegex(entity)
+		if err != nil {
+			return err
+		}
+	} else {
+		pattern = glob.Compile(entity)
+	}
+	var targets []safeguardedEntity
+	switch entityType {
+	case policylist.EntityTypeUser:
+		targets = pe.getSafeguardedUsers()
+	case policylist.EntityTypeServer:
+		targets = pe.getSafeguardedServers()
+	default:
+		return nil
+	}
+	for _, target := range targets {
+		if pattern.Match(target.Entity) {
+			return fmt.Errorf("%w: %s matches %s", ErrSafeguardedEntity, entity, target.Description)
+		}
+	}
+	return nil
+}
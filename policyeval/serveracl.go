@@ -2,6 +2,8 @@ package policyeval
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"slices"
 	"strings"
 	"sync"
@@ -10,8 +12,13 @@ import (
 
 	"github.com/rs/zerolog"
 	"go.mau.fi/util/exslices"
+	"go.mau.fi/util/glob"
+	"maunium.net/go/mautrix"
 	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
 	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/meowlnir/util"
 )
 
 func (pe *PolicyEvaluator) CompileACL() (*event.ServerACLEventContent, time.Duration) {
@@ -24,13 +31,27 @@ func (pe *PolicyEvaluator) CompileACL() (*event.ServerACLEventContent, time.Dura
 		AllowIPLiterals: false,
 	}
 	for entity, policy := range rules {
-		if policy.Pattern.Match(pe.Bot.ServerName) {
+		// Server ACLs only support globs, so regex policies can only be enforced against users
+		if policy.IsRegex || policy.Pattern.Match(pe.Bot.ServerName) {
 			continue
 		}
 		if policy.Recommendation != event.PolicyRecommendationUnban {
 			acl.Deny = append(acl.Deny, entity)
 		}
 	}
+	// Hashed policies can't be put in ACLs directly, so they're only applied to servers that have members
+	// in protected rooms. Other servers are still banned on the member level when they join.
+	if hashRules := pe.Store.ListServerHashRules(pe.GetWatchedListsForACLs()); len(hashRules) > 0 {
+		for _, server := range pe.getKnownServers() {
+			policy, ok := hashRules[util.SHA256String(server)]
+			if _, alreadyListed := rules[server]; !ok || alreadyListed || server == pe.Bot.ServerName {
+				continue
+			}
+			if policy.Recommendation != event.PolicyRecommendationUnban {
+				acl.Deny = append(acl.Deny, server)
+			}
+		}
+	}
 	slices.Sort(acl.Deny)
 	return &acl, time.Since(start)
 }
@@ -62,19 +83,62 @@ func (pe *PolicyEvaluator) aclDeferLoop() {
 	}
 }
 
-func (pe *PolicyEvaluator) UpdateACL(ctx context.Context) {
+type aclUpdate struct {
+	oldDeny []string
+	newACL  *event.ServerACLEventContent
+}
+
+// mergeACLDeny returns a copy of the given ACL with the extra entries added to the deny list.
+func mergeACLDeny(acl *event.ServerACLEventContent, extraDeny []string) *event.ServerACLEventContent {
+	merged := *acl
+	merged.Deny = slices.Concat(acl.Deny, extraDeny)
+	slices.Sort(merged.Deny)
+	merged.Deny = slices.Compact(merged.Deny)
+	return &merged
+}
+
+// aclBlocksServer checks whether the given ACL would prevent the given server from participating in a room.
+func aclBlocksServer(acl *event.ServerACLEventContent, server string) bool {
+	for _, deny := range acl.Deny {
+		if glob.Compile(deny).Match(server) {
+			return true
+		}
+	}
+	for _, allow := range acl.Allow {
+		if glob.Compile(allow).Match(server) {
+			return false
+		}
+	}
+	return true
+}
+
+// UpdateACL sends the compiled server ACL to all protected rooms where it differs from the current ACL.
+// If pruning is disabled in the config, existing deny list entries are kept in addition to the compiled ones.
+func (pe *PolicyEvaluator) UpdateACL(ctx context.Context) (changed, succeeded int) {
 	log := zerolog.Ctx(ctx)
+	if !pe.ServerACL.Enabled {
+		log.Debug().Msg("Server ACL management is disabled, not updating ACLs")
+		return
+	}
 	pe.aclLock.Lock()
 	defer pe.aclLock.Unlock()
-	newACL, compileDur := pe.CompileACL()
+	compiledACL, compileDur := pe.CompileACL()
 	pe.protectedRoomsLock.RLock()
-	changedRooms := make(map[id.RoomID][]string, len(pe.protectedRooms))
+	changedRooms := make(map[id.RoomID]aclUpdate, len(pe.protectedRooms))
 	for roomID, meta := range pe.protectedRooms {
-		if !meta.ApplyACL {
+		if !meta.ApplyACL || meta.ObserveOnly {
 			continue
 		}
-		if meta.ACL == nil || !slices.Equal(meta.ACL.Deny, newACL.Deny) {
-			changedRooms[roomID] = meta.ACL.Deny
+		var oldDeny []string
+		if meta.ACL != nil {
+			oldDeny = meta.ACL.Deny
+		}
+		newACL := compiledACL
+		if !pe.ServerACL.Prune && len(oldDeny) > 0 {
+			newACL = mergeACLDeny(compiledACL, oldDeny)
+		}
+		if meta.ACL == nil || !slices.Equal(oldDeny, newACL.Deny) {
+			changedRooms[roomID] = aclUpdate{oldDeny: oldDeny, newACL: newACL}
 		}
 	}
 	pe.protectedRoomsLock.RUnlock()
@@ -86,16 +150,27 @@ func (pe *PolicyEvaluator) UpdateACL(ctx context.Context) {
 	}
 	log.Info().
 		Int("room_count", len(changedRooms)).
-		Any("new_acl", newACL).
+		Any("new_acl", compiledACL).
 		Dur("compile_duration", compileDur).
 		Msg("Sending updated server ACL event")
 	var wg sync.WaitGroup
 	wg.Add(len(changedRooms))
 	var successCount atomic.Int32
-	for roomID, oldACLDeny := range changedRooms {
-		go func(roomID id.RoomID, oldACLDeny []string) {
+	var sentLock sync.Mutex
+	sentRooms := make(map[id.RoomID]*event.ServerACLEventContent, len(changedRooms))
+	for roomID, update := range changedRooms {
+		go func(roomID id.RoomID, update aclUpdate) {
 			defer wg.Done()
-			removed, added := exslices.SortedDiff(oldACLDeny, newACL.Deny, strings.Compare)
+			removed, added := exslices.SortedDiff(update.oldDeny, update.newACL.Deny, strings.Compare)
+			if aclBlocksServer(update.newACL, pe.Bot.ServerName) {
+				log.Warn().
+					Stringer("room_id", roomID).
+					Strs("deny_added", added).
+					Strs("deny_removed", removed).
+					Msg("Refusing to send server ACL that would ban own server")
+				pe.sendNotice(ctx, "Refusing to send server ACL to room %s as it would ban the bot's own server", roomID)
+				return
+			}
 			if pe.DryRun {
 				log.Debug().
 					Stringer("room_id", roomID).
@@ -105,14 +180,14 @@ func (pe *PolicyEvaluator) UpdateACL(ctx context.Context) {
 				successCount.Add(1)
 				return
 			}
-			resp, err := pe.Bot.SendStateEvent(ctx, roomID, event.StateServerACL, "", newACL)
+			resp, err := pe.Bot.SendStateEvent(ctx, roomID, event.StateServerACL, "", update.newACL)
 			if err != nil {
 				log.Err(err).
 					Strs("deny_added", added).
 					Strs("deny_removed", removed).
 					Stringer("room_id", roomID).
 					Msg("Failed to send server ACL to room")
-				pe.sendNotice(ctx, "Failed to send server ACL to room %s: %v", roomID, err)
+				pe.sendNoticeNow(ctx, "Failed to send server ACL to room %s: %v", roomID, err)
 			} else {
 				log.Debug().
 					Stringer("room_id", roomID).
@@ -121,13 +196,18 @@ func (pe *PolicyEvaluator) UpdateACL(ctx context.Context) {
 					Strs("deny_removed", removed).
 					Msg("Sent new server ACL to room")
 				successCount.Add(1)
+				sentLock.Lock()
+				sentRooms[roomID] = update.newACL
+				sentLock.Unlock()
 			}
-		}(roomID, oldACLDeny)
+		}(roomID, update)
 	}
 	wg.Wait()
 	pe.protectedRoomsLock.Lock()
-	for roomID := range changedRooms {
-		pe.protectedRooms[roomID].ACL = newACL
+	for roomID, acl := range sentRooms {
+		if meta, ok := pe.protectedRooms[roomID]; ok {
+			meta.ACL = acl
+		}
 	}
 	pe.protectedRoomsLock.Unlock()
 	log.Info().
@@ -135,4 +215,132 @@ func (pe *PolicyEvaluator) UpdateACL(ctx context.Context) {
 		Int32("success_count", successCount.Load()).
 		Msg("Finished sending server ACL updates")
 	pe.sendNotice(ctx, "Successfully sent updated server ACL to %d/%d rooms", successCount.Load(), len(changedRooms))
+	return len(changedRooms), int(successCount.Load())
+}
+
+// SyncACL refetches the current server ACL of all protected rooms and then reapplies the compiled ACL
+// to any rooms where it differs, e.g. because the ACL was changed manually.
+func (pe *PolicyEvaluator) SyncACL(ctx context.Context) (changed, succeeded int) {
+	if !pe.ServerACL.Enabled {
+		return
+	}
+	pe.protectedRoomsLock.RLock()
+	roomIDs := make([]id.RoomID, 0, len(pe.protectedRooms))
+	for roomID, meta := range pe.protectedRooms {
+		if meta.ApplyACL && !meta.ObserveOnly {
+			roomIDs = append(roomIDs, roomID)
+		}
+	}
+	pe.protectedRoomsLock.RUnlock()
+	for _, roomID := range roomIDs {
+		var acl event.ServerACLEventContent
+		err := pe.Bot.StateEvent(ctx, roomID, event.StateServerACL, "", &acl)
+		if err != nil && !errors.Is(err, mautrix.MNotFound) {
+			zerolog.Ctx(ctx).Warn().Err(err).Stringer("room_id", roomID).Msg("Failed to get server ACL for sync")
+			continue
+		}
+		slices.Sort(acl.Deny)
+		pe.protectedRoomsLock.Lock()
+		if meta, ok := pe.protectedRooms[roomID]; ok {
+			meta.ACL = &acl
+		}
+		pe.protectedRoomsLock.Unlock()
+	}
+	return pe.UpdateACL(ctx)
+}
+
+const aclResyncCheckInterval = 1 * time.Minute
+
+func (pe *PolicyEvaluator) aclResyncLoop() {
+	ctx := pe.Bot.Log.With().
+		Str("action", "periodic acl sync").
+		Stringer("management_room", pe.ManagementRoom).
+		Logger().
+		WithContext(context.Background())
+	ticker := time.NewTicker(aclResyncCheckInterval)
+	defer ticker.Stop()
+	lastSync := time.Now()
+	for range ticker.C {
+		interval := time.Duration(pe.ServerACL.ResyncIntervalMinutes) * time.Minute
+		if interval <= 0 || time.Since(lastSync) < interval {
+			continue
+		}
+		lastSync = time.Now()
+		pe.SyncACL(ctx)
+	}
+}
+
+// DenyServerInProtectedRooms adds the given server to the deny list of the server ACL in all protected rooms,
+// keeping any existing entries. Rooms where the bot can't change the ACL are skipped.
+// The returned lines describe the result in each room.
+func (pe *PolicyEvaluator) DenyServerInProtectedRooms(ctx context.Context, server string) []string {
+	log := zerolog.Ctx(ctx).With().Str("server", server).Logger()
+	pe.aclLock.Lock()
+	defer pe.aclLock.Unlock()
+	pe.protectedRoomsLock.RLock()
+	rooms := make(map[id.RoomID]*protectedRoomMeta, len(pe.protectedRooms))
+	for roomID, meta := range pe.protectedRooms {
+		rooms[roomID] = meta
+	}
+	pe.protectedRoomsLock.RUnlock()
+	results := make([]string, 0, len(rooms))
+	for roomID, meta := range rooms {
+		roomName := format.EscapeMarkdown(meta.Name)
+		if roomName == "" {
+			roomName = format.SafeMarkdownCode(roomID)
+		}
+		if !meta.ApplyACL {
+			results = append(results, fmt.Sprintf("* %s - skipped: ACL management is disabled for the room", roomName))
+			continue
+		} else if meta.ObserveOnly {
+			results = append(results, fmt.Sprintf("* %s - skipped: the room is observe-only", roomName))
+			continue
+		}
+		var acl event.ServerACLEventContent
+		err := pe.Bot.StateEvent(ctx, roomID, event.StateServerACL, "", &acl)
+		if err != nil && !errors.Is(err, mautrix.MNotFound) {
+			log.Err(err).Stringer("room_id", roomID).Msg("Failed to get server ACL")
+			results = append(results, fmt.Sprintf("* %s - failed to get current ACL: %v", roomName, err))
+			continue
+		}
+		var powerLevels event.PowerLevelsEventContent
+		err = pe.Bot.StateEvent(ctx, roomID, event.StatePowerLevels, "", &powerLevels)
+		if err != nil {
+			log.Err(err).Stringer("room_id", roomID).Msg("Failed to get power levels")
+			results = append(results, fmt.Sprintf("* %s - failed to get power levels: %v", roomName, err))
+			continue
+		} else if powerLevels.GetUserLevel(pe.Bot.UserID) < powerLevels.GetEventLevel(event.StateServerACL) {
+			results = append(results, fmt.Sprintf("* %s - skipped: missing permission to change server ACL", roomName))
+			continue
+		}
+		if slices.Contains(acl.Deny, server) {
+			results = append(results, fmt.Sprintf("* %s - already denied", roomName))
+			continue
+		}
+		if len(acl.Allow) == 0 {
+			acl.Allow = []string{"*"}
+		}
+		acl.Deny = append(slices.Clone(acl.Deny), server)
+		slices.Sort(acl.Deny)
+		if pe.DryRun {
+			results = append(results, fmt.Sprintf("* %s - would update ACL (dry run)", roomName))
+			continue
+		}
+		resp, err := pe.Bot.SendStateEvent(ctx, roomID, event.StateServerACL, "", &acl)
+		if err != nil {
+			log.Err(err).Stringer("room_id", roomID).Msg("Failed to send server ACL")
+			results = append(results, fmt.Sprintf("* %s - failed to send ACL: %v", roomName, err))
+			continue
+		}
+		log.Debug().
+			Stringer("room_id", roomID).
+			Stringer("event_id", resp.EventID).
+			Msg("Added server to ACL deny list")
+		pe.protectedRoomsLock.Lock()
+		meta.ACL = &acl
+		pe.protectedRoomsLock.Unlock()
+		results = append(results, fmt.Sprintf("* %s - updated", roomName))
+	}
+	slices.Sort(results)
+	return results
 }
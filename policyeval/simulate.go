@@ -0,0 +1,197 @@
+package policyeval
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mau.fi/util/glob"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/meowlnir/policylist"
+)
+
+const simulatePageSize = 50
+
+// simulationResult describes the effect a hypothetical policy would have on a single target.
+type simulationResult struct {
+	Target   string
+	Affected bool
+	Outcome  string
+}
+
+// simulatePolicy computes what enforcement actions a hypothetical policy would cause without sending it.
+// The hypothetical policy is treated as having the lowest priority, so existing policies in watched lists
+// (including unban exemptions) take precedence over it, just like they would after adding it to a list.
+func (pe *PolicyEvaluator) simulatePolicy(entityType policylist.EntityType, entity string, rec event.PolicyRecommendation) []simulationResult {
+	pattern := glob.Compile(entity)
+	hypothetical := &policylist.Policy{
+		ModPolicyContent: &event.ModPolicyContent{Entity: entity, Recommendation: rec},
+		Pattern:          pattern,
+		EntityType:       entityType,
+	}
+	var results []simulationResult
+	switch entityType {
+	case policylist.EntityTypeUser:
+		for userID := range pe.findMatchingUsers(hypothetical.UserMatchPattern(), nil, true) {
+			if userID == pe.Bot.UserID {
+				continue
+			}
+			existing := pe.Store.MatchUser(pe.GetWatchedLists(), userID)
+			results = append(results, pe.simulateUser(userID, append(existing, hypothetical)))
+		}
+	case policylist.EntityTypeServer:
+		servers := make(map[string]struct{})
+		for _, userID := range pe.getAllUsers() {
+			if len(pe.getRoomsUserIsIn(userID)) > 0 && pattern.Match(userID.Homeserver()) {
+				servers[userID.Homeserver()] = struct{}{}
+			}
+		}
+		for server := range servers {
+			existing := pe.Store.MatchServer(pe.GetWatchedListsForACLs(), server)
+			winner := append(existing, hypothetical).Recommendations().BanOrUnban
+			result := simulationResult{Target: server}
+			switch {
+			case server == pe.Bot.ServerName:
+				result.Outcome = "not affected, the bot's own server is never added to ACLs"
+			case winner != hypothetical:
+				result.Outcome = fmt.Sprintf("not affected, existing %s policy takes precedence", format.SafeMarkdownCode(winner.Recommendation))
+			case rec == event.PolicyRecommendationUnban:
+				result.Outcome = "not affected, unban policies only exempt servers from other bans"
+			default:
+				result.Affected = true
+				result.Outcome = "would be added to server ACLs"
+			}
+			results = append(results, result)
+		}
+	case policylist.EntityTypeRoom:
+		for _, roomID := range pe.GetProtectedRooms() {
+			if pattern.Match(string(roomID)) {
+				results = append(results, simulationResult{
+					Target:  fmt.Sprintf("[%s](%s)", roomID, roomID.URI().MatrixToURL()),
+					Outcome: "protected room matches, but room policies are not enforced",
+				})
+			}
+		}
+	}
+	slices.SortFunc(results, func(a, b simulationResult) int {
+		if a.Affected != b.Affected {
+			if a.Affected {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Target, b.Target)
+	})
+	return results
+}
+
+func (pe *PolicyEvaluator) simulateUser(userID id.UserID, match policylist.Match) simulationResult {
+	result := simulationResult{Target: fmt.Sprintf("[%s](%s)", userID, userID.URI().MatrixToURL())}
+	winner := match.Recommendations().BanOrUnban
+	hypothetical := match[len(match)-1]
+	rooms := pe.getRoomsUserIsIn(userID)
+	switch {
+	case winner == nil:
+		result.Outcome = fmt.Sprintf("not affected, %s recommendations aren't enforced", format.SafeMarkdownCode(hypothetical.Recommendation))
+	case winner != hypothetical:
+		listName := winner.RoomID.String()
+		if meta := pe.GetWatchedListMeta(winner.RoomID); meta != nil {
+			listName = meta.Name
+		}
+		result.Outcome = fmt.Sprintf("not affected, existing %s policy in %s takes precedence", format.SafeMarkdownCode(winner.Recommendation), format.EscapeMarkdown(listName))
+	case winner.Recommendation == event.PolicyRecommendationUnban:
+		result.Outcome = "not affected, unban policies only exempt users from other bans"
+	case winner.Recommendation == event.PolicyRecommendationUnstableTakedown:
+		result.Affected = true
+		result.Outcome = fmt.Sprintf("would be banned from %s and have their events redacted", pluralize(len(rooms), "room"))
+	default:
+		result.Affected = true
+		result.Outcome = fmt.Sprintf("would be banned from %s", pluralize(len(rooms), "room"))
+	}
+	return result
+}
+
+var cmdTestRule = &CommandHandler{
+	Name: "test-rule",
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) < 1 || len(ce.Args) > 2 {
+			replyUsage(ce)
+			return
+		}
+		entity := ce.Args[0]
+		entityType, ok := validateEntity(entity)
+		if !ok {
+			ce.Reply("Invalid entity %s", format.SafeMarkdownCode(entity))
+			return
+		}
+		rec := event.PolicyRecommendationBan
+		if len(ce.Args) > 1 {
+			rec, ok = ce.Meta.parseRecommendation(ce, ce.Args[1])
+			if !ok {
+				return
+			}
+		}
+		start := time.Now()
+		results := ce.Meta.simulatePolicy(entityType, entity, rec)
+		dur := time.Since(start)
+
+		var buf strings.Builder
+		var affectedCount int
+		for _, result := range results {
+			if result.Affected {
+				affectedCount++
+			}
+		}
+		_, _ = fmt.Fprintf(
+			&buf, "Tested %s rule %s for %s in %s (nothing was sent): %d matches, %d would be affected",
+			entityType, format.SafeMarkdownCode(rec), format.SafeMarkdownCode(entity), dur, len(results), affectedCount,
+		)
+		if len(results) > 0 {
+			buf.WriteString("\n\n")
+			for _, result := range results[:min(len(results), simulatePageSize)] {
+				_, _ = fmt.Fprintf(&buf, "* %s: %s\n", result.Target, result.Outcome)
+			}
+			if len(results) > simulatePageSize {
+				_, _ = fmt.Fprintf(
+					&buf, "* ...and %d more, use `%ssimulate-policy %s %s %s 2` to see the rest\n",
+					len(results)-simulatePageSize, ce.Meta.commandPrefix(), entityType, entity, rec,
+				)
+			}
+		}
+		if existing := ce.Meta.findPoliciesWithEntity(entityType, entity); len(existing) > 0 {
+			buf.WriteString("\nExisting policies for the same entity:\n\n")
+			for _, policy := range existing {
+				listName := policy.RoomID.String()
+				if meta := ce.Meta.GetWatchedListMeta(policy.RoomID); meta != nil {
+					listName = meta.Name
+				}
+				note := ""
+				if policy.Recommendation == rec {
+					note = " (the tested rule would be a duplicate)"
+				}
+				_, _ = fmt.Fprintf(
+					&buf, "* [%s] %s for %s%s\n",
+					format.EscapeMarkdown(listName), format.SafeMarkdownCode(policy.Recommendation),
+					format.SafeMarkdownCode(policy.Reason), note,
+				)
+			}
+		}
+		ce.Reply(buf.String())
+	},
+}
+
+// findPoliciesWithEntity returns the policies in watched lists whose entity is exactly the given string.
+func (pe *PolicyEvaluator) findPoliciesWithEntity(entityType policylist.EntityType, entity string) (output policylist.Match) {
+	for _, listID := range pe.GetWatchedLists() {
+		for _, policy := range pe.Store.GetAllPolicies(listID) {
+			if policy.EntityType == entityType && policy.Entity == entity {
+				output = append(output, policy)
+			}
+		}
+	}
+	return
+}
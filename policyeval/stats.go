@@ -0,0 +1,91 @@
+package policyeval
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+	"maunium.net/go/mautrix/id"
+)
+
+type senderStats struct {
+	Sender id.UserID
+	Count  int
+	Oldest int64
+	Newest int64
+}
+
+func (ss *senderStats) add(ts int64) {
+	ss.Count++
+	if ss.Oldest == 0 || ts < ss.Oldest {
+		ss.Oldest = ts
+	}
+	if ts > ss.Newest {
+		ss.Newest = ts
+	}
+}
+
+func formatStatsTime(ts int64) string {
+	return time.UnixMilli(ts).UTC().Format("2006-01-02 15:04")
+}
+
+var cmdStats = &CommandHandler{
+	Name: "stats",
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) != 1 {
+			replyUsage(ce)
+			return
+		}
+		list := ce.Meta.FindListByShortcode(ce.Args[0])
+		if list == nil {
+			ce.Reply("List %s not found", format.SafeMarkdownCode(ce.Args[0]))
+			return
+		}
+		policies := ce.Meta.Store.GetAllPolicies(list.RoomID)
+		if len(policies) == 0 {
+			ce.Reply("No rules found in %s", format.EscapeMarkdown(list.Name))
+			return
+		}
+		var total senderStats
+		bySender := make(map[id.UserID]*senderStats)
+		byRecommendation := make(map[event.PolicyRecommendation]int)
+		for _, policy := range policies {
+			total.add(policy.Timestamp)
+			byRecommendation[policy.Recommendation]++
+			stats, ok := bySender[policy.Sender]
+			if !ok {
+				stats = &senderStats{Sender: policy.Sender}
+				bySender[policy.Sender] = stats
+			}
+			stats.add(policy.Timestamp)
+		}
+		var buf strings.Builder
+		_, _ = fmt.Fprintf(&buf, "Statistics for **%s** (%s):\n\n", format.EscapeMarkdown(list.Name), pluralize(total.Count, "rule"))
+		_, _ = fmt.Fprintf(&buf, "* Oldest rule: %s\n", formatStatsTime(total.Oldest))
+		_, _ = fmt.Fprintf(&buf, "* Newest rule: %s\n", formatStatsTime(total.Newest))
+		buf.WriteString("\nBy recommendation:\n\n")
+		recs := slices.SortedFunc(maps.Keys(byRecommendation), func(a, b event.PolicyRecommendation) int {
+			return cmp.Or(cmp.Compare(byRecommendation[b], byRecommendation[a]), cmp.Compare(a, b))
+		})
+		for _, rec := range recs {
+			_, _ = fmt.Fprintf(&buf, "* %s: %d\n", format.SafeMarkdownCode(rec), byRecommendation[rec])
+		}
+		buf.WriteString("\nBy sender:\n\n")
+		senders := slices.SortedFunc(maps.Values(bySender), func(a, b *senderStats) int {
+			return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Sender, b.Sender))
+		})
+		for _, stats := range senders {
+			_, _ = fmt.Fprintf(
+				&buf, "* [%s](%s): %s, oldest %s, newest %s\n",
+				stats.Sender, stats.Sender.URI().MatrixToURL(), pluralize(stats.Count, "rule"),
+				formatStatsTime(stats.Oldest), formatStatsTime(stats.Newest),
+			)
+		}
+		ce.Reply(buf.String())
+	},
+}
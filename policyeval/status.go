@@ -0,0 +1,71 @@
+package policyeval
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+var cmdStatus = &CommandHandler{
+	Name: "status",
+	Func: func(ce *CommandEvent) {
+		verbose := len(ce.Args) > 0 && (ce.Args[0] == "--verbose" || ce.Args[0] == "-v")
+		if len(ce.Args) > 0 && !verbose {
+			replyUsage(ce)
+			return
+		}
+		var buf strings.Builder
+		buf.WriteString("Meowlnir status:\n\n")
+		_, _ = fmt.Fprintf(&buf, "* Protected rooms: %d\n", len(ce.Meta.GetProtectedRooms()))
+		lists := ce.Meta.GetWatchedLists()
+		var totalRules, unloadedLists int
+		for _, listID := range lists {
+			counts := ce.Meta.Store.CountPolicies(listID)
+			if counts == nil {
+				unloadedLists++
+				continue
+			}
+			for _, recCounts := range counts {
+				for _, count := range recCounts {
+					totalRules += count
+				}
+			}
+		}
+		_, _ = fmt.Fprintf(&buf, "* Watched lists: %d with %d rules in total", len(lists), totalRules)
+		if unloadedLists > 0 {
+			_, _ = fmt.Fprintf(&buf, " (%d not loaded)", unloadedLists)
+		}
+		buf.WriteString("\n")
+		_, _ = fmt.Fprintf(&buf, "* Dry run: %t\n", ce.Meta.DryRun)
+		var lastEvent time.Time
+		if ce.Meta.GetLastEventReceived != nil {
+			lastEvent = ce.Meta.GetLastEventReceived()
+		}
+		if lastEvent.IsZero() {
+			buf.WriteString("* Last event received: never\n")
+		} else {
+			_, _ = fmt.Fprintf(&buf, "* Last event received: %s ago\n", time.Since(lastEvent).Truncate(time.Second))
+		}
+		if ce.Meta.Bot.CryptoHelper == nil || ce.Meta.Bot.Mach == nil {
+			buf.WriteString("* Encryption: disabled\n")
+		} else if hasKeys, isVerified, err := ce.Meta.Bot.GetVerificationStatus(ce.Ctx); err != nil {
+			_, _ = fmt.Fprintf(&buf, "* Encryption: enabled, failed to check cross-signing status: %v\n", err)
+		} else if !hasKeys {
+			buf.WriteString("* Encryption: enabled, cross-signing keys not set up\n")
+		} else {
+			_, _ = fmt.Fprintf(&buf, "* Encryption: enabled, device verified: %t\n", isVerified)
+		}
+		_, _ = fmt.Fprintf(&buf, "* Pending confirmations: %d\n", ce.Meta.countPendingConfirmations())
+		if verbose {
+			var mem runtime.MemStats
+			runtime.ReadMemStats(&mem)
+			_, _ = fmt.Fprintf(&buf, "* Goroutines: %d\n", runtime.NumGoroutine())
+			_, _ = fmt.Fprintf(
+				&buf, "* Memory: %.1f MiB heap in use, %.1f MiB from OS, %d GC cycles\n",
+				float64(mem.HeapInuse)/1024/1024, float64(mem.Sys)/1024/1024, mem.NumGC,
+			)
+		}
+		ce.Reply(buf.String())
+	},
+}
@@ -0,0 +1,173 @@
+package policyeval
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"go.mau.fi/util/glob"
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/meowlnir/database"
+	"go.mau.fi/meowlnir/policylist"
+)
+
+type serverUnbanTargets struct {
+	RoomID id.RoomID
+	Users  []id.UserID
+}
+
+// findServerBannedUsers finds users on servers matching the given glob who are banned in protected rooms.
+// Users who are still banned by a user policy are skipped and counted separately.
+func (pe *PolicyEvaluator) findServerBannedUsers(ctx context.Context, server glob.Glob) (targets []*serverUnbanTargets, stillBanned int, errors []string) {
+	watchedLists := pe.GetWatchedLists()
+	for _, roomID := range pe.GetProtectedRooms() {
+		members, err := pe.Bot.Members(ctx, roomID, mautrix.ReqMembers{Membership: event.MembershipBan})
+		if err != nil {
+			zerolog.Ctx(ctx).Err(err).Stringer("room_id", roomID).Msg("Failed to get banned members")
+			errors = append(errors, fmt.Sprintf("* Failed to get banned members of [%s](%s): %v", roomID, roomID.URI().MatrixToURL(), err))
+			continue
+		}
+		target := &serverUnbanTargets{RoomID: roomID}
+		for _, evt := range members.Chunk {
+			content, ok := evt.Content.Parsed.(*event.MemberEventContent)
+			if !ok || content.Membership != event.MembershipBan {
+				continue
+			}
+			userID := id.UserID(evt.GetStateKey())
+			if !server.Match(userID.Homeserver()) {
+				continue
+			}
+			rec := pe.Store.MatchUser(watchedLists, userID).Recommendations().BanOrUnban
+			if rec != nil && rec.Recommendation != event.PolicyRecommendationUnban {
+				stillBanned++
+				continue
+			}
+			target.Users = append(target.Users, userID)
+		}
+		if len(target.Users) > 0 {
+			targets = append(targets, target)
+		}
+	}
+	return
+}
+
+// unbanServerUsers unbans the given users and returns a line per room with the number of successful unbans.
+func (pe *PolicyEvaluator) unbanServerUsers(ctx context.Context, targets []*serverUnbanTargets, reason string) []string {
+	results := make([]string, 0, len(targets))
+	for _, target := range targets {
+		var unbanned, failed int
+		for _, userID := range target.Users {
+			if !pe.DryRun {
+				_, err := pe.Bot.UnbanUser(ctx, target.RoomID, &mautrix.ReqUnbanUser{
+					Reason: reason,
+					UserID: userID,
+				})
+				if err != nil {
+					zerolog.Ctx(ctx).Err(err).
+						Stringer("room_id", target.RoomID).
+						Stringer("user_id", userID).
+						Msg("Failed to unban user")
+					failed++
+					continue
+				}
+				err = pe.DB.TakenAction.Delete(ctx, userID, target.RoomID, database.TakenActionTypeBanOrUnban)
+				if err != nil {
+					zerolog.Ctx(ctx).Err(err).Stringer("room_id", target.RoomID).Msg("Failed to delete taken action after unbanning")
+				}
+			}
+			unbanned++
+			pe.logAction(ctx, &database.AuditLogEntry{
+				Action:     database.AuditLogActionUnban,
+				TargetUser: userID,
+				InRoomID:   target.RoomID,
+				Reason:     reason,
+			})
+		}
+		line := fmt.Sprintf(
+			"* [%s](%s): unbanned %d",
+			format.EscapeMarkdown(pe.getProtectedRoomName(target.RoomID)), target.RoomID.URI().MatrixToURL(), unbanned,
+		)
+		if failed > 0 {
+			line += fmt.Sprintf(", failed to unban %d", failed)
+		}
+		results = append(results, line)
+	}
+	return results
+}
+
+var cmdUnbanServer = &CommandHandler{
+	Name: "unban-server",
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) < 1 {
+			replyUsage(ce)
+			return
+		}
+		server := strings.ToLower(ce.Args[0])
+		reason := strings.Join(ce.Args[1:], " ")
+		if entityType, _ := validateEntity(server); entityType != policylist.EntityTypeServer {
+			ce.Reply("%s is not a valid server name or glob", format.SafeMarkdownCode(server))
+			return
+		}
+		var policies []*policylist.Policy
+		for _, policy := range ce.Meta.Store.MatchExact(ce.Meta.GetWatchedLists(), policylist.EntityTypeServer, server) {
+			if policy.Recommendation == event.PolicyRecommendationBan || policy.Recommendation == event.PolicyRecommendationUnstableTakedown {
+				policies = append(policies, policy)
+			}
+		}
+		targets, stillBanned, errors := ce.Meta.findServerBannedUsers(ce.Ctx, glob.Compile(server))
+		if len(errors) > 0 {
+			ce.Reply("%s", strings.Join(errors, "\n"))
+		}
+		var totalUsers int
+		for _, target := range targets {
+			totalUsers += len(target.Users)
+		}
+		if len(policies) == 0 && totalUsers == 0 {
+			ce.Reply("No server ban policies or room bans found for %s", format.SafeMarkdownCode(server))
+			return
+		}
+		var summary strings.Builder
+		_, _ = fmt.Fprintf(&summary, "Found %d server ban policies for %s", len(policies), format.SafeMarkdownCode(server))
+		for _, policy := range policies {
+			listName := policy.RoomID.String()
+			if meta := ce.Meta.GetWatchedListMeta(policy.RoomID); meta != nil {
+				listName = meta.Name
+			}
+			_, _ = fmt.Fprintf(&summary, "\n* %s in %s", format.SafeMarkdownCode(policy.EntityOrHash()), format.EscapeMarkdown(listName))
+		}
+		_, _ = fmt.Fprintf(&summary, "\n\n%d banned users from matching servers in %s", totalUsers, pluralize(len(targets), "room"))
+		if stillBanned > 0 {
+			_, _ = fmt.Fprintf(&summary, " (%d more skipped as they're still banned by user policies)", stillBanned)
+		}
+		ce.Meta.requestConfirmation(
+			ce.Ctx,
+			summary.String()+"\n\nAre you sure you want to remove the policies and unban the users?",
+			func(ctx context.Context, _ id.UserID) {
+				ce.Ctx = ctx
+				for _, policy := range policies {
+					resp, err := ce.Meta.RemovePolicy(ctx, policy)
+					if err != nil {
+						ce.Reply("Failed to remove %s policy for %s: %v", format.SafeMarkdownCode(policy.Recommendation), format.SafeMarkdownCode(policy.EntityOrHash()), err)
+						continue
+					}
+					zerolog.Ctx(ctx).Info().
+						Stringer("policy_list", policy.RoomID).
+						Any("removed_policy", policy).
+						Stringer("policy_event_id", resp.EventID).
+						Msg("Removed server ban policy from unban-server command")
+				}
+				if len(targets) == 0 {
+					ce.Reply("No banned users from %s found in protected rooms", format.SafeMarkdownCode(server))
+				} else {
+					ce.Reply("Unban results:\n\n%s", strings.Join(ce.Meta.unbanServerUsers(ctx, targets, reason), "\n"))
+				}
+				ce.React(SuccessReaction)
+			},
+		)
+	},
+}
@@ -0,0 +1,107 @@
+package policyeval
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/meowlnir/database"
+)
+
+// withUndo marks the given context as being used to undo an action, so that the resulting actions aren't undoable.
+func withUndo(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextKeyUndo, true)
+}
+
+func isUndoContext(ctx context.Context) bool {
+	isUndo, _ := ctx.Value(contextKeyUndo).(bool)
+	return isUndo
+}
+
+// popUndoableAction finds the most recent action that hasn't been undone yet and marks it as undone.
+// If UndoAnyAdmin is false, only actions taken by the given user are considered.
+func (pe *PolicyEvaluator) popUndoableAction(actor id.UserID) *database.AuditLogEntry {
+	pe.recentActionsLock.Lock()
+	defer pe.recentActionsLock.Unlock()
+	for i := len(pe.recentActions) - 1; i >= 0; i-- {
+		entry := pe.recentActions[i]
+		if entry.Undone || (!pe.UndoAnyAdmin && entry.Actor != actor) {
+			continue
+		}
+		entry.Undone = true
+		return entry
+	}
+	return nil
+}
+
+// undoAction reverses the given action and returns a description of what was done.
+func (pe *PolicyEvaluator) undoAction(ctx context.Context, entry *database.AuditLogEntry) (string, error) {
+	if entry.DryRun {
+		return "The action was taken in dry run mode, so there's nothing to undo", nil
+	}
+	switch entry.Action {
+	case database.AuditLogActionSendPolicy:
+		for _, policy := range pe.Store.GetAllPolicies(entry.PolicyList) {
+			if policy.ID != entry.TargetEvent {
+				continue
+			}
+			_, err := pe.RemovePolicy(ctx, policy)
+			if err != nil {
+				return "", fmt.Errorf("failed to remove policy: %w", err)
+			}
+			return fmt.Sprintf(
+				"Removed %s policy for %s", format.SafeMarkdownCode(policy.Recommendation), format.SafeMarkdownCode(policy.EntityOrHash()),
+			), nil
+		}
+		return "The policy has already been removed or replaced", nil
+	case database.AuditLogActionBan, database.AuditLogActionTakedown:
+		if !pe.UndoBan(ctx, entry.TargetUser, entry.InRoomID) {
+			return "", fmt.Errorf("failed to unban user")
+		}
+		err := pe.DB.TakenAction.Delete(ctx, entry.TargetUser, entry.InRoomID, database.TakenActionTypeBanOrUnban)
+		if err != nil {
+			zerolog.Ctx(ctx).Err(err).Stringer("room_id", entry.InRoomID).Msg("Failed to delete taken action after unbanning")
+		}
+		msg := fmt.Sprintf("Unbanned [%s](%s) in %s", entry.TargetUser, entry.TargetUser.URI().MatrixToURL(), pe.formatRoomLink(entry.InRoomID))
+		rec := pe.Store.MatchUser(pe.GetWatchedLists(), entry.TargetUser).Recommendations().BanOrUnban
+		if rec != nil && rec.Recommendation != event.PolicyRecommendationUnban {
+			msg += fmt.Sprintf(
+				". The user still matches a %s policy for %s, so they may be banned again unless it's removed",
+				format.SafeMarkdownCode(rec.Recommendation), format.SafeMarkdownCode(rec.EntityOrHash()),
+			)
+		}
+		return msg, nil
+	default:
+		return fmt.Sprintf("%s actions can't be undone, run `%sundo` again to undo the action before it", format.SafeMarkdownCode(entry.Action), pe.commandPrefix()), nil
+	}
+}
+
+var cmdUndo = &CommandHandler{
+	Name: "undo",
+	Func: func(ce *CommandEvent) {
+		entry := ce.Meta.popUndoableAction(actorFromContext(ce.Ctx))
+		if entry == nil {
+			if ce.Meta.UndoAnyAdmin {
+				ce.Reply("No recent actions to undo")
+			} else {
+				ce.Reply("You have no recent actions to undo")
+			}
+			return
+		}
+		zerolog.Ctx(ce.Ctx).Info().Any("undo_entry", entry).Msg("Undoing action")
+		result, err := ce.Meta.undoAction(withUndo(ce.Ctx), entry)
+		if err != nil {
+			ce.Meta.recentActionsLock.Lock()
+			entry.Undone = false
+			ce.Meta.recentActionsLock.Unlock()
+			ce.Reply("Failed to undo action:\n\n%s\n\n%v", formatAuditLogEntry(entry), err)
+			return
+		}
+		ce.Reply("Undid action:\n\n%s\n\n%s", formatAuditLogEntry(entry), result)
+		ce.React(SuccessReaction)
+	},
+}
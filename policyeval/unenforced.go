@@ -0,0 +1,108 @@
+package policyeval
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/meowlnir/policylist"
+)
+
+type unenforcedBan struct {
+	UserID id.UserID
+	Rooms  []id.RoomID
+	Policy *policylist.Policy
+}
+
+// findUnenforcedBans finds users who match a ban or takedown policy, but are still in some protected rooms.
+// If onlyRoom is set, only that room is checked. Observe-only rooms are skipped.
+func (pe *PolicyEvaluator) findUnenforcedBans(onlyRoom id.RoomID) (bans []*unenforcedBan) {
+	watchedLists := pe.GetWatchedLists()
+	for _, userID := range pe.getAllUsers() {
+		if userID == pe.Bot.UserID {
+			continue
+		}
+		rooms := pe.getRoomsUserIsIn(userID)
+		if onlyRoom != "" {
+			rooms = slices.DeleteFunc(rooms, func(roomID id.RoomID) bool {
+				return roomID != onlyRoom
+			})
+		}
+		rooms = slices.DeleteFunc(rooms, pe.isObserveOnlyRoom)
+		if len(rooms) == 0 {
+			continue
+		}
+		rec := pe.Store.MatchUser(watchedLists, userID).Recommendations().BanOrUnban
+		if rec == nil || (rec.Recommendation != event.PolicyRecommendationBan && rec.Recommendation != event.PolicyRecommendationUnstableTakedown) {
+			continue
+		}
+		bans = append(bans, &unenforcedBan{UserID: userID, Rooms: rooms, Policy: rec})
+	}
+	slices.SortFunc(bans, func(a, b *unenforcedBan) int {
+		return strings.Compare(string(a.UserID), string(b.UserID))
+	})
+	return
+}
+
+var cmdUnenforced = &CommandHandler{
+	Name: "unenforced",
+	Func: func(ce *CommandEvent) {
+		enforce := slices.Contains(ce.Args, "--enforce")
+		if enforce {
+			ce.Args = slices.DeleteFunc(ce.Args, func(arg string) bool { return arg == "--enforce" })
+		}
+		var onlyRoom id.RoomID
+		if len(ce.Args) > 0 {
+			onlyRoom = resolveRoom(ce, ce.Args[0])
+			if onlyRoom == "" {
+				return
+			} else if !ce.Meta.IsProtectedRoom(onlyRoom) {
+				ce.Reply("%s is not a protected room", format.SafeMarkdownCode(ce.Args[0]))
+				return
+			}
+		}
+		bans := ce.Meta.findUnenforcedBans(onlyRoom)
+		if len(bans) == 0 {
+			ce.Reply("All users matching ban policies have been removed from protected rooms")
+			return
+		}
+		lines := make([]string, 0, min(len(bans), maxPreviewedUsers))
+		for _, ban := range bans[:min(len(bans), maxPreviewedUsers)] {
+			roomLinks := make([]string, len(ban.Rooms))
+			for i, roomID := range ban.Rooms {
+				roomLinks[i] = fmt.Sprintf("[%s](%s)", format.EscapeMarkdown(ce.Meta.getProtectedRoomName(roomID)), roomID.URI().MatrixToURL())
+			}
+			lines = append(lines, fmt.Sprintf(
+				"* [%s](%s) matches %s for %s, but is still in %s",
+				ban.UserID, ban.UserID.URI().MatrixToURL(),
+				format.SafeMarkdownCode(ban.Policy.Recommendation), format.SafeMarkdownCode(ban.Policy.EntityOrHash()),
+				strings.Join(roomLinks, ", "),
+			))
+		}
+		if len(bans) > maxPreviewedUsers {
+			lines = append(lines, fmt.Sprintf("* ...and %d more", len(bans)-maxPreviewedUsers))
+		}
+		summary := fmt.Sprintf("Found %s matching ban policies who are still in protected rooms:\n\n%s", pluralize(len(bans), "user"), strings.Join(lines, "\n"))
+		if !enforce {
+			ce.Reply("%s\n\nUse `%sunenforced --enforce` to retry banning them", summary, ce.Meta.commandPrefix())
+			return
+		}
+		ce.Meta.requestConfirmation(
+			ce.Ctx,
+			summary+"\n\nAre you sure you want to retry banning them?",
+			func(ctx context.Context, _ id.UserID) {
+				for _, ban := range bans {
+					for _, roomID := range ban.Rooms {
+						ce.Meta.ApplyBan(ctx, ban.UserID, roomID, ban.Policy)
+					}
+				}
+				ce.React(SuccessReaction)
+			},
+		)
+	},
+}
@@ -0,0 +1,94 @@
+package policyeval
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.mau.fi/util/glob"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/meowlnir/config"
+	"go.mau.fi/meowlnir/policylist"
+)
+
+type upstreamReport struct {
+	Entity         string                     `json:"entity"`
+	EntityType     policylist.EntityType      `json:"entity_type"`
+	Recommendation event.PolicyRecommendation `json:"recommendation"`
+	Category       string                     `json:"category"`
+	Reason         string                     `json:"reason,omitempty"`
+	Evidence       string                     `json:"evidence"`
+	Reporter       id.UserID                  `json:"reporter,omitempty"`
+	Timestamp      int64                      `json:"timestamp"`
+}
+
+// upstreamReportCategory returns the category that should be used when submitting the given policy upstream,
+// or an empty string if the policy shouldn't be submitted.
+func upstreamReportCategory(cfg *config.UpstreamReportingConfig, policy *event.ModPolicyContent) string {
+	if policy.Recommendation == event.PolicyRecommendationUnstableTakedown {
+		return "takedown"
+	}
+	for _, category := range cfg.Categories {
+		if glob.Compile(category).Match(policy.Reason) {
+			return category
+		}
+	}
+	return ""
+}
+
+// maybeSubmitUpstreamReport submits a report about the given policy to the configured upstream service
+// in the background if it's a takedown or the reason matches one of the configured categories.
+func (pe *PolicyEvaluator) maybeSubmitUpstreamReport(
+	ctx context.Context, entityType policylist.EntityType, policy *event.ModPolicyContent, listID id.RoomID, policyEventID id.EventID,
+) {
+	cfg := pe.UpstreamReporting
+	if cfg == nil || cfg.URL == "" {
+		return
+	}
+	category := upstreamReportCategory(cfg, policy)
+	if category == "" {
+		return
+	}
+	report := &upstreamReport{
+		Entity:         policy.EntityOrHash(),
+		EntityType:     entityType,
+		Recommendation: policy.Recommendation,
+		Category:       category,
+		Reason:         policy.Reason,
+		Evidence:       listID.EventURI(policyEventID, pe.Bot.ServerName).MatrixToURL(),
+		Reporter:       actorFromContext(ctx),
+		Timestamp:      time.Now().UnixMilli(),
+	}
+	go pe.submitUpstreamReport(context.WithoutCancel(ctx), cfg, report)
+}
+
+func (pe *PolicyEvaluator) submitUpstreamReport(ctx context.Context, cfg *config.UpstreamReportingConfig, report *upstreamReport) {
+	log := zerolog.Ctx(ctx).With().
+		Str("entity", report.Entity).
+		Str("category", report.Category).
+		Logger()
+	body, err := json.Marshal(report)
+	if err != nil {
+		log.Err(err).Msg("Failed to marshal upstream report")
+		return
+	}
+	headers := make(http.Header)
+	if cfg.Token != "" {
+		headers.Set("Authorization", "Bearer "+cfg.Token)
+	}
+	attempts, err := postJSONWithRetries(log.WithContext(ctx), cfg.URL, headers, body, cfg.MaxRetries)
+	if err != nil {
+		log.Error().Err(err).Int("attempts", attempts).Msg("Giving up on submitting report to upstream service")
+		pe.sendNoticeNow(ctx, "Failed to submit %s to the upstream reporting service after %s: %v",
+			format.SafeMarkdownCode(report.Entity), pluralize(attempts, "attempt"), err)
+		return
+	}
+	log.Info().Int("attempt", attempts).Msg("Submitted report to upstream service")
+	pe.sendNotice(ctx, "Submitted %s to the upstream reporting service (category %s)",
+		format.SafeMarkdownCode(report.Entity), format.SafeMarkdownCode(report.Category))
+}
@@ -0,0 +1,148 @@
+package policyeval
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"maunium.net/go/mautrix/format"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/meowlnir/policylist"
+	"go.mau.fi/meowlnir/util"
+)
+
+const (
+	userHashFlushInterval   = 1 * time.Minute
+	userHashRebuildInterval = 24 * time.Hour
+	// userHashRetention is how long the hashes of users who have left all protected rooms are kept.
+	userHashRetention = 90 * 24 * time.Hour
+)
+
+// getKnownServers returns the servers of all users in protected rooms.
+func (pe *PolicyEvaluator) getKnownServers() []string {
+	servers := make(map[string]struct{})
+	for _, userID := range pe.getAllUsers() {
+		servers[userID.Homeserver()] = struct{}{}
+	}
+	return slices.Collect(maps.Keys(servers))
+}
+
+// resolveEntityHash finds the entity with the given hash among known users, protected rooms
+// and the servers of users in protected rooms.
+func (pe *PolicyEvaluator) resolveEntityHash(ctx context.Context, hash [32]byte) (string, policylist.EntityType, bool) {
+	if userID, ok := pe.resolveUserHash(ctx, hash); ok {
+		return userID.String(), policylist.EntityTypeUser, true
+	}
+	for _, roomID := range pe.GetProtectedRooms() {
+		if util.SHA256String(roomID.String()) == hash {
+			return roomID.String(), policylist.EntityTypeRoom, true
+		}
+	}
+	for _, server := range pe.getKnownServers() {
+		if util.SHA256String(server) == hash {
+			return server, policylist.EntityTypeServer, true
+		}
+	}
+	return "", "", false
+}
+
+// resolveUserHash finds the user ID with the given hash, first from current members of protected rooms
+// and then from the persistent index, which also includes users who have since left.
+func (pe *PolicyEvaluator) resolveUserHash(ctx context.Context, hash [32]byte) (id.UserID, bool) {
+	if userID, ok := pe.getUserIDFromHash(hash); ok {
+		return userID, true
+	}
+	userID, err := pe.DB.UserHash.Get(ctx, hash)
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Msg("Failed to get user ID from hash index")
+		return "", false
+	}
+	return userID, userID != ""
+}
+
+func (pe *PolicyEvaluator) userHashIndexLoop() {
+	ctx := pe.Bot.Log.With().
+		Str("action", "user hash index").
+		Stringer("management_room", pe.ManagementRoom).
+		Logger().
+		WithContext(context.Background())
+	ticker := time.NewTicker(userHashFlushInterval)
+	defer ticker.Stop()
+	lastRebuild := time.Now()
+	for range ticker.C {
+		if time.Since(lastRebuild) >= userHashRebuildInterval {
+			lastRebuild = time.Now()
+			pe.rebuildUserHashIndex(ctx)
+		} else {
+			pe.flushUserHashes(ctx)
+		}
+	}
+}
+
+// flushUserHashes stores the hashes of users whose membership changed since the last flush.
+func (pe *PolicyEvaluator) flushUserHashes(ctx context.Context) {
+	pe.protectedRoomsLock.Lock()
+	dirty := pe.dirtyUserHashes
+	pe.dirtyUserHashes = make(map[id.UserID]struct{})
+	pe.protectedRoomsLock.Unlock()
+	if len(dirty) == 0 {
+		return
+	}
+	err := pe.DB.UserHash.Put(ctx, time.Now(), slices.Collect(maps.Keys(dirty))...)
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Int("user_count", len(dirty)).Msg("Failed to store user hashes")
+		pe.protectedRoomsLock.Lock()
+		maps.Copy(pe.dirtyUserHashes, dirty)
+		pe.protectedRoomsLock.Unlock()
+	}
+}
+
+// rebuildUserHashIndex refreshes the hashes of all users currently in protected rooms and
+// evicts users who haven't been seen in any protected room within the retention period.
+func (pe *PolicyEvaluator) rebuildUserHashIndex(ctx context.Context) {
+	pe.protectedRoomsLock.Lock()
+	for userID, rooms := range pe.protectedRoomMembers {
+		if len(rooms) > 0 {
+			pe.dirtyUserHashes[userID] = struct{}{}
+		}
+	}
+	pe.protectedRoomsLock.Unlock()
+	pe.flushUserHashes(ctx)
+	deleted, err := pe.DB.UserHash.DeleteOlderThan(ctx, time.Now().Add(-userHashRetention))
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Msg("Failed to evict old user hashes")
+	} else {
+		zerolog.Ctx(ctx).Debug().Int64("deleted_count", deleted).Msg("Evicted old user hashes")
+	}
+}
+
+var cmdResolve = &CommandHandler{
+	Name: "resolve",
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) < 1 {
+			replyUsage(ce)
+			return
+		}
+		lines := make([]string, len(ce.Args))
+		for i, arg := range ce.Args {
+			hash, ok := util.DecodeBase64Hash(arg)
+			if !ok {
+				lines[i] = fmt.Sprintf("* %s - not a valid hash", format.SafeMarkdownCode(arg))
+			} else if userID, found := ce.Meta.resolveUserHash(ce.Ctx, *hash); found {
+				lines[i] = fmt.Sprintf("* %s - [%s](%s)", format.SafeMarkdownCode(arg), userID, userID.URI().MatrixToURL())
+			} else {
+				lines[i] = fmt.Sprintf("* %s - no user found", format.SafeMarkdownCode(arg))
+			}
+		}
+		if len(lines) == 1 {
+			ce.Reply("%s", strings.TrimPrefix(lines[0], "* "))
+		} else {
+			ce.Reply("%s", strings.Join(lines, "\n"))
+		}
+	},
+}
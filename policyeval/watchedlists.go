@@ -48,6 +48,25 @@ func (pe *PolicyEvaluator) GetWatchedLists() []id.RoomID {
 	return pe.watchedListsList
 }
 
+// GetWatchedListsInOrder returns the metadata of all watched lists (including ones that aren't applied)
+// in priority order.
+func (pe *PolicyEvaluator) GetWatchedListsInOrder() []*config.WatchedPolicyList {
+	pe.watchedListsLock.RLock()
+	defer pe.watchedListsLock.RUnlock()
+	if pe.watchedListsEvent == nil {
+		return nil
+	}
+	output := make([]*config.WatchedPolicyList, 0, len(pe.watchedListsEvent.Lists))
+	seen := make(map[id.RoomID]struct{}, len(pe.watchedListsEvent.Lists))
+	for _, list := range pe.watchedListsEvent.Lists {
+		if _, alreadySeen := seen[list.RoomID]; !alreadySeen {
+			seen[list.RoomID] = struct{}{}
+			output = append(output, pe.watchedListsMap[list.RoomID])
+		}
+	}
+	return output
+}
+
 func (pe *PolicyEvaluator) GetWatchedListsForACLs() []id.RoomID {
 	pe.watchedListsLock.RLock()
 	defer pe.watchedListsLock.RUnlock()
@@ -76,6 +95,13 @@ func (pe *PolicyEvaluator) handleWatchedLists(ctx context.Context, evt *event.Ev
 				}
 				pe.Store.Add(listInfo.RoomID, state)
 			}
+			if !listInfo.AllowPublic {
+				if warning := pe.checkListRoomSecurity(ctx, &listInfo); warning != "" {
+					outLock.Lock()
+					errors = append(errors, warning)
+					outLock.Unlock()
+				}
+			}
 		}()
 	}
 	wg.Wait()
@@ -148,3 +174,55 @@ func (pe *PolicyEvaluator) handleWatchedLists(ctx context.Context, evt *event.Ev
 	}
 	return
 }
+
+type listRoomSecurity struct {
+	JoinRule          event.JoinRule
+	HistoryVisibility event.HistoryVisibility
+}
+
+const (
+	recommendedListJoinRule          = event.JoinRuleInvite
+	recommendedListHistoryVisibility = event.HistoryVisibilityShared
+)
+
+func (lrs *listRoomSecurity) IsPermissive() bool {
+	return lrs.JoinRule == event.JoinRulePublic || lrs.HistoryVisibility == event.HistoryVisibilityWorldReadable
+}
+
+func (lrs *listRoomSecurity) String() string {
+	return fmt.Sprintf(
+		"join rule `%s` (recommended `%s`), history visibility `%s` (recommended `%s`)",
+		lrs.JoinRule, recommendedListJoinRule, lrs.HistoryVisibility, recommendedListHistoryVisibility,
+	)
+}
+
+func (pe *PolicyEvaluator) getListRoomSecurity(ctx context.Context, roomID id.RoomID) (*listRoomSecurity, error) {
+	var joinRules event.JoinRulesEventContent
+	err := pe.Bot.StateEvent(ctx, roomID, event.StateJoinRules, "", &joinRules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get join rules: %w", err)
+	}
+	var historyVisibility event.HistoryVisibilityEventContent
+	err = pe.Bot.StateEvent(ctx, roomID, event.StateHistoryVisibility, "", &historyVisibility)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history visibility: %w", err)
+	}
+	return &listRoomSecurity{
+		JoinRule:          joinRules.JoinRule,
+		HistoryVisibility: historyVisibility.HistoryVisibility,
+	}, nil
+}
+
+func (pe *PolicyEvaluator) checkListRoomSecurity(ctx context.Context, list *config.WatchedPolicyList) string {
+	security, err := pe.getListRoomSecurity(ctx, list.RoomID)
+	if err != nil {
+		zerolog.Ctx(ctx).Warn().Err(err).Stringer("room_id", list.RoomID).Msg("Failed to check security of watched list")
+		return ""
+	} else if !security.IsPermissive() {
+		return ""
+	}
+	return fmt.Sprintf(
+		"* ⚠️ Watched list [%s](%s) is readable by outsiders: %s. Use `%ssecure-list %s` to fix or set `allow_public` to silence this warning.",
+		list.Name, list.RoomID.URI().MatrixToURL(), security, pe.commandPrefix(), list.Shortcode,
+	)
+}
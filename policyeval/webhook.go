@@ -0,0 +1,51 @@
+package policyeval
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"github.com/rs/zerolog"
+
+	"go.mau.fi/meowlnir/config"
+	"go.mau.fi/meowlnir/database"
+)
+
+// WebhookSignatureHeader is the header containing the hex-encoded HMAC-SHA256 of the webhook body
+// when a webhook secret is configured.
+const WebhookSignatureHeader = "X-Meowlnir-Signature"
+
+// maybeSendWebhook sends the given audit log entry to the configured webhook in the background.
+func (pe *PolicyEvaluator) maybeSendWebhook(ctx context.Context, entry *database.AuditLogEntry) {
+	cfg := pe.Webhook
+	if cfg == nil || cfg.URL == "" {
+		return
+	}
+	body, err := json.Marshal(entry)
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Msg("Failed to marshal webhook payload")
+		return
+	}
+	go sendWebhook(context.WithoutCancel(ctx), cfg, entry.Action, body)
+}
+
+func sendWebhook(ctx context.Context, cfg *config.WebhookConfig, action database.AuditLogAction, body []byte) {
+	log := zerolog.Ctx(ctx).With().
+		Str("webhook_action", string(action)).
+		Logger()
+	headers := make(http.Header)
+	if cfg.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(cfg.Secret))
+		mac.Write(body)
+		headers.Set(WebhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	attempts, err := postJSONWithRetries(log.WithContext(ctx), cfg.URL, headers, body, cfg.MaxRetries)
+	if err != nil {
+		log.Error().Err(err).Int("attempts", attempts).Msg("Giving up on sending webhook")
+	} else {
+		log.Debug().Int("attempt", attempts).Msg("Sent webhook")
+	}
+}
@@ -0,0 +1,89 @@
+package policyeval
+
+import (
+	"fmt"
+	"strings"
+
+	"maunium.net/go/mautrix/format"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/meowlnir/policylist"
+	"go.mau.fi/meowlnir/util"
+)
+
+var cmdWhois = &CommandHandler{
+	Name: "whois",
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) < 1 {
+			replyUsage(ce)
+			return
+		}
+		userID := id.UserID(ce.Args[0])
+		if userIDHash, ok := util.DecodeBase64Hash(ce.Args[0]); ok {
+			userID, ok = ce.Meta.resolveUserHash(ce.Ctx, *userIDHash)
+			if !ok {
+				ce.Reply("No user found for hash %s", format.SafeMarkdownCode(ce.Args[0]))
+				return
+			}
+		} else if entityType, _ := validateEntity(ce.Args[0]); entityType != policylist.EntityTypeUser {
+			ce.Reply("%s is not a user ID", format.SafeMarkdownCode(ce.Args[0]))
+			return
+		}
+		var buf strings.Builder
+		_, _ = fmt.Fprintf(&buf, "Information about [%s](%s):\n\n", userID, userID.URI().MatrixToURL())
+
+		if userID.Homeserver() == ce.Meta.Bot.ServerName {
+			info, err := ce.Meta.synapseAdmin().GetUserInfo(ce.Ctx, userID)
+			if err != nil {
+				_, _ = fmt.Fprintf(&buf, "* Account: failed to fetch info: %v\n", err)
+			} else {
+				_, _ = fmt.Fprintf(
+					&buf, "* Account: created at %s, admin: %t, deactivated: %t, shadow banned: %t\n",
+					format.EscapeMarkdown(info.CreationTS.Time.String()), info.Admin, info.Deactivated, info.ShadowBanned,
+				)
+			}
+		}
+
+		match := ce.Meta.Store.MatchUser(nil, userID)
+		if len(match) == 0 {
+			buf.WriteString("* Matching policies: none\n")
+		} else {
+			_, _ = fmt.Fprintf(&buf, "* Matching policies: %s\n", ce.Meta.formatRecommendations(match.Recommendations()))
+			for _, policy := range match {
+				_, _ = fmt.Fprintf(&buf, "%s\n", formatWhyPolicy(policy, describeUserMatchKind(policy), "matches"))
+			}
+		}
+
+		rooms := ce.Meta.getRoomsUserIsIn(userID)
+		if len(rooms) == 0 {
+			buf.WriteString("* Protected rooms: not in any\n")
+		} else {
+			_, _ = fmt.Fprintf(&buf, "* Protected rooms: %d\n", len(rooms))
+		}
+		for _, roomID := range rooms {
+			_, _ = fmt.Fprintf(
+				&buf, "    * [%s](%s)",
+				format.EscapeMarkdown(ce.Meta.getProtectedRoomName(roomID)), roomID.URI().MatrixToURL(),
+			)
+			member, err := ce.Meta.Bot.StateStore.GetMember(ce.Ctx, roomID, userID)
+			if err != nil {
+				_, _ = fmt.Fprintf(&buf, ": failed to get member info: %v", err)
+			} else if member != nil {
+				if member.Displayname != "" {
+					_, _ = fmt.Fprintf(&buf, ", display name %s", format.SafeMarkdownCode(member.Displayname))
+				}
+				if member.AvatarURL != "" {
+					_, _ = fmt.Fprintf(&buf, ", avatar %s", format.SafeMarkdownCode(member.AvatarURL))
+				}
+			}
+			pls, err := ce.Meta.Bot.StateStore.GetPowerLevels(ce.Ctx, roomID)
+			if err != nil {
+				_, _ = fmt.Fprintf(&buf, ", failed to get power levels: %v", err)
+			} else if pls != nil {
+				_, _ = fmt.Fprintf(&buf, ", power level %d", pls.GetUserLevel(userID))
+			}
+			buf.WriteString("\n")
+		}
+		ce.Reply(buf.String())
+	},
+}
@@ -0,0 +1,156 @@
+package policyeval
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.mau.fi/util/glob"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/meowlnir/config"
+	"go.mau.fi/meowlnir/policylist"
+	"go.mau.fi/meowlnir/util"
+)
+
+// describeUserMatchKind describes how the given user policy matched a user.
+func describeUserMatchKind(policy *policylist.Policy) string {
+	if policy.Entity == "" && policy.EntityHash != nil {
+		return "by hash"
+	} else if _, isExact := policy.Pattern.(glob.ExactGlob); isExact {
+		return "exact match"
+	} else if strings.HasPrefix(policy.Entity, "@*:") {
+		return "by server"
+	}
+	return "by wildcard glob"
+}
+
+func formatWhyPolicy(policy *policylist.Policy, kind, outcome string) string {
+	return fmt.Sprintf(
+		"    * %s for %s (%s) by [%s](%s): %s, reason: %s",
+		format.SafeMarkdownCode(policy.Recommendation),
+		format.SafeMarkdownCode(policy.EntityOrHash()),
+		kind,
+		policy.Sender,
+		policy.Sender.URI().MatrixToURL(),
+		outcome,
+		format.SafeMarkdownCode(policy.Reason),
+	)
+}
+
+var cmdWhy = &CommandHandler{
+	Name: "why",
+	Func: func(ce *CommandEvent) {
+		if len(ce.Args) < 1 {
+			replyUsage(ce)
+			return
+		}
+		userID := id.UserID(ce.Args[0])
+		if userIDHash, ok := util.DecodeBase64Hash(ce.Args[0]); ok {
+			userID, ok = ce.Meta.resolveUserHash(ce.Ctx, *userIDHash)
+			if !ok {
+				ce.Reply("No user found for hash %s", format.SafeMarkdownCode(ce.Args[0]))
+				return
+			}
+		} else if entityType, _ := validateEntity(ce.Args[0]); entityType != policylist.EntityTypeUser {
+			ce.Reply("%s is not a user ID", format.SafeMarkdownCode(ce.Args[0]))
+			return
+		}
+		server := userID.Homeserver()
+		var buf strings.Builder
+		_, _ = fmt.Fprintf(&buf, "Policies affecting [%s](%s), in list priority order:\n\n", userID, userID.URI().MatrixToURL())
+		var effective, effectiveServer *policylist.Policy
+		var effectiveList, effectiveServerList *config.WatchedPolicyList
+		var matchedLists int
+		for _, list := range ce.Meta.GetWatchedListsInOrder() {
+			listIDs := []id.RoomID{list.RoomID}
+			userMatch := ce.Meta.Store.MatchUser(listIDs, userID)
+			serverMatch := ce.Meta.Store.MatchServer(listIDs, server)
+			if len(userMatch) == 0 && len(serverMatch) == 0 {
+				continue
+			}
+			matchedLists++
+			listNote := ""
+			if list.DontApply {
+				listNote = " (list is not applied)"
+			}
+			_, _ = fmt.Fprintf(&buf, "* [%s](%s)%s:\n", format.EscapeMarkdown(list.Name), list.RoomID.URI().MatrixToURL(), listNote)
+			for _, policy := range userMatch {
+				var outcome string
+				switch {
+				case !policylist.IsBanOrUnban(policy.Recommendation):
+					outcome = "doesn't affect bans"
+				case list.DontApply:
+					outcome = "ignored"
+				case effective != nil:
+					outcome = fmt.Sprintf("overridden by %s in %s", format.SafeMarkdownCode(effective.Recommendation), format.EscapeMarkdown(effectiveList.Name))
+				default:
+					outcome = "**wins**"
+					effective, effectiveList = policy, list
+				}
+				buf.WriteString(formatWhyPolicy(policy, describeUserMatchKind(policy), outcome))
+				buf.WriteByte('\n')
+			}
+			for _, policy := range serverMatch {
+				var outcome string
+				switch {
+				case !policylist.IsBanOrUnban(policy.Recommendation):
+					outcome = "doesn't affect bans"
+				case list.DontApply || list.DontApplyACL:
+					outcome = "ignored, list isn't applied to server ACLs"
+				case effectiveServer != nil:
+					outcome = fmt.Sprintf("overridden by %s in %s", format.SafeMarkdownCode(effectiveServer.Recommendation), format.EscapeMarkdown(effectiveServerList.Name))
+				default:
+					outcome = "**wins** for the server ACL"
+					effectiveServer, effectiveServerList = policy, list
+				}
+				buf.WriteString(formatWhyPolicy(policy, "server policy", outcome))
+				buf.WriteByte('\n')
+			}
+		}
+		if matchedLists == 0 {
+			buf.WriteString("* No policies match the user\n")
+		}
+		buf.WriteString("\nVerdict: ")
+		switch {
+		case effective == nil:
+			buf.WriteString("no user policy applies")
+		case effective.Recommendation == event.PolicyRecommendationUnban:
+			_, _ = fmt.Fprintf(&buf, "exempt from bans, the unban policy in %s takes precedence over any lower priority bans", format.EscapeMarkdown(effectiveList.Name))
+		case effective.Recommendation == event.PolicyRecommendationUnstableTakedown:
+			_, _ = fmt.Fprintf(&buf, "banned and content removed due to the takedown policy in %s", format.EscapeMarkdown(effectiveList.Name))
+		default:
+			_, _ = fmt.Fprintf(&buf, "banned due to the policy in %s", format.EscapeMarkdown(effectiveList.Name))
+		}
+		if effectiveServer != nil && effectiveServer.Recommendation != event.PolicyRecommendationUnban {
+			_, _ = fmt.Fprintf(&buf, ", and the user's server %s is denied by server ACLs", format.SafeMarkdownCode(server))
+		}
+		buf.WriteString("\n\n")
+
+		joinedRooms := ce.Meta.getRoomsUserIsIn(userID)
+		var bannedRooms []string
+		for _, roomID := range ce.Meta.GetProtectedRooms() {
+			if ce.Meta.Bot.StateStore.IsMembership(ce.Ctx, roomID, userID, event.MembershipBan) {
+				bannedRooms = append(bannedRooms, fmt.Sprintf("[%s](%s)", format.EscapeMarkdown(ce.Meta.getProtectedRoomName(roomID)), roomID.URI().MatrixToURL()))
+			}
+		}
+		slices.Sort(bannedRooms)
+		if len(joinedRooms) == 0 {
+			buf.WriteString("The user is not in any protected rooms.")
+		} else {
+			formattedRooms := make([]string, len(joinedRooms))
+			for i, roomID := range joinedRooms {
+				formattedRooms[i] = fmt.Sprintf("[%s](%s)", format.EscapeMarkdown(ce.Meta.getProtectedRoomName(roomID)), roomID.URI().MatrixToURL())
+			}
+			_, _ = fmt.Fprintf(&buf, "The user is in %s: %s", pluralize(len(joinedRooms), "protected room"), strings.Join(formattedRooms, ", "))
+		}
+		if len(bannedRooms) > 0 {
+			_, _ = fmt.Fprintf(&buf, "\n\nThe user is already banned in %s: %s", pluralize(len(bannedRooms), "protected room"), strings.Join(bannedRooms, ", "))
+		}
+		buf.WriteString(ce.Meta.formatEntityNotesSuffix(ce.Ctx, userID.String()))
+		buf.WriteString(ce.Meta.formatEntityNotesSuffix(ce.Ctx, server))
+		ce.Reply("%s", buf.String())
+	},
+}
@@ -0,0 +1,25 @@
+package policylist
+
+import (
+	"reflect"
+
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+)
+
+// StatePolicyContent is the state event type for content policies. Content policies use the same content as other
+// policy events, but the entity is a glob (or regex, see UnstableRegexKey) that is matched against message bodies.
+var StatePolicyContent = event.Type{Type: "fi.mau.meowlnir.rule.content", Class: event.StateEventType}
+
+// UnstableBanSenderKey is the content key used to mark content policies that should also ban the sender
+// of matching messages instead of only redacting the message.
+const UnstableBanSenderKey = "fi.mau.meowlnir.ban_sender"
+
+func init() {
+	event.TypeMap[StatePolicyContent] = reflect.TypeOf(event.ModPolicyContent{})
+}
+
+// MatchContent finds all content policies in the given lists that match the given message body.
+func (s *Store) MatchContent(listIDs []id.RoomID, body string) Match {
+	return s.match(listIDs, body, (*Room).GetContentRules)
+}
@@ -0,0 +1,85 @@
+package policylist
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/meowlnir/util"
+)
+
+const testListID id.RoomID = "!list:example.com"
+
+// newTestPolicyEvent returns a ban policy state event for the given entity, which is parsed like events from a real list.
+// If hashed is true, the policy only contains the hash of the entity. Extra keys (e.g. UnstableRegexKey) are added to the raw content.
+func newTestPolicyEvent(evtType event.Type, stateKey, entity string, hashed bool, extra map[string]any) *event.Event {
+	content := &event.ModPolicyContent{
+		Entity:         entity,
+		Recommendation: event.PolicyRecommendationBan,
+	}
+	if hashed {
+		hash := util.SHA256String(entity)
+		content.Entity = ""
+		content.UnstableHashes = &event.PolicyHashes{SHA256: base64.StdEncoding.EncodeToString(hash[:])}
+	}
+	return &event.Event{
+		Type:     evtType,
+		StateKey: &stateKey,
+		RoomID:   testListID,
+		ID:       id.EventID("$" + stateKey),
+		Sender:   "@admin:example.com",
+		Content:  event.Content{Parsed: content, Raw: extra},
+	}
+}
+
+// setTestFoldUserIDCase sets FoldUserIDCase for the duration of the test. It must be called before creating lists.
+func setTestFoldUserIDCase(t *testing.T, foldCase bool) {
+	t.Helper()
+	oldFold := FoldUserIDCase
+	FoldUserIDCase = foldCase
+	t.Cleanup(func() { FoldUserIDCase = oldFold })
+}
+
+// newTestRoom returns a policy room containing the given policy events.
+func newTestRoom(t *testing.T, foldCase bool, evts ...*event.Event) *Room {
+	t.Helper()
+	setTestFoldUserIDCase(t, foldCase)
+	room := NewRoom(testListID)
+	for _, evt := range evts {
+		if added, _ := room.Update(evt); added == nil {
+			t.Fatalf("Failed to add policy %s", *evt.StateKey)
+		}
+	}
+	return room
+}
+
+// newTestStore returns a policy store containing a single list with the given policy events.
+func newTestStore(t *testing.T, foldCase bool, evts ...*event.Event) *Store {
+	t.Helper()
+	setTestFoldUserIDCase(t, foldCase)
+	state := make(map[event.Type]map[string]*event.Event)
+	for _, evt := range evts {
+		if state[evt.Type] == nil {
+			state[evt.Type] = make(map[string]*event.Event)
+		}
+		state[evt.Type][*evt.StateKey] = evt
+	}
+	store := NewStore()
+	store.Add(testListID, state)
+	return store
+}
+
+func assertStateKeys(t *testing.T, fn string, match Match, want []string) {
+	t.Helper()
+	if len(match) != len(want) {
+		t.Errorf("%s returned %d policies, expected %d", fn, len(match), len(want))
+		return
+	}
+	for i, policy := range match {
+		if policy.StateKey != want[i] {
+			t.Errorf("%s returned policy %s at index %d, expected %s", fn, policy.StateKey, i, want[i])
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package policylist
 
 import (
+	"strings"
 	"sync"
 	"time"
 
@@ -15,8 +16,40 @@ import (
 
 type dplNode struct {
 	*Policy
-	prev *dplNode
-	next *dplNode
+	pattern glob.Glob
+	prev    *dplNode
+	next    *dplNode
+}
+
+// FoldUserIDCase makes user policies match case-insensitively by lowercasing the localpart
+// of both the policy entity and the user ID being matched. It must be set before any lists are created.
+var FoldUserIDCase bool
+
+func foldUserIDCase(entity string) string {
+	idx := strings.IndexByte(entity, ':')
+	if idx < 0 {
+		return strings.ToLower(entity)
+	}
+	return strings.ToLower(entity[:idx]) + entity[idx:]
+}
+
+type foldedGlob struct {
+	glob.Glob
+}
+
+func (fg foldedGlob) Match(entity string) bool {
+	return fg.Glob.Match(foldUserIDCase(entity))
+}
+
+// foldedRegex matches a regex policy against both the original and the case-folded entity.
+// The regex itself can't be folded like globs, as lowercasing it could change its meaning,
+// so this makes lowercase regexes match any case while regexes containing uppercase still match as written.
+type foldedRegex struct {
+	glob.Glob
+}
+
+func (fr foldedRegex) Match(entity string) bool {
+	return fr.Glob.Match(entity) || fr.Glob.Match(foldUserIDCase(entity))
 }
 
 // List represents the list of rules for a single entity type.
@@ -29,21 +62,33 @@ type List struct {
 	byEntity      map[string]*dplNode
 	byEntityHash  map[[util.HashSize]byte]*dplNode
 	dynamicHead   *dplNode
+	normalize     func(string) string
 	lock          sync.RWMutex
 }
 
 func NewList(roomID id.RoomID, entityType string) *List {
-	return &List{
+	l := &List{
 		matchDuration: matchDuration.WithLabelValues(roomID.String(), entityType),
 		byStateKey:    make(map[string]*dplNode),
 		byEntity:      make(map[string]*dplNode),
 		byEntityHash:  make(map[[util.HashSize]byte]*dplNode),
 	}
+	if entityType == string(EntityTypeUser) && FoldUserIDCase {
+		l.normalize = foldUserIDCase
+	}
+	return l
+}
+
+func (l *List) normalizeEntity(entity string) string {
+	if l.normalize == nil {
+		return entity
+	}
+	return l.normalize(entity)
 }
 
 func typeQuality(evtType event.Type) int {
 	switch evtType {
-	case event.StatePolicyUser, event.StatePolicyRoom, event.StatePolicyServer:
+	case event.StatePolicyUser, event.StatePolicyRoom, event.StatePolicyServer, StatePolicyContent, StatePolicyMedia:
 		return 5
 	case event.StateLegacyPolicyUser, event.StateLegacyPolicyRoom, event.StateLegacyPolicyServer:
 		return 4
@@ -83,17 +128,24 @@ func (l *List) Add(value *Policy) (*Policy, bool) {
 		// There's an existing event with the same state key, but the entity changed, remove the old node.
 		l.removeFromLinkedList(existing)
 		if existing.Entity != "" {
-			delete(l.byEntity, existing.Entity)
+			delete(l.byEntity, l.normalizeEntity(existing.Entity))
 		}
 		if existing.EntityHash != nil {
 			delete(l.byEntityHash, *existing.EntityHash)
 		}
 	}
-	node := &dplNode{Policy: value}
+	node := &dplNode{Policy: value, pattern: value.Pattern}
+	if l.normalize != nil && value.Entity != "" {
+		if value.IsRegex {
+			node.pattern = foldedRegex{value.Pattern}
+		} else {
+			node.pattern = foldedGlob{glob.Compile(l.normalize(value.Entity))}
+		}
+	}
 	l.byStateKey[value.StateKey] = node
 	if !value.Ignored {
 		if value.Entity != "" {
-			l.byEntity[value.Entity] = node
+			l.byEntity[l.normalizeEntity(value.Entity)] = node
 		}
 		if value.EntityHash != nil {
 			l.byEntityHash[*value.EntityHash] = node
@@ -117,8 +169,9 @@ func (l *List) Remove(eventType event.Type, stateKey string) *Policy {
 	defer l.lock.Unlock()
 	if value, ok := l.byStateKey[stateKey]; ok && eventType == value.Type {
 		l.removeFromLinkedList(value)
-		if entValue, ok := l.byEntity[value.Entity]; ok && entValue == value && value.Entity != "" {
-			delete(l.byEntity, value.Entity)
+		normalizedEntity := l.normalizeEntity(value.Entity)
+		if entValue, ok := l.byEntity[normalizedEntity]; ok && entValue == value && value.Entity != "" {
+			delete(l.byEntity, normalizedEntity)
 		}
 		if value.EntityHash != nil {
 			if entHashValue, ok := l.byEntityHash[*value.EntityHash]; ok && entHashValue == value {
@@ -149,15 +202,14 @@ func (l *List) Match(entity string) (output Match) {
 	l.lock.RLock()
 	defer l.lock.RUnlock()
 	start := time.Now()
-	exactMatch, ok := l.byEntity[entity]
+	normalizedEntity := l.normalizeEntity(entity)
+	exactMatch, ok := l.byEntity[normalizedEntity]
 	if ok {
 		output = Match{exactMatch.Policy}
 	}
-	if value, ok := l.byEntityHash[util.SHA256String(entity)]; ok {
-		output = append(output, value.Policy)
-	}
+	output = l.appendHashMatches(output, entity, normalizedEntity)
 	for item := l.dynamicHead; item != nil; item = item.next {
-		if !item.Ignored && item.Pattern.Match(entity) && item != exactMatch {
+		if !item.Ignored && item.pattern.Match(entity) && item != exactMatch {
 			output = append(output, item.Policy)
 		}
 	}
@@ -171,13 +223,24 @@ func (l *List) MatchExact(entity string) (output Match) {
 	}
 	l.lock.RLock()
 	defer l.lock.RUnlock()
-	if value, ok := l.byEntity[entity]; ok {
+	normalizedEntity := l.normalizeEntity(entity)
+	if value, ok := l.byEntity[normalizedEntity]; ok {
 		output = Match{value.Policy}
 	}
-	if value, ok := l.byEntityHash[util.SHA256String(entity)]; ok {
+	return l.appendHashMatches(output, entity, normalizedEntity)
+}
+
+func (l *List) appendHashMatches(output Match, entity, normalizedEntity string) Match {
+	value, ok := l.byEntityHash[util.SHA256String(entity)]
+	if ok {
 		output = append(output, value.Policy)
 	}
-	return
+	if normalizedEntity != entity {
+		if normalizedValue, ok := l.byEntityHash[util.SHA256String(normalizedEntity)]; ok && normalizedValue != value {
+			output = append(output, normalizedValue.Policy)
+		}
+	}
+	return output
 }
 
 func (l *List) MatchHash(hash [util.HashSize]byte) (output Match) {
@@ -199,3 +262,42 @@ func (l *List) Search(patternString string, pattern glob.Glob) (output Match) {
 	}
 	return
 }
+
+func (l *List) GetExpired(now time.Time) (output Match) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	for _, item := range l.byStateKey {
+		if item.IsExpired(now) {
+			output = append(output, item.Policy)
+		}
+	}
+	return
+}
+
+// GetAll returns all policies in the list, including ignored ones.
+func (l *List) GetAll() (output Match) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	output = make(Match, 0, len(l.byStateKey))
+	for _, item := range l.byStateKey {
+		output = append(output, item.Policy)
+	}
+	return
+}
+
+// Len returns the number of policies in the list, including ignored ones.
+func (l *List) Len() int {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return len(l.byStateKey)
+}
+
+func (l *List) CountByRecommendation() map[event.PolicyRecommendation]int {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	output := make(map[event.PolicyRecommendation]int)
+	for _, item := range l.byStateKey {
+		output[item.Recommendation]++
+	}
+	return output
+}
@@ -0,0 +1,60 @@
+package policylist
+
+import (
+	"testing"
+
+	"maunium.net/go/mautrix/event"
+
+	"go.mau.fi/meowlnir/util"
+)
+
+func TestList_FoldUserIDCase(t *testing.T) {
+	policies := []*event.Event{
+		newTestPolicyEvent(event.StatePolicyUser, "exact", "@Alice:example.com", false, nil),
+		newTestPolicyEvent(event.StatePolicyUser, "glob", "@spam*:example.com", false, nil),
+		newTestPolicyEvent(event.StatePolicyUser, "hash", "@bob:example.com", true, nil),
+		newTestPolicyEvent(event.StatePolicyUser, "mixed-hash", "@Carol:example.com", true, nil),
+		newTestPolicyEvent(event.StatePolicyUser, "regex", `@Eve[0-9]+:example\.com`, false, map[string]any{UnstableRegexKey: true}),
+		newTestPolicyEvent(event.StatePolicyUser, "lower-regex", `@mallory[0-9]+:example\.com`, false, map[string]any{UnstableRegexKey: true}),
+	}
+	tests := []struct {
+		name      string
+		entity    string
+		foldCase  bool
+		wantMatch []string
+		wantExact []string
+	}{
+		{"exact same case", "@Alice:example.com", false, []string{"exact"}, []string{"exact"}},
+		{"exact other case without folding", "@alice:example.com", false, nil, nil},
+		{"exact other case with folding", "@ALICE:example.com", true, []string{"exact"}, []string{"exact"}},
+		{"server name is not folded", "@alice:Example.com", true, nil, nil},
+		{"glob other case without folding", "@SPAMMER:example.com", false, nil, nil},
+		{"glob other case with folding", "@SPAMMER:example.com", true, []string{"glob"}, nil},
+		{"hash same case", "@bob:example.com", false, []string{"hash"}, []string{"hash"}},
+		{"hash other case without folding", "@Bob:example.com", false, nil, nil},
+		{"hash other case with folding", "@Bob:example.com", true, []string{"hash"}, []string{"hash"}},
+		{"mixed case hash with folding", "@Carol:example.com", true, []string{"mixed-hash"}, []string{"mixed-hash"}},
+		{"mixed case hash other case with folding", "@carol:example.com", true, nil, nil},
+		{"uppercase regex same case without folding", "@Eve123:example.com", false, []string{"regex"}, nil},
+		{"uppercase regex same case with folding", "@Eve123:example.com", true, []string{"regex"}, nil},
+		{"uppercase regex other case with folding", "@eve123:example.com", true, nil, nil},
+		{"lowercase regex other case without folding", "@Mallory1:example.com", false, nil, nil},
+		{"lowercase regex other case with folding", "@Mallory1:example.com", true, []string{"lower-regex"}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			list := newTestRoom(t, test.foldCase, policies...).UserRules
+			assertStateKeys(t, "Match", list.Match(test.entity), test.wantMatch)
+			assertStateKeys(t, "MatchExact", list.MatchExact(test.entity), test.wantExact)
+		})
+	}
+}
+
+func TestList_MatchHash(t *testing.T) {
+	for _, foldCase := range []bool{false, true} {
+		list := newTestRoom(t, foldCase, newTestPolicyEvent(event.StatePolicyUser, "hash", "@bob:example.com", true, nil)).UserRules
+		assertStateKeys(t, "MatchHash", list.MatchHash(util.SHA256String("@bob:example.com")), []string{"hash"})
+		// Hash lookups can't be normalized, so only the exact hash matches
+		assertStateKeys(t, "MatchHash", list.MatchHash(util.SHA256String("@Bob:example.com")), nil)
+	}
+}
@@ -0,0 +1,43 @@
+package policylist
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"reflect"
+
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+)
+
+// StatePolicyMedia is the state event type for media policies. Media policies use the same content as other
+// policy events, but the entity is the SHA-256 hash of a media file (see HashMedia).
+var StatePolicyMedia = event.Type{Type: "fi.mau.meowlnir.rule.media", Class: event.StateEventType}
+
+func init() {
+	event.TypeMap[StatePolicyMedia] = reflect.TypeOf(event.ModPolicyContent{})
+}
+
+// HashMedia returns the entity used in media policies for the given file.
+func HashMedia(data []byte) string {
+	hash := sha256.Sum256(data)
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// MatchMedia finds all media policies in the given lists for the given media hash.
+func (s *Store) MatchMedia(listIDs []id.RoomID, mediaHash string) Match {
+	return s.MatchExact(listIDs, EntityTypeMedia, mediaHash)
+}
+
+// HasMediaPolicies returns true if any of the given lists contain media policies.
+// It's used to avoid downloading media for hashing when there's nothing to match against.
+func (s *Store) HasMediaPolicies(listIDs []id.RoomID) bool {
+	for _, roomID := range listIDs {
+		s.roomsLock.RLock()
+		list, ok := s.rooms[roomID]
+		s.roomsLock.RUnlock()
+		if ok && list.GetMediaRules().Len() > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,8 @@
 package policylist
 
 import (
+	"time"
+
 	"go.mau.fi/util/glob"
 	"maunium.net/go/mautrix/event"
 	"maunium.net/go/mautrix/id"
@@ -22,13 +24,44 @@ type Policy struct {
 	Timestamp  int64
 	ID         id.EventID
 	Ignored    bool
+	// IsRegex is true if the entity is a regular expression (see UnstableRegexKey) rather than a glob.
+	IsRegex bool
+	// Expiry is the unix millisecond timestamp after which the policy should be removed, or 0 if it doesn't expire.
+	Expiry int64
+	// BanSender is true if the senders of messages matching a content policy should be banned
+	// in addition to redacting the message (see UnstableBanSenderKey).
+	BanSender bool
+}
+
+// UnstableExpiryKey is the content key used to store the expiry timestamp of temporary policies.
+const UnstableExpiryKey = "fi.mau.meowlnir.expiry"
+
+// IsExpired returns true if the policy has an expiry timestamp that is before the given time.
+func (p *Policy) IsExpired(now time.Time) bool {
+	return p.Expiry != 0 && p.Expiry <= now.UnixMilli()
+}
+
+// UserMatchPattern returns the pattern that should be used to find users affected by this policy.
+// It's the same as Pattern unless FoldUserIDCase is enabled.
+func (p *Policy) UserMatchPattern() glob.Glob {
+	if !FoldUserIDCase || p.Entity == "" {
+		return p.Pattern
+	} else if p.IsRegex {
+		return foldedRegex{p.Pattern}
+	}
+	return foldedGlob{glob.Compile(foldUserIDCase(p.Entity))}
 }
 
 // Match represent a list of policies that matched a specific entity.
 type Match []*Policy
 
+// UnstableRecommendationWarn is a recommendation that doesn't ban matching users,
+// but flags them to moderators when they join a protected room.
+const UnstableRecommendationWarn event.PolicyRecommendation = "fi.mau.meowlnir.warn"
+
 type Recommendations struct {
 	BanOrUnban *Policy
+	Warn       *Policy
 }
 
 func (r Recommendations) String() string {
@@ -38,14 +71,37 @@ func (r Recommendations) String() string {
 	return ""
 }
 
+// PolicyList returns the room ID of the policy list that the winning ban or unban policy came from,
+// or an empty string if there was no such policy.
+func (r Recommendations) PolicyList() id.RoomID {
+	if r.BanOrUnban != nil {
+		return r.BanOrUnban.RoomID
+	}
+	return ""
+}
+
+// IsBanOrUnban returns true if the given recommendation is one of the recommendations
+// aggregated into [Recommendations.BanOrUnban].
+func IsBanOrUnban(rec event.PolicyRecommendation) bool {
+	switch rec {
+	case event.PolicyRecommendationBan, event.PolicyRecommendationUnban, event.PolicyRecommendationUnstableTakedown:
+		return true
+	default:
+		return false
+	}
+}
+
 // Recommendations aggregates the recommendations in the match.
+//
+// The first ban, unban or takedown policy in the match wins, which means the order of the match
+// (list priority, then exact matches, hashes and patterns within each list) determines the result.
+// Warn policies are tracked separately, as they don't affect bans.
 func (m Match) Recommendations() (output Recommendations) {
 	for _, policy := range m {
-		switch policy.Recommendation {
-		case event.PolicyRecommendationBan, event.PolicyRecommendationUnban, event.PolicyRecommendationUnstableTakedown:
-			if output.BanOrUnban == nil {
-				output.BanOrUnban = policy
-			}
+		if IsBanOrUnban(policy.Recommendation) && output.BanOrUnban == nil {
+			output.BanOrUnban = policy
+		} else if policy.Recommendation == UnstableRecommendationWarn && output.Warn == nil {
+			output.Warn = policy
 		}
 	}
 	return
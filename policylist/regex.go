@@ -0,0 +1,59 @@
+package policylist
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+
+	"go.mau.fi/util/glob"
+)
+
+// UnstableRegexKey is the content key used to mark policies whose entity is a regular expression instead of a glob.
+const UnstableRegexKey = "fi.mau.meowlnir.regex"
+
+const (
+	// MaxRegexLength is the maximum length of a regex entity.
+	MaxRegexLength = 256
+	// maxRegexNodes is the maximum number of nodes in the parsed syntax tree of a regex entity.
+	maxRegexNodes = 200
+)
+
+type regexGlob struct {
+	*regexp.Regexp
+}
+
+func (rg regexGlob) Match(s string) bool {
+	return rg.MatchString(s)
+}
+
+// CompileEntityRegex compiles a regex entity into a glob-compatible matcher. The regex is always anchored to
+// match the whole entity. Go's regexp engine runs in linear time, so catastrophic backtracking isn't possible,
+// but overly long or complex patterns are still rejected to keep matching against every policy cheap.
+func CompileEntityRegex(pattern string) (glob.Glob, error) {
+	if len(pattern) > MaxRegexLength {
+		return nil, fmt.Errorf("regex is too long (%d > %d characters)", len(pattern), MaxRegexLength)
+	}
+	parsed, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex: %w", err)
+	} else if nodes := countRegexNodes(parsed); nodes > maxRegexNodes {
+		return nil, fmt.Errorf("regex is too complex (%d > %d nodes)", nodes, maxRegexNodes)
+	}
+	compiled, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex: %w", err)
+	}
+	return regexGlob{compiled}, nil
+}
+
+func countRegexNodes(re *syntax.Regexp) int {
+	count := 1
+	for _, sub := range re.Sub {
+		count += countRegexNodes(sub)
+	}
+	// Bounded repetitions are expanded by the compiler, so count them as multiple nodes
+	if re.Op == syntax.OpRepeat && re.Max > 1 {
+		count *= re.Max
+	}
+	return count
+}
@@ -22,18 +22,24 @@ type Room struct {
 	UserRules   *List
 	RoomRules   *List
 	ServerRules *List
-	mapLock     sync.RWMutex
-	byEventID   map[id.EventID]typeStateKeyTuple
+	// ContentRules are Meowlnir-specific policies that match message bodies instead of entities.
+	ContentRules *List
+	// MediaRules are Meowlnir-specific policies whose entity is the SHA-256 hash of a media file.
+	MediaRules *List
+	mapLock    sync.RWMutex
+	byEventID  map[id.EventID]typeStateKeyTuple
 }
 
 // NewRoom creates a new store for a single policy room.
 func NewRoom(roomID id.RoomID) *Room {
 	return &Room{
-		RoomID:      roomID,
-		UserRules:   NewList(roomID, "user"),
-		RoomRules:   NewList(roomID, "room"),
-		ServerRules: NewList(roomID, "server"),
-		byEventID:   make(map[id.EventID]typeStateKeyTuple),
+		RoomID:       roomID,
+		UserRules:    NewList(roomID, "user"),
+		RoomRules:    NewList(roomID, "room"),
+		ServerRules:  NewList(roomID, "server"),
+		ContentRules: NewList(roomID, "content"),
+		MediaRules:   NewList(roomID, "media"),
+		byEventID:    make(map[id.EventID]typeStateKeyTuple),
 	}
 }
 
@@ -49,6 +55,14 @@ func (r *Room) GetServerRules() *List {
 	return r.ServerRules
 }
 
+func (r *Room) GetContentRules() *List {
+	return r.ContentRules
+}
+
+func (r *Room) GetMediaRules() *List {
+	return r.MediaRules
+}
+
 type EntityType string
 
 func (et EntityType) EventType() event.Type {
@@ -59,6 +73,10 @@ func (et EntityType) EventType() event.Type {
 		return event.StatePolicyRoom
 	case EntityTypeServer:
 		return event.StatePolicyServer
+	case EntityTypeContent:
+		return StatePolicyContent
+	case EntityTypeMedia:
+		return StatePolicyMedia
 	}
 	return event.Type{}
 }
@@ -67,8 +85,29 @@ const (
 	EntityTypeUser   EntityType = "user"
 	EntityTypeRoom   EntityType = "room"
 	EntityTypeServer EntityType = "server"
+	// EntityTypeContent is the entity type of content policies, whose entity is a glob or regex for message bodies.
+	EntityTypeContent EntityType = "content"
+	// EntityTypeMedia is the entity type of media policies, whose entity is the base64 SHA-256 hash of a file.
+	EntityTypeMedia EntityType = "media"
 )
 
+// EntityTypeFromEventType returns the entity type of the given policy event type, including legacy and unstable types.
+func EntityTypeFromEventType(evtType event.Type) (EntityType, bool) {
+	switch evtType {
+	case event.StatePolicyUser, event.StateLegacyPolicyUser, event.StateUnstablePolicyUser:
+		return EntityTypeUser, true
+	case event.StatePolicyRoom, event.StateLegacyPolicyRoom, event.StateUnstablePolicyRoom:
+		return EntityTypeRoom, true
+	case event.StatePolicyServer, event.StateLegacyPolicyServer, event.StateUnstablePolicyServer:
+		return EntityTypeServer, true
+	case StatePolicyContent:
+		return EntityTypeContent, true
+	case StatePolicyMedia:
+		return EntityTypeMedia, true
+	}
+	return "", false
+}
+
 // Update updates the state of this object with the given policy event.
 //
 // It returns the added and removed/replaced policies, if any.
@@ -83,6 +122,10 @@ func (r *Room) Update(evt *event.Event) (added, removed *Policy) {
 		added, removed = r.updatePolicyList(evt, EntityTypeRoom, r.RoomRules)
 	case event.StatePolicyServer, event.StateLegacyPolicyServer, event.StateUnstablePolicyServer:
 		added, removed = r.updatePolicyList(evt, EntityTypeServer, r.ServerRules)
+	case StatePolicyContent:
+		added, removed = r.updatePolicyList(evt, EntityTypeContent, r.ContentRules)
+	case StatePolicyMedia:
+		added, removed = r.updatePolicyList(evt, EntityTypeMedia, r.MediaRules)
 	case event.EventRedaction:
 		redacts := evt.Redacts
 		if redacts == "" {
@@ -99,6 +142,10 @@ func (r *Room) Update(evt *event.Event) (added, removed *Policy) {
 				removed = r.RoomRules.Remove(target.Type, target.StateKey)
 			case event.StatePolicyServer, event.StateLegacyPolicyServer, event.StateUnstablePolicyServer:
 				removed = r.ServerRules.Remove(target.Type, target.StateKey)
+			case StatePolicyContent:
+				removed = r.ContentRules.Remove(target.Type, target.StateKey)
+			case StatePolicyMedia:
+				removed = r.MediaRules.Remove(target.Type, target.StateKey)
 			}
 		}
 	}
@@ -116,6 +163,8 @@ func (r *Room) ParseState(state map[event.Type]map[string]*event.Event) *Room {
 	r.massUpdatePolicyList(userPolicies, EntityTypeUser, r.UserRules)
 	r.massUpdatePolicyList(roomPolicies, EntityTypeRoom, r.RoomRules)
 	r.massUpdatePolicyList(serverPolicies, EntityTypeServer, r.ServerRules)
+	r.massUpdatePolicyList(state[StatePolicyContent], EntityTypeContent, r.ContentRules)
+	r.massUpdatePolicyList(state[StatePolicyMedia], EntityTypeMedia, r.MediaRules)
 	return r
 }
 
@@ -180,8 +229,24 @@ func (r *Room) updatePolicyList(evt *event.Event, entityType EntityType, rules *
 		Timestamp:        evt.Timestamp,
 		ID:               evt.ID,
 	}
+	if expiry, ok := evt.Content.Raw[UnstableExpiryKey].(float64); ok {
+		added.Expiry = int64(expiry)
+	}
+	if entityType == EntityTypeContent {
+		added.BanSender, _ = evt.Content.Raw[UnstableBanSenderKey].(bool)
+	}
 	if entityHash != nil {
 		added.Pattern = (*hashGlob)(entityHash)
+	} else if isRegex, _ := evt.Content.Raw[UnstableRegexKey].(bool); isRegex {
+		added.IsRegex = true
+		regexPattern, err := CompileEntityRegex(content.Entity)
+		if err != nil {
+			// Invalid regexes are stored, but never match anything
+			added.Pattern = glob.ExactGlob(content.Entity)
+			added.Ignored = true
+		} else {
+			added.Pattern = regexPattern
+		}
 	}
 	if added.Recommendation == event.PolicyRecommendationBan {
 		if added.EntityHash != nil {
@@ -4,8 +4,12 @@ import (
 	"maps"
 	"regexp"
 	"slices"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"go.mau.fi/util/glob"
 	"maunium.net/go/mautrix/event"
 	"maunium.net/go/mautrix/id"
@@ -27,17 +31,40 @@ func NewStore() *Store {
 	}
 }
 
+var storeMatchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name: "meowlnir_store_match_duration_nanoseconds",
+	Help: "Time taken to evaluate an entity against all policies in the requested lists",
+	Buckets: []float64{
+		// 1µs - 100µs
+		1_000, 5_000, 10_000, 25_000, 50_000, 75_000, 100_000,
+		// 250µs - 10ms
+		250_000, 500_000, 750_000, 1_000_000, 5_000_000, 10_000_000,
+	},
+}, []string{"entity_type"})
+
+var (
+	userMatchDuration   = storeMatchDuration.WithLabelValues(string(EntityTypeUser))
+	roomMatchDuration   = storeMatchDuration.WithLabelValues(string(EntityTypeRoom))
+	serverMatchDuration = storeMatchDuration.WithLabelValues(string(EntityTypeServer))
+)
+
 // MatchUser finds all matching policies for the given user ID in the given policy rooms.
 func (s *Store) MatchUser(listIDs []id.RoomID, userID id.UserID) Match {
+	defer observeDuration(userMatchDuration, time.Now())
 	return s.match(listIDs, string(userID), (*Room).GetUserRules)
 }
 
 // MatchRoom finds all matching policies for the given room ID in the given policy rooms.
 // If no matches are found, nil is returned.
 func (s *Store) MatchRoom(listIDs []id.RoomID, roomID id.RoomID) Match {
+	defer observeDuration(roomMatchDuration, time.Now())
 	return s.match(listIDs, string(roomID), (*Room).GetRoomRules)
 }
 
+func observeDuration(observer prometheus.Observer, start time.Time) {
+	observer.Observe(float64(time.Since(start)))
+}
+
 var portRegex = regexp.MustCompile(`:\d+$`)
 var ipRegex = regexp.MustCompile(`^(?:\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3})|(?:\[[0-9a-fA-F:.]+\])$`)
 var fakeBanForIPLiterals = &Policy{
@@ -59,6 +86,7 @@ func IsIPLiteral(serverName string) bool {
 
 // MatchServer finds all matching policies for the given server name in the given policy rooms.
 func (s *Store) MatchServer(listIDs []id.RoomID, serverName string) Match {
+	defer observeDuration(serverMatchDuration, time.Now())
 	serverName = CleanupServerNameForMatch(serverName)
 	if IsIPLiteral(serverName) {
 		return Match{fakeBanForIPLiterals}
@@ -70,6 +98,27 @@ func (s *Store) ListServerRules(listIDs []id.RoomID) map[string]*Policy {
 	return s.compileList(listIDs, (*Room).GetServerRules)
 }
 
+// ListServerHashRules returns the hashed server policies in the given policy rooms, keyed by hash.
+// Like ListServerRules, policies in higher priority lists take precedence.
+func (s *Store) ListServerHashRules(listIDs []id.RoomID) map[[util.HashSize]byte]*Policy {
+	output := make(map[[util.HashSize]byte]*Policy)
+	for _, roomID := range slices.Backward(listIDs) {
+		s.roomsLock.RLock()
+		list, ok := s.rooms[roomID]
+		s.roomsLock.RUnlock()
+		if !ok {
+			continue
+		}
+		rules := list.GetServerRules()
+		rules.lock.RLock()
+		for hash, policy := range rules.byEntityHash {
+			output[hash] = policy.Policy
+		}
+		rules.lock.RUnlock()
+	}
+	return output
+}
+
 // Update updates the store with the given policy event.
 //
 // The provided event will be ignored if it belongs to a room that is not tracked by this store,
@@ -84,7 +133,7 @@ func (s *Store) Update(evt *event.Event) (added, removed *Policy) {
 	case event.StatePolicyUser, event.StateLegacyPolicyUser, event.StateUnstablePolicyUser,
 		event.StatePolicyRoom, event.StateLegacyPolicyRoom, event.StateUnstablePolicyRoom,
 		event.StatePolicyServer, event.StateLegacyPolicyServer, event.StateUnstablePolicyServer,
-		event.EventRedaction:
+		StatePolicyContent, StatePolicyMedia, event.EventRedaction:
 	default:
 		return
 	}
@@ -155,6 +204,10 @@ func (s *Store) matchExactFunc(listIDs []id.RoomID, entityType EntityType, fn fu
 			rules = list.GetRoomRules()
 		case EntityTypeServer:
 			rules = list.GetServerRules()
+		case EntityTypeContent:
+			rules = list.GetContentRules()
+		case EntityTypeMedia:
+			rules = list.GetMediaRules()
 		}
 		output = append(output, fn(rules)...)
 	}
@@ -190,6 +243,92 @@ func (s *Store) Search(listIDs []id.RoomID, entity string) (output Match) {
 		output = append(output, list.GetUserRules().Search(entity, entityGlob)...)
 		output = append(output, list.GetRoomRules().Search(entity, entityGlob)...)
 		output = append(output, list.GetServerRules().Search(entity, entityGlob)...)
+		output = append(output, list.GetContentRules().Search(entity, entityGlob)...)
+		output = append(output, list.GetMediaRules().Search(entity, entityGlob)...)
+	}
+	return
+}
+
+// SearchReason finds all non-ignored policies whose reason contains the given text, ignoring case.
+func (s *Store) SearchReason(listIDs []id.RoomID, text string) (output Match) {
+	if listIDs == nil {
+		s.roomsLock.RLock()
+		listIDs = slices.Collect(maps.Keys(s.rooms))
+		s.roomsLock.RUnlock()
+	}
+	text = strings.ToLower(text)
+	for _, roomID := range listIDs {
+		for _, policy := range s.GetAllPolicies(roomID) {
+			if !policy.Ignored && strings.Contains(strings.ToLower(policy.Reason), text) {
+				output = append(output, policy)
+			}
+		}
+	}
+	return
+}
+
+// PolicyCounts contains the number of policies with each recommendation for each entity type.
+type PolicyCounts map[EntityType]map[event.PolicyRecommendation]int
+
+// CountPolicies counts the policies in the given policy room, or returns nil if the room isn't in the store.
+func (s *Store) CountPolicies(roomID id.RoomID) PolicyCounts {
+	s.roomsLock.RLock()
+	list, ok := s.rooms[roomID]
+	s.roomsLock.RUnlock()
+	if !ok {
+		return nil
+	}
+	return PolicyCounts{
+		EntityTypeUser:    list.GetUserRules().CountByRecommendation(),
+		EntityTypeRoom:    list.GetRoomRules().CountByRecommendation(),
+		EntityTypeServer:  list.GetServerRules().CountByRecommendation(),
+		EntityTypeContent: list.GetContentRules().CountByRecommendation(),
+		EntityTypeMedia:   list.GetMediaRules().CountByRecommendation(),
+	}
+}
+
+// Add adds the counts from the other object to this one.
+func (pc PolicyCounts) Add(other PolicyCounts) {
+	for entityType, counts := range other {
+		if pc[entityType] == nil {
+			pc[entityType] = make(map[event.PolicyRecommendation]int, len(counts))
+		}
+		for rec, count := range counts {
+			pc[entityType][rec] += count
+		}
+	}
+}
+
+// GetAllPolicies returns all policies in the given policy room, or nil if the room isn't in the store.
+func (s *Store) GetAllPolicies(roomID id.RoomID) (output Match) {
+	s.roomsLock.RLock()
+	list, ok := s.rooms[roomID]
+	s.roomsLock.RUnlock()
+	if !ok {
+		return nil
+	}
+	output = append(output, list.GetUserRules().GetAll()...)
+	output = append(output, list.GetRoomRules().GetAll()...)
+	output = append(output, list.GetServerRules().GetAll()...)
+	output = append(output, list.GetContentRules().GetAll()...)
+	output = append(output, list.GetMediaRules().GetAll()...)
+	return
+}
+
+// GetExpired finds all policies in the given policy rooms whose expiry timestamp has passed.
+func (s *Store) GetExpired(listIDs []id.RoomID, now time.Time) (output Match) {
+	for _, roomID := range listIDs {
+		s.roomsLock.RLock()
+		list, ok := s.rooms[roomID]
+		s.roomsLock.RUnlock()
+		if !ok {
+			continue
+		}
+		output = append(output, list.GetUserRules().GetExpired(now)...)
+		output = append(output, list.GetRoomRules().GetExpired(now)...)
+		output = append(output, list.GetServerRules().GetExpired(now)...)
+		output = append(output, list.GetContentRules().GetExpired(now)...)
+		output = append(output, list.GetMediaRules().GetExpired(now)...)
 	}
 	return
 }
@@ -0,0 +1,47 @@
+package policylist
+
+import (
+	"testing"
+
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/meowlnir/util"
+)
+
+func newTestHashedStore(t *testing.T) *Store {
+	return newTestStore(
+		t, false,
+		newTestPolicyEvent(event.StatePolicyRoom, "room", "!evil:example.com", true, nil),
+		newTestPolicyEvent(event.StatePolicyServer, "server", "evil.example", true, nil),
+	)
+}
+
+func TestStore_MatchRoom_Hashed(t *testing.T) {
+	store := newTestHashedStore(t)
+	assertStateKeys(t, "MatchRoom", store.MatchRoom(nil, "!evil:example.com"), []string{"room"})
+	assertStateKeys(t, "MatchRoom", store.MatchRoom([]id.RoomID{testListID}, "!evil:example.com"), []string{"room"})
+	assertStateKeys(t, "MatchRoom", store.MatchRoom(nil, "!good:example.com"), nil)
+	assertStateKeys(t, "MatchRoom", store.MatchRoom([]id.RoomID{"!other:example.com"}, "!evil:example.com"), nil)
+	assertStateKeys(t, "MatchHash", store.MatchHash(nil, EntityTypeRoom, util.SHA256String("!evil:example.com")), []string{"room"})
+	// Room hashes must not leak into other entity types
+	assertStateKeys(t, "MatchServer", store.MatchServer(nil, "!evil:example.com"), nil)
+}
+
+func TestStore_MatchServer_Hashed(t *testing.T) {
+	store := newTestHashedStore(t)
+	assertStateKeys(t, "MatchServer", store.MatchServer(nil, "evil.example"), []string{"server"})
+	assertStateKeys(t, "MatchServer", store.MatchServer(nil, "evil.example:8448"), []string{"server"})
+	assertStateKeys(t, "MatchServer", store.MatchServer(nil, "sub.evil.example"), nil)
+	assertStateKeys(t, "MatchRoom", store.MatchRoom(nil, "evil.example"), nil)
+
+	hashRules := store.ListServerHashRules([]id.RoomID{testListID})
+	if policy, ok := hashRules[util.SHA256String("evil.example")]; !ok || policy.StateKey != "server" {
+		t.Errorf("ListServerHashRules didn't contain the hashed server policy: %v", hashRules)
+	} else if len(hashRules) != 1 {
+		t.Errorf("ListServerHashRules returned %d policies, expected 1", len(hashRules))
+	}
+	if rules := store.ListServerRules([]id.RoomID{testListID}); len(rules) != 0 {
+		t.Errorf("ListServerRules returned %d policies, expected hashed policies to be excluded", len(rules))
+	}
+}
@@ -51,6 +51,14 @@ const getUnredactedEventsBySenderInRoomQuery = `
 	WHERE events.sender = $1 AND events.room_id = ANY($2) AND redactions.redacts IS NULL
 `
 
+const getRecentUnredactedEventsBySenderInRoomQuery = `
+	SELECT events.room_id, events.event_id, events.origin_server_ts
+	FROM events
+	LEFT JOIN redactions ON events.event_id=redactions.redacts
+	WHERE events.sender = $1 AND events.room_id = ANY($2) AND redactions.redacts IS NULL AND events.origin_server_ts >= $3
+	ORDER BY events.origin_server_ts DESC
+`
+
 const getEventQuery = `
 	SELECT events.room_id, sender, type, state_key, origin_server_ts, json
 	FROM events
@@ -82,6 +90,21 @@ func (s *SynapseDB) GetEventsToRedact(ctx context.Context, sender id.UserID, inR
 	return output, time.UnixMilli(maxTSRaw), err
 }
 
+// GetRecentEventsToRedact is like GetEventsToRedact, but only returns events sent at or after minTS,
+// and at most limitPerRoom of the most recent events in each room if limitPerRoom is non-zero.
+func (s *SynapseDB) GetRecentEventsToRedact(ctx context.Context, sender id.UserID, inRooms []id.RoomID, minTS int64, limitPerRoom int) (map[id.RoomID][]id.EventID, error) {
+	output := make(map[id.RoomID][]id.EventID)
+	err := scanRoomEventTuple.NewRowIter(
+		s.DB.Query(ctx, getRecentUnredactedEventsBySenderInRoomQuery, sender, pq.Array(exslices.CastToString[string](inRooms)), minTS),
+	).Iter(func(tuple roomEventTuple) (bool, error) {
+		if limitPerRoom <= 0 || len(output[tuple.RoomID]) < limitPerRoom {
+			output[tuple.RoomID] = append(output[tuple.RoomID], tuple.EventID)
+		}
+		return true, nil
+	})
+	return output, err
+}
+
 func (s *SynapseDB) GetEvent(ctx context.Context, eventID id.EventID) (*event.Event, error) {
 	var evt event.Event
 	evt.ID = eventID
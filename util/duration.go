@@ -0,0 +1,32 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	Day  = 24 * time.Hour
+	Week = 7 * Day
+)
+
+// ParseDuration parses a duration string like [time.ParseDuration],
+// but also accepts a single number followed by `d` (days) or `w` (weeks).
+func ParseDuration(input string) (time.Duration, error) {
+	var unit time.Duration
+	switch {
+	case strings.HasSuffix(input, "d"):
+		unit = Day
+	case strings.HasSuffix(input, "w"):
+		unit = Week
+	default:
+		return time.ParseDuration(input)
+	}
+	count, err := strconv.ParseFloat(input[:len(input)-1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", input)
+	}
+	return time.Duration(count * float64(unit)), nil
+}